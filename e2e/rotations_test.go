@@ -44,12 +44,12 @@ func TestPushToFile(t *testing.T) {
 					"\"password\": \"7H1SiSmYp@5Sw0rd\"\n" +
 					"\"encoded\": \"secret-value\"",
 				"group2.json": "{\"url\":\"postgresql://test-app-backend.app-test.svc.cluster.local:5432\",\"username\":\"some-user\",\"password\":\"7H1SiSmYp@5Sw0rd\",\"still_encoded\":\"c2VjcmV0LXZhbHVl\"}",
-				"some-dotenv.env": "url=\"postgresql://test-app-backend.app-test.svc.cluster.local:5432\"\n" +
-					"username=\"some-user\"\n" +
-					"password=\"7H1SiSmYp@5Sw0rd\"",
-				"group4.bash": "export url=\"postgresql://test-app-backend.app-test.svc.cluster.local:5432\"\n" +
-					"export username=\"some-user\"\n" +
-					"export password=\"7H1SiSmYp@5Sw0rd\"",
+				"some-dotenv.env": "url='postgresql://test-app-backend.app-test.svc.cluster.local:5432'\n" +
+					"username='some-user'\n" +
+					"password='7H1SiSmYp@5Sw0rd'",
+				"group4.bash": "export url='postgresql://test-app-backend.app-test.svc.cluster.local:5432'\n" +
+					"export username='some-user'\n" +
+					"export password='7H1SiSmYp@5Sw0rd'",
 				"group5.template": "username | some-user\n" +
 					"password | 7H1SiSmYp@5Sw0rd\n",
 				"group6.json":     FetchAllJSONContent,
@@ -132,14 +132,14 @@ func TestPushToFileSecretsRotation(t *testing.T) {
 					"\"test\": \"secret2\"\n" +
 					"\"encoded\": \"secret-value2\"",
 				"group2.json": "{\"url\":\"postgresql://test-app-backend.app-test.svc.cluster.local:5432\",\"username\":\"some-user\",\"password\":\"7H1SiSmYp@5Sw0rd\",\"test\":\"secret2\",\"still_encoded\":\"c2VjcmV0LXZhbHVlMg==\"}",
-				"some-dotenv.env": "url=\"postgresql://test-app-backend.app-test.svc.cluster.local:5432\"\n" +
-					"username=\"some-user\"\n" +
-					"password=\"7H1SiSmYp@5Sw0rd\"\n" +
-					"test=\"secret2\"",
-				"group4.bash": "export url=\"postgresql://test-app-backend.app-test.svc.cluster.local:5432\"\n" +
-					"export username=\"some-user\"\n" +
-					"export password=\"7H1SiSmYp@5Sw0rd\"\n" +
-					"export test=\"secret2\"",
+				"some-dotenv.env": "url='postgresql://test-app-backend.app-test.svc.cluster.local:5432'\n" +
+					"username='some-user'\n" +
+					"password='7H1SiSmYp@5Sw0rd'\n" +
+					"test='secret2'",
+				"group4.bash": "export url='postgresql://test-app-backend.app-test.svc.cluster.local:5432'\n" +
+					"export username='some-user'\n" +
+					"export password='7H1SiSmYp@5Sw0rd'\n" +
+					"export test='secret2'",
 				"group5.template": "username | some-user\n" +
 					"password | 7H1SiSmYp@5Sw0rd\n" +
 					"test | secret2\n",
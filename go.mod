@@ -7,6 +7,7 @@ require (
 	github.com/cyberark/conjur-api-go v0.13.17
 	github.com/cyberark/conjur-authn-k8s-client v0.26.10
 	github.com/cyberark/conjur-opentelemetry-tracer v1.55.55
+	github.com/joho/godotenv v1.5.1
 	github.com/stretchr/testify v1.11.1
 	go.opentelemetry.io/otel v1.41.0
 	gopkg.in/yaml.v2 v2.4.0
@@ -4,6 +4,7 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"syscall"
 
 	"github.com/cyberark/conjur-authn-k8s-client/pkg/log"
 	"github.com/cyberark/secrets-provider-for-k8s/pkg/log/messages"
@@ -12,6 +13,7 @@ import (
 // OS Function table
 type osFuncs struct {
 	chmod    func(string, os.FileMode) error
+	chown    func(string, int, int) error
 	rename   func(string, string) error
 	remove   func(string) error
 	sync     func(*os.File) error
@@ -23,6 +25,7 @@ type osFuncs struct {
 // Instantiation of OS Function table using std OS
 var stdOSFuncs = osFuncs{
 	chmod:  os.Chmod,
+	chown:  os.Chown,
 	rename: os.Rename,
 	remove: os.Remove,
 	sync: func(file *os.File) error {
@@ -35,9 +38,27 @@ var stdOSFuncs = osFuncs{
 	},
 }
 
+// FileOwner is the numeric owner of a written file. A negative UID or GID
+// is left unchanged, as with os.Chown.
+type FileOwner struct {
+	UID int
+	GID int
+}
+
+// Owns returns whether the file with info is owned by the owner. Any file is
+// owned by a nil owner.
+func (o *FileOwner) Owns(info os.FileInfo) bool {
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	if o == nil || !ok {
+		return true
+	}
+	return (o.UID < 0 || int(stat.Uid) == o.UID) && (o.GID < 0 || int(stat.Gid) == o.GID)
+}
+
 type atomicWriter struct {
 	path        string
 	permissions os.FileMode
+	owner       *FileOwner
 	tempFile    *os.File
 	os          osFuncs
 }
@@ -49,14 +70,24 @@ type atomicWriter struct {
 // `Write()` doesn't need to be concerned with the destination, just like
 // any other writer.
 func NewAtomicWriter(path string, permissions os.FileMode) io.WriteCloser {
-	return newAtomicWriter(path, permissions, stdOSFuncs)
+	return newAtomicWriter(path, permissions, nil, stdOSFuncs)
 }
 
-func newAtomicWriter(path string, permissions os.FileMode, osFuncs osFuncs) io.WriteCloser {
+// NewAtomicWriterWithOwner provides an atomic file writer like
+// NewAtomicWriter, which also sets the owner of the file before it replaces
+// the destination. Since the file is replaced by a rename, a file that is
+// read-only or owned by another user can still be replaced on the next write.
+// The owner is left unchanged when owner is nil.
+func NewAtomicWriterWithOwner(path string, permissions os.FileMode, owner *FileOwner) io.WriteCloser {
+	return newAtomicWriter(path, permissions, owner, stdOSFuncs)
+}
+
+func newAtomicWriter(path string, permissions os.FileMode, owner *FileOwner, osFuncs osFuncs) io.WriteCloser {
 	return &atomicWriter{
 		path:        path,
 		tempFile:    nil,
 		permissions: permissions,
+		owner:       owner,
 		os:          osFuncs,
 	}
 }
@@ -104,6 +135,16 @@ func (w *atomicWriter) Close() error {
 		// Try to rename the file anyway
 	}
 
+	// Set the file owner. Unlike permissions, a file with the wrong owner
+	// may not be readable at all by its consumer, so it isn't published.
+	if w.owner != nil {
+		err = w.os.chown(w.tempFile.Name(), w.owner.UID, w.owner.GID)
+		if err != nil {
+			log.Error(messages.CSPFK106E, w.tempFile.Name(), w.owner.UID, w.owner.GID, err)
+			return err
+		}
+	}
+
 	// Rename the temporary file to the destination
 	err = w.os.rename(w.tempFile.Name(), w.path)
 	if err != nil {
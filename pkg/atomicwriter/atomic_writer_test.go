@@ -16,6 +16,7 @@ import (
 // made to each OS function during an individual atomic writer test case.
 type osFuncCounts struct {
 	chmodCount    int
+	chownCount    int
 	renameCount   int
 	removeCount   int
 	syncCount     int
@@ -28,6 +29,7 @@ type osFuncCounts struct {
 // OS functions for a given atomic writer test case.
 type injectErrors struct {
 	chmodErr    error
+	chownErr    error
 	renameErr   error
 	removeErr   error
 	syncErr     error
@@ -52,6 +54,13 @@ func newTestOSFuncs(injectErrs injectErrors) (osFuncs, *osFuncCounts) {
 			}
 			return stdOSFuncs.chmod(filename, mode)
 		},
+		chown: func(filename string, uid, gid int) error {
+			counts.chownCount++
+			if injectErrs.chownErr != nil {
+				return injectErrs.chownErr
+			}
+			return stdOSFuncs.chown(filename, uid, gid)
+		},
 		rename: func(oldName, newName string) error {
 			counts.renameCount++
 			if injectErrs.renameErr != nil {
@@ -235,6 +244,7 @@ func TestLogsErrors(t *testing.T) {
 	testCases := []struct {
 		name         string
 		path         string
+		owner        *FileOwner
 		injectErrs   injectErrors
 		errorOnWrite bool
 		assert       errorAssertFunc
@@ -335,6 +345,23 @@ func TestLogsErrors(t *testing.T) {
 				assert.NoFileExists(t, tempFileName)
 			},
 		},
+		{
+			name:       "unable to chown",
+			path:       "test_file.txt",
+			owner:      &FileOwner{UID: 1000, GID: 1000},
+			injectErrs: injectErrors{chownErr: os.ErrPermission},
+			assert: func(t *testing.T, buf *bytes.Buffer, wc io.WriteCloser,
+				tempFileName string, err error) {
+				assert.Error(t, err)
+				assert.Contains(t, buf.String(), "Could not set owner of temporary file")
+
+				// Check that the file wasn't published
+				writer, ok := wc.(*atomicWriter)
+				assert.True(t, ok)
+				assert.NoFileExists(t, writer.path)
+				assert.NoFileExists(t, tempFileName)
+			},
+		},
 		{
 			name:       "unable to sync",
 			path:       "test_file.txt",
@@ -360,6 +387,7 @@ func TestLogsErrors(t *testing.T) {
 
 			// Create a test atomic writer
 			writer, _ := newTestWriter(path, 0644, tc.injectErrs)
+			writer.(*atomicWriter).owner = tc.owner
 
 			// Try to write the file
 			_, err := writer.Write([]byte("test content"))
@@ -382,6 +410,31 @@ func TestLogsErrors(t *testing.T) {
 	}
 }
 
+func TestReadOnlyFileWithOwner(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test_file.txt")
+	owner := &FileOwner{UID: os.Getuid(), GID: os.Getgid()}
+
+	// A read-only file is replaced by the next write
+	for _, content := range []string{"first", "second"} {
+		writer := NewAtomicWriterWithOwner(path, 0400, owner)
+		_, err := writer.Write([]byte(content))
+		assert.NoError(t, err)
+		assert.NoError(t, writer.Close())
+
+		actual, err := os.ReadFile(path)
+		assert.NoError(t, err)
+		assert.Equal(t, content, string(actual))
+	}
+
+	info, err := os.Stat(path)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0400), info.Mode().Perm())
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	assert.True(t, ok)
+	assert.Equal(t, uint32(owner.UID), stat.Uid)
+	assert.Equal(t, uint32(owner.GID), stat.Gid)
+}
+
 func TestDefaultDirectory(t *testing.T) {
 	writer := NewAtomicWriter("test_file.txt", 0644)
 
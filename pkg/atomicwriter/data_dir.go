@@ -0,0 +1,348 @@
+package atomicwriter
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/cyberark/conjur-authn-k8s-client/pkg/log"
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/log/messages"
+)
+
+const (
+	dataDirName       = "..data"
+	newDataDirName    = "..data_tmp"
+	generationPrefix  = ".."
+	generationPattern = "..2006_01_02_15_04_05."
+)
+
+// DataDir publishes a set of files under a base path all at once, using the
+// scheme kubelet uses for projected volumes. Files are written to a timestamped
+// generation directory. The "..data" symlink points to the current generation,
+// and every top-level entry of the base path is a symlink through "..data".
+// Swapping the "..data" symlink switches every file to the new generation in a
+// single rename, so readers never observe a mix of old and new files.
+type DataDir struct {
+	basePath string
+	staging  string
+}
+
+// NewDataDir creates a DataDir that manages the files under basePath
+func NewDataDir(basePath string) *DataDir {
+	return &DataDir{basePath: filepath.Clean(basePath)}
+}
+
+// Stage starts a new generation. The new generation is seeded with a copy of
+// the current generation, so files that aren't rewritten are carried over.
+func (d *DataDir) Stage() error {
+	d.Abort()
+
+	if err := os.MkdirAll(d.basePath, os.ModePerm); err != nil {
+		return fmt.Errorf("unable to create secrets base path %q: %s", d.basePath, err)
+	}
+
+	staging, err := os.MkdirTemp(d.basePath, time.Now().UTC().Format(generationPattern))
+	if err != nil {
+		return fmt.Errorf("unable to create secret files generation in %q: %s", d.basePath, err)
+	}
+	d.staging = staging
+
+	// Readers must be able to traverse the generation, like any other directory
+	if err := os.Chmod(staging, 0755); err != nil {
+		d.Abort()
+		return fmt.Errorf("unable to set permissions of secret files generation %q: %s", staging, err)
+	}
+
+	current, err := d.currentGeneration()
+	if err != nil {
+		d.Abort()
+		return err
+	}
+	if current == "" {
+		return nil
+	}
+
+	if err := copyTree(filepath.Join(d.basePath, current), staging); err != nil {
+		d.Abort()
+		return fmt.Errorf("unable to copy secret files generation %q: %s", current, err)
+	}
+	return nil
+}
+
+// StagedPath maps a path below the base path to its location in the staged
+// generation
+func (d *DataDir) StagedPath(path string) (string, error) {
+	if d.staging == "" {
+		return "", fmt.Errorf("no secret files generation staged in %q", d.basePath)
+	}
+
+	rel, err := filepath.Rel(d.basePath, path)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, "../") {
+		return "", fmt.Errorf("path %q is not below secrets base path %q", path, d.basePath)
+	}
+	if strings.HasPrefix(rel, generationPrefix) {
+		return "", fmt.Errorf("path %q must not start with %q", rel, generationPrefix)
+	}
+
+	return filepath.Join(d.staging, rel), nil
+}
+
+// Restore replaces the staged copy of a path below the base path with its
+// copy in the current generation, or removes it when the current generation
+// doesn't have it, so that files partially written to the staged generation
+// are published as they were
+func (d *DataDir) Restore(path string) error {
+	staged, err := d.StagedPath(path)
+	if err != nil {
+		return err
+	}
+	if err := os.RemoveAll(staged); err != nil {
+		return fmt.Errorf("unable to restore %q: %s", path, err)
+	}
+
+	current, err := d.currentGeneration()
+	if err != nil || current == "" {
+		return err
+	}
+	rel, _ := filepath.Rel(d.staging, staged)
+	src := filepath.Join(d.basePath, current, rel)
+	info, err := os.Lstat(src)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return fmt.Errorf("unable to restore %q: %s", path, err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(staged), os.ModePerm); err != nil {
+		return fmt.Errorf("unable to restore %q: %s", path, err)
+	}
+	switch {
+	case info.IsDir():
+		err = os.Mkdir(staged, info.Mode().Perm())
+		if err == nil {
+			err = copyTree(src, staged)
+		}
+	case info.Mode().IsRegular():
+		err = copyFile(src, staged, info)
+	}
+	if err != nil {
+		return fmt.Errorf("unable to restore %q: %s", path, err)
+	}
+	return nil
+}
+
+// Commit publishes the staged generation by swapping the "..data" symlink,
+// then links the top-level entries of the generation into the base path, and
+// removes previous generations.
+func (d *DataDir) Commit() error {
+	if d.staging == "" {
+		return fmt.Errorf("no secret files generation staged in %q", d.basePath)
+	}
+	generation := filepath.Base(d.staging)
+
+	// Swap the "..data" symlink with a rename, which replaces it atomically
+	newDataDir := filepath.Join(d.basePath, newDataDirName)
+	if err := os.Remove(newDataDir); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := os.Symlink(generation, newDataDir); err != nil {
+		return fmt.Errorf("unable to link secret files generation %q: %s", generation, err)
+	}
+	if err := os.Rename(newDataDir, filepath.Join(d.basePath, dataDirName)); err != nil {
+		_ = os.Remove(newDataDir)
+		return fmt.Errorf("unable to publish secret files generation %q: %s", generation, err)
+	}
+	d.staging = ""
+	log.Info(messages.CSPFK041I, generation)
+
+	entries, err := os.ReadDir(filepath.Join(d.basePath, generation))
+	if err != nil {
+		return err
+	}
+	published := map[string]struct{}{}
+	for _, entry := range entries {
+		published[entry.Name()] = struct{}{}
+		if err := d.linkEntry(entry.Name()); err != nil {
+			return err
+		}
+	}
+
+	return d.removeStale(generation, published)
+}
+
+// Abort removes the staged generation, leaving the current one in place
+func (d *DataDir) Abort() {
+	if d.staging == "" {
+		return
+	}
+	if err := os.RemoveAll(d.staging); err != nil {
+		log.Error(messages.CSPFK095E, d.staging, err)
+	}
+	d.staging = ""
+}
+
+// RemoveAll removes every generation, the "..data" symlink and the top-level
+// symlinks through it
+func (d *DataDir) RemoveAll() error {
+	d.Abort()
+	return d.removeStale("", nil)
+}
+
+func (d *DataDir) currentGeneration() (string, error) {
+	target, err := os.Readlink(filepath.Join(d.basePath, dataDirName))
+	if os.IsNotExist(err) {
+		return "", nil
+	} else if err != nil {
+		return "", fmt.Errorf("unable to read secret files generation link: %s", err)
+	}
+	return target, nil
+}
+
+// linkEntry makes a top-level entry of the base path a symlink through
+// "..data". Entries written before atomic updates were enabled are replaced.
+func (d *DataDir) linkEntry(name string) error {
+	path := filepath.Join(d.basePath, name)
+	target := filepath.Join(dataDirName, name)
+
+	if existing, err := os.Readlink(path); err == nil && existing == target {
+		return nil
+	}
+
+	info, err := os.Lstat(path)
+	if err == nil && info.IsDir() {
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+
+	tmp := filepath.Join(d.basePath, newDataDirName+"_"+name)
+	_ = os.Remove(tmp)
+	if err := os.Symlink(target, tmp); err != nil {
+		return fmt.Errorf("unable to link secret file %q: %s", name, err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		_ = os.Remove(tmp)
+		return fmt.Errorf("unable to link secret file %q: %s", name, err)
+	}
+	return nil
+}
+
+// removeStale removes generations other than the current one, and top-level
+// symlinks through "..data" to entries that are no longer published
+func (d *DataDir) removeStale(current string, published map[string]struct{}) error {
+	entries, err := os.ReadDir(d.basePath)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	for _, entry := range entries {
+		name := entry.Name()
+		path := filepath.Join(d.basePath, name)
+
+		switch {
+		case name == current:
+			continue
+		case name == dataDirName:
+			if current != "" {
+				continue
+			}
+		case strings.HasPrefix(name, generationPrefix):
+			// Previous generations, and leftovers of interrupted swaps
+		case entry.Type()&fs.ModeSymlink != 0:
+			target, err := os.Readlink(path)
+			if err != nil || !strings.HasPrefix(target, dataDirName+"/") {
+				continue
+			}
+			if _, ok := published[name]; ok {
+				continue
+			}
+		default:
+			continue
+		}
+
+		if err := os.RemoveAll(path); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// copyTree copies the regular files and directories under src to dst,
+// keeping their permissions, and the owner of files
+func copyTree(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(src, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		target := filepath.Join(dst, rel)
+
+		info, err := entry.Info()
+		if err != nil {
+			return err
+		}
+		switch {
+		case entry.IsDir():
+			return os.Mkdir(target, info.Mode().Perm())
+		case info.Mode().IsRegular():
+			return copyFile(path, target, info)
+		}
+		return nil
+	})
+}
+
+func copyFile(src, dst string, info fs.FileInfo) error {
+	perm := info.Mode().Perm()
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(out, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Close(); err != nil {
+		return err
+	}
+	// Permissions given to OpenFile are subject to the umask
+	if err := os.Chmod(dst, perm); err != nil {
+		return err
+	}
+	return copyOwner(info, dst)
+}
+
+// copyOwner sets the owner of dst to the owner of a file with info, when
+// they differ, such as when the file was written with
+// NewAtomicWriterWithOwner
+func copyOwner(info fs.FileInfo, dst string) error {
+	srcStat, ok := info.Sys().(*syscall.Stat_t)
+	if !ok {
+		return nil
+	}
+	dstInfo, err := os.Stat(dst)
+	if err != nil {
+		return err
+	}
+	dstStat, ok := dstInfo.Sys().(*syscall.Stat_t)
+	if !ok || (srcStat.Uid == dstStat.Uid && srcStat.Gid == dstStat.Gid) {
+		return nil
+	}
+	return os.Chown(dst, int(srcStat.Uid), int(srcStat.Gid))
+}
@@ -0,0 +1,213 @@
+package atomicwriter
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeStaged(t *testing.T, d *DataDir, path, content string) {
+	staged, err := d.StagedPath(filepath.Join(d.basePath, path))
+	require.NoError(t, err)
+	require.NoError(t, os.MkdirAll(filepath.Dir(staged), 0755))
+	require.NoError(t, os.WriteFile(staged, []byte(content), 0640))
+}
+
+func assertContent(t *testing.T, path, content string) {
+	actual, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, content, string(actual))
+}
+
+func generations(t *testing.T, basePath string) []string {
+	entries, err := os.ReadDir(basePath)
+	require.NoError(t, err)
+
+	var names []string
+	for _, entry := range entries {
+		if entry.IsDir() && strings.HasPrefix(entry.Name(), generationPrefix) {
+			names = append(names, entry.Name())
+		}
+	}
+	return names
+}
+
+func TestDataDir(t *testing.T) {
+	basePath := t.TempDir()
+	d := NewDataDir(basePath)
+
+	// First generation
+	require.NoError(t, d.Stage())
+	writeStaged(t, d, "cert.pem", "cert-1")
+	writeStaged(t, d, "key.pem", "key-1")
+	writeStaged(t, d, "db/password", "password-1")
+
+	// Nothing is visible until the generation is committed
+	assert.NoFileExists(t, filepath.Join(basePath, "cert.pem"))
+	require.NoError(t, d.Commit())
+
+	assertContent(t, filepath.Join(basePath, "cert.pem"), "cert-1")
+	assertContent(t, filepath.Join(basePath, "key.pem"), "key-1")
+	assertContent(t, filepath.Join(basePath, "db/password"), "password-1")
+	target, err := os.Readlink(filepath.Join(basePath, "cert.pem"))
+	assert.NoError(t, err)
+	assert.Equal(t, "..data/cert.pem", target)
+	first := generations(t, basePath)
+	require.Len(t, first, 1)
+
+	// Second generation only rewrites some of the files
+	require.NoError(t, d.Stage())
+	writeStaged(t, d, "cert.pem", "cert-2")
+	writeStaged(t, d, "key.pem", "key-2")
+
+	// Files of the current generation stay in place until commit
+	assertContent(t, filepath.Join(basePath, "cert.pem"), "cert-1")
+	require.NoError(t, d.Commit())
+
+	assertContent(t, filepath.Join(basePath, "cert.pem"), "cert-2")
+	assertContent(t, filepath.Join(basePath, "key.pem"), "key-2")
+	assertContent(t, filepath.Join(basePath, "db/password"), "password-1")
+	second := generations(t, basePath)
+	require.Len(t, second, 1)
+	assert.NotEqual(t, first, second)
+
+	// Copied files keep their permissions
+	info, err := os.Stat(filepath.Join(basePath, "db/password"))
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0640, info.Mode().Perm())
+
+	t.Run("aborted generation is discarded", func(t *testing.T) {
+		require.NoError(t, d.Stage())
+		writeStaged(t, d, "cert.pem", "cert-3")
+		d.Abort()
+
+		assertContent(t, filepath.Join(basePath, "cert.pem"), "cert-2")
+		assert.Equal(t, second, generations(t, basePath))
+	})
+
+	t.Run("entries missing from the generation are unlinked", func(t *testing.T) {
+		require.NoError(t, d.Stage())
+		staged, err := d.StagedPath(filepath.Join(basePath, "key.pem"))
+		require.NoError(t, err)
+		require.NoError(t, os.Remove(staged))
+		require.NoError(t, d.Commit())
+
+		_, err = os.Lstat(filepath.Join(basePath, "key.pem"))
+		assert.True(t, os.IsNotExist(err))
+		assertContent(t, filepath.Join(basePath, "cert.pem"), "cert-2")
+	})
+
+	t.Run("remove all", func(t *testing.T) {
+		unmanaged := filepath.Join(basePath, "unmanaged")
+		require.NoError(t, os.WriteFile(unmanaged, []byte("keep"), 0644))
+
+		require.NoError(t, d.RemoveAll())
+
+		entries, err := os.ReadDir(basePath)
+		require.NoError(t, err)
+		require.Len(t, entries, 1)
+		assert.Equal(t, "unmanaged", entries[0].Name())
+	})
+}
+
+func TestDataDir_ReplacesExistingFiles(t *testing.T) {
+	basePath := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(basePath, "app.yaml"), []byte("old"), 0644))
+	require.NoError(t, os.MkdirAll(filepath.Join(basePath, "db"), 0755))
+	require.NoError(t, os.WriteFile(filepath.Join(basePath, "db", "password"), []byte("old"), 0644))
+
+	d := NewDataDir(basePath)
+	require.NoError(t, d.Stage())
+	writeStaged(t, d, "app.yaml", "new")
+	writeStaged(t, d, "db/password", "new")
+	require.NoError(t, d.Commit())
+
+	assertContent(t, filepath.Join(basePath, "app.yaml"), "new")
+	assertContent(t, filepath.Join(basePath, "db", "password"), "new")
+	info, err := os.Lstat(filepath.Join(basePath, "db"))
+	assert.NoError(t, err)
+	assert.NotZero(t, info.Mode()&os.ModeSymlink)
+}
+
+func TestDataDir_Restore(t *testing.T) {
+	basePath := t.TempDir()
+	d := NewDataDir(basePath)
+	require.NoError(t, d.Stage())
+	writeStaged(t, d, "app.yaml", "v1")
+	writeStaged(t, d, "db/password", "v1")
+	require.NoError(t, d.Commit())
+
+	require.NoError(t, d.Stage())
+	writeStaged(t, d, "app.yaml", "v2")
+	writeStaged(t, d, "db/password", "v2")
+	writeStaged(t, d, "db/user", "v2")
+	writeStaged(t, d, "cache.yaml", "v2")
+
+	// Restored paths are published as they were in the current generation
+	for _, path := range []string{"app.yaml", "db", "cache.yaml"} {
+		require.NoError(t, d.Restore(filepath.Join(basePath, path)))
+	}
+	require.NoError(t, d.Commit())
+
+	assertContent(t, filepath.Join(basePath, "app.yaml"), "v1")
+	assertContent(t, filepath.Join(basePath, "db", "password"), "v1")
+	assert.NoFileExists(t, filepath.Join(basePath, "db", "user"))
+	assert.NoFileExists(t, filepath.Join(basePath, "cache.yaml"))
+}
+
+func TestDataDir_StagedPath(t *testing.T) {
+	d := NewDataDir("/conjur/secrets")
+
+	_, err := d.StagedPath("/conjur/secrets/app.yaml")
+	assert.EqualError(t, err, `no secret files generation staged in "/conjur/secrets"`)
+
+	d.staging = "/conjur/secrets/..2026_01_01_00_00_00.1234"
+	for path, expected := range map[string]string{
+		"/conjur/secrets/app.yaml":    "/conjur/secrets/..2026_01_01_00_00_00.1234/app.yaml",
+		"/conjur/secrets/db/password": "/conjur/secrets/..2026_01_01_00_00_00.1234/db/password",
+	} {
+		staged, err := d.StagedPath(path)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, staged)
+	}
+
+	for _, path := range []string{"/conjur/secrets", "/conjur/other/app.yaml", "/conjur/secrets/..data/app.yaml"} {
+		_, err := d.StagedPath(path)
+		assert.Error(t, err, path)
+	}
+}
+
+func TestDataDir_KeepsFileOwner(t *testing.T) {
+	if os.Geteuid() != 0 {
+		t.Skip("changing the owner of a file requires root")
+	}
+
+	basePath := t.TempDir()
+	d := NewDataDir(basePath)
+	require.NoError(t, d.Stage())
+	staged, err := d.StagedPath(filepath.Join(basePath, "app.yaml"))
+	require.NoError(t, err)
+	writer := NewAtomicWriterWithOwner(staged, 0400, &FileOwner{UID: 1000, GID: 2000})
+	_, err = writer.Write([]byte("secret"))
+	require.NoError(t, err)
+	require.NoError(t, writer.Close())
+	require.NoError(t, d.Commit())
+
+	// The unchanged file is copied to the next generation with its owner
+	require.NoError(t, d.Stage())
+	writeStaged(t, d, "other.yaml", "other")
+	require.NoError(t, d.Commit())
+
+	info, err := os.Stat(filepath.Join(basePath, "app.yaml"))
+	require.NoError(t, err)
+	assert.EqualValues(t, 0400, info.Mode().Perm())
+	stat, ok := info.Sys().(*syscall.Stat_t)
+	require.True(t, ok)
+	assert.EqualValues(t, 1000, stat.Uid)
+	assert.EqualValues(t, 2000, stat.Gid)
+}
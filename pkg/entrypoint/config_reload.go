@@ -0,0 +1,110 @@
+package entrypoint
+
+import (
+	"path/filepath"
+	"time"
+
+	"github.com/cyberark/conjur-authn-k8s-client/pkg/log"
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/log/messages"
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/secrets"
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/annotations"
+	secretsConfigProvider "github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/config"
+)
+
+// Kubernetes updates a volume with several changes in a row, which are
+// handled as a single change
+const configReloadDebounceDelay = 500 * time.Millisecond
+
+// configDirs returns the directories holding the configuration of reloader,
+// which are watched for changes: the directories of the annotations file, of
+// the secret file templates and of the configuration files of reloader.
+// Directories of configuration files added by a reload aren't watched until
+// the Secrets Provider restarts.
+func configDirs(
+	annotationsFilePath string,
+	templatesBasePath string,
+	reloader secrets.ConfigReloader,
+) []string {
+	dirs := []string{filepath.Clean(templatesBasePath)}
+	seen := map[string]struct{}{dirs[0]: {}}
+	for _, path := range append([]string{annotationsFilePath}, reloader.ConfigFiles()...) {
+		dir := filepath.Dir(path)
+		if _, ok := seen[dir]; ok {
+			continue
+		}
+		seen[dir] = struct{}{}
+		dirs = append(dirs, dir)
+	}
+	return dirs
+}
+
+// reloadOnChange reloads the configuration of reloader from the annotations
+// file after changes reported on changes. Kubernetes updates downward API and
+// ConfigMap volumes in place, so secret groups and templates can change while
+// the Secrets Provider is running. A value is sent on the returned channel
+// after each reload that changed the configuration. The returned channel is
+// closed once changes is closed.
+func reloadOnChange(
+	changes <-chan struct{},
+	annotationsFilePath string,
+	reloader secrets.ConfigReloader,
+) <-chan struct{} {
+	reloads := make(chan struct{}, 1)
+
+	go func() {
+		defer close(reloads)
+
+		var debounce <-chan time.Time
+		for {
+			select {
+			case _, ok := <-changes:
+				if !ok {
+					return
+				}
+				debounce = time.After(configReloadDebounceDelay)
+			case <-debounce:
+				debounce = nil
+				if !reloadConfig(annotationsFilePath, reloader) {
+					continue
+				}
+				select {
+				case reloads <- struct{}{}:
+				default:
+				}
+			}
+		}
+	}()
+
+	return reloads
+}
+
+// reloadConfig validates the annotations file and reloads the configuration
+// of reloader with it. The current configuration is kept when validation
+// fails. Returns whether the configuration changed.
+func reloadConfig(annotationsFilePath string, reloader secrets.ConfigReloader) bool {
+	newAnnotations, err := annotations.NewAnnotationsFromFile(annotationsFilePath)
+	if err != nil {
+		log.Error(err.Error())
+		log.Error(messages.CSPFK102E)
+		return false
+	}
+
+	errLogs, infoLogs := secretsConfigProvider.ValidateAnnotations(newAnnotations)
+	if err := logErrorsAndInfos(errLogs, infoLogs); err != nil {
+		log.Error(messages.CSPFK102E)
+		return false
+	}
+
+	changed, errs := reloader.Reload(newAnnotations)
+	if err := logErrorsAndInfos(errs, nil); err != nil {
+		log.Error(messages.CSPFK102E)
+		return false
+	}
+	if !changed {
+		log.Debug(messages.CSPFK017D)
+		return false
+	}
+
+	log.Info(messages.CSPFK043I)
+	return true
+}
@@ -0,0 +1,145 @@
+package entrypoint
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/cyberark/conjur-authn-k8s-client/pkg/log"
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/log/messages"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type fakeReloader struct {
+	annotations []map[string]string
+	changed     bool
+	errs        []error
+	configFiles []string
+}
+
+func (r *fakeReloader) Reload(annotations map[string]string) (bool, []error) {
+	r.annotations = append(r.annotations, annotations)
+	return r.changed, r.errs
+}
+
+func (r *fakeReloader) ConfigFiles() []string {
+	return r.configFiles
+}
+
+func TestConfigDirs(t *testing.T) {
+	reloader := &fakeReloader{configFiles: []string{
+		"/conjur/templates/manifest.yml",
+		"/conjur/templates/app/secrets.yml",
+		"/etc/config/secrets.yml",
+		"/etc/config/other.yml",
+	}}
+	dirs := configDirs("/conjur/podinfo/annotations", "/conjur/templates/", reloader)
+	assert.Equal(t, []string{
+		"/conjur/templates",
+		"/conjur/podinfo",
+		"/conjur/templates/app",
+		"/etc/config",
+	}, dirs)
+}
+
+func TestReloadConfig(t *testing.T) {
+	testCases := []struct {
+		description    string
+		content        string
+		reloader       *fakeReloader
+		expectedReload bool
+		expectedCalls  int
+		errMsg         string
+	}{
+		{
+			description:    "changed secret groups",
+			content:        `conjur.org/conjur-secrets.db="- db/password"`,
+			reloader:       &fakeReloader{changed: true},
+			expectedReload: true,
+			expectedCalls:  1,
+		},
+		{
+			description:   "unchanged secret groups",
+			content:       `conjur.org/conjur-secrets.db="- db/password"`,
+			reloader:      &fakeReloader{},
+			expectedCalls: 1,
+		},
+		{
+			description:   "invalid annotation",
+			content:       `conjur.org/container-mode="bogus"`,
+			reloader:      &fakeReloader{changed: true},
+			expectedCalls: 0,
+			errMsg:        messages.CSPFK102E,
+		},
+		{
+			description:   "invalid secret groups",
+			content:       `conjur.org/conjur-secrets.db="- db/password"`,
+			reloader:      &fakeReloader{changed: true, errs: []error{errors.New("invalid secret group")}},
+			expectedCalls: 1,
+			errMsg:        "invalid secret group",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			log.ErrorLogger.SetOutput(buf)
+			defer log.ErrorLogger.SetOutput(os.Stderr)
+
+			annotationsFilePath := filepath.Join(t.TempDir(), "annotations")
+			require.NoError(t, os.WriteFile(annotationsFilePath, []byte(tc.content), 0644))
+
+			reloaded := reloadConfig(annotationsFilePath, tc.reloader)
+			assert.Equal(t, tc.expectedReload, reloaded)
+			assert.Len(t, tc.reloader.annotations, tc.expectedCalls)
+			if tc.errMsg != "" {
+				assert.Contains(t, buf.String(), tc.errMsg)
+				assert.Contains(t, buf.String(), messages.CSPFK102E)
+			}
+		})
+	}
+
+	t.Run("missing annotations file", func(t *testing.T) {
+		buf := &bytes.Buffer{}
+		log.ErrorLogger.SetOutput(buf)
+		defer log.ErrorLogger.SetOutput(os.Stderr)
+
+		reloader := &fakeReloader{changed: true}
+		assert.False(t, reloadConfig(filepath.Join(t.TempDir(), "annotations"), reloader))
+		assert.Len(t, reloader.annotations, 0)
+		assert.Contains(t, buf.String(), messages.CSPFK102E)
+	})
+}
+
+func TestReloadOnChange(t *testing.T) {
+	annotationsFilePath := filepath.Join(t.TempDir(), "annotations")
+	require.NoError(t, os.WriteFile(annotationsFilePath, []byte(`conjur.org/conjur-secrets.db="- db/password"`), 0644))
+
+	changes := make(chan struct{}, 10)
+	reloader := &fakeReloader{changed: true}
+	reloads := reloadOnChange(changes, annotationsFilePath, reloader)
+
+	// A burst of changes is reloaded once
+	for i := 0; i < 3; i++ {
+		changes <- struct{}{}
+	}
+	select {
+	case <-reloads:
+	case <-time.After(configReloadDebounceDelay + time.Second):
+		t.Fatal("timed out waiting for a reload")
+	}
+	assert.Len(t, reloader.annotations, 1)
+	assert.Equal(t, map[string]string{"conjur.org/conjur-secrets.db": "- db/password"}, reloader.annotations[0])
+
+	close(changes)
+	select {
+	case _, ok := <-reloads:
+		assert.False(t, ok)
+	case <-time.After(time.Second):
+		t.Fatal("reloads was not closed")
+	}
+}
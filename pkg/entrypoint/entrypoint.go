@@ -5,21 +5,26 @@ import (
 	"errors"
 	"fmt"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	authnConfig "github.com/cyberark/conjur-authn-k8s-client/pkg/authenticator/config"
 	"github.com/cyberark/conjur-authn-k8s-client/pkg/log"
 	"github.com/cyberark/conjur-opentelemetry-tracer/pkg/trace"
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/filewatcher"
 	"github.com/cyberark/secrets-provider-for-k8s/pkg/log/messages"
 	"github.com/cyberark/secrets-provider-for-k8s/pkg/secrets"
 	"github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/annotations"
 	"github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/clients/conjur"
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/clients/k8s"
 	"github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/config"
 	secretsConfigProvider "github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/config"
 	k8sinformer "github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/k8s_informer"
 	k8sSecretsStorage "github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/k8s_secrets_storage"
 	"github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/pushtofile"
 	"github.com/cyberark/secrets-provider-for-k8s/pkg/server"
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/utils"
 	"go.opentelemetry.io/otel/attribute"
 )
 
@@ -75,13 +80,24 @@ func startSecretsProviderWithDeps(
 	providerFactory secrets.ProviderFactory,
 	statusUpdaterFactory secrets.StatusUpdaterFactory,
 ) (exitCode int) {
-	exitCode = 0
+	exitCode = exitCodeSuccess
 	authnConfig.SetLogLevel(customEnv)
 
-	logError := func(errStr string) {
-		log.Error(errStr)
-		exitCode = 1
+	// The exit code is derived from the class of the first failure, and is
+	// recorded in the status directory once provided secrets are wiped
+	status := statusUpdaterFactory()
+	fail := func(err error) {
+		log.Error(err.Error())
+		if exitCode == exitCodeSuccess {
+			exitCode = exitCodeForError(err)
+			log.Info(messages.CSPFK050I, exitCode, utils.FailureClassOf(err))
+		}
 	}
+	defer func() {
+		if err := status.SetExitCode(exitCode); err != nil {
+			log.Warn(messages.CSPFK121E, exitCode, err)
+		}
+	}()
 
 	log.Info(messages.CSPFK008I, secrets.FullVersionName)
 
@@ -90,20 +106,20 @@ func startSecretsProviderWithDeps(
 	ctx, tracer, deferFunc, err := createTracer(tracerType, tracerURL)
 	defer deferFunc(ctx)
 	if err != nil {
-		logError(err.Error())
+		fail(err)
 		return
 	}
 
 	// Process Pod Annotations
 	if err := processAnnotations(ctx, tracer, annotationsFilePath); err != nil {
-		logError(err.Error())
+		fail(utils.Classify(utils.FailureConfig, err))
 		return
 	}
 
 	// Retrieves secrets using provided access token (after auth)
 	secretRetriever, err := secretRetriever(ctx, tracer, retrieverFactory, authenticatorFactory)
 	if err != nil {
-		logError(err.Error())
+		fail(utils.Classify(utils.FailureConfig, err))
 		return
 	}
 
@@ -116,7 +132,7 @@ func startSecretsProviderWithDeps(
 		providerFactory,
 	)
 	if err != nil {
-		logError(err.Error())
+		fail(utils.Classify(utils.FailureConfig, err))
 		return
 	}
 
@@ -129,19 +145,26 @@ func startSecretsProviderWithDeps(
 		var err error
 		httpServer, err = server.NewServer(secretsConfig.ServerAddress)
 		if err != nil {
-			log.Error(messages.CSPFK094E, err)
+			fail(utils.Classify(utils.FailureConfig, fmt.Errorf(messages.CSPFK094E, err)))
 			return
 		}
-
 		httpServer.Start()
 	}
-	defer func() {
-		if httpServer != nil {
-			shutdownCtx, cancel := context.WithTimeout(ctx, time.Second)
-			defer cancel()
-			_ = httpServer.Shutdown(shutdownCtx)
+	defer shutdownServer(ctx, httpServer)
+
+	// Push to File secret groups can be rolled back through the admin
+	// endpoints in sidecar and standalone modes. They're served on a server
+	// of their own, which listens on the loopback interface by default.
+	groupAdmin, _ := secrets.P2FProviderInstance.(server.SecretGroupAdmin)
+	if !runOnce && secretsConfig.AdminEndpointsEnabled && groupAdmin != nil {
+		adminServer, err := server.NewAdminServer(secretsConfig.AdminServerAddress, groupAdmin)
+		if err != nil {
+			fail(utils.Classify(utils.FailureConfig, fmt.Errorf(messages.CSPFK094E, err)))
+			return
 		}
-	}()
+		adminServer.Start()
+		defer shutdownServer(ctx, adminServer)
+	}
 
 	// Create channel for informer events only when using labeled secrets mode
 	var informerEventsChan chan k8sinformer.SecretEvent
@@ -155,7 +178,7 @@ func startSecretsProviderWithDeps(
 		// Create channel for informer events
 		informerEventsChan = make(chan k8sinformer.SecretEvent, 10)
 
-		k8sClientset, err := k8sinformer.NewInClusterK8sClient()
+		k8sClientset, err := k8s.Client()
 		if err != nil {
 			log.Warn(messages.CSPFK071E, err)
 		} else {
@@ -178,30 +201,168 @@ func startSecretsProviderWithDeps(
 		}
 	}()
 
+	// In sidecar and standalone modes, Push to File secret groups can be
+	// reloaded when the Pod annotations, the secret file templates, the
+	// secret groups manifest or the Summon secrets.yml files change
+	var configReloads <-chan struct{}
+	configReload := secretsConfig.ConfigReloadEnabled
+	if configReload && runOnce {
+		log.Warn(messages.CSPFK112E, secretsConfigProvider.ConfigReloadEnabledKey)
+		configReload = false
+	}
+	if configReload && secretsConfig.StoreType == config.File && secrets.P2FProviderInstance != nil {
+		if _, err := os.Stat(annotationsFilePath); err == nil {
+			dirs := configDirs(annotationsFilePath, templatesBasePath, secrets.P2FProviderInstance)
+			watcher, err := filewatcher.New(dirs...)
+			if err != nil {
+				log.Warn(messages.CSPFK103E, err)
+			} else {
+				defer watcher.Close()
+				configReloads = reloadOnChange(watcher.Events, annotationsFilePath, secrets.P2FProviderInstance)
+			}
+		}
+	}
+
+	// In sidecar and standalone modes, SIGINT and SIGTERM stop providing
+	// secrets, so that the Secrets Provider shuts down gracefully: the provided
+	// secrets can be wiped and the servers and the tracer stopped before the
+	// container stops.
+	providerCtx := ctx
+	if !runOnce {
+		var stop context.CancelFunc
+		providerCtx, stop = cancelOnSignal(ctx)
+		defer stop()
+	}
+	wipeOnExit := wipeOnExitEnabled(secretsConfig, containerMode)
+
+	// In sidecar and standalone modes, secrets are refreshed when they're due,
+	// on their own refresh interval or on the global one
+	var scheduler secrets.RefreshScheduler
+	if !runOnce {
+		scheduler = refreshScheduler(secretsConfig.StoreType)
+	}
+
 	if err = secrets.RunSecretsProvider(
+		providerCtx,
 		secrets.ProviderRefreshConfig{
 			Mode:                  containerMode,
 			RunOnce:               runOnce,
 			SecretRefreshInterval: secretsConfig.SecretsRefreshInterval,
-			// Create a channel to send a quit signal to the periodic secret provider.
-			// TODO: Currently, this is just used for testing, but in the future we
-			// may want to create a SIGTERM or SIGHUP handler to catch a signal from
-			// a user / external entity, and then send an (empty struct) quit signal
-			// on this channel to trigger a graceful shut down of the Secrets Provider.
-			ProviderQuit:   make(chan struct{}),
-			InformerEvents: informerEventsChan,
-			RetryInterval:  time.Duration(secretsConfig.RetryIntervalSec) * time.Second,
-			RetryCountLimit: secretsConfig.RetryCountLimit,
+			InformerEvents:        informerEventsChan,
+			RetryInterval:         time.Duration(secretsConfig.RetryIntervalSec) * time.Second,
+			RetryCountLimit:       secretsConfig.RetryCountLimit,
+			RetryMaxInterval:      time.Duration(secretsConfig.RetryMaxIntervalSec) * time.Second,
+			ProvideTimeout:        secretsConfig.ProvideTimeout,
+			ConfigReloads:         configReloads,
+			Scheduler:             scheduler,
 		},
 		provideSecrets,
-		statusUpdaterFactory(),
+		status,
 		httpServer,
 	); err != nil {
-		logError(err.Error())
+		fail(err)
+	}
+
+	if wipeOnExit {
+		if err := wipeSecrets(ctx, secretsConfig.StoreType, status); err != nil {
+			fail(utils.Classify(utils.FailureWrite, fmt.Errorf(messages.CSPFK111E, err)))
+		}
 	}
 	return
 }
 
+// cancelOnSignal returns a copy of ctx that's cancelled when the Secrets
+// Provider receives SIGINT or SIGTERM, until stop is called
+func cancelOnSignal(ctx context.Context) (context.Context, context.CancelFunc) {
+	return signal.NotifyContext(ctx, syscall.SIGINT, syscall.SIGTERM)
+}
+
+// shutdownServer shuts down httpServer, if any, waiting up to a second for
+// the requests being served
+func shutdownServer(ctx context.Context, httpServer *server.Server) {
+	if httpServer == nil {
+		return
+	}
+	shutdownCtx, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	_ = httpServer.Shutdown(shutdownCtx)
+}
+
+// refreshScheduler returns the refresh scheduler of the provider for the
+// store type, or nil when the provider doesn't schedule its refreshes
+func refreshScheduler(storeType string) secrets.RefreshScheduler {
+	switch storeType {
+	case config.File:
+		scheduler, _ := secrets.P2FProviderInstance.(secrets.RefreshScheduler)
+		return scheduler
+	case config.K8s:
+		if secrets.K8sProviderInstance != nil {
+			return secrets.K8sProviderInstance
+		}
+	}
+	return nil
+}
+
+// wipeOnExitEnabled returns whether the provided secrets are wiped when the
+// Secrets Provider exits. Secret files are wiped with WipeOnExitKey, while K8s
+// Secrets need WipeK8sSecretsOnExitKey: they may be shared by the replicas of
+// a Deployment, so wiping them when one replica exits blanks them for the
+// others, and they aren't wiped at all in standalone mode.
+func wipeOnExitEnabled(secretsConfig *secretsConfigProvider.Config, containerMode string) bool {
+	key, enabled := secretsConfigProvider.WipeOnExitKey, secretsConfig.WipeOnExit
+	if secretsConfig.StoreType == config.K8s {
+		if enabled {
+			log.Warn(messages.CSPFK122E, key, secretsConfigProvider.WipeK8sSecretsOnExitKey)
+		}
+		key, enabled = secretsConfigProvider.WipeK8sSecretsOnExitKey, secretsConfig.WipeK8sSecretsOnExit
+	}
+	if !enabled {
+		return false
+	}
+
+	switch {
+	case containerMode != "sidecar" && containerMode != "standalone":
+		log.Warn(messages.CSPFK112E, key)
+		return false
+	case containerMode == "standalone" && secretsConfig.StoreType == config.K8s:
+		log.Warn(messages.CSPFK123E, key)
+		return false
+	}
+	return true
+}
+
+// wipeSecrets wipes the secrets provided for the store type, once the
+// secrets being provided are written, and removes the status files
+func wipeSecrets(ctx context.Context, storeType string, status secrets.StatusUpdater) error {
+	log.Info(messages.CSPFK048I)
+
+	var wiper secrets.SecretsWiper
+	switch storeType {
+	case config.File:
+		wiper, _ = secrets.P2FProviderInstance.(secrets.SecretsWiper)
+	case config.K8s:
+		if secrets.K8sProviderInstance != nil {
+			wiper = secrets.K8sProviderInstance
+		}
+	}
+
+	var errs []error
+	if wiper != nil {
+		if err := wiper.Wipe(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if err := status.RemoveStatus(); err != nil {
+		errs = append(errs, err)
+	}
+	if err := errors.Join(errs...); err != nil {
+		return err
+	}
+
+	log.Info(messages.CSPFK049I)
+	return nil
+}
+
 func processAnnotations(ctx context.Context, tracer trace.Tracer, annotationsFilePath string) error {
 	// Only attempt to populate from annotations if the annotations file exists
 	// TODO: Figure out strategy for dealing with explicit annotation file path
@@ -268,10 +429,22 @@ func secretsProvider(
 		span.RecordErrorAndSetStatus(err)
 		return nil, nil, err
 	}
+
+	// The Kubernetes client shared by the providers and the K8s Secret
+	// informer is created on its first use
+	k8s.ConfigureClient(k8s.ClientConfig{
+		QPS:   float32(secretsConfig.K8sAPIQPS),
+		Burst: secretsConfig.K8sAPIBurst,
+	})
+
 	providerConfig := &secrets.ProviderConfig{
 		CommonProviderConfig: secrets.CommonProviderConfig{
 			StoreType:       secretsConfig.StoreType,
 			SanitizeEnabled: secretsConfig.SanitizeEnabled,
+			RefreshInterval: secretsConfig.SecretsRefreshInterval,
+			RefreshCron:     secretsConfig.SecretsRefreshSchedule,
+			RefreshJitter:   secretsConfig.SecretsRefreshJitter,
+			RequestTimeout:  secretsConfig.RequestTimeout,
 		},
 		K8sProviderConfig: k8sSecretsStorage.K8sProviderConfig{
 			PodNamespace:       secretsConfig.PodNamespace,
@@ -288,8 +461,7 @@ func secretsProvider(
 	span.SetAttributes(attribute.String("store_type", secretsConfig.StoreType))
 
 	// Create a secrets provider
-	provideSecrets, errs := providerFactory(ctx,
-		secretRetriever, *providerConfig)
+	provideSecrets, errs := providerFactory(secretRetriever, *providerConfig)
 	if err := logErrorsAndInfos(errs, nil); err != nil {
 		log.Error(messages.CSPFK053E)
 		span.RecordErrorAndSetStatus(errors.New(messages.CSPFK053E))
@@ -7,11 +7,15 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"syscall"
 	"testing"
+	"time"
 
 	"github.com/cyberark/conjur-authn-k8s-client/pkg/log"
 	"github.com/cyberark/secrets-provider-for-k8s/pkg/secrets"
 	"github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/clients/conjur"
+	secretsConfigProvider "github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/config"
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/utils"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -34,7 +38,7 @@ type mockProviderFactory struct {
 	errs         []error
 }
 
-func (p mockProviderFactory) GetProvider(traceContext context.Context, secretsRetrieverFunc conjur.RetrieveSecretsFunc, providerConfig secrets.ProviderConfig) (secrets.ProviderFunc, []error) {
+func (p mockProviderFactory) GetProvider(secretsRetrieverFunc conjur.RetrieveSecretsFunc, providerConfig secrets.ProviderConfig) (secrets.ProviderFunc, []error) {
 	return p.providerFunc, p.errs
 }
 
@@ -52,10 +56,22 @@ func (s mockStatusUpdater) SetSecretsUpdated() error {
 	return nil
 }
 
+func (s mockStatusUpdater) SetSecretsDegraded(groups []string) error {
+	return nil
+}
+
+func (s mockStatusUpdater) SetExitCode(code int) error {
+	return nil
+}
+
 func (s mockStatusUpdater) CopyScripts() error {
 	return nil
 }
 
+func (s mockStatusUpdater) RemoveStatus() error {
+	return nil
+}
+
 type editableMap map[string]string
 
 func (m editableMap) Delete(key string) editableMap {
@@ -128,13 +144,13 @@ func TestStartSecretsProvider(t *testing.T) {
 				}.Retrieve,
 			},
 			providerFactory: mockProviderFactory{
-				providerFunc: func() (bool, error) {
+				providerFunc: func(context.Context) (bool, error) {
 					return true, nil
 				},
 				errs: []error{},
 			},
 			assertions: func(t *testing.T, code int, logs string) {
-				assert.Equal(t, 0, code)
+				assert.Equal(t, exitCodeSuccess, code)
 			},
 		},
 		{
@@ -150,13 +166,13 @@ func TestStartSecretsProvider(t *testing.T) {
 				}.Retrieve,
 			},
 			providerFactory: mockProviderFactory{
-				providerFunc: func() (bool, error) {
+				providerFunc: func(context.Context) (bool, error) {
 					return true, nil
 				},
 				errs: []error{errors.New("provider factory failure")},
 			},
 			assertions: func(t *testing.T, code int, logs string) {
-				assert.Equal(t, 1, code)
+				assert.Equal(t, exitCodeConfig, code)
 				assert.Contains(t, logs, "CSPFK053E")
 				assert.Contains(t, logs, "provider factory failure")
 			},
@@ -173,13 +189,13 @@ func TestStartSecretsProvider(t *testing.T) {
 				err: errors.New("retriever factory failure"),
 			},
 			providerFactory: mockProviderFactory{
-				providerFunc: func() (bool, error) {
+				providerFunc: func(context.Context) (bool, error) {
 					return true, nil
 				},
 				errs: []error{},
 			},
 			assertions: func(t *testing.T, code int, logs string) {
-				assert.Equal(t, 1, code)
+				assert.Equal(t, exitCodeConfig, code)
 				assert.Contains(t, logs, "retriever factory failure")
 			},
 		},
@@ -195,13 +211,13 @@ func TestStartSecretsProvider(t *testing.T) {
 				err: nil,
 			},
 			providerFactory: mockProviderFactory{
-				providerFunc: func() (bool, error) {
+				providerFunc: func(context.Context) (bool, error) {
 					return true, nil
 				},
 				errs: []error{},
 			},
 			assertions: func(t *testing.T, code int, logs string) {
-				assert.Equal(t, 1, code)
+				assert.Equal(t, exitCodeConfig, code)
 				assert.Contains(t, logs, "CSPFK049E")
 			},
 		},
@@ -217,14 +233,14 @@ func TestStartSecretsProvider(t *testing.T) {
 				err: nil,
 			},
 			providerFactory: mockProviderFactory{
-				providerFunc: func() (bool, error) {
+				providerFunc: func(context.Context) (bool, error) {
 					return true, nil
 				},
 				errs: []error{},
 			},
 			authenticatorError: errors.New("authenticator factory failure"),
 			assertions: func(t *testing.T, code int, logs string) {
-				assert.Equal(t, 1, code)
+				assert.Equal(t, exitCodeConfig, code)
 				assert.Contains(t, logs, "authenticator factory failure")
 			},
 		},
@@ -240,16 +256,38 @@ func TestStartSecretsProvider(t *testing.T) {
 				err: nil,
 			},
 			providerFactory: mockProviderFactory{
-				providerFunc: func() (bool, error) {
+				providerFunc: func(context.Context) (bool, error) {
 					return true, nil
 				},
 				errs: []error{},
 			},
 			assertions: func(t *testing.T, code int, logs string) {
-				assert.Equal(t, 1, code)
+				assert.Equal(t, exitCodeConfig, code)
 				assert.Contains(t, logs, "CSPFK015E")
 			},
 		},
+		{
+			description: "provider failure exits with the code of its class",
+			environment: env,
+			annotations: annots,
+			retrieverFactory: mockRetrieverFactory{
+				retriever: mockRetriever{
+					data: nil,
+					err:  nil,
+				}.Retrieve,
+				err: nil,
+			},
+			providerFactory: mockProviderFactory{
+				providerFunc: func(context.Context) (bool, error) {
+					return false, utils.Permanent(utils.Classify(utils.FailureAuthz, errors.New("403 Forbidden")))
+				},
+				errs: []error{},
+			},
+			assertions: func(t *testing.T, code int, logs string) {
+				assert.Equal(t, exitCodeAuthz, code)
+				assert.Contains(t, logs, "403 Forbidden")
+			},
+		},
 	}
 
 	for _, tc := range TestCases {
@@ -459,3 +497,88 @@ func TestGetContainerMode(t *testing.T) {
 		})
 	}
 }
+
+func TestCancelOnSignal(t *testing.T) {
+	ctx, stop := cancelOnSignal(t.Context())
+	defer stop()
+
+	assert.NoError(t, syscall.Kill(os.Getpid(), syscall.SIGTERM))
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		assert.Fail(t, "ctx wasn't cancelled on SIGTERM")
+	}
+}
+
+func TestWipeOnExitEnabled(t *testing.T) {
+	testCases := []struct {
+		description   string
+		config        secretsConfigProvider.Config
+		containerMode string
+		expected      bool
+		warning       string
+	}{
+		{
+			description:   "secret files in sidecar mode",
+			config:        secretsConfigProvider.Config{StoreType: secretsConfigProvider.File, WipeOnExit: true},
+			containerMode: "sidecar",
+			expected:      true,
+		},
+		{
+			description:   "secret files in standalone mode",
+			config:        secretsConfigProvider.Config{StoreType: secretsConfigProvider.File, WipeOnExit: true},
+			containerMode: "standalone",
+			expected:      true,
+		},
+		{
+			description:   "secret files in init mode",
+			config:        secretsConfigProvider.Config{StoreType: secretsConfigProvider.File, WipeOnExit: true},
+			containerMode: "init",
+			warning:       "CSPFK112E",
+		},
+		{
+			description:   "K8s Secrets in sidecar mode",
+			config:        secretsConfigProvider.Config{StoreType: secretsConfigProvider.K8s, WipeK8sSecretsOnExit: true},
+			containerMode: "sidecar",
+			expected:      true,
+		},
+		{
+			description:   "K8s Secrets aren't wiped by the secret files annotation",
+			config:        secretsConfigProvider.Config{StoreType: secretsConfigProvider.K8s, WipeOnExit: true},
+			containerMode: "sidecar",
+			warning:       "CSPFK122E",
+		},
+		{
+			description:   "K8s Secrets in standalone mode",
+			config:        secretsConfigProvider.Config{StoreType: secretsConfigProvider.K8s, WipeK8sSecretsOnExit: true},
+			containerMode: "standalone",
+			warning:       "CSPFK123E",
+		},
+		{
+			description:   "K8s Secrets in application mode",
+			config:        secretsConfigProvider.Config{StoreType: secretsConfigProvider.K8s, WipeK8sSecretsOnExit: true},
+			containerMode: "application",
+			warning:       "CSPFK112E",
+		},
+		{
+			description:   "not enabled",
+			config:        secretsConfigProvider.Config{StoreType: secretsConfigProvider.File},
+			containerMode: "sidecar",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			log.WarnLogger.SetOutput(buf)
+			defer log.WarnLogger.SetOutput(os.Stdout)
+
+			assert.Equal(t, tc.expected, wipeOnExitEnabled(&tc.config, tc.containerMode))
+			if tc.warning != "" {
+				assert.Contains(t, buf.String(), tc.warning)
+			} else {
+				assert.Empty(t, buf.String())
+			}
+		})
+	}
+}
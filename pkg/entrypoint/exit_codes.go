@@ -0,0 +1,40 @@
+package entrypoint
+
+import (
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/utils"
+)
+
+// Exit codes of the Secrets Provider, by the class of the failure that made
+// it exit. They're documented in the README, and are recorded in the status
+// directory.
+const (
+	exitCodeSuccess      = 0
+	exitCodeFailure      = 1
+	exitCodeConfig       = 2
+	exitCodeAuthn        = 3
+	exitCodeAuthz        = 4
+	exitCodeNotFound     = 5
+	exitCodeTransient    = 6
+	exitCodeWriteFailure = 7
+)
+
+var exitCodesByFailureClass = map[utils.FailureClass]int{
+	utils.FailureConfig:    exitCodeConfig,
+	utils.FailureAuthn:     exitCodeAuthn,
+	utils.FailureAuthz:     exitCodeAuthz,
+	utils.FailureNotFound:  exitCodeNotFound,
+	utils.FailureTransient: exitCodeTransient,
+	utils.FailureWrite:     exitCodeWriteFailure,
+}
+
+// exitCodeForError returns the exit code for the class of the failure that
+// err caused. Errors that aren't classified exit with exitCodeFailure.
+func exitCodeForError(err error) int {
+	if err == nil {
+		return exitCodeSuccess
+	}
+	if code, ok := exitCodesByFailureClass[utils.FailureClassOf(err)]; ok {
+		return code
+	}
+	return exitCodeFailure
+}
@@ -0,0 +1,37 @@
+package entrypoint
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/utils"
+)
+
+func TestExitCodeForError(t *testing.T) {
+	testCases := []struct {
+		description string
+		err         error
+		expected    int
+	}{
+		{"success", nil, exitCodeSuccess},
+		{"unclassified", errors.New("failed"), exitCodeFailure},
+		{"config", utils.Classify(utils.FailureConfig, errors.New("CSPFK049E")), exitCodeConfig},
+		{"authentication", utils.Classify(utils.FailureAuthn, errors.New("CSPFK010E")), exitCodeAuthn},
+		{"authorization", utils.Permanent(utils.Classify(utils.FailureAuthz, errors.New("403"))), exitCodeAuthz},
+		{"not found", fmt.Errorf("group: %w", utils.Classify(utils.FailureNotFound, errors.New("404"))), exitCodeNotFound},
+		{"transient", utils.Transient(errors.New("connection refused")), exitCodeTransient},
+		{"deadline exceeded", context.DeadlineExceeded, exitCodeTransient},
+		{"write", utils.Classify(utils.FailureWrite, os.ErrPermission), exitCodeWriteFailure},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			assert.Equal(t, tc.expected, exitCodeForError(tc.err))
+		})
+	}
+}
@@ -0,0 +1,23 @@
+package filewatcher
+
+import "os"
+
+// Watcher reports changes to the entries of a set of directories. Kubernetes
+// updates ConfigMap and downward API volumes by swapping a "..data" symlink in
+// the volume directory, which replaces the files of the volume instead of
+// writing to them, so directories are watched rather than files.
+type Watcher struct {
+	// Events receives a value after entries of the watched directories
+	// change. Changes that happen before the value is received are
+	// coalesced. The channel is closed once the Watcher is closed.
+	Events <-chan struct{}
+	file   *os.File
+}
+
+// Close stops watching the directories
+func (w *Watcher) Close() error {
+	if w.file == nil {
+		return nil
+	}
+	return w.file.Close()
+}
@@ -0,0 +1,67 @@
+//go:build linux
+
+package filewatcher
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+)
+
+const watchMask = syscall.IN_CREATE | syscall.IN_DELETE | syscall.IN_MOVED_FROM |
+	syscall.IN_MOVED_TO | syscall.IN_CLOSE_WRITE | syscall.IN_ATTRIB
+
+// New watches dirs for changes with inotify. Directories that don't exist are
+// skipped, but at least one of them must exist.
+func New(dirs ...string) (*Watcher, error) {
+	// A non-blocking descriptor is read through the runtime poller, so that
+	// closing the file interrupts a pending read
+	fd, err := syscall.InotifyInit1(syscall.IN_CLOEXEC | syscall.IN_NONBLOCK)
+	if err != nil {
+		return nil, fmt.Errorf("unable to initialize inotify: %s", err)
+	}
+
+	watched := 0
+	for _, dir := range dirs {
+		_, err := syscall.InotifyAddWatch(fd, dir, watchMask)
+		if err == syscall.ENOENT {
+			continue
+		} else if err != nil {
+			_ = syscall.Close(fd)
+			return nil, fmt.Errorf("unable to watch %q: %s", dir, err)
+		}
+		watched++
+	}
+	if watched == 0 {
+		_ = syscall.Close(fd)
+		return nil, fmt.Errorf("none of the directories %q exist", dirs)
+	}
+
+	events := make(chan struct{}, 1)
+	w := &Watcher{
+		Events: events,
+		file:   os.NewFile(uintptr(fd), "inotify"),
+	}
+	go w.read(events)
+	return w, nil
+}
+
+func (w *Watcher) read(events chan<- struct{}) {
+	defer close(events)
+
+	buf := make([]byte, 64*(syscall.SizeofInotifyEvent+syscall.NAME_MAX+1))
+	for {
+		n, err := w.file.Read(buf)
+		if err != nil {
+			return
+		}
+		if n < syscall.SizeofInotifyEvent {
+			continue
+		}
+
+		select {
+		case events <- struct{}{}:
+		default:
+		}
+	}
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package filewatcher
+
+import "fmt"
+
+// New watches dirs for changes, which is only supported on Linux
+func New(dirs ...string) (*Watcher, error) {
+	return nil, fmt.Errorf("watching %q for changes is only supported on Linux", dirs)
+}
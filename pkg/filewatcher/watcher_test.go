@@ -0,0 +1,50 @@
+//go:build linux
+
+package filewatcher
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func waitForEvent(t *testing.T, w *Watcher) bool {
+	select {
+	case _, ok := <-w.Events:
+		return ok
+	case <-time.After(time.Second):
+		t.Fatal("timed out waiting for a watcher event")
+		return false
+	}
+}
+
+func TestWatcher(t *testing.T) {
+	dir := t.TempDir()
+	w, err := New(dir, filepath.Join(dir, "missing"))
+	require.NoError(t, err)
+
+	t.Run("file written", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "annotations"), []byte("a"), 0644))
+		assert.True(t, waitForEvent(t, w))
+	})
+
+	t.Run("symlink swapped", func(t *testing.T) {
+		require.NoError(t, os.Symlink("..2024_01_01", filepath.Join(dir, "..data_tmp")))
+		require.NoError(t, os.Rename(filepath.Join(dir, "..data_tmp"), filepath.Join(dir, "..data")))
+		assert.True(t, waitForEvent(t, w))
+	})
+
+	t.Run("closed", func(t *testing.T) {
+		require.NoError(t, w.Close())
+		assert.False(t, waitForEvent(t, w))
+	})
+}
+
+func TestNew_NoDirectories(t *testing.T) {
+	_, err := New(filepath.Join(t.TempDir(), "missing"))
+	assert.ErrorContains(t, err, "none of the directories")
+}
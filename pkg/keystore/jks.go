@@ -0,0 +1,194 @@
+package keystore
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"errors"
+	"math"
+	"time"
+	"unicode/utf16"
+)
+
+// JKS is the legacy proprietary Java keystore format. Its layout is
+// documented by the OpenJDK sun.security.provider.JavaKeyStore sources.
+const (
+	jksMagic            uint32 = 0xfeedfeed
+	jksVersion          uint32 = 2
+	jksPrivateKeyTag    uint32 = 1
+	jksTrustedCertTag   uint32 = 2
+	jksCertType                = "X.509"
+	jksKeyProtectorSalt        = sha1.Size
+	// jksIntegritySalt is mixed into the keystore integrity digest
+	jksIntegritySalt = "Mighty Aphrodite"
+)
+
+// oidJavaKeyProtector identifies the proprietary JKS private key protection
+// algorithm
+var oidJavaKeyProtector = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 42, 2, 17, 1, 1}
+
+// EncodeJKS encodes key and certificate material into a password protected
+// JKS file. When the material holds a private key, it is written as a key
+// entry together with its certificate chain, and the file is a keystore.
+// Without a private key, every certificate is written as a trusted
+// certificate entry, and the file is a truststore.
+func EncodeJKS(material Material, password string) ([]byte, error) {
+	if len(password) == 0 {
+		return nil, errors.New("JKS keystore requires a non-empty password")
+	}
+	if material.PrivateKey != nil && material.Certificate == nil {
+		return nil, errors.New("JKS keystore requires a certificate for the private key")
+	}
+
+	now := time.Now()
+	w := &jksWriter{}
+	w.uint32(jksMagic)
+	w.uint32(jksVersion)
+
+	if material.PrivateKey != nil {
+		protectedKey, err := protectJKSKey(material, password)
+		if err != nil {
+			return nil, err
+		}
+
+		chain := append([]*x509.Certificate{material.Certificate}, material.CACerts...)
+		w.uint32(1)
+		w.uint32(jksPrivateKeyTag)
+		w.utf(material.Alias)
+		w.timestamp(now)
+		w.bytes(protectedKey)
+		w.uint32(uint32(len(chain)))
+		for _, cert := range chain {
+			w.certificate(cert)
+		}
+	} else {
+		var trusted []*x509.Certificate
+		if material.Certificate != nil {
+			trusted = append(trusted, material.Certificate)
+		}
+		trusted = append(trusted, material.CACerts...)
+		if len(trusted) == 0 {
+			return nil, errors.New("JKS truststore requires at least one certificate")
+		}
+
+		w.uint32(uint32(len(trusted)))
+		for i, cert := range trusted {
+			w.uint32(jksTrustedCertTag)
+			w.utf(material.trustedCertAlias(i))
+			w.timestamp(now)
+			w.certificate(cert)
+		}
+	}
+
+	if w.err != nil {
+		return nil, w.err
+	}
+
+	digest := sha1.New()
+	digest.Write(jksPassword(password))
+	digest.Write([]byte(jksIntegritySalt))
+	digest.Write(w.buf.Bytes())
+	w.buf.Write(digest.Sum(nil))
+
+	return w.buf.Bytes(), nil
+}
+
+// protectJKSKey encrypts a private key with the JKS key protector, and wraps
+// it into an EncryptedPrivateKeyInfo structure
+func protectJKSKey(material Material, password string) ([]byte, error) {
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(material.PrivateKey)
+	if err != nil {
+		return nil, errors.New("unable to encode private key")
+	}
+
+	salt, err := randomBytes(jksKeyProtectorSalt)
+	if err != nil {
+		return nil, err
+	}
+
+	passwordBytes := jksPassword(password)
+
+	// The keystream is a chain of SHA-1 digests, seeded with the salt
+	encrypted := make([]byte, len(pkcs8))
+	digest := salt
+	for offset := 0; offset < len(pkcs8); offset += sha1.Size {
+		sum := sha1.Sum(append(append([]byte{}, passwordBytes...), digest...))
+		digest = sum[:]
+		for i := 0; i < sha1.Size && offset+i < len(pkcs8); i++ {
+			encrypted[offset+i] = pkcs8[offset+i] ^ digest[i]
+		}
+	}
+
+	check := sha1.Sum(append(append([]byte{}, passwordBytes...), pkcs8...))
+
+	protected := append(append(salt, encrypted...), check[:]...)
+	return asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm:     pkix.AlgorithmIdentifier{Algorithm: oidJavaKeyProtector, Parameters: asn1.NullRawValue},
+		EncryptedData: protected,
+	})
+}
+
+// jksPassword returns the password as UTF-16BE bytes, as used by JKS
+func jksPassword(password string) []byte {
+	return bmpString(password)
+}
+
+// jksWriter writes the big-endian primitives used by java.io.DataOutputStream.
+// The first error is kept, and returned once the keystore is written.
+type jksWriter struct {
+	buf bytes.Buffer
+	err error
+}
+
+func (w *jksWriter) uint32(v uint32) {
+	_ = binary.Write(&w.buf, binary.BigEndian, v)
+}
+
+// utf writes a string like DataOutputStream.writeUTF, as modified UTF-8
+// prefixed with its length in bytes
+func (w *jksWriter) utf(s string) {
+	encoded := modifiedUTF8(s)
+	if len(encoded) > math.MaxUint16 {
+		if w.err == nil {
+			w.err = errors.New("JKS alias is too long")
+		}
+		return
+	}
+	_ = binary.Write(&w.buf, binary.BigEndian, uint16(len(encoded)))
+	w.buf.Write(encoded)
+}
+
+func (w *jksWriter) timestamp(t time.Time) {
+	_ = binary.Write(&w.buf, binary.BigEndian, t.UnixMilli())
+}
+
+func (w *jksWriter) bytes(b []byte) {
+	w.uint32(uint32(len(b)))
+	w.buf.Write(b)
+}
+
+func (w *jksWriter) certificate(cert *x509.Certificate) {
+	w.utf(jksCertType)
+	w.bytes(cert.Raw)
+}
+
+// modifiedUTF8 encodes a string as the modified UTF-8 used by Java. Unlike
+// UTF-8, NUL is encoded in two bytes, and supplementary characters are
+// encoded as their UTF-16 surrogate pairs, in three bytes each.
+func modifiedUTF8(s string) []byte {
+	var out []byte
+	for _, c := range utf16.Encode([]rune(s)) {
+		switch {
+		case c != 0 && c < 0x80:
+			out = append(out, byte(c))
+		case c < 0x800:
+			out = append(out, 0xc0|byte(c>>6), 0x80|byte(c&0x3f))
+		default:
+			out = append(out, 0xe0|byte(c>>12), 0x80|byte(c>>6&0x3f), 0x80|byte(c&0x3f))
+		}
+	}
+	return out
+}
@@ -0,0 +1,129 @@
+// Package keystore encodes PEM private keys and certificates into the binary
+// keystore formats consumed by Java applications: PKCS#12 and JKS.
+package keystore
+
+import (
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Material holds the parsed key and certificates that go into a keystore.
+// A keystore holds a private key together with its certificate chain, while
+// a truststore holds certificates only.
+type Material struct {
+	// Alias is the entry name used for the private key entry, and the prefix
+	// used for trusted certificate entries.
+	Alias string
+	// PrivateKey is the private key for the Certificate. It is nil for
+	// truststores.
+	PrivateKey crypto.PrivateKey
+	// Certificate is the leaf certificate for the PrivateKey.
+	Certificate *x509.Certificate
+	// CACerts holds the remaining certificates of the chain, or the trusted
+	// certificates of a truststore.
+	CACerts []*x509.Certificate
+}
+
+// NewMaterial parses PEM encoded key and certificate material. Any of the
+// inputs can be empty. Errors never include the contents of the inputs, since
+// these are secret values.
+func NewMaterial(alias, keyPEM, certPEM, caPEM string) (Material, error) {
+	material := Material{Alias: strings.ToLower(alias)}
+
+	if len(keyPEM) > 0 {
+		key, err := parsePrivateKey([]byte(keyPEM))
+		if err != nil {
+			return Material{}, err
+		}
+		material.PrivateKey = key
+	}
+
+	if len(certPEM) > 0 {
+		certs, err := parseCertificates([]byte(certPEM))
+		if err != nil {
+			return Material{}, err
+		}
+		// Anything after the leaf is treated as part of the chain
+		material.Certificate = certs[0]
+		material.CACerts = append(material.CACerts, certs[1:]...)
+	}
+
+	if len(caPEM) > 0 {
+		certs, err := parseCertificates([]byte(caPEM))
+		if err != nil {
+			return Material{}, err
+		}
+		material.CACerts = append(material.CACerts, certs...)
+	}
+
+	if material.PrivateKey != nil && material.Certificate == nil {
+		return Material{}, errors.New("private key requires a certificate")
+	}
+
+	return material, nil
+}
+
+func parsePrivateKey(data []byte) (crypto.PrivateKey, error) {
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return nil, errors.New("value does not contain a PEM private key")
+		}
+
+		switch block.Type {
+		case "PRIVATE KEY":
+			key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, errors.New("value contains a malformed PEM private key")
+			}
+			return key, nil
+		case "RSA PRIVATE KEY":
+			key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+			if err != nil {
+				return nil, errors.New("value contains a malformed PEM private key")
+			}
+			return key, nil
+		case "EC PRIVATE KEY":
+			key, err := x509.ParseECPrivateKey(block.Bytes)
+			if err != nil {
+				return nil, errors.New("value contains a malformed PEM private key")
+			}
+			return key, nil
+		}
+	}
+}
+
+func parseCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, errors.New("value contains a malformed PEM certificate")
+		}
+		certs = append(certs, cert)
+	}
+
+	if len(certs) == 0 {
+		return nil, errors.New("value does not contain a PEM certificate")
+	}
+	return certs, nil
+}
+
+// trustedCertAlias returns the alias of the i-th trusted certificate entry
+func (m Material) trustedCertAlias(i int) string {
+	return fmt.Sprintf("%s-ca-%d", m.Alias, i)
+}
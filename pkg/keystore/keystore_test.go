@@ -0,0 +1,464 @@
+package keystore
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/pem"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"unicode/utf16"
+
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewMaterial(t *testing.T) {
+	b := testutils.NewPEMBundle(t)
+
+	t.Run("keystore material", func(t *testing.T) {
+		m, err := NewMaterial("App", b.RSAKey, b.LeafCert, b.ChainCert)
+		require.NoError(t, err)
+		assert.Equal(t, "app", m.Alias)
+		assert.NotNil(t, m.PrivateKey)
+		assert.Equal(t, b.CertsDER[0], m.Certificate.Raw)
+		require.Len(t, m.CACerts, 1)
+		assert.Equal(t, b.CertsDER[1], m.CACerts[0].Raw)
+	})
+
+	t.Run("chain in certificate value", func(t *testing.T) {
+		m, err := NewMaterial("app", b.RSAKey, b.LeafCert+b.ChainCert, "")
+		require.NoError(t, err)
+		assert.Equal(t, b.CertsDER[0], m.Certificate.Raw)
+		require.Len(t, m.CACerts, 1)
+	})
+
+	t.Run("truststore material", func(t *testing.T) {
+		m, err := NewMaterial("app", "", "", b.ChainCert)
+		require.NoError(t, err)
+		assert.Nil(t, m.PrivateKey)
+		assert.Nil(t, m.Certificate)
+		assert.Len(t, m.CACerts, 1)
+	})
+
+	errorCases := []struct {
+		name   string
+		key    string
+		cert   string
+		errMsg string
+	}{
+		{"key without certificate", b.RSAKey, "", "private key requires a certificate"},
+		{"certificate in key", b.LeafCert, b.LeafCert, "value does not contain a PEM private key"},
+		{"key in certificate", b.RSAKey, b.RSAKey, "value does not contain a PEM certificate"},
+		{
+			"malformed certificate",
+			b.RSAKey,
+			string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: []byte("secret")})),
+			"value contains a malformed PEM certificate",
+		},
+	}
+	for _, tc := range errorCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := NewMaterial("app", tc.key, tc.cert, "")
+			assert.EqualError(t, err, tc.errMsg)
+			assert.NotContains(t, err.Error(), "secret")
+		})
+	}
+}
+
+func TestEncodePKCS12(t *testing.T) {
+	b := testutils.NewPEMBundle(t)
+	m, err := NewMaterial("app", b.RSAKey, b.LeafCert, b.ChainCert)
+	require.NoError(t, err)
+
+	data, err := EncodePKCS12(m, "changeit")
+	require.NoError(t, err)
+
+	var pfx pfxPdu
+	rest, err := asn1.Unmarshal(data, &pfx)
+	require.NoError(t, err)
+	assert.Empty(t, rest)
+	assert.Equal(t, 3, pfx.Version)
+	authSafe := unwrapData(t, pfx.AuthSafe)
+
+	// The MAC must verify with the store password
+	macKey := pkcs12KDF(sha256.New, 64, pkcs12MacKeyID, bmpStringZeroTerminated("changeit"), pfx.MacData.MacSalt, pfx.MacData.Iterations, sha256.Size)
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(authSafe)
+	assert.True(t, oidSHA256.Equal(pfx.MacData.Mac.Algorithm.Algorithm))
+	assert.Equal(t, mac.Sum(nil), pfx.MacData.Mac.Digest)
+
+	var contents []contentInfo
+	_, err = asn1.Unmarshal(authSafe, &contents)
+	require.NoError(t, err)
+
+	var certs [][]byte
+	var keys [][]byte
+	for _, ci := range contents {
+		var bags []safeBag
+		_, err = asn1.Unmarshal(unwrapData(t, ci), &bags)
+		require.NoError(t, err)
+
+		for _, bag := range bags {
+			switch {
+			case bag.ID.Equal(oidCertBag):
+				var cb certBag
+				_, err = asn1.Unmarshal(bag.Value.Bytes, &cb)
+				require.NoError(t, err)
+				certs = append(certs, cb.Data)
+			case bag.ID.Equal(oidPKCS8ShroudedKeyBag):
+				keys = append(keys, decryptPBES2(t, bag.Value.Bytes, "changeit"))
+			}
+		}
+	}
+
+	assert.Equal(t, b.CertsDER, certs)
+	assert.Equal(t, [][]byte{b.PKCS8DER}, keys)
+
+	t.Run("requires key and certificate", func(t *testing.T) {
+		trust, err := NewMaterial("app", "", "", b.ChainCert)
+		require.NoError(t, err)
+		_, err = EncodePKCS12(trust, "changeit")
+		assert.EqualError(t, err, "PKCS#12 keystore requires a private key and a certificate")
+	})
+
+	t.Run("requires password", func(t *testing.T) {
+		_, err := EncodePKCS12(m, "")
+		assert.EqualError(t, err, "PKCS#12 keystore requires a non-empty password")
+	})
+}
+
+func TestEncodeJKS(t *testing.T) {
+	b := testutils.NewPEMBundle(t)
+
+	t.Run("keystore", func(t *testing.T) {
+		m, err := NewMaterial("app", b.RSAKey, b.LeafCert, b.ChainCert)
+		require.NoError(t, err)
+
+		data, err := EncodeJKS(m, "changeit")
+		require.NoError(t, err)
+
+		entries := readJKS(t, data, "changeit")
+		require.Len(t, entries, 1)
+		assert.Equal(t, jksPrivateKeyTag, entries[0].tag)
+		assert.Equal(t, "app", entries[0].alias)
+		assert.Equal(t, b.PKCS8DER, entries[0].key)
+		assert.Equal(t, b.CertsDER, entries[0].certs)
+	})
+
+	t.Run("truststore", func(t *testing.T) {
+		m, err := NewMaterial("app", "", b.LeafCert, b.ChainCert)
+		require.NoError(t, err)
+
+		data, err := EncodeJKS(m, "changeit")
+		require.NoError(t, err)
+
+		entries := readJKS(t, data, "changeit")
+		require.Len(t, entries, 2)
+		for i, entry := range entries {
+			assert.Equal(t, jksTrustedCertTag, entry.tag)
+			assert.Equal(t, m.trustedCertAlias(i), entry.alias)
+			assert.Equal(t, [][]byte{b.CertsDER[i]}, entry.certs)
+		}
+	})
+
+	t.Run("alias with NUL and supplementary characters", func(t *testing.T) {
+		m, err := NewMaterial("app\x00\U0001F600", b.RSAKey, b.LeafCert, b.ChainCert)
+		require.NoError(t, err)
+
+		data, err := EncodeJKS(m, "changeit")
+		require.NoError(t, err)
+
+		entries := readJKS(t, data, "changeit")
+		require.Len(t, entries, 1)
+		assert.Equal(t, "app\x00\U0001F600", entries[0].alias)
+	})
+
+	t.Run("alias too long", func(t *testing.T) {
+		m, err := NewMaterial(strings.Repeat("a", 1<<16), b.RSAKey, b.LeafCert, b.ChainCert)
+		require.NoError(t, err)
+
+		_, err = EncodeJKS(m, "changeit")
+		assert.EqualError(t, err, "JKS alias is too long")
+	})
+
+	t.Run("requires certificates", func(t *testing.T) {
+		_, err := EncodeJKS(Material{Alias: "app"}, "changeit")
+		assert.EqualError(t, err, "JKS truststore requires at least one certificate")
+	})
+
+	t.Run("requires password", func(t *testing.T) {
+		_, err := EncodeJKS(Material{Alias: "app"}, "")
+		assert.EqualError(t, err, "JKS keystore requires a non-empty password")
+	})
+}
+
+func TestModifiedUTF8(t *testing.T) {
+	// Expected bytes are the output of java.io.DataOutputStream.writeUTF,
+	// without the length prefix
+	testCases := []struct {
+		description string
+		value       string
+		expected    []byte
+	}{
+		{"ASCII", "app", []byte{0x61, 0x70, 0x70}},
+		{"NUL", "a\x00b", []byte{0x61, 0xc0, 0x80, 0x62}},
+		{"two bytes", "\u00e9", []byte{0xc3, 0xa9}},
+		{"three bytes", "\u20ac", []byte{0xe2, 0x82, 0xac}},
+		{"supplementary", "\U0001F600", []byte{0xed, 0xa0, 0xbd, 0xed, 0xb8, 0x80}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			assert.Equal(t, tc.expected, modifiedUTF8(tc.value))
+		})
+	}
+}
+
+// TestEncodePKCS12_OpenSSL checks that the openssl CLI reads the keystore
+func TestEncodePKCS12_OpenSSL(t *testing.T) {
+	openssl, err := exec.LookPath("openssl")
+	if err != nil {
+		t.Skip("openssl is not available")
+	}
+
+	b := testutils.NewPEMBundle(t)
+	m, err := NewMaterial("app", b.RSAKey, b.LeafCert, b.ChainCert)
+	require.NoError(t, err)
+	data, err := EncodePKCS12(m, "changeit")
+	require.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "keystore.p12")
+	require.NoError(t, os.WriteFile(path, data, 0600))
+
+	out, err := exec.Command(openssl, "pkcs12", "-in", path, "-passin", "pass:changeit", "-nodes").Output()
+	require.NoError(t, err)
+
+	var certs [][]byte
+	var keys [][]byte
+	for rest := out; ; {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			break
+		}
+		switch block.Type {
+		case "CERTIFICATE":
+			certs = append(certs, block.Bytes)
+		case "PRIVATE KEY":
+			keys = append(keys, block.Bytes)
+		}
+	}
+	assert.ElementsMatch(t, b.CertsDER, certs)
+	assert.Equal(t, [][]byte{b.PKCS8DER}, keys)
+	assert.Contains(t, string(out), "friendlyName: app")
+
+	_, err = exec.Command(openssl, "pkcs12", "-in", path, "-passin", "pass:wrong", "-nodes").Output()
+	assert.Error(t, err)
+}
+
+// TestEncodeJKS_Keytool checks that the Java keytool CLI reads the keystore
+// and the truststore
+func TestEncodeJKS_Keytool(t *testing.T) {
+	keytool, err := exec.LookPath("keytool")
+	if err != nil {
+		t.Skip("keytool is not available")
+	}
+
+	b := testutils.NewPEMBundle(t)
+	list := func(m Material) string {
+		data, err := EncodeJKS(m, "changeit")
+		require.NoError(t, err)
+		path := filepath.Join(t.TempDir(), "keystore.jks")
+		require.NoError(t, os.WriteFile(path, data, 0600))
+
+		out, err := exec.Command(
+			keytool, "-list", "-v", "-storetype", "JKS", "-keystore", path, "-storepass", "changeit",
+		).CombinedOutput()
+		require.NoError(t, err, string(out))
+		return string(out)
+	}
+
+	keystore, err := NewMaterial("app", b.RSAKey, b.LeafCert, b.ChainCert)
+	require.NoError(t, err)
+	out := list(keystore)
+	assert.Contains(t, out, "Alias name: app")
+	assert.Contains(t, out, "Entry type: PrivateKeyEntry")
+	assert.Contains(t, out, "Certificate chain length: 2")
+
+	truststore, err := NewMaterial("app", "", b.LeafCert, b.ChainCert)
+	require.NoError(t, err)
+	out = list(truststore)
+	assert.Contains(t, out, "Alias name: "+truststore.trustedCertAlias(0))
+	assert.Contains(t, out, "Alias name: "+truststore.trustedCertAlias(1))
+	assert.Contains(t, out, "Entry type: trustedCertEntry")
+}
+
+func unwrapData(t *testing.T, ci contentInfo) []byte {
+	require.True(t, oidDataContentType.Equal(ci.ContentType))
+	var data []byte
+	_, err := asn1.Unmarshal(ci.Content.Bytes, &data)
+	require.NoError(t, err)
+	return data
+}
+
+func decryptPBES2(t *testing.T, der []byte, password string) []byte {
+	var info encryptedPrivateKeyInfo
+	_, err := asn1.Unmarshal(der, &info)
+	require.NoError(t, err)
+	require.True(t, oidPBES2.Equal(info.Algorithm.Algorithm))
+
+	var params pbes2Params
+	_, err = asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &params)
+	require.NoError(t, err)
+	var kdf pbkdf2Params
+	_, err = asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdf)
+	require.NoError(t, err)
+	var iv []byte
+	_, err = asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv)
+	require.NoError(t, err)
+
+	key, err := pbkdf2.Key(sha256.New, password, kdf.Salt, kdf.Iterations, 32)
+	require.NoError(t, err)
+	block, err := aes.NewCipher(key)
+	require.NoError(t, err)
+	plaintext := make([]byte, len(info.EncryptedData))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, info.EncryptedData)
+
+	padding := int(plaintext[len(plaintext)-1])
+	return plaintext[:len(plaintext)-padding]
+}
+
+type jksEntry struct {
+	tag   uint32
+	alias string
+	key   []byte
+	certs [][]byte
+}
+
+// readJKS parses a JKS file the way sun.security.provider.JavaKeyStore does
+func readJKS(t *testing.T, data []byte, password string) []jksEntry {
+	require.Greater(t, len(data), sha1.Size)
+	body, digest := data[:len(data)-sha1.Size], data[len(data)-sha1.Size:]
+	expected := sha1.New()
+	expected.Write(jksPassword(password))
+	expected.Write([]byte(jksIntegritySalt))
+	expected.Write(body)
+	require.Equal(t, expected.Sum(nil), digest, "keystore integrity check failed")
+
+	r := bytes.NewReader(body)
+	readUint32 := func() uint32 {
+		var v uint32
+		require.NoError(t, binary.Read(r, binary.BigEndian, &v))
+		return v
+	}
+	readUTF := func() string {
+		var n uint16
+		require.NoError(t, binary.Read(r, binary.BigEndian, &n))
+		s := make([]byte, n)
+		_, err := io.ReadFull(r, s)
+		require.NoError(t, err)
+		return decodeModifiedUTF8(t, s)
+	}
+	readBytes := func() []byte {
+		b := make([]byte, readUint32())
+		_, err := io.ReadFull(r, b)
+		require.NoError(t, err)
+		return b
+	}
+	readCert := func() []byte {
+		require.Equal(t, jksCertType, readUTF())
+		return readBytes()
+	}
+
+	require.Equal(t, jksMagic, readUint32())
+	require.Equal(t, jksVersion, readUint32())
+
+	var entries []jksEntry
+	count := readUint32()
+	for i := uint32(0); i < count; i++ {
+		entry := jksEntry{tag: readUint32(), alias: readUTF()}
+		var timestamp int64
+		require.NoError(t, binary.Read(r, binary.BigEndian, &timestamp))
+
+		switch entry.tag {
+		case jksPrivateKeyTag:
+			entry.key = recoverJKSKey(t, readBytes(), password)
+			chainLen := readUint32()
+			for j := uint32(0); j < chainLen; j++ {
+				entry.certs = append(entry.certs, readCert())
+			}
+		case jksTrustedCertTag:
+			entry.certs = append(entry.certs, readCert())
+		default:
+			t.Fatalf("unexpected entry tag %d", entry.tag)
+		}
+		entries = append(entries, entry)
+	}
+	assert.Zero(t, r.Len())
+
+	return entries
+}
+
+// decodeModifiedUTF8 decodes modified UTF-8 the way
+// java.io.DataInputStream.readUTF does
+func decodeModifiedUTF8(t *testing.T, b []byte) string {
+	var chars []uint16
+	for i := 0; i < len(b); {
+		switch {
+		case b[i]&0x80 == 0:
+			require.NotZero(t, b[i], "NUL must be encoded in two bytes")
+			chars = append(chars, uint16(b[i]))
+			i++
+		case b[i]&0xe0 == 0xc0:
+			require.Less(t, i+1, len(b))
+			chars = append(chars, uint16(b[i]&0x1f)<<6|uint16(b[i+1]&0x3f))
+			i += 2
+		case b[i]&0xf0 == 0xe0:
+			require.Less(t, i+2, len(b))
+			chars = append(chars, uint16(b[i]&0x0f)<<12|uint16(b[i+1]&0x3f)<<6|uint16(b[i+2]&0x3f))
+			i += 3
+		default:
+			t.Fatalf("malformed modified UTF-8 byte %#x", b[i])
+		}
+	}
+	return string(utf16.Decode(chars))
+}
+
+func recoverJKSKey(t *testing.T, der []byte, password string) []byte {
+	var info encryptedPrivateKeyInfo
+	_, err := asn1.Unmarshal(der, &info)
+	require.NoError(t, err)
+	require.True(t, oidJavaKeyProtector.Equal(info.Algorithm.Algorithm))
+
+	protected := info.EncryptedData
+	salt := protected[:jksKeyProtectorSalt]
+	encrypted := protected[jksKeyProtectorSalt : len(protected)-sha1.Size]
+	check := protected[len(protected)-sha1.Size:]
+
+	passwordBytes := jksPassword(password)
+	plaintext := make([]byte, len(encrypted))
+	digest := salt
+	for offset := 0; offset < len(encrypted); offset += sha1.Size {
+		sum := sha1.Sum(append(append([]byte{}, passwordBytes...), digest...))
+		digest = sum[:]
+		for i := 0; i < sha1.Size && offset+i < len(encrypted); i++ {
+			plaintext[offset+i] = encrypted[offset+i] ^ digest[i]
+		}
+	}
+
+	expectedCheck := sha1.Sum(append(append([]byte{}, passwordBytes...), plaintext...))
+	require.Equal(t, expectedCheck[:], check)
+
+	return plaintext
+}
@@ -0,0 +1,386 @@
+package keystore
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"hash"
+	"math/big"
+	"unicode/utf16"
+)
+
+// PKCS#12 files are written using the algorithms current Java and OpenSSL
+// versions use by default: the private key is encrypted with PBES2
+// (PBKDF2-HMAC-SHA256, AES-256-CBC) and the file integrity is protected with
+// an HMAC-SHA256 MAC.
+const (
+	pkcs12SaltLen    = 16
+	pkcs12Iterations = 10000
+	// pkcs12MacKeyID is the RFC 7292 KDF diversifier for MAC keys
+	pkcs12MacKeyID byte = 3
+)
+
+var (
+	oidDataContentType     = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidPKCS8ShroudedKeyBag = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 2}
+	oidCertBag             = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 12, 10, 1, 3}
+	oidCertTypeX509        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 22, 1}
+	oidFriendlyName        = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 20}
+	oidLocalKeyID          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 21}
+	oidPBES2               = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2              = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA256      = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidAES256CBC           = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+	oidSHA256              = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+)
+
+type pfxPdu struct {
+	Version  int
+	AuthSafe contentInfo
+	MacData  macData
+}
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue
+}
+
+type macData struct {
+	Mac        digestInfo
+	MacSalt    []byte
+	Iterations int
+}
+
+type digestInfo struct {
+	Algorithm pkix.AlgorithmIdentifier
+	Digest    []byte
+}
+
+type safeBag struct {
+	ID         asn1.ObjectIdentifier
+	Value      asn1.RawValue
+	Attributes []pkcs12Attribute `asn1:"set,optional"`
+}
+
+type pkcs12Attribute struct {
+	ID    asn1.ObjectIdentifier
+	Value asn1.RawValue
+}
+
+type certBag struct {
+	ID   asn1.ObjectIdentifier
+	Data []byte `asn1:"tag:0,explicit"`
+}
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt       []byte
+	Iterations int
+	PRF        pkix.AlgorithmIdentifier
+}
+
+// EncodePKCS12 encodes a private key and its certificate chain into a
+// password protected PKCS#12 file.
+func EncodePKCS12(material Material, password string) ([]byte, error) {
+	if material.PrivateKey == nil || material.Certificate == nil {
+		return nil, errors.New("PKCS#12 keystore requires a private key and a certificate")
+	}
+	if len(password) == 0 {
+		return nil, errors.New("PKCS#12 keystore requires a non-empty password")
+	}
+
+	localKeyID := sha1.Sum(material.Certificate.Raw)
+	keyAttributes, err := bagAttributes(material.Alias, localKeyID[:])
+	if err != nil {
+		return nil, err
+	}
+
+	// Certificates are stored unencrypted, as is common practice
+	var certBags []safeBag
+	leafBag, err := newCertBag(material.Certificate.Raw, keyAttributes)
+	if err != nil {
+		return nil, err
+	}
+	certBags = append(certBags, leafBag)
+	for _, cert := range material.CACerts {
+		caBag, err := newCertBag(cert.Raw, nil)
+		if err != nil {
+			return nil, err
+		}
+		certBags = append(certBags, caBag)
+	}
+
+	keyBag, err := newShroudedKeyBag(material, password, keyAttributes)
+	if err != nil {
+		return nil, err
+	}
+
+	var authSafe []contentInfo
+	for _, bags := range [][]safeBag{certBags, {keyBag}} {
+		ci, err := newDataContentInfo(bags)
+		if err != nil {
+			return nil, err
+		}
+		authSafe = append(authSafe, ci)
+	}
+
+	authSafeBytes, err := asn1.Marshal(authSafe)
+	if err != nil {
+		return nil, err
+	}
+
+	pfx := pfxPdu{Version: 3}
+	if pfx.AuthSafe, err = dataContentInfo(authSafeBytes); err != nil {
+		return nil, err
+	}
+	if pfx.MacData, err = newMacData(authSafeBytes, password); err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(pfx)
+}
+
+func newCertBag(der []byte, attributes []pkcs12Attribute) (safeBag, error) {
+	bag, err := asn1.Marshal(certBag{ID: oidCertTypeX509, Data: der})
+	if err != nil {
+		return safeBag{}, err
+	}
+	return safeBag{
+		ID:         oidCertBag,
+		Value:      explicitTag0(bag),
+		Attributes: attributes,
+	}, nil
+}
+
+func newShroudedKeyBag(material Material, password string, attributes []pkcs12Attribute) (safeBag, error) {
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(material.PrivateKey)
+	if err != nil {
+		return safeBag{}, errors.New("unable to encode private key")
+	}
+
+	salt, err := randomBytes(pkcs12SaltLen)
+	if err != nil {
+		return safeBag{}, err
+	}
+	iv, err := randomBytes(aes.BlockSize)
+	if err != nil {
+		return safeBag{}, err
+	}
+
+	key, err := pbkdf2.Key(sha256.New, password, salt, pkcs12Iterations, 32)
+	if err != nil {
+		return safeBag{}, err
+	}
+	encrypted, err := encryptAESCBC(key, iv, pkcs8)
+	if err != nil {
+		return safeBag{}, err
+	}
+
+	kdfParams, err := asn1.Marshal(pbkdf2Params{
+		Salt:       salt,
+		Iterations: pkcs12Iterations,
+		PRF:        pkix.AlgorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: asn1.NullRawValue},
+	})
+	if err != nil {
+		return safeBag{}, err
+	}
+	ivParam, err := asn1.Marshal(iv)
+	if err != nil {
+		return safeBag{}, err
+	}
+	schemeParams, err := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdfParams}},
+		EncryptionScheme:  pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivParam}},
+	})
+	if err != nil {
+		return safeBag{}, err
+	}
+
+	bag, err := asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm:     pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: schemeParams}},
+		EncryptedData: encrypted,
+	})
+	if err != nil {
+		return safeBag{}, err
+	}
+
+	return safeBag{
+		ID:         oidPKCS8ShroudedKeyBag,
+		Value:      explicitTag0(bag),
+		Attributes: attributes,
+	}, nil
+}
+
+func bagAttributes(alias string, localKeyID []byte) ([]pkcs12Attribute, error) {
+	var attributes []pkcs12Attribute
+
+	if len(alias) > 0 {
+		name, err := asn1.Marshal(asn1.RawValue{
+			Tag:   asn1.TagBMPString,
+			Bytes: bmpString(alias),
+		})
+		if err != nil {
+			return nil, err
+		}
+		attributes = append(attributes, pkcs12Attribute{ID: oidFriendlyName, Value: setOf(name)})
+	}
+
+	id, err := asn1.Marshal(localKeyID)
+	if err != nil {
+		return nil, err
+	}
+	attributes = append(attributes, pkcs12Attribute{ID: oidLocalKeyID, Value: setOf(id)})
+
+	return attributes, nil
+}
+
+func newDataContentInfo(bags []safeBag) (contentInfo, error) {
+	safeContents, err := asn1.Marshal(bags)
+	if err != nil {
+		return contentInfo{}, err
+	}
+	return dataContentInfo(safeContents)
+}
+
+func dataContentInfo(data []byte) (contentInfo, error) {
+	content, err := asn1.Marshal(data)
+	if err != nil {
+		return contentInfo{}, err
+	}
+	return contentInfo{ContentType: oidDataContentType, Content: explicitTag0(content)}, nil
+}
+
+func newMacData(authSafe []byte, password string) (macData, error) {
+	salt, err := randomBytes(pkcs12SaltLen)
+	if err != nil {
+		return macData{}, err
+	}
+
+	key := pkcs12KDF(sha256.New, 64, pkcs12MacKeyID, bmpStringZeroTerminated(password), salt, pkcs12Iterations, sha256.Size)
+	mac := hmac.New(sha256.New, key)
+	mac.Write(authSafe)
+
+	return macData{
+		Mac: digestInfo{
+			Algorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256, Parameters: asn1.NullRawValue},
+			Digest:    mac.Sum(nil),
+		},
+		MacSalt:    salt,
+		Iterations: pkcs12Iterations,
+	}, nil
+}
+
+// pkcs12KDF implements the key derivation function from RFC 7292, Appendix B.2,
+// which is still required for the integrity MAC.
+func pkcs12KDF(h func() hash.Hash, v int, id byte, password, salt []byte, iterations, size int) []byte {
+	fill := func(src []byte) []byte {
+		if len(src) == 0 {
+			return nil
+		}
+		out := make([]byte, v*((len(src)+v-1)/v))
+		for i := range out {
+			out[i] = src[i%len(src)]
+		}
+		return out
+	}
+
+	d := make([]byte, v)
+	for i := range d {
+		d[i] = id
+	}
+	input := append(fill(salt), fill(password)...)
+
+	one := big.NewInt(1)
+	var out []byte
+	for len(out) < size {
+		digest := h()
+		digest.Write(d)
+		digest.Write(input)
+		a := digest.Sum(nil)
+		for i := 1; i < iterations; i++ {
+			digest.Reset()
+			digest.Write(a)
+			a = digest.Sum(a[:0])
+		}
+		out = append(out, a...)
+
+		// Each v-byte block of the input becomes (block + B + 1) mod 2^(8v)
+		b := new(big.Int).SetBytes(fill(a)[:v])
+		b.Add(b, one)
+		for j := 0; j < len(input); j += v {
+			block := new(big.Int).SetBytes(input[j : j+v])
+			block.Add(block, b)
+			blockBytes := block.Bytes()
+			if len(blockBytes) > v {
+				blockBytes = blockBytes[len(blockBytes)-v:]
+			}
+			clear(input[j : j+v])
+			copy(input[j+v-len(blockBytes):j+v], blockBytes)
+		}
+	}
+
+	return out[:size]
+}
+
+func encryptAESCBC(key, iv, plaintext []byte) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	// PKCS#7 padding
+	padding := aes.BlockSize - len(plaintext)%aes.BlockSize
+	padded := make([]byte, len(plaintext), len(plaintext)+padding)
+	copy(padded, plaintext)
+	for i := 0; i < padding; i++ {
+		padded = append(padded, byte(padding))
+	}
+
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(padded, padded)
+	return padded, nil
+}
+
+func explicitTag0(der []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: der}
+}
+
+func setOf(der []byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: der}
+}
+
+func bmpString(s string) []byte {
+	var out []byte
+	for _, r := range utf16.Encode([]rune(s)) {
+		out = append(out, byte(r>>8), byte(r))
+	}
+	return out
+}
+
+func bmpStringZeroTerminated(s string) []byte {
+	return append(bmpString(s), 0, 0)
+}
+
+func randomBytes(n int) ([]byte, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return nil, err
+	}
+	return b, nil
+}
@@ -15,3 +15,9 @@ const CSPFK013D string = "CSPFK013D Kubernetes Secret '%s' has no '%s' annotatio
 const CSPFK014D string = "CSPFK014D Found %d secret group templates for Kubernetes secret %s"
 const CSPFK015D string = "CSPFK015D No secrets to update"
 const CSPFK016D string = "CSPFK016D No change in Kubernetes secret '%s'"
+const CSPFK017D string = "CSPFK017D No change in secret groups after a configuration change"
+const CSPFK018D string = "CSPFK018D Secrets were wiped on exit, not providing secrets"
+const CSPFK019D string = "CSPFK019D Failed to fetch the secrets of every secret group at once, fetching them for each secret group. Reason: %s"
+const CSPFK020D string = "CSPFK020D No secrets are due for a refresh"
+const CSPFK021D string = "CSPFK021D Not running in a Pod, loading Kubernetes client config from KUBECONFIG %s"
+const CSPFK022D string = "CSPFK022D Output of post hook of secret group '%s': %s"
@@ -87,6 +87,7 @@ const CSPFK058E string = "CSPFK058E Could not rename temporary file '%s' to '%s'
 const CSPFK059E string = "CSPFK059E Could not delete temporary file '%s'. Truncated file."
 const CSPFK060E string = "CSPFK060E Could not delete temporary file '%s'. File may be left on disk."
 const CSPFK061E string = "CSPFK061E Could not write content to temporary file for '%s'"
+const CSPFK106E string = "CSPFK106E Could not set owner of temporary file '%s' to %d:%d. Reason: %s"
 
 // Secrets Decoding
 const CSPFK064E string = "CSPFK064E Failed to decode secret '%s' with '%s' content-type. Reason: %s"
@@ -134,3 +135,57 @@ const CSPFK089E string = "CSPFK089E Failed to render template for %s group in %s
 
 // Http server
 const CSPFK094E string = "CSPFK094E Failed to create HTTP server: %v"
+
+// Atomic file updates
+const CSPFK095E string = "CSPFK095E Failed to remove staged secret files generation %s: %v"
+
+// JSON content-type
+const CSPFK096E string = "CSPFK096E Failed to read JSON secret '%s' for secret group '%s': %s"
+const CSPFK097E string = "CSPFK097E Failed to read JSON secret '%s' for K8s secret '%s': %s"
+const CSPFK098E string = "CSPFK098E Field of secret '%s' in destination '%s' requires the 'json' content-type"
+
+// Optional secrets
+const CSPFK099E string = "CSPFK099E Retrieved secrets did not include optional secret '%s' requested by secret group '%s', using its default value"
+const CSPFK100E string = "CSPFK100E Retrieved secrets did not include optional secret '%s' requested by secret group '%s', leaving it out"
+const CSPFK101E string = "CSPFK101E Failed to retrieve secrets in a single batch, retrieving optional secrets separately. Reason: %s"
+
+// Configuration hot reload
+const CSPFK102E string = "CSPFK102E Failed to reload configuration after a change, keeping the current secret groups"
+const CSPFK103E string = "CSPFK103E Failed to watch configuration for changes, configuration changes require a restart. Reason: %s"
+
+// Post hooks
+const CSPFK104E string = "CSPFK104E Post hook of secret group '%s' failed after %s: %s"
+const CSPFK105E string = "CSPFK105E Post hook failed: %w"
+
+// Secret file change detection
+const CSPFK107E string = "CSPFK107E Secret file '%s' of secret group '%s' was modified or removed outside of the Secrets Provider, restoring it. Secret files restored: %d"
+
+// Secret file backups and rollback
+const CSPFK108E string = "CSPFK108E Failed to back up secret file of secret group '%s': %s"
+const CSPFK109E string = "CSPFK109E Rejected admin request from %s, admin endpoints only accept requests from the loopback interface"
+const CSPFK110E string = "CSPFK110E Admin request %s %s failed: %s"
+
+// Wipe on exit
+const CSPFK111E string = "CSPFK111E Failed to wipe provided secrets on exit. Reason: %s"
+const CSPFK112E string = "CSPFK112E %s is only supported in sidecar and standalone modes, ignoring it"
+
+// Secret group errors
+const CSPFK113E string = "CSPFK113E Failed to provide secrets of secret group '%s'. Reason: %s"
+const CSPFK114E string = "CSPFK114E Failed to record degraded secret groups. Reason: %s"
+const CSPFK115E string = "CSPFK115E Invalid %s annotation of K8s Secret '%s', using the global refresh interval. Reason: %s"
+
+// Refresh scheduling
+const CSPFK116E string = "CSPFK116E Invalid secrets refresh schedule annotation: %s %s"
+const CSPFK117E string = "CSPFK117E Invalid secrets refresh jitter annotation: %s %s"
+
+// Retries
+const CSPFK118E string = "CSPFK118E Failed to provide secrets with an error that retrying won't fix, not retrying. Reason: %s"
+const CSPFK119E string = "CSPFK119E Failed to provide secrets within the %s provide timeout. Reason: %w"
+const CSPFK120E string = "CSPFK120E Invalid %s annotation: %s %s"
+
+// Exit codes
+const CSPFK121E string = "CSPFK121E Failed to record exit code %d. Reason: %s"
+
+// Wiping Kubernetes Secrets on exit
+const CSPFK122E string = "CSPFK122E %s doesn't wipe Kubernetes Secrets, which are only wiped with %s, ignoring it"
+const CSPFK123E string = "CSPFK123E %s isn't supported in standalone mode, where Kubernetes Secrets may be shared with other workloads, ignoring it"
@@ -47,3 +47,13 @@ const CSPFK037I string = "CSPFK037I Namespace allowlist set to '*' (all namespac
 const CSPFK038I string = "CSPFK038I HTTP server start listening at %s"
 const CSPFK039I string = "CSPFK039I Secrets Provider healthy status changed to %t"
 const CSPFK040I string = "CSPFK040I Secrets Provider ready status changed to %t"
+const CSPFK041I string = "CSPFK041I Published secret files generation %s"
+const CSPFK042I string = "CSPFK042I Removing secret files of secret group '%s', which was removed or moved by a configuration change"
+const CSPFK043I string = "CSPFK043I Reloaded secret groups after a configuration change"
+const CSPFK044I string = "CSPFK044I Post hook of secret group '%s' succeeded in %s"
+const CSPFK045I string = "CSPFK045I Rolled back secret file of secret group '%s' to backup generation %d, its refreshes are paused"
+const CSPFK046I string = "CSPFK046I Resumed refreshes of secret group '%s'"
+const CSPFK047I string = "CSPFK047I Secret group '%s' is paused after a rollback, skipping its refresh"
+const CSPFK048I string = "CSPFK048I Wiping provided secrets on exit"
+const CSPFK049I string = "CSPFK049I Wiped provided secrets"
+const CSPFK050I string = "CSPFK050I Exiting with code %d (%s)"
@@ -141,7 +141,7 @@ func (a *K8sAuthenticator) GetAccessToken(ctx context.Context) ([]byte, error) {
 		return nil, fmt.Errorf("%s", messages.CSPFK009E)
 	}
 	if err := authn.AuthenticateWithContext(ctx); err != nil {
-		return nil, fmt.Errorf("%s", messages.CSPFK010E)
+		return nil, fmt.Errorf("%s: %w", messages.CSPFK010E, err)
 	}
 	tokenData, err := authn.GetAccessToken().Read()
 	if err != nil {
@@ -176,7 +176,7 @@ func (a *JwtAuthenticator) GetAccessToken(ctx context.Context) ([]byte, error) {
 		return nil, fmt.Errorf("%s", messages.CSPFK009E)
 	}
 	if err := authn.AuthenticateWithContext(ctx); err != nil {
-		return nil, fmt.Errorf("%s", messages.CSPFK010E)
+		return nil, fmt.Errorf("%s: %w", messages.CSPFK010E, err)
 	}
 	tokenData, err := authn.GetAccessToken().Read()
 	if err != nil {
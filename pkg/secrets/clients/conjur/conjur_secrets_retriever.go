@@ -2,15 +2,21 @@ package conjur
 
 import (
 	"context"
+	"errors"
+	"net/http"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/cyberark/conjur-api-go/conjurapi/response"
 	"github.com/cyberark/conjur-authn-k8s-client/pkg/log"
 	"go.opentelemetry.io/otel"
 	"go.opentelemetry.io/otel/attribute"
 
 	"github.com/cyberark/conjur-opentelemetry-tracer/pkg/trace"
 	"github.com/cyberark/secrets-provider-for-k8s/pkg/log/messages"
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/utils"
 )
 
 var fetchAllMaxSecrets = 500
@@ -22,8 +28,9 @@ type secretRetriever struct {
 	authenticator ConjurAuthenticator
 }
 
-// RetrieveSecretsFunc defines a function type for retrieving secrets.
-type RetrieveSecretsFunc func(variableIDs []string, traceContext context.Context) (map[string][]byte, error)
+// RetrieveSecretsFunc defines a function type for retrieving secrets. Retrieving
+// secrets is abandoned when ctx is done.
+type RetrieveSecretsFunc func(variableIDs []string, ctx context.Context) (map[string][]byte, error)
 
 // RetrieverFactory defines a function type for creating a RetrieveSecretsFunc
 // given a ConjurAuthenticator
@@ -38,14 +45,27 @@ func NewSecretRetriever(authenticator ConjurAuthenticator) (RetrieveSecretsFunc,
 	return retriever.Retrieve, nil
 }
 
+// RetrieveSecretsWithTimeout returns a RetrieveSecretsFunc that abandons each
+// retrieval of secrets after timeout, when it's greater than zero
+func RetrieveSecretsWithTimeout(retrieveSecrets RetrieveSecretsFunc, timeout time.Duration) RetrieveSecretsFunc {
+	if timeout <= 0 {
+		return retrieveSecrets
+	}
+	return func(variableIDs []string, ctx context.Context) (map[string][]byte, error) {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return retrieveSecrets(variableIDs, ctx)
+	}
+}
+
 // Retrieve implements a RetrieveSecretsFunc for a given SecretRetriever.
 // Authenticates the client, and retrieves a given batch of variables from Conjur.
-func (retriever secretRetriever) Retrieve(variableIDs []string, traceContext context.Context) (map[string][]byte, error) {
+func (retriever secretRetriever) Retrieve(variableIDs []string, ctx context.Context) (map[string][]byte, error) {
 	// Authenticate and get access token
-	accessTokenData, err := retriever.authenticator.GetAccessToken(traceContext)
+	accessTokenData, err := retriever.authenticator.GetAccessToken(ctx)
 	if err != nil {
 		log.Debug(err.Error())
-		return nil, log.RecordedError(messages.CSPFK010E)
+		return nil, classifyAuthnError(err, log.RecordedError(messages.CSPFK010E))
 	}
 	defer func() {
 		// Clear the access token from memory after we use it to authenticate
@@ -58,7 +78,7 @@ func (retriever secretRetriever) Retrieve(variableIDs []string, traceContext con
 	fetchAll := len(variableIDs) == 1 && variableIDs[0] == "*"
 
 	tr := trace.NewOtelTracer(otel.Tracer("secrets-provider"))
-	_, span := tr.Start(traceContext, "Retrieve secrets")
+	_, span := tr.Start(ctx, "Retrieve secrets")
 	span.SetAttributes(attribute.Bool("fetch_all", fetchAll))
 	if !fetchAll {
 		span.SetAttributes(attribute.Int("variable_count", len(variableIDs)))
@@ -70,24 +90,29 @@ func (retriever secretRetriever) Retrieve(variableIDs []string, traceContext con
 		return nil, log.RecordedError(messages.CSPFK033E)
 	}
 
+	// Requests abandoned when ctx is done keep using the client, so it's
+	// only cleaned up once they complete
+	conjurClient = &abandonableClient{ConjurClient: conjurClient}
 	defer conjurClient.Cleanup()
 	if fetchAll {
-		return retrieveConjurSecretsAll(conjurClient)
+		return retrieveConjurSecretsAll(ctx, conjurClient)
 	}
 
-	return retrieveConjurSecrets(conjurClient, variableIDs)
+	return retrieveConjurSecrets(ctx, conjurClient, variableIDs)
 }
 
-func retrieveConjurSecrets(conjurClient ConjurClient, variableIDs []string) (map[string][]byte, error) {
+func retrieveConjurSecrets(ctx context.Context, conjurClient ConjurClient, variableIDs []string) (map[string][]byte, error) {
 	log.Debug(messages.CSPFK003I, variableIDs)
 
 	if len(variableIDs) == 0 {
 		return nil, log.RecordedError(messages.CSPFK034E, "no variables to retrieve")
 	}
 
-	retrievedSecretsByFullIDs, err := conjurClient.RetrieveBatchSecretsSafe(variableIDs)
+	retrievedSecretsByFullIDs, err := callWithContext(ctx, func() (map[string][]byte, error) {
+		return conjurClient.RetrieveBatchSecretsSafe(variableIDs)
+	}, clearSecretValues)
 	if err != nil {
-		return nil, err
+		return nil, classifyConjurError(err)
 	}
 
 	// Normalise secret IDs from batch secrets back to <variable_id>
@@ -100,7 +125,7 @@ func retrieveConjurSecrets(conjurClient ConjurClient, variableIDs []string) (map
 	return retrievedSecrets, nil
 }
 
-func retrieveConjurSecretsAll(conjurClient ConjurClient) (map[string][]byte, error) {
+func retrieveConjurSecretsAll(ctx context.Context, conjurClient ConjurClient) (map[string][]byte, error) {
 	log.Info(messages.CSPFK023I)
 
 	// Page through all secrets available to the host
@@ -111,9 +136,11 @@ func retrieveConjurSecretsAll(conjurClient ConjurClient) (map[string][]byte, err
 			Limit:  100,
 			Offset: offset,
 		}
-		resources, err := conjurClient.Resources(resFilter)
+		resources, err := callWithContext(ctx, func() ([]map[string]interface{}, error) {
+			return conjurClient.Resources(resFilter)
+		}, nil)
 		if err != nil {
-			return nil, err
+			return nil, classifyConjurError(err)
 		}
 
 		log.Debug(messages.CSPFK010D, len(resources))
@@ -141,9 +168,11 @@ func retrieveConjurSecretsAll(conjurClient ConjurClient) (map[string][]byte, err
 	log.Info(messages.CSPFK003I, allResourcePaths)
 
 	// Retrieve all secrets in a single batch
-	retrievedSecretsByFullIDs, err := conjurClient.RetrieveBatchSecretsSafe(allResourcePaths)
+	retrievedSecretsByFullIDs, err := callWithContext(ctx, func() (map[string][]byte, error) {
+		return conjurClient.RetrieveBatchSecretsSafe(allResourcePaths)
+	}, clearSecretValues)
 	if err != nil {
-		return nil, err
+		return nil, classifyConjurError(err)
 	}
 
 	// Normalise secret IDs from batch secrets back to <variable_id>
@@ -156,6 +185,153 @@ func retrieveConjurSecretsAll(conjurClient ConjurClient) (map[string][]byte, err
 	return retrievedSecrets, nil
 }
 
+// callWithContext makes a Conjur request, which doesn't support contexts, and
+// returns once ctx is done even when the request didn't complete. The result
+// of an abandoned request is passed to abandon once it completes, such as to
+// clear secret values from memory.
+func callWithContext[T any](ctx context.Context, request func() (T, error), abandon func(T)) (T, error) {
+	var zero T
+	if err := ctx.Err(); err != nil {
+		return zero, err
+	}
+
+	type result struct {
+		value T
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		value, err := request()
+		done <- result{value, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.value, r.err
+	case <-ctx.Done():
+		go func() {
+			if r := <-done; abandon != nil {
+				abandon(r.value)
+			}
+		}()
+		return zero, ctx.Err()
+	}
+}
+
+// errClientCleanedUp is returned by requests made with an abandonableClient
+// after it's cleaned up
+var errClientCleanedUp = errors.New("conjur client was cleaned up")
+
+// abandonableClient is a ConjurClient whose requests may be abandoned by
+// callWithContext while they keep using it. Cleaning it up is postponed until
+// the requests in progress complete.
+type abandonableClient struct {
+	ConjurClient
+	mutex     sync.Mutex
+	requests  int
+	cleanedUp bool
+}
+
+func (c *abandonableClient) RetrieveBatchSecretsSafe(variableIDs []string) (map[string][]byte, error) {
+	if !c.startRequest() {
+		return nil, errClientCleanedUp
+	}
+	defer c.endRequest()
+	return c.ConjurClient.RetrieveBatchSecretsSafe(variableIDs)
+}
+
+func (c *abandonableClient) Resources(filter *conjurapi.ResourceFilter) ([]map[string]interface{}, error) {
+	if !c.startRequest() {
+		return nil, errClientCleanedUp
+	}
+	defer c.endRequest()
+	return c.ConjurClient.Resources(filter)
+}
+
+// Cleanup cleans up the client now if no requests are in progress, and
+// otherwise once the last of them completes
+func (c *abandonableClient) Cleanup() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.cleanedUp {
+		return
+	}
+	c.cleanedUp = true
+	if c.requests == 0 {
+		c.ConjurClient.Cleanup()
+	}
+}
+
+func (c *abandonableClient) startRequest() bool {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	if c.cleanedUp {
+		return false
+	}
+	c.requests++
+	return true
+}
+
+func (c *abandonableClient) endRequest() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.requests--
+	if c.cleanedUp && c.requests == 0 {
+		c.ConjurClient.Cleanup()
+	}
+}
+
+// clearSecretValues clears secret values from memory
+func clearSecretValues(secrets map[string][]byte) {
+	for id, secret := range secrets {
+		for b := range secret {
+			secret[b] = 0
+		}
+		delete(secrets, id)
+	}
+}
+
+// classifyConjurError classifies the errors of Conjur requests. Client errors,
+// such as a host that isn't permitted to access a variable, or a variable
+// that doesn't exist, are permanent. Server errors, rate limiting, network
+// errors and expired access tokens, which are renewed on the next attempt,
+// are transient. The class of the failure is derived from the status code of
+// the response.
+func classifyConjurError(err error) error {
+	var conjurErr *response.ConjurError
+	if !errors.As(err, &conjurErr) {
+		return utils.Transient(err)
+	}
+	switch conjurErr.Code {
+	case http.StatusUnauthorized:
+		err = utils.Classify(utils.FailureAuthn, err)
+	case http.StatusForbidden:
+		err = utils.Classify(utils.FailureAuthz, err)
+	case http.StatusNotFound:
+		err = utils.Classify(utils.FailureNotFound, err)
+	}
+	if conjurErr.Code >= http.StatusBadRequest && conjurErr.Code < http.StatusInternalServerError &&
+		conjurErr.Code != http.StatusUnauthorized && conjurErr.Code != http.StatusRequestTimeout &&
+		conjurErr.Code != http.StatusTooManyRequests {
+		return utils.Permanent(err)
+	}
+	return utils.Transient(err)
+}
+
+// classifyAuthnError classifies recordedErr, which is returned for err of
+// authenticating with Conjur. Only a host that Conjur refuses to
+// authenticate, with a 401 or 403 response, is an authentication failure.
+// Network errors, timeouts and server errors, and errors without the status
+// of the response, are transient.
+func classifyAuthnError(err error, recordedErr error) error {
+	var conjurErr *response.ConjurError
+	if errors.As(err, &conjurErr) &&
+		(conjurErr.Code == http.StatusUnauthorized || conjurErr.Code == http.StatusForbidden) {
+		return utils.Classify(utils.FailureAuthn, recordedErr)
+	}
+	return utils.Transient(recordedErr)
+}
+
 // The variable ID can be in the format "<account>:variable:<variable_id>". This function
 // just makes sure that if a variable is of the form "<account>:variable:<variable_id>"
 // we normalise it to "<variable_id>", otherwise we just leave it be!
@@ -1,9 +1,19 @@
 package conjur
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	"github.com/cyberark/conjur-api-go/conjurapi"
+	"github.com/cyberark/conjur-api-go/conjurapi/response"
 	"github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/clients/conjur/mocks"
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/utils"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -46,7 +56,7 @@ func TestRetrieveConjurSecrets(t *testing.T) {
 			client := &mocks.ConjurMockClient{
 				AutoGenerateResults: true,
 			}
-			secrets, err := retrieveConjurSecrets(client, tc.variableIDs)
+			secrets, err := retrieveConjurSecrets(t.Context(), client, tc.variableIDs)
 			if tc.expectError {
 				assert.Error(t, err)
 			} else {
@@ -83,7 +93,7 @@ func TestRetrieveConjurSecretsAll(t *testing.T) {
 			client := &mocks.ConjurMockClient{
 				AutoGenerateResults: true,
 			}
-			secrets, err := retrieveConjurSecretsAll(client)
+			secrets, err := retrieveConjurSecretsAll(t.Context(), client)
 			if tc.expectError {
 				assert.Error(t, err)
 			} else {
@@ -103,13 +113,178 @@ func TestRetrieveConjurSecretsAllWithNoSecrets(t *testing.T) {
 	client := &mocks.ConjurMockClient{
 		ReturnNoSecrets: true,
 	}
-	secrets, err := retrieveConjurSecretsAll(client)
+	secrets, err := retrieveConjurSecretsAll(t.Context(), client)
 	assert.Error(t, err)
 	assert.Contains(t, err.Error(), "CSPFK034E")
 	assert.Contains(t, err.Error(), "no variables to retrieve")
 	assert.Len(t, secrets, 0)
 }
 
+func TestRetrieveConjurSecretsErrorClassification(t *testing.T) {
+	testCases := []struct {
+		name              string
+		err               error
+		expectedPermanent bool
+		expectedClass     utils.FailureClass
+	}{
+		{"forbidden", &response.ConjurError{Code: http.StatusForbidden, Message: "403 Forbidden"}, true, utils.FailureAuthz},
+		{"not found", &response.ConjurError{Code: http.StatusNotFound, Message: "404 Not Found"}, true, utils.FailureNotFound},
+		{"unauthorized", &response.ConjurError{Code: http.StatusUnauthorized, Message: "401 Unauthorized"}, false, utils.FailureAuthn},
+		{"rate limited", &response.ConjurError{Code: http.StatusTooManyRequests, Message: "429 Too Many Requests"}, false, utils.FailureTransient},
+		{"server error", &response.ConjurError{Code: http.StatusBadGateway, Message: "502 Bad Gateway"}, false, utils.FailureTransient},
+		{"wrapped client error", fmt.Errorf("batch: %w", &response.ConjurError{Code: http.StatusForbidden}), true, utils.FailureAuthz},
+		{"network error", errors.New("dial tcp: connection refused"), false, utils.FailureTransient},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			client := &mocks.ConjurMockClient{ErrOnExecute: tc.err}
+			_, err := retrieveConjurSecrets(t.Context(), client, []string{"secret1"})
+			assert.ErrorIs(t, err, tc.err)
+			assert.Equal(t, tc.expectedPermanent, utils.IsPermanent(err))
+			assert.Equal(t, tc.expectedClass, utils.FailureClassOf(err))
+		})
+	}
+}
+
+// failingAuthenticator is a ConjurAuthenticator that fails to get an access
+// token
+type failingAuthenticator struct {
+	err error
+}
+
+func (a *failingAuthenticator) GetAccessToken(context.Context) ([]byte, error) {
+	return nil, a.err
+}
+
+func TestRetrieveAuthnErrorClassification(t *testing.T) {
+	testCases := []struct {
+		name          string
+		err           error
+		expectedClass utils.FailureClass
+	}{
+		{"unauthorized", &response.ConjurError{Code: http.StatusUnauthorized, Message: "401 Unauthorized"}, utils.FailureAuthn},
+		{"forbidden", &response.ConjurError{Code: http.StatusForbidden, Message: "403 Forbidden"}, utils.FailureAuthn},
+		{"wrapped unauthorized", fmt.Errorf("CSPFK010E Failed to authenticate: %w", &response.ConjurError{Code: http.StatusUnauthorized}), utils.FailureAuthn},
+		{"status only in message", errors.New("CAKC015 Login failed: dial tcp 10.0.0.1:4030 for account 403"), utils.FailureTransient},
+		{"server error", &response.ConjurError{Code: http.StatusBadGateway, Message: "502 Bad Gateway"}, utils.FailureTransient},
+		{"unavailable", &response.ConjurError{Code: http.StatusServiceUnavailable, Message: "503 Service Unavailable"}, utils.FailureTransient},
+		{"network error", &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}, utils.FailureTransient},
+		{"timeout", fmt.Errorf("CSPFK010E Failed to authenticate: %w", context.DeadlineExceeded), utils.FailureTransient},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			retrieveSecrets, err := NewSecretRetriever(&failingAuthenticator{err: tc.err})
+			assert.NoError(t, err)
+
+			_, err = retrieveSecrets([]string{"secret1"}, t.Context())
+			assert.ErrorContains(t, err, "CSPFK010E")
+			assert.False(t, utils.IsPermanent(err))
+			assert.Equal(t, tc.expectedClass, utils.FailureClassOf(err))
+		})
+	}
+}
+
+// blockingConjurClient is a Conjur client whose requests block until they're
+// released
+type blockingConjurClient struct {
+	release   chan struct{}
+	secrets   map[string][]byte
+	cleanedUp atomic.Bool
+}
+
+func (c *blockingConjurClient) RetrieveBatchSecretsSafe([]string) (map[string][]byte, error) {
+	<-c.release
+	return c.secrets, nil
+}
+
+func (c *blockingConjurClient) Resources(*conjurapi.ResourceFilter) ([]map[string]interface{}, error) {
+	<-c.release
+	return nil, nil
+}
+
+func (c *blockingConjurClient) Cleanup() {
+	c.cleanedUp.Store(true)
+}
+
+func TestRetrieveConjurSecretsContext(t *testing.T) {
+	t.Run("retrieving secrets is abandoned when the context is done", func(t *testing.T) {
+		client := &blockingConjurClient{
+			release: make(chan struct{}),
+			secrets: map[string][]byte{"conjur:variable:secret1": []byte("secret")},
+		}
+		ctx, cancel := context.WithTimeout(t.Context(), 10*time.Millisecond)
+		defer cancel()
+
+		_, err := retrieveConjurSecrets(ctx, client, []string{"secret1"})
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.False(t, utils.IsPermanent(err))
+		close(client.release)
+	})
+
+	t.Run("fetching all secrets is abandoned when the context is done", func(t *testing.T) {
+		client := &blockingConjurClient{release: make(chan struct{})}
+		defer close(client.release)
+		ctx, cancel := context.WithCancel(t.Context())
+		cancel()
+
+		_, err := retrieveConjurSecretsAll(ctx, client)
+		assert.ErrorIs(t, err, context.Canceled)
+	})
+
+	t.Run("the client is cleaned up once abandoned requests complete", func(t *testing.T) {
+		client := &blockingConjurClient{
+			release: make(chan struct{}),
+			secrets: map[string][]byte{"conjur:variable:secret1": []byte("secret")},
+		}
+		abandonable := &abandonableClient{ConjurClient: client}
+		ctx, cancel := context.WithCancel(t.Context())
+		done := make(chan error, 1)
+		go func() {
+			_, err := retrieveConjurSecrets(ctx, abandonable, []string{"secret1"})
+			done <- err
+		}()
+		assert.Eventually(t, func() bool {
+			abandonable.mutex.Lock()
+			defer abandonable.mutex.Unlock()
+			return abandonable.requests == 1
+		}, time.Second, time.Millisecond)
+
+		cancel()
+		assert.ErrorIs(t, <-done, context.Canceled)
+		abandonable.Cleanup()
+		assert.False(t, client.cleanedUp.Load())
+
+		close(client.release)
+		assert.Eventually(t, client.cleanedUp.Load, time.Second, time.Millisecond)
+	})
+
+	t.Run("requests made after the client is cleaned up fail", func(t *testing.T) {
+		client := &blockingConjurClient{release: make(chan struct{})}
+		abandonable := &abandonableClient{ConjurClient: client}
+		abandonable.Cleanup()
+		assert.True(t, client.cleanedUp.Load())
+
+		_, err := abandonable.RetrieveBatchSecretsSafe([]string{"secret1"})
+		assert.ErrorIs(t, err, errClientCleanedUp)
+		_, err = abandonable.Resources(nil)
+		assert.ErrorIs(t, err, errClientCleanedUp)
+	})
+}
+
+func TestRetrieveSecretsWithTimeout(t *testing.T) {
+	retrieveSecrets := func(variableIDs []string, ctx context.Context) (map[string][]byte, error) {
+		<-ctx.Done()
+		return nil, ctx.Err()
+	}
+
+	start := time.Now()
+	_, err := RetrieveSecretsWithTimeout(retrieveSecrets, 10*time.Millisecond)([]string{"secret1"}, t.Context())
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(start), time.Second)
+}
+
 func TestNormalizeVariableId(t *testing.T) {
 	testCases := []struct {
 		input    string
@@ -8,6 +8,7 @@ import (
 
 	"github.com/cyberark/conjur-api-go/conjurapi"
 	"github.com/cyberark/secrets-provider-for-k8s/pkg/log/messages"
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/utils"
 )
 
 type ConjurMockClient struct {
@@ -55,7 +56,8 @@ func (mc *ConjurMockClient) RetrieveBatchSecretsSafe(variableIDs []string) (map[
 			// Check if the secret exists in the mock Conjur DB
 			variableData, ok := mc.Database[secretID]
 			if !ok {
-				return nil, errors.New("no_conjur_secret_error")
+				// Conjur responds with 404 Not Found
+				return nil, utils.Classify(utils.FailureNotFound, errors.New("no_conjur_secret_error"))
 			}
 			secrets[secretID] = []byte(variableData)
 		}
@@ -0,0 +1,85 @@
+package k8s
+
+import (
+	"errors"
+	"os"
+	"sync"
+
+	"github.com/cyberark/conjur-authn-k8s-client/pkg/log"
+	"k8s.io/client-go/kubernetes"
+	"k8s.io/client-go/rest"
+	"k8s.io/client-go/tools/clientcmd"
+
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/log/messages"
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/utils"
+)
+
+// ClientConfig configures the rate limiting of the Kubernetes client. Zero
+// values use the defaults of client-go.
+type ClientConfig struct {
+	QPS   float32
+	Burst int
+}
+
+var (
+	clientMu     sync.Mutex
+	clientConfig ClientConfig
+	sharedClient kubernetes.Interface
+)
+
+// ConfigureClient sets the configuration of the Kubernetes client. It only
+// applies to the client when it's created, on its first use.
+func ConfigureClient(config ClientConfig) {
+	clientMu.Lock()
+	defer clientMu.Unlock()
+	clientConfig = config
+}
+
+// Client returns the Kubernetes client shared by the Secrets Provider,
+// creating it on its first use. A client that can't be created is created
+// again on its next use.
+func Client() (kubernetes.Interface, error) {
+	clientMu.Lock()
+	defer clientMu.Unlock()
+	if sharedClient != nil {
+		return sharedClient, nil
+	}
+
+	log.Info(messages.CSPFK004I)
+	kubeConfig, err := restConfig(clientConfig)
+	if err != nil {
+		// Error messages returned from K8s should be printed only in debug mode
+		log.Debug(messages.CSPFK002D, err.Error())
+		return nil, utils.Classify(utils.FailureConfig, log.RecordedError(messages.CSPFK019E))
+	}
+
+	kubeClient, err := kubernetes.NewForConfig(kubeConfig)
+	if err != nil {
+		// Error messages returned from K8s should be printed only in debug mode
+		log.Debug(messages.CSPFK003D, err.Error())
+		return nil, utils.Classify(utils.FailureConfig, log.RecordedError(messages.CSPFK018E))
+	}
+	sharedClient = kubeClient
+	return sharedClient, nil
+}
+
+// restConfig loads the in-cluster Kubernetes client config. When the Secrets
+// Provider isn't running in a Pod, such as when it's run locally against a
+// development cluster, the config is loaded from KUBECONFIG instead.
+func restConfig(config ClientConfig) (*rest.Config, error) {
+	kubeConfig, err := rest.InClusterConfig()
+	if errors.Is(err, rest.ErrNotInCluster) && os.Getenv(clientcmd.RecommendedConfigPathEnvVar) != "" {
+		log.Debug(messages.CSPFK021D, os.Getenv(clientcmd.RecommendedConfigPathEnvVar))
+		kubeConfig, err = clientcmd.NewNonInteractiveDeferredLoadingClientConfig(
+			clientcmd.NewDefaultClientConfigLoadingRules(),
+			&clientcmd.ConfigOverrides{},
+		).ClientConfig()
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	kubeConfig.QPS = config.QPS
+	kubeConfig.Burst = config.Burst
+	return kubeConfig, nil
+}
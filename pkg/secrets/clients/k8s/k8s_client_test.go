@@ -0,0 +1,55 @@
+package k8s
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"k8s.io/client-go/rest"
+)
+
+const testKubeconfig = `apiVersion: v1
+kind: Config
+clusters:
+- name: kind
+  cluster:
+    server: https://127.0.0.1:6443
+    insecure-skip-tls-verify: true
+contexts:
+- name: kind
+  context:
+    cluster: kind
+    user: developer
+current-context: kind
+users:
+- name: developer
+  user:
+    token: test-token
+`
+
+func TestRestConfig(t *testing.T) {
+	// Not running in a Pod
+	t.Setenv("KUBERNETES_SERVICE_HOST", "")
+	t.Setenv("KUBERNETES_SERVICE_PORT", "")
+
+	t.Run("fails when KUBECONFIG isn't set", func(t *testing.T) {
+		t.Setenv("KUBECONFIG", "")
+		_, err := restConfig(ClientConfig{})
+		assert.ErrorIs(t, err, rest.ErrNotInCluster)
+	})
+
+	t.Run("falls back to KUBECONFIG", func(t *testing.T) {
+		kubeconfig := filepath.Join(t.TempDir(), "config")
+		require.NoError(t, os.WriteFile(kubeconfig, []byte(testKubeconfig), 0600))
+		t.Setenv("KUBECONFIG", kubeconfig)
+
+		config, err := restConfig(ClientConfig{QPS: 20, Burst: 40})
+		require.NoError(t, err)
+		assert.Equal(t, "https://127.0.0.1:6443", config.Host)
+		assert.Equal(t, "test-token", config.BearerToken)
+		assert.Equal(t, float32(20), config.QPS)
+		assert.Equal(t, 40, config.Burst)
+	})
+}
@@ -7,34 +7,38 @@ import (
 
 	"github.com/cyberark/conjur-authn-k8s-client/pkg/log"
 	v1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
-	"k8s.io/client-go/kubernetes"
-	"k8s.io/client-go/rest"
 
 	"github.com/cyberark/secrets-provider-for-k8s/pkg/log/messages"
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/utils"
 )
 
-type RetrieveK8sSecretFunc func(namespace string, secretName string) (*v1.Secret, error)
-type UpdateK8sSecretFunc func(namespace string, secretName string, originalK8sSecret *v1.Secret, stringDataEntriesMap map[string][]byte) error
-type ListLabeledK8sSecretsFunc func(namespace string) (*v1.SecretList, error)
+type RetrieveK8sSecretFunc func(ctx context.Context, namespace string, secretName string) (*v1.Secret, error)
+type UpdateK8sSecretFunc func(ctx context.Context, namespace string, secretName string, originalK8sSecret *v1.Secret, stringDataEntriesMap map[string][]byte) error
+type ListLabeledK8sSecretsFunc func(ctx context.Context, namespace string) (*v1.SecretList, error)
 
-func RetrieveK8sSecret(namespace string, secretName string) (*v1.Secret, error) {
-	// get K8s client object
-	kubeClient, _ := configK8sClient()
+func RetrieveK8sSecret(ctx context.Context, namespace string, secretName string) (*v1.Secret, error) {
+	kubeClient, err := Client()
+	if err != nil {
+		return nil, err
+	}
 	log.Info(messages.CSPFK005I, secretName, namespace)
-	k8sSecret, err := kubeClient.CoreV1().Secrets(namespace).Get(context.Background(), secretName, metav1.GetOptions{})
+	k8sSecret, err := kubeClient.CoreV1().Secrets(namespace).Get(ctx, secretName, metav1.GetOptions{})
 	if err != nil {
 		// Error messages returned from K8s should be printed only in debug mode
 		log.Debug(messages.CSPFK004D, err.Error())
-		return nil, log.RecordedError(messages.CSPFK020E)
+		return nil, classifyK8sError(err, log.RecordedError(messages.CSPFK020E))
 	}
 
 	return k8sSecret, nil
 }
 
-func UpdateK8sSecret(namespace string, secretName string, originalK8sSecret *v1.Secret, stringDataEntriesMap map[string][]byte) error {
-	// get K8s client object
-	kubeClient, _ := configK8sClient()
+func UpdateK8sSecret(ctx context.Context, namespace string, secretName string, originalK8sSecret *v1.Secret, stringDataEntriesMap map[string][]byte) error {
+	kubeClient, err := Client()
+	if err != nil {
+		return err
+	}
 
 	if originalK8sSecret.Data == nil {
 		originalK8sSecret.Data = map[string][]byte{}
@@ -45,47 +49,53 @@ func UpdateK8sSecret(namespace string, secretName string, originalK8sSecret *v1.
 	}
 
 	log.Info(messages.CSPFK006I, secretName, namespace)
-	_, err := kubeClient.CoreV1().Secrets(namespace).Update(context.Background(), originalK8sSecret, metav1.UpdateOptions{})
+	_, err = kubeClient.CoreV1().Secrets(namespace).Update(ctx, originalK8sSecret, metav1.UpdateOptions{})
 	// Clear secret from memory
 	stringDataEntriesMap = nil
 	originalK8sSecret = nil
 	if err != nil {
 		// Error messages returned from K8s should be printed only in debug mode
 		log.Debug(messages.CSPFK005D, err.Error())
-		return log.RecordedError(messages.CSPFK022E)
+		return classifyK8sError(err, log.RecordedError(messages.CSPFK022E))
 	}
 
 	return nil
 }
 
-func ListLabeledK8sSecrets(namespace string) (*v1.SecretList, error) {
-	kubeClient, _ := configK8sClient()
+func ListLabeledK8sSecrets(ctx context.Context, namespace string) (*v1.SecretList, error) {
+	kubeClient, err := Client()
+	if err != nil {
+		return nil, err
+	}
 	log.Info(messages.CSPFK025I, namespace)
-	secretList, err := kubeClient.CoreV1().Secrets(namespace).List(context.TODO(), metav1.ListOptions{LabelSelector: config.ManagedByProviderKey + "=true"})
+	secretList, err := kubeClient.CoreV1().Secrets(namespace).List(ctx, metav1.ListOptions{LabelSelector: config.ManagedByProviderKey + "=true"})
 	if err != nil {
 		log.Debug(messages.CSPFK011D, err.Error())
-		return nil, log.RecordedError(messages.CSPFK024E)
+		return nil, classifyK8sError(err, log.RecordedError(messages.CSPFK024E))
 	}
 
 	return secretList, nil
 }
 
-func configK8sClient() (*kubernetes.Clientset, error) {
-	// Create the Kubernetes client
-	log.Info(messages.CSPFK004I)
-	kubeConfig, err := rest.InClusterConfig()
-	if err != nil {
-		// Error messages returned from K8s should be printed only in debug mode
-		log.Debug(messages.CSPFK002D, err.Error())
-		return nil, log.RecordedError(messages.CSPFK019E)
+// classifyK8sError classifies recordedErr, which is returned for err of a
+// K8s API request. Errors such as a service account that isn't permitted to
+// access a K8s Secret, or an invalid request, are permanent. Other errors,
+// such as server errors, timeouts and conflicting updates, are transient, as
+// are expired tokens, which are renewed, and K8s Secrets that don't exist
+// yet. The class of the failure is derived from the status of err.
+func classifyK8sError(err error, recordedErr error) error {
+	switch {
+	case apierrors.IsUnauthorized(err):
+		recordedErr = utils.Classify(utils.FailureAuthn, recordedErr)
+	case apierrors.IsForbidden(err):
+		recordedErr = utils.Classify(utils.FailureAuthz, recordedErr)
+	case apierrors.IsNotFound(err):
+		recordedErr = utils.Classify(utils.FailureNotFound, recordedErr)
+	case apierrors.IsInvalid(err) || apierrors.IsBadRequest(err):
+		recordedErr = utils.Classify(utils.FailureConfig, recordedErr)
 	}
-
-	kubeClient, err := kubernetes.NewForConfig(kubeConfig)
-	if err != nil {
-		// Error messages returned from K8s should be printed only in debug mode
-		log.Debug(messages.CSPFK003D, err.Error())
-		return nil, log.RecordedError(messages.CSPFK018E)
+	if apierrors.IsForbidden(err) || apierrors.IsInvalid(err) || apierrors.IsBadRequest(err) {
+		return utils.Permanent(recordedErr)
 	}
-	// return a K8s client
-	return kubeClient, err
+	return utils.Transient(recordedErr)
 }
@@ -0,0 +1,41 @@
+package k8s
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/utils"
+)
+
+func TestClassifyK8sError(t *testing.T) {
+	resource := schema.GroupResource{Resource: "secrets"}
+	recordedErr := errors.New("CSPFK020E Failed to retrieve Kubernetes Secret")
+
+	testCases := []struct {
+		description       string
+		err               error
+		expectedPermanent bool
+		expectedClass     utils.FailureClass
+	}{
+		{"forbidden", apierrors.NewForbidden(resource, "db-credentials", errors.New("denied")), true, utils.FailureAuthz},
+		{"not found", apierrors.NewNotFound(resource, "db-credentials"), false, utils.FailureNotFound},
+		{"unauthorized", apierrors.NewUnauthorized("expired token"), false, utils.FailureAuthn},
+		{"conflict", apierrors.NewConflict(resource, "db-credentials", errors.New("modified")), false, utils.FailureTransient},
+		{"server timeout", apierrors.NewServerTimeout(resource, "get", 1), false, utils.FailureTransient},
+		{"too many requests", apierrors.NewTooManyRequests("slow down", 1), false, utils.FailureTransient},
+		{"network error", errors.New("dial tcp: connection refused"), false, utils.FailureTransient},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			err := classifyK8sError(tc.err, recordedErr)
+			assert.ErrorIs(t, err, recordedErr)
+			assert.Equal(t, tc.expectedPermanent, utils.IsPermanent(err))
+			assert.Equal(t, tc.expectedClass, utils.FailureClassOf(err))
+		})
+	}
+}
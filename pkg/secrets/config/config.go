@@ -9,6 +9,7 @@ import (
 	"time"
 
 	"github.com/cyberark/secrets-provider-for-k8s/pkg/log/messages"
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/utils"
 )
 
 // Constants for Secrets Provider operation modes,
@@ -20,6 +21,7 @@ const (
 	DefaultRetryCountLimit           = 5
 	DefaultRetryCountLimitStandalone = -1 // default to unlimited retries for standalone mode, readiness probe will indicate errors
 	DefaultRetryIntervalSec          = 1
+	DefaultRetryMaxIntervalSec       = 60
 	MinRetryCountLimit               = -1
 	MinRetryIntervalSec              = 0
 	MinRefreshInterval               = time.Second
@@ -36,12 +38,24 @@ type Config struct {
 	RequiredK8sSecrets     []string
 	RetryCountLimit        int
 	RetryIntervalSec       int
+	RetryMaxIntervalSec    int
+	ProvideTimeout         time.Duration
+	RequestTimeout         time.Duration
+	K8sAPIQPS              int
+	K8sAPIBurst            int
 	StoreType              string
 	SecretsRefreshInterval time.Duration
+	SecretsRefreshSchedule *utils.CronSchedule
+	SecretsRefreshJitter   time.Duration
 	SanitizeEnabled        bool
 	ContainerMode          string
 	NamespaceAllowlist     string
 	ServerAddress          string
+	AdminEndpointsEnabled  bool
+	AdminServerAddress     string
+	WipeOnExit             bool
+	WipeK8sSecretsOnExit   bool
+	ConfigReloadEnabled    bool
 }
 
 type annotationType int
@@ -68,12 +82,36 @@ const (
 	k8sSecretsKey         = "conjur.org/k8s-secrets"
 	retryCountLimitKey    = "conjur.org/retry-count-limit"
 	retryIntervalSecKey   = "conjur.org/retry-interval-sec"
+	// RetryBackoffKey is the Annotation key for setting whether retries back
+	// off exponentially, or are retried on a constant interval
+	RetryBackoffKey = "conjur.org/retry-backoff"
+	// RetryMaxIntervalSecKey is the Annotation key for setting the maximum
+	// interval of retries that back off exponentially
+	RetryMaxIntervalSecKey = "conjur.org/retry-max-interval-sec"
+	// ProvideTimeoutKey is the Annotation key for setting the deadline of
+	// providing secrets, retries included, in init and application modes
+	ProvideTimeoutKey = "conjur.org/provide-timeout"
+	// RequestTimeoutKey is the Annotation key for setting the timeout of each
+	// retrieval of secrets from Conjur and of each K8s API request
+	RequestTimeoutKey = "conjur.org/request-timeout"
+	// K8sAPIQPSKey is the Annotation key for setting the maximum queries per
+	// second of the Kubernetes client
+	K8sAPIQPSKey = "conjur.org/k8s-api-qps"
+	// K8sAPIBurstKey is the Annotation key for setting the maximum burst of
+	// queries of the Kubernetes client
+	K8sAPIBurstKey = "conjur.org/k8s-api-burst"
 	// SecretsRefreshIntervalKey is the Annotation key for setting the interval
 	// for retrieving Conjur secrets and updating Kubernetes Secrets or
 	// application secret files if necessary.
 	SecretsRefreshIntervalKey = "conjur.org/secrets-refresh-interval"
 	// SecretsRefreshEnabledKey is the Annotation key for enabling the refresh
 	SecretsRefreshEnabledKey = "conjur.org/secrets-refresh-enabled"
+	// SecretsRefreshScheduleKey is the Annotation key for setting a cron
+	// schedule for refreshing secrets, instead of an interval
+	SecretsRefreshScheduleKey = "conjur.org/secrets-refresh-schedule"
+	// SecretsRefreshJitterKey is the Annotation key for setting the maximum
+	// random delay of secrets refreshes
+	SecretsRefreshJitterKey = "conjur.org/secrets-refresh-jitter"
 	// RemoveDeletedSecretsKey is the annotaion key for enabling removing deleted secrets
 	RemoveDeletedSecretsKey = "conjur.org/remove-deleted-secrets-enabled"
 	debugLoggingKey         = "conjur.org/debug-logging"
@@ -82,6 +120,38 @@ const (
 	jaegerCollectorUrl      = "conjur.org/jaeger-collector-url"
 	ManagedByProviderKey    = "conjur.org/managed-by-provider"
 	ServerAddressKey        = "conjur.org/server-address"
+
+	// AtomicFileUpdatesKey is the annotation key for enabling publishing all
+	// secret files of a refresh at once, through a symlinked "..data" directory
+	AtomicFileUpdatesKey = "conjur.org/atomic-file-updates-enabled"
+
+	// SecretGroupsManifestKey is the annotation key for the path of the Push
+	// to File secret groups manifest. Relative paths are relative to the
+	// templates base path.
+	SecretGroupsManifestKey = "conjur.org/secret-groups-manifest"
+
+	// AdminEndpointsEnabledKey is the annotation key for enabling the admin
+	// endpoints of the HTTP server, which roll back Push to File secret files
+	AdminEndpointsEnabledKey = "conjur.org/admin-endpoints-enabled"
+
+	// AdminServerAddressKey is the annotation key for the address of the
+	// server of the admin endpoints, which listens on the loopback interface
+	// by default
+	AdminServerAddressKey = "conjur.org/admin-server-address"
+
+	// WipeOnExitKey is the annotation key for enabling wiping the provided
+	// secrets when the Secrets Provider exits
+	WipeOnExitKey = "conjur.org/wipe-on-exit"
+
+	// WipeK8sSecretsOnExitKey is the annotation key for enabling clearing the
+	// Conjur secrets of the K8s Secrets when the Secrets Provider exits. It's
+	// separate from WipeOnExitKey since K8s Secrets may be shared by the
+	// replicas of a Deployment, which exit one at a time.
+	WipeK8sSecretsOnExitKey = "conjur.org/wipe-k8s-secrets-on-exit"
+
+	// ConfigReloadEnabledKey is the annotation key for enabling reloading the
+	// Push to File secret groups when their configuration files change
+	ConfigReloadEnabledKey = "conjur.org/config-reload-enabled"
 )
 
 // Define supported annotation keys for Secrets Provider config, as well as value restraints for each
@@ -94,9 +164,24 @@ var secretsProviderAnnotations = map[string]annotationRestraints{
 	k8sSecretsKey:             {TYPESTRING, []string{}},
 	retryCountLimitKey:        {TYPEINT, []string{}},
 	retryIntervalSecKey:       {TYPEINT, []string{}},
+	RetryBackoffKey:           {TYPESTRING, []string{"constant", "exponential"}},
+	RetryMaxIntervalSecKey:    {TYPEINT, []string{}},
+	ProvideTimeoutKey:         {TYPESTRING, []string{}},
+	RequestTimeoutKey:         {TYPESTRING, []string{}},
+	K8sAPIQPSKey:              {TYPEINT, []string{}},
+	K8sAPIBurstKey:            {TYPEINT, []string{}},
 	SecretsRefreshIntervalKey: {TYPESTRING, []string{}},
 	SecretsRefreshEnabledKey:  {TYPEBOOL, []string{}},
+	SecretsRefreshScheduleKey: {TYPESTRING, []string{}},
+	SecretsRefreshJitterKey:   {TYPESTRING, []string{}},
 	RemoveDeletedSecretsKey:   {TYPEBOOL, []string{}},
+	AtomicFileUpdatesKey:      {TYPEBOOL, []string{}},
+	SecretGroupsManifestKey:   {TYPESTRING, []string{}},
+	AdminEndpointsEnabledKey:  {TYPEBOOL, []string{}},
+	AdminServerAddressKey:     {TYPESTRING, []string{}},
+	WipeOnExitKey:             {TYPEBOOL, []string{}},
+	WipeK8sSecretsOnExitKey:   {TYPEBOOL, []string{}},
+	ConfigReloadEnabledKey:    {TYPEBOOL, []string{}},
 	debugLoggingKey:           {TYPEBOOL, []string{}},
 	logLevelKey:               {TYPESTRING, []string{"debug", "info", "warn", "error"}},
 	logTracesKey:              {TYPEBOOL, []string{}},
@@ -109,12 +194,19 @@ var secretsProviderAnnotations = map[string]annotationRestraints{
 // The values listed here will confirm hardcoded formatting, dynamic annotation content will
 // be validated when used.
 var pushToFileAnnotationPrefixes = map[string]annotationRestraints{
-	"conjur.org/conjur-secrets.":             {TYPESTRING, []string{}},
-	"conjur.org/conjur-secrets-policy-path.": {TYPESTRING, []string{}},
-	"conjur.org/secret-file-path.":           {TYPESTRING, []string{}},
-	"conjur.org/secret-file-format.":         {TYPESTRING, []string{"yaml", "json", "dotenv", "bash", "properties", "template"}},
-	"conjur.org/secret-file-permissions.":    {TYPESTRING, []string{}},
-	"conjur.org/secret-file-template.":       {TYPESTRING, []string{}},
+	"conjur.org/conjur-secrets.":                {TYPESTRING, []string{}},
+	"conjur.org/conjur-secrets-policy-path.":    {TYPESTRING, []string{}},
+	"conjur.org/conjur-secrets-yml.":            {TYPESTRING, []string{}},
+	"conjur.org/secret-file-path.":              {TYPESTRING, []string{}},
+	"conjur.org/secret-file-format.":            {TYPESTRING, []string{"yaml", "json", "dotenv", "bash", "properties", "template", "pkcs12", "jks", "directory"}},
+	"conjur.org/secret-file-permissions.":       {TYPESTRING, []string{}},
+	"conjur.org/secret-file-owner.":             {TYPESTRING, []string{}},
+	"conjur.org/secret-file-backups.":           {TYPEINT, []string{}},
+	"conjur.org/secret-file-template.":          {TYPESTRING, []string{}},
+	"conjur.org/secret-file-nesting.":           {TYPESTRING, []string{"dot", "slash"}},
+	"conjur.org/secret-file-post-hook.":         {TYPESTRING, []string{}},
+	"conjur.org/secret-file-post-hook-timeout.": {TYPESTRING, []string{}},
+	"conjur.org/secrets-refresh-interval.":      {TYPESTRING, []string{}},
 }
 
 // Define environment variables used in Secrets Provider config
@@ -236,6 +328,23 @@ func ValidateSecretsProviderSettings(envAndAnnots map[string]string) ([]error, [
 		errorList = append(errorList, err)
 	}
 
+	annotSecretsRefreshSchedule := envAndAnnots[SecretsRefreshScheduleKey]
+	err = validRefreshSchedule(annotSecretsRefreshSchedule, annotSecretsRefreshInterval, annotSecretsRefreshEnable, envAndAnnots)
+	if err != nil {
+		errorList = append(errorList, err)
+	}
+
+	err = validRefreshJitter(envAndAnnots[SecretsRefreshJitterKey], envAndAnnots)
+	if err != nil {
+		errorList = append(errorList, err)
+	}
+
+	for _, key := range []string{ProvideTimeoutKey, RequestTimeoutKey} {
+		if err := validTimeout(key, envAndAnnots[key], envAndAnnots); err != nil {
+			errorList = append(errorList, err)
+		}
+	}
+
 	// Resolve container mode (annotation takes precedence over env)
 	annotContainerMode := envAndAnnots[ContainerModeKey]
 	envContainerMode := envAndAnnots["CONTAINER_MODE"]
@@ -308,15 +417,35 @@ func NewConfig(settings map[string]string) *Config {
 	}
 	retryIntervalSec := parseIntFromStringOrDefault(retryIntervalSecStr, DefaultRetryIntervalSec, MinRetryIntervalSec)
 
+	// Retries back off exponentially up to the max interval, which is zero
+	// for retries on a constant interval
+	var retryMaxIntervalSec int
+	if settings[RetryBackoffKey] == "exponential" {
+		retryMaxIntervalSec = parseIntFromStringOrDefault(settings[RetryMaxIntervalSecKey], DefaultRetryMaxIntervalSec, 1)
+		retryMaxIntervalSec = max(retryMaxIntervalSec, retryIntervalSec)
+	}
+
 	refreshIntervalStr := settings[SecretsRefreshIntervalKey]
 	refreshEnableStr := settings[SecretsRefreshEnabledKey]
+	refreshScheduleStr := settings[SecretsRefreshScheduleKey]
 
-	if refreshIntervalStr == "" && refreshEnableStr == "true" {
+	if refreshIntervalStr == "" && refreshScheduleStr == "" && refreshEnableStr == "true" {
 		refreshIntervalStr = DefaultRefreshIntervalStr
 	}
 	// ignore errors here, if the interval string is null, zero is returned
 	refreshInterval, _ := time.ParseDuration(refreshIntervalStr)
 
+	var refreshSchedule *utils.CronSchedule
+	if refreshScheduleStr != "" {
+		refreshSchedule, _ = utils.ParseCronSchedule(refreshScheduleStr)
+	}
+	refreshJitter, _ := time.ParseDuration(settings[SecretsRefreshJitterKey])
+	provideTimeout, _ := time.ParseDuration(settings[ProvideTimeoutKey])
+	requestTimeout, _ := time.ParseDuration(settings[RequestTimeoutKey])
+	// The Kubernetes client uses the defaults of client-go unless set
+	k8sAPIQPS := parseIntFromStringOrDefault(settings[K8sAPIQPSKey], 0, 1)
+	k8sAPIBurst := parseIntFromStringOrDefault(settings[K8sAPIBurstKey], 0, 1)
+
 	sanitizeEnableStr := settings[RemoveDeletedSecretsKey]
 	if sanitizeEnableStr == "" {
 		sanitizeEnableStr = settings["REMOVE_DELETED_SECRETS"]
@@ -333,17 +462,35 @@ func NewConfig(settings map[string]string) *Config {
 		serverAddress = settings["SERVER_ADDRESS"]
 	}
 
+	adminEndpointsEnabled := parseBoolFromStringOrDefault(settings[AdminEndpointsEnabledKey], false)
+	adminServerAddress := settings[AdminServerAddressKey]
+	wipeOnExit := parseBoolFromStringOrDefault(settings[WipeOnExitKey], false)
+	wipeK8sSecretsOnExit := parseBoolFromStringOrDefault(settings[WipeK8sSecretsOnExitKey], false)
+	configReloadEnabled := parseBoolFromStringOrDefault(settings[ConfigReloadEnabledKey], false)
+
 	return &Config{
 		PodNamespace:           podNamespace,
 		RequiredK8sSecrets:     k8sSecretsArr,
 		RetryCountLimit:        retryCountLimit,
 		RetryIntervalSec:       retryIntervalSec,
+		RetryMaxIntervalSec:    retryMaxIntervalSec,
+		ProvideTimeout:         provideTimeout,
+		RequestTimeout:         requestTimeout,
+		K8sAPIQPS:              k8sAPIQPS,
+		K8sAPIBurst:            k8sAPIBurst,
 		StoreType:              storeType,
 		SecretsRefreshInterval: refreshInterval,
+		SecretsRefreshSchedule: refreshSchedule,
+		SecretsRefreshJitter:   refreshJitter,
 		SanitizeEnabled:        sanitizeEnable,
 		ContainerMode:          containerMode,
 		NamespaceAllowlist:     namespaceAllowlist,
 		ServerAddress:          serverAddress,
+		AdminEndpointsEnabled:  adminEndpointsEnabled,
+		AdminServerAddress:     adminServerAddress,
+		WipeOnExit:             wipeOnExit,
+		WipeK8sSecretsOnExit:   wipeK8sSecretsOnExit,
+		ConfigReloadEnabled:    configReloadEnabled,
 	}
 }
 
@@ -445,16 +592,30 @@ func validateStore(envStoreType string) (string, error) {
 	return storeType, err
 }
 
+// ParseRefreshInterval parses the refresh interval of Push to File secret
+// groups and labeled K8s Secrets, set with the
+// "conjur.org/secrets-refresh-interval" annotation. An empty interval is
+// parsed as zero, for which the global refresh interval is used.
+func ParseRefreshInterval(interval string) (time.Duration, error) {
+	if len(interval) == 0 {
+		return 0, nil
+	}
+
+	duration, err := time.ParseDuration(interval)
+	if err != nil {
+		return 0, err
+	}
+	if duration < MinRefreshInterval {
+		return 0, fmt.Errorf("refresh interval must be at least one second, got %q", interval)
+	}
+	return duration, nil
+}
+
 func validRefreshInterval(intervalStr string, enableStr string, envAndAnnots map[string]string) error {
 
 	var err error
 
-	containerMode := envAndAnnots[ContainerModeKey]
-	envContainerMode := envAndAnnots["CONTAINER_MODE"]
-	if containerMode == "" {
-		containerMode = envContainerMode
-	}
-
+	containerMode := refreshContainerMode(envAndAnnots)
 	if intervalStr != "" || enableStr != "" {
 		if containerMode != "sidecar" && containerMode != "standalone" {
 			return fmt.Errorf(messages.CSPFK051E, "Secrets refresh is enabled while container mode is set to", containerMode)
@@ -479,6 +640,78 @@ func validRefreshInterval(intervalStr string, enableStr string, envAndAnnots map
 	return err
 }
 
+func validRefreshSchedule(scheduleStr string, intervalStr string, enableStr string, envAndAnnots map[string]string) error {
+	if scheduleStr == "" {
+		return nil
+	}
+
+	containerMode := refreshContainerMode(envAndAnnots)
+	if containerMode != "sidecar" && containerMode != "standalone" {
+		return fmt.Errorf(messages.CSPFK051E, "Secrets refresh is enabled while container mode is set to", containerMode)
+	}
+	if enabled, _ := strconv.ParseBool(enableStr); !enabled && enableStr != "" {
+		return fmt.Errorf(messages.CSPFK116E, scheduleStr, "Secrets refresh schedule set to value while enable is false")
+	}
+	if intervalStr != "" {
+		return fmt.Errorf(messages.CSPFK116E, scheduleStr, "Secrets refresh schedule set together with a refresh interval")
+	}
+	if _, err := utils.ParseCronSchedule(scheduleStr); err != nil {
+		return fmt.Errorf(messages.CSPFK116E, scheduleStr, err.Error())
+	}
+	return nil
+}
+
+func validRefreshJitter(jitterStr string, envAndAnnots map[string]string) error {
+	if jitterStr == "" {
+		return nil
+	}
+
+	containerMode := refreshContainerMode(envAndAnnots)
+	if containerMode != "sidecar" && containerMode != "standalone" {
+		return fmt.Errorf(messages.CSPFK051E, "Secrets refresh jitter is set while container mode is set to", containerMode)
+	}
+	jitter, err := time.ParseDuration(jitterStr)
+	if err != nil {
+		return fmt.Errorf(messages.CSPFK117E, jitterStr, err.Error())
+	}
+	if jitter < 0 {
+		return fmt.Errorf(messages.CSPFK117E, jitterStr, "Secrets refresh jitter must not be negative")
+	}
+	return nil
+}
+
+// validTimeout validates the provide timeout and request timeout annotations.
+// The provide timeout is only supported in init and application modes, where
+// the Secrets Provider exits once secrets are provided.
+func validTimeout(key string, timeoutStr string, envAndAnnots map[string]string) error {
+	if timeoutStr == "" {
+		return nil
+	}
+
+	timeout, err := time.ParseDuration(timeoutStr)
+	if err != nil {
+		return fmt.Errorf(messages.CSPFK120E, key, timeoutStr, err.Error())
+	}
+	if timeout <= 0 {
+		return fmt.Errorf(messages.CSPFK120E, key, timeoutStr, "Timeout must be positive")
+	}
+	containerMode := refreshContainerMode(envAndAnnots)
+	if key == ProvideTimeoutKey && (containerMode == "sidecar" || containerMode == "standalone") {
+		return fmt.Errorf(messages.CSPFK120E, key, timeoutStr, "Provide timeout is only supported in init and application modes")
+	}
+	return nil
+}
+
+// refreshContainerMode returns the container mode, from the annotation or
+// the environment variable
+func refreshContainerMode(envAndAnnots map[string]string) string {
+	containerMode := envAndAnnots[ContainerModeKey]
+	if containerMode == "" {
+		containerMode = envAndAnnots["CONTAINER_MODE"]
+	}
+	return containerMode
+}
+
 // parseK8sSecretsList parses Kubernetes secrets from either annotation format (YAML list)
 // or environment variable format (comma-separated), and filters out empty strings.
 func parseK8sSecretsList(settings map[string]string) []string {
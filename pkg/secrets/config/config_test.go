@@ -4,10 +4,12 @@ import (
 	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 
 	"github.com/cyberark/secrets-provider-for-k8s/pkg/log/messages"
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/utils"
 )
 
 type errorAssertFunc func(*testing.T, []error, []error)
@@ -36,6 +38,14 @@ func assertGoodMap(expected map[string]string) func(*testing.T, map[string]strin
 	}
 }
 
+func mustParseCronSchedule(expr string) *utils.CronSchedule {
+	schedule, err := utils.ParseCronSchedule(expr)
+	if err != nil {
+		panic(err)
+	}
+	return schedule
+}
+
 func assertGoodConfig(expected *Config) func(*testing.T, *Config) {
 	return func(t *testing.T, result *Config) {
 		assert.Equal(t, expected, result)
@@ -471,6 +481,109 @@ var validateSecretsProviderSettingsTestCases = []validateSecretsProviderSettings
 		},
 		assert: assertEmptyErrorList(),
 	},
+	{
+		description: "if refresh schedule is valid in sidecar mode, no errors are returned",
+		envAndAnnots: map[string]string{
+			"MY_POD_NAMESPACE":        "test-namespace",
+			SecretsDestinationKey:     "file",
+			SecretsRefreshScheduleKey: "5 * * * *",
+			SecretsRefreshJitterKey:   "30s",
+			ContainerModeKey:          "sidecar",
+		},
+		assert: assertEmptyErrorList(),
+	},
+	{
+		description: "if refresh schedule is malformed, an error is returned",
+		envAndAnnots: map[string]string{
+			"MY_POD_NAMESPACE":        "test-namespace",
+			SecretsRefreshScheduleKey: "5 * * *",
+			ContainerModeKey:          "sidecar",
+		},
+		assert: assertErrorInList(fmt.Errorf(messages.CSPFK116E, "5 * * *", "cron expression must have 5 fields, got 4")),
+	},
+	{
+		description: "if refresh schedule and interval are both set, an error is returned",
+		envAndAnnots: map[string]string{
+			"MY_POD_NAMESPACE":        "test-namespace",
+			SecretsRefreshScheduleKey: "5 * * * *",
+			SecretsRefreshIntervalKey: "5m",
+			ContainerModeKey:          "sidecar",
+		},
+		assert: assertErrorInList(fmt.Errorf(messages.CSPFK116E, "5 * * * *", "Secrets refresh schedule set together with a refresh interval")),
+	},
+	{
+		description: "if refresh schedule is set and enable is false, an error is returned",
+		envAndAnnots: map[string]string{
+			"MY_POD_NAMESPACE":        "test-namespace",
+			SecretsRefreshScheduleKey: "5 * * * *",
+			SecretsRefreshEnabledKey:  "false",
+			ContainerModeKey:          "sidecar",
+		},
+		assert: assertErrorInList(fmt.Errorf(messages.CSPFK116E, "5 * * * *", "Secrets refresh schedule set to value while enable is false")),
+	},
+	{
+		description: "if refresh schedule is set and container mode is init, an error is returned",
+		envAndAnnots: map[string]string{
+			"MY_POD_NAMESPACE":        "test-namespace",
+			SecretsRefreshScheduleKey: "5 * * * *",
+			ContainerModeKey:          "init",
+		},
+		assert: assertErrorInList(fmt.Errorf(messages.CSPFK051E, "Secrets refresh is enabled while container mode is set to", "init")),
+	},
+	{
+		description: "if refresh jitter is negative, an error is returned",
+		envAndAnnots: map[string]string{
+			"MY_POD_NAMESPACE":      "test-namespace",
+			SecretsRefreshJitterKey: "-5s",
+			ContainerModeKey:        "sidecar",
+		},
+		assert: assertErrorInList(fmt.Errorf(messages.CSPFK117E, "-5s", "Secrets refresh jitter must not be negative")),
+	},
+	{
+		description: "if refresh jitter is set and container mode is init, an error is returned",
+		envAndAnnots: map[string]string{
+			"MY_POD_NAMESPACE":      "test-namespace",
+			SecretsRefreshJitterKey: "5s",
+			ContainerModeKey:        "init",
+		},
+		assert: assertErrorInList(fmt.Errorf(messages.CSPFK051E, "Secrets refresh jitter is set while container mode is set to", "init")),
+	},
+	{
+		description: "if provide timeout is malformed, an error is returned",
+		envAndAnnots: map[string]string{
+			"MY_POD_NAMESPACE": "test-namespace",
+			ProvideTimeoutKey:  "5",
+		},
+		assert: assertErrorInList(fmt.Errorf(messages.CSPFK120E, ProvideTimeoutKey, "5", `time: missing unit in duration "5"`)),
+	},
+	{
+		description: "if request timeout is not positive, an error is returned",
+		envAndAnnots: map[string]string{
+			"MY_POD_NAMESPACE": "test-namespace",
+			RequestTimeoutKey:  "0s",
+		},
+		assert: assertErrorInList(fmt.Errorf(messages.CSPFK120E, RequestTimeoutKey, "0s", "Timeout must be positive")),
+	},
+	{
+		description: "if provide timeout is set and container mode is sidecar, an error is returned",
+		envAndAnnots: map[string]string{
+			"MY_POD_NAMESPACE": "test-namespace",
+			ProvideTimeoutKey:  "2m",
+			ContainerModeKey:   "sidecar",
+		},
+		assert: assertErrorInList(fmt.Errorf(messages.CSPFK120E, ProvideTimeoutKey, "2m", "Provide timeout is only supported in init and application modes")),
+	},
+	{
+		description: "request timeout is valid in sidecar mode",
+		envAndAnnots: map[string]string{
+			"MY_POD_NAMESPACE":        "test-namespace",
+			SecretsDestinationKey:     "file",
+			SecretsRefreshIntervalKey: "1m",
+			RequestTimeoutKey:         "10s",
+			ContainerModeKey:          "sidecar",
+		},
+		assert: assertEmptyErrorList(),
+	},
 	{
 		description: "standalone mode with namespace allowlist via annotation is valid",
 		envAndAnnots: map[string]string{
@@ -579,6 +692,175 @@ var newConfigTestCases = []newConfigTestCase{
 			SanitizeEnabled:    false,
 		}),
 	},
+	{
+		description: "admin endpoints can be enabled",
+		settings: map[string]string{
+			"MY_POD_NAMESPACE":       "test-namespace",
+			SecretsDestinationKey:    "file",
+			RemoveDeletedSecretsKey:  "false",
+			AdminEndpointsEnabledKey: "true",
+			AdminServerAddressKey:    "127.0.0.1:9091",
+		},
+		assert: assertGoodConfig(&Config{
+			PodNamespace:          "test-namespace",
+			StoreType:             "file",
+			RequiredK8sSecrets:    []string{},
+			RetryCountLimit:       DefaultRetryCountLimit,
+			RetryIntervalSec:      DefaultRetryIntervalSec,
+			SanitizeEnabled:       false,
+			AdminEndpointsEnabled: true,
+			AdminServerAddress:    "127.0.0.1:9091",
+		}),
+	},
+	{
+		description: "secrets can be wiped on exit",
+		settings: map[string]string{
+			"MY_POD_NAMESPACE":      "test-namespace",
+			SecretsDestinationKey:   "file",
+			RemoveDeletedSecretsKey: "false",
+			WipeOnExitKey:           "true",
+		},
+		assert: assertGoodConfig(&Config{
+			PodNamespace:       "test-namespace",
+			StoreType:          "file",
+			RequiredK8sSecrets: []string{},
+			RetryCountLimit:    DefaultRetryCountLimit,
+			RetryIntervalSec:   DefaultRetryIntervalSec,
+			SanitizeEnabled:    false,
+			WipeOnExit:         true,
+		}),
+	},
+	{
+		description: "K8s Secrets can be wiped on exit",
+		settings: map[string]string{
+			"MY_POD_NAMESPACE":      "test-namespace",
+			SecretsDestinationKey:   "k8s_secrets",
+			"K8S_SECRETS":           "secret-1",
+			WipeK8sSecretsOnExitKey: "true",
+		},
+		assert: assertGoodConfig(&Config{
+			PodNamespace:         "test-namespace",
+			StoreType:            "k8s_secrets",
+			RequiredK8sSecrets:   []string{"secret-1"},
+			RetryCountLimit:      DefaultRetryCountLimit,
+			RetryIntervalSec:     DefaultRetryIntervalSec,
+			SanitizeEnabled:      DefaultSanitizeEnabled,
+			WipeK8sSecretsOnExit: true,
+		}),
+	},
+	{
+		description: "config reloads can be enabled",
+		settings: map[string]string{
+			"MY_POD_NAMESPACE":      "test-namespace",
+			SecretsDestinationKey:   "file",
+			RemoveDeletedSecretsKey: "false",
+			ConfigReloadEnabledKey:  "true",
+		},
+		assert: assertGoodConfig(&Config{
+			PodNamespace:        "test-namespace",
+			StoreType:           "file",
+			RequiredK8sSecrets:  []string{},
+			RetryCountLimit:     DefaultRetryCountLimit,
+			RetryIntervalSec:    DefaultRetryIntervalSec,
+			SanitizeEnabled:     false,
+			ConfigReloadEnabled: true,
+		}),
+	},
+	{
+		description: "retries can back off exponentially up to the default max interval",
+		settings: map[string]string{
+			"MY_POD_NAMESPACE":      "test-namespace",
+			SecretsDestinationKey:   "file",
+			RemoveDeletedSecretsKey: "false",
+			RetryBackoffKey:         "exponential",
+		},
+		assert: assertGoodConfig(&Config{
+			PodNamespace:        "test-namespace",
+			StoreType:           "file",
+			RequiredK8sSecrets:  []string{},
+			RetryCountLimit:     DefaultRetryCountLimit,
+			RetryIntervalSec:    DefaultRetryIntervalSec,
+			RetryMaxIntervalSec: DefaultRetryMaxIntervalSec,
+			SanitizeEnabled:     false,
+		}),
+	},
+	{
+		description: "the max interval of exponential retries is at least the retry interval",
+		settings: map[string]string{
+			"MY_POD_NAMESPACE":      "test-namespace",
+			SecretsDestinationKey:   "file",
+			RemoveDeletedSecretsKey: "false",
+			retryIntervalSecKey:     "20",
+			RetryBackoffKey:         "exponential",
+			RetryMaxIntervalSecKey:  "10",
+		},
+		assert: assertGoodConfig(&Config{
+			PodNamespace:        "test-namespace",
+			StoreType:           "file",
+			RequiredK8sSecrets:  []string{},
+			RetryCountLimit:     DefaultRetryCountLimit,
+			RetryIntervalSec:    20,
+			RetryMaxIntervalSec: 20,
+			SanitizeEnabled:     false,
+		}),
+	},
+	{
+		description: "the max interval is ignored for constant retries",
+		settings: map[string]string{
+			"MY_POD_NAMESPACE":      "test-namespace",
+			SecretsDestinationKey:   "file",
+			RemoveDeletedSecretsKey: "false",
+			RetryBackoffKey:         "constant",
+			RetryMaxIntervalSecKey:  "30",
+		},
+		assert: assertGoodConfig(&Config{
+			PodNamespace:       "test-namespace",
+			StoreType:          "file",
+			RequiredK8sSecrets: []string{},
+			RetryCountLimit:    DefaultRetryCountLimit,
+			RetryIntervalSec:   DefaultRetryIntervalSec,
+			SanitizeEnabled:    false,
+		}),
+	},
+	{
+		description: "provide and request timeouts are parsed as durations",
+		settings: map[string]string{
+			"MY_POD_NAMESPACE":      "test-namespace",
+			SecretsDestinationKey:   "file",
+			RemoveDeletedSecretsKey: "false",
+			ProvideTimeoutKey:       "2m",
+			RequestTimeoutKey:       "15s",
+		},
+		assert: assertGoodConfig(&Config{
+			PodNamespace:       "test-namespace",
+			StoreType:          "file",
+			RequiredK8sSecrets: []string{},
+			RetryCountLimit:    DefaultRetryCountLimit,
+			RetryIntervalSec:   DefaultRetryIntervalSec,
+			SanitizeEnabled:    false,
+			ProvideTimeout:     2 * time.Minute,
+			RequestTimeout:     15 * time.Second,
+		}),
+	},
+	{
+		description: "Kubernetes client QPS and burst are parsed, and ignored when not positive",
+		settings: map[string]string{
+			"MY_POD_NAMESPACE":    "test-namespace",
+			SecretsDestinationKey: "k8s_secrets",
+			"K8S_SECRETS":         "secret-1",
+			K8sAPIQPSKey:          "20",
+			K8sAPIBurstKey:        "0",
+		},
+		assert: assertGoodConfig(&Config{
+			PodNamespace:       "test-namespace",
+			StoreType:          "k8s_secrets",
+			RequiredK8sSecrets: []string{"secret-1"},
+			RetryCountLimit:    DefaultRetryCountLimit,
+			RetryIntervalSec:   DefaultRetryIntervalSec,
+			SanitizeEnabled:    DefaultSanitizeEnabled,
+			K8sAPIQPS:          20,
+		}),
+	},
 	{
 		description: "RetryCountLimit can be set to -1 (retry indefinitely)",
 		settings: map[string]string{
@@ -654,6 +936,26 @@ var newConfigTestCases = []newConfigTestCase{
 			SanitizeEnabled:        DefaultSanitizeEnabled,
 		}),
 	},
+	{
+		description: "a refresh schedule and jitter return a Config without a refresh interval",
+		settings: map[string]string{
+			"MY_POD_NAMESPACE":        "test-namespace",
+			SecretsDestinationKey:     "file",
+			SecretsRefreshEnabledKey:  "true",
+			SecretsRefreshScheduleKey: "5 * * * *",
+			SecretsRefreshJitterKey:   "30s",
+		},
+		assert: assertGoodConfig(&Config{
+			PodNamespace:           "test-namespace",
+			StoreType:              "file",
+			RequiredK8sSecrets:     []string{},
+			RetryCountLimit:        5,
+			RetryIntervalSec:       1,
+			SecretsRefreshSchedule: mustParseCronSchedule("5 * * * *"),
+			SecretsRefreshJitter:   30 * time.Second,
+			SanitizeEnabled:        DefaultSanitizeEnabled,
+		}),
+	},
 	{
 		description: "annotation-based k8s secrets with blank lines filters out empty strings",
 		settings: map[string]string{
@@ -0,0 +1,85 @@
+package filetemplates
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// ExpandJSONSecret reads the value of a secret with the "json" content type,
+// which must hold a JSON object. With a field, the value of that field is
+// returned as a single secret named after the alias. Without a field, each
+// key of the object is returned as a secret named after the key, in the order
+// of the object. String values are returned as is, and other values as their
+// compact JSON text. Errors never include the secret value.
+func ExpandJSONSecret(alias string, field string, value []byte) ([]*Secret, error) {
+	notObjectErr := fmt.Errorf("secret value for alias %q is not a JSON object", alias)
+
+	dec := json.NewDecoder(bytes.NewReader(value))
+	if tok, err := dec.Token(); err != nil || tok != json.Delim('{') {
+		return nil, notObjectErr
+	}
+
+	var secrets []*Secret
+	// Duplicate keys keep their first position, and the last value wins as
+	// it does when decoding into a map
+	indexByKey := map[string]int{}
+	for dec.More() {
+		tok, err := dec.Token()
+		if err != nil {
+			return nil, notObjectErr
+		}
+		key, _ := tok.(string)
+
+		var raw json.RawMessage
+		if err := dec.Decode(&raw); err != nil {
+			return nil, notObjectErr
+		}
+		if field != "" && key != field {
+			continue
+		}
+
+		secret := &Secret{Alias: key, Value: jsonValueText(raw)}
+		if field != "" {
+			secret.Alias = alias
+		}
+		if i, ok := indexByKey[key]; ok {
+			secrets[i] = secret
+			continue
+		}
+		indexByKey[key] = len(secrets)
+		secrets = append(secrets, secret)
+	}
+
+	// The object must be closed, and nothing may follow it
+	if _, err := dec.Token(); err != nil {
+		return nil, notObjectErr
+	}
+	if _, err := dec.Token(); err != io.EOF {
+		return nil, notObjectErr
+	}
+
+	if field != "" && len(secrets) == 0 {
+		return nil, fmt.Errorf("field %q not present in JSON secret value for alias %q", field, alias)
+	}
+	return secrets, nil
+}
+
+// jsonValueText returns a JSON string as is, a null as an empty string, and
+// any other JSON value as its compact JSON text
+func jsonValueText(raw json.RawMessage) string {
+	if bytes.Equal(raw, []byte("null")) {
+		return ""
+	}
+	var str string
+	if err := json.Unmarshal(raw, &str); err == nil {
+		return str
+	}
+
+	buf := &bytes.Buffer{}
+	if err := json.Compact(buf, raw); err != nil {
+		return string(raw)
+	}
+	return buf.String()
+}
@@ -0,0 +1,96 @@
+package filetemplates
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpandJSONSecret(t *testing.T) {
+	credentials := []byte(`{
+  "username": "admin",
+  "password": "p@ss\"word\n",
+  "port": 5432,
+  "options": {"ssl": true, "hosts": ["a", "b"]},
+  "comment": null
+}`)
+
+	testCases := []struct {
+		description string
+		field       string
+		value       []byte
+		expected    []*Secret
+		errMsg      string
+	}{
+		{
+			description: "all keys in object order",
+			value:       credentials,
+			expected: []*Secret{
+				{Alias: "username", Value: "admin"},
+				{Alias: "password", Value: "p@ss\"word\n"},
+				{Alias: "port", Value: "5432"},
+				{Alias: "options", Value: `{"ssl":true,"hosts":["a","b"]}`},
+				{Alias: "comment", Value: ""},
+			},
+		},
+		{
+			description: "string field",
+			field:       "password",
+			value:       credentials,
+			expected:    []*Secret{{Alias: "db-password", Value: "p@ss\"word\n"}},
+		},
+		{
+			description: "object field",
+			field:       "options",
+			value:       credentials,
+			expected:    []*Secret{{Alias: "db-password", Value: `{"ssl":true,"hosts":["a","b"]}`}},
+		},
+		{
+			description: "duplicate keys",
+			value:       []byte(`{"a": "1", "b": "2", "a": "3"}`),
+			expected:    []*Secret{{Alias: "a", Value: "3"}, {Alias: "b", Value: "2"}},
+		},
+		{
+			description: "empty object",
+			value:       []byte(`{}`),
+		},
+		{
+			description: "missing field",
+			field:       "token",
+			value:       credentials,
+			errMsg:      `field "token" not present in JSON secret value for alias "db-password"`,
+		},
+		{
+			description: "array",
+			value:       []byte(`["admin"]`),
+			errMsg:      `secret value for alias "db-password" is not a JSON object`,
+		},
+		{
+			description: "plain text",
+			value:       []byte(`s3cr3t`),
+			errMsg:      `secret value for alias "db-password" is not a JSON object`,
+		},
+		{
+			description: "truncated object",
+			value:       []byte(`{"password": "s3cr3t"`),
+			errMsg:      `secret value for alias "db-password" is not a JSON object`,
+		},
+		{
+			description: "trailing data",
+			value:       []byte(`{"password": "s3cr3t"} s3cr3t`),
+			errMsg:      `secret value for alias "db-password" is not a JSON object`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			secrets, err := ExpandJSONSecret("db-password", tc.field, tc.value)
+			if tc.errMsg != "" {
+				assert.EqualError(t, err, tc.errMsg)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, secrets)
+		})
+	}
+}
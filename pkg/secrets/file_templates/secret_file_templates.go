@@ -28,7 +28,18 @@ func RenderFile(tpl *template.Template, tplData TemplateData) (*bytes.Buffer, er
 }
 
 func GetTemplate(name string, secretsMap map[string]*Secret) *template.Template {
+	return newTemplate(name, secretsMap, false)
+}
+
+// GetValidationTemplate returns a template for the first-pass validation of
+// a file template, which is rendered with placeholder secret values. The
+// template functions that parse secret values, such as the PEM helpers,
+// return them unchanged.
+func GetValidationTemplate(name string, secretsMap map[string]*Secret) *template.Template {
+	return newTemplate(name, secretsMap, true)
+}
 
+func newTemplate(name string, secretsMap map[string]*Secret, validating bool) *template.Template {
 	return template.New(name).Funcs(template.FuncMap{
 		// secret is a custom utility function for streamlined access to secret values.
 		// It panics for secrets aliases not specified on the group.
@@ -44,5 +55,16 @@ func GetTemplate(name string, secretsMap map[string]*Secret) *template.Template
 		},
 		"b64enc": b64encTemplateFunc,
 		"b64dec": b64decTemplateFunc,
+		// Quoting for values sourced by shells and dotenv parsers
+		"shellQuote":  shellQuoteTemplateFunc,
+		"dotenvQuote": dotenvQuoteTemplateFunc,
+		// PEM and X.509 helpers for secrets holding key and certificate bundles
+		"pemCerts":     skipWhenValidating(validating, pemCertsTemplateFunc),
+		"pemLeaf":      skipWhenValidating(validating, pemLeafTemplateFunc),
+		"pemChain":     skipWhenValidating(validating, pemChainTemplateFunc),
+		"pemKey":       skipWhenValidating(validating, pemKeyTemplateFunc),
+		"certNotAfter": skipWhenValidating(validating, certNotAfterTemplateFunc),
+		"certSubject":  skipWhenValidating(validating, certSubjectTemplateFunc),
+		"pkcs1ToPkcs8": skipWhenValidating(validating, pkcs1ToPkcs8TemplateFunc),
 	})
 }
@@ -2,6 +2,7 @@ package filetemplates
 
 import (
 	"fmt"
+	"strconv"
 	"strings"
 
 	"github.com/cyberark/conjur-authn-k8s-client/pkg/log"
@@ -20,6 +21,26 @@ type SecretSpec struct {
 	Alias       string
 	Path        string
 	ContentType string
+	// Field selects a single field of a secret with the "json" content type.
+	// Without a field, each key of the JSON object becomes a separate secret.
+	Field string
+	// Optional secrets that are missing or can't be retrieved are replaced
+	// by their Default, or left out when there's no Default
+	Optional bool
+	Default  *string
+	// Literal secrets hold their value instead of a Conjur variable path,
+	// like the "!str" entries of a Summon secrets.yml
+	Literal *string
+	// AsFile secrets are written to a file of their own, and are replaced by
+	// the path of that file, like the "!var:file" entries of a Summon
+	// secrets.yml
+	AsFile bool
+}
+
+// ExpandsJSON returns whether the spec expands into a secret for each key of
+// a JSON object, so that its secrets are only known once fetched
+func (t SecretSpec) ExpandsJSON() bool {
+	return t.ContentType == "json" && t.Field == ""
 }
 
 // SecretGroup incorporates common information about a secret group
@@ -36,6 +57,18 @@ func (t SecretSpec) MarshalYAML() (interface{}, error) {
 }
 
 const invalidSecretSpecErr = `expected a "string (path)" or "single entry map of string to string (alias to path)" on line %d`
+const invalidOptionalErr = `expected "true" or "false" for "optional" on line %d`
+const requiredDefaultErr = `"default" can't be set on a secret spec with "optional: false" on line %d`
+const missingAliasErr = `expected an alias to path entry on line %d, the secret spec options "content-type", "field", "optional" and "default" can't be used as aliases`
+
+// secretSpecOptions are the keys of a secret spec map that set its options
+// rather than an alias
+var secretSpecOptions = map[string]struct{}{
+	"content-type": {},
+	"field":        {},
+	"optional":     {},
+	"default":      {},
+}
 
 // UnmarshalYAML is a custom unmarshaller for SecretSpec that allows us to
 // unmarshal from different YAML node representations i.e. literal string or
@@ -77,13 +110,38 @@ func (t *SecretSpec) unmarshalFromMap(node *yaml.Node) error {
 		return fmt.Errorf(invalidSecretSpecErr, node.Line)
 	}
 
+	// Mapping node without an alias. An alias named like an option would
+	// silently set that option instead, so the spec is rejected rather than
+	// left without a path.
+	hasAlias := false
+	for k := range mapValue {
+		if _, isOption := secretSpecOptions[k]; !isOption {
+			hasAlias = true
+		}
+	}
+	if !hasAlias {
+		return fmt.Errorf(missingAliasErr, node.Line)
+	}
+
 	// Mapping node but has multiple entries
 
 	t.ContentType = "text"
 	count := 0
+	optionalSet := false
 	for k, v := range mapValue {
 		if k == "content-type" {
 			t.ContentType = v
+		} else if k == "field" {
+			t.Field = v
+		} else if k == "optional" {
+			t.Optional, err = strconv.ParseBool(v)
+			if err != nil {
+				return fmt.Errorf(invalidOptionalErr, node.Line)
+			}
+			optionalSet = true
+		} else if k == "default" {
+			defaultValue := v
+			t.Default = &defaultValue
 		} else {
 			count = count + 1
 			if count > 1 {
@@ -93,6 +151,14 @@ func (t *SecretSpec) unmarshalFromMap(node *yaml.Node) error {
 			t.Alias = k
 		}
 	}
+
+	// A default makes the secret optional
+	if t.Default != nil {
+		if optionalSet && !t.Optional {
+			return fmt.Errorf(requiredDefaultErr, node.Line)
+		}
+		t.Optional = true
+	}
 	return nil
 }
 
@@ -123,6 +189,7 @@ func NewSecretSpecs(raw []byte) ([]SecretSpec, error) {
 func ValidateSecretPathsAndContents(secretSpecs []SecretSpec, groupName string) []error {
 
 	errors := ValidateSecretPaths(secretSpecs, groupName)
+	errors = append(errors, ValidateSecretFields(secretSpecs, groupName)...)
 
 	errs := ValidateSecretContents(secretSpecs, groupName)
 	if errs != nil {
@@ -137,6 +204,10 @@ func ValidateSecretPathsAndContents(secretSpecs []SecretSpec, groupName string)
 func ValidateSecretPaths(secretSpecs []SecretSpec, groupName string) []error {
 	var errors []error
 	for _, secretSpec := range secretSpecs {
+		// Literal secrets aren't retrieved from Conjur
+		if secretSpec.Literal != nil {
+			continue
+		}
 		if err := validateSecretPath(secretSpec.Path, groupName); err != nil {
 			errors = append(errors, err)
 		}
@@ -154,8 +225,31 @@ func ValidateSecretContents(secretSpecs []SecretSpec, groupName string) []error
 	return errors
 }
 
+// ValidateSecretFields ensures that fields are only selected from secrets
+// with the "json" content type. Ignoring the field would provide the whole
+// JSON object where a single value is expected. JSON secrets without a field
+// can't have a default, since their keys are only known from their value.
+func ValidateSecretFields(secretSpecs []SecretSpec, groupName string) []error {
+	var errors []error
+	for _, secretSpec := range secretSpecs {
+		if secretSpec.Field != "" && secretSpec.ContentType != "json" {
+			errors = append(errors, fmt.Errorf(
+				"Secret group %s: field '%s' of secret alias '%s' requires the 'json' content-type",
+				groupName, secretSpec.Field, secretSpec.Alias,
+			))
+		}
+		if secretSpec.Default != nil && secretSpec.ExpandsJSON() {
+			errors = append(errors, fmt.Errorf(
+				"Secret group %s: secret alias '%s' with the 'json' content-type requires a field to have a default",
+				groupName, secretSpec.Alias,
+			))
+		}
+	}
+	return errors
+}
+
 func validateSecretContent(content, groupName string) error {
-	if content == "text" || content == "base64" {
+	if content == "text" || content == "base64" || content == "json" {
 		return nil
 	} else {
 		return fmt.Errorf(messages.CSPFK065E, groupName, content)
@@ -5,6 +5,7 @@ import (
 	"strings"
 	"testing"
 
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/testutils"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -141,6 +142,114 @@ another-password: dev/openshift/password
 			},
 		),
 	},
+	{
+		description: "valid secret content-type json with field",
+		contents: `
+- db-password: dev/openshift/db-credentials
+  content-type: json
+  field: password
+- api-credentials: dev/openshift/api-credentials
+  content-type: json
+`,
+		assert: assertGoodSecretSpecs(
+			[]SecretSpec{
+				{
+					Alias:       "db-password",
+					Path:        "dev/openshift/db-credentials",
+					ContentType: "json",
+					Field:       "password",
+				},
+				{
+					Alias:       "api-credentials",
+					Path:        "dev/openshift/api-credentials",
+					ContentType: "json",
+				},
+			},
+		),
+	},
+	{
+		description: "optional secrets with and without default",
+		contents: `
+- feature-flag: dev/openshift/feature-flag
+  optional: true
+- log-level: dev/openshift/log-level
+  default: info
+- empty: dev/openshift/empty
+  optional: "true"
+  default: ""
+- required: dev/openshift/required
+  optional: false
+`,
+		assert: assertGoodSecretSpecs(
+			[]SecretSpec{
+				{
+					Alias:       "feature-flag",
+					Path:        "dev/openshift/feature-flag",
+					ContentType: "text",
+					Optional:    true,
+				},
+				{
+					Alias:       "log-level",
+					Path:        "dev/openshift/log-level",
+					ContentType: "text",
+					Optional:    true,
+					Default:     testutils.StringPtr("info"),
+				},
+				{
+					Alias:       "empty",
+					Path:        "dev/openshift/empty",
+					ContentType: "text",
+					Optional:    true,
+					Default:     testutils.StringPtr(""),
+				},
+				{
+					Alias:       "required",
+					Path:        "dev/openshift/required",
+					ContentType: "text",
+				},
+			},
+		),
+	},
+	{
+		description: "invalid optional value",
+		contents: `
+- feature-flag: dev/openshift/feature-flag
+  optional: maybe
+`,
+		assert: func(t *testing.T, result []SecretSpec, err error) {
+			assert.ErrorContains(t, err, `expected "true" or "false" for "optional" on line 2`)
+		},
+	},
+	{
+		description: "default on a required secret",
+		contents: `
+- log-level: dev/openshift/log-level
+  optional: false
+  default: info
+`,
+		assert: func(t *testing.T, result []SecretSpec, err error) {
+			assert.ErrorContains(t, err, `"default" can't be set on a secret spec with "optional: false" on line 2`)
+		},
+	},
+	{
+		description: "alias named like an option",
+		contents: `
+- default: dev/openshift/default
+  optional: true
+`,
+		assert: func(t *testing.T, result []SecretSpec, err error) {
+			assert.ErrorContains(t, err, `expected an alias to path entry on line 2, the secret spec options "content-type", "field", "optional" and "default" can't be used as aliases`)
+		},
+	},
+	{
+		description: "only an alias named like an option",
+		contents: `
+- default: dev/openshift/default
+`,
+		assert: func(t *testing.T, result []SecretSpec, err error) {
+			assert.ErrorContains(t, err, `expected an alias to path entry on line 2`)
+		},
+	},
 	{
 		description: "fetch all",
 		contents:    "*",
@@ -302,6 +411,12 @@ func TestValidateSecretSpecContents(t *testing.T) {
 			"base65",
 			assertErrorsContain("is invalid"),
 		},
+		{
+			"valid Conjur paths",
+			"json",
+			"base64",
+			assertNoErrors(),
+		},
 	}
 
 	for _, tc := range testCases {
@@ -318,3 +433,22 @@ func TestValidateSecretSpecContents(t *testing.T) {
 		tc.assert(t, err, tc.description)
 	}
 }
+
+func TestValidateSecretSpecFields(t *testing.T) {
+	errors := ValidateSecretFields([]SecretSpec{
+		{Alias: "username", Path: validConjurPath1, ContentType: "json", Field: "username"},
+		{Alias: "credentials", Path: validConjurPath1, ContentType: "json"},
+		{Alias: "password", Path: validConjurPath2, ContentType: "text", Field: "password"},
+	}, "some-group-name")
+
+	assert.Len(t, errors, 1)
+	assert.EqualError(t, errors[0], "Secret group some-group-name: field 'password' of secret alias 'password' requires the 'json' content-type")
+
+	errors = ValidateSecretFields([]SecretSpec{
+		{Alias: "username", Path: validConjurPath1, ContentType: "json", Field: "username", Optional: true, Default: testutils.StringPtr("admin")},
+		{Alias: "credentials", Path: validConjurPath1, ContentType: "json", Optional: true, Default: testutils.StringPtr("{}")},
+	}, "some-group-name")
+
+	assert.Len(t, errors, 1)
+	assert.EqualError(t, errors[0], "Secret group some-group-name: secret alias 'credentials' with the 'json' content-type requires a field to have a default")
+}
@@ -1,11 +1,29 @@
 package filetemplates
 
-import "encoding/base64"
+import (
+	"bytes"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"strings"
+	"time"
+)
 
 // Define template functions that don't need access to secrets in this file
 // to keep the push_to_writer.go file cleaner with only the functions that
 // require access to secrets.
 
+// RedactedSecretValue is the placeholder secret value used for the template
+// "first-pass", which validates templates before any secrets are retrieved.
+// Templates for the first-pass are created with GetValidationTemplate.
+const RedactedSecretValue = "REDACTED"
+
+const (
+	pemCertificateType = "CERTIFICATE"
+	pemPrivateKeyType  = "PRIVATE KEY"
+	pemRSAKeyType      = "RSA PRIVATE KEY"
+)
+
 // b64enc is a custom template function for performing a base64 encode
 // on a secret value.
 func b64encTemplateFunc(value string) string {
@@ -24,3 +42,211 @@ func b64decTemplateFunc(encValue string) string {
 	// when the template is executed.
 	panic("value could not be base64 decoded")
 }
+
+// shellQuote is a custom template function that quotes a value for POSIX
+// shells. The value is wrapped in single quotes, where no character is
+// special. Embedded single quotes close the quoted string, add an escaped
+// quote and reopen it. The quoted value reads back byte for byte, including
+// newlines, "$" and backticks.
+func shellQuoteTemplateFunc(value string) string {
+	rejectNULBytes(value)
+
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// dotenvQuote is a custom template function that quotes a value for dotenv
+// files. Values are wrapped in single quotes, which dotenv parsers read
+// literally, without variable expansion. Values with single quotes, or with
+// backslashes that python-dotenv reads as escapes within single quotes, are
+// wrapped in double quotes instead, with backslashes and double quotes
+// escaped. Newlines are kept as is within the quotes. Either way, the file
+// can also be sourced by a POSIX shell. Values that dotenv parsers can't read
+// back byte for byte are rejected.
+func dotenvQuoteTemplateFunc(value string) string {
+	rejectNULBytes(value)
+	if strings.HasSuffix(value, `\`) {
+		// Parsers read a backslash before the closing quote as an escaped
+		// quote
+		panic("value ends with a backslash, which can't be quoted for dotenv files")
+	}
+	if strings.Contains(value, "\r\n") {
+		// godotenv reads "\r\n" line breaks as "\n"
+		panic("value contains a \"\\r\\n\" line break, which can't be quoted for dotenv files")
+	}
+
+	if !strings.Contains(value, "'") && !strings.Contains(value, `\\`) {
+		return "'" + value + "'"
+	}
+
+	// Within double quotes, python-dotenv doesn't unescape "$" nor backticks,
+	// while godotenv expands variables, and godotenv trims every double quote
+	// from the end of the value
+	if strings.ContainsAny(value, "$`") {
+		panic("value with single quotes or \"\\\\\" contains \"$\" or backticks, which can't be quoted for dotenv files")
+	}
+	if strings.HasSuffix(value, `"`) {
+		panic("value with single quotes or \"\\\\\" ends with a double quote, which can't be quoted for dotenv files")
+	}
+
+	var b strings.Builder
+	b.WriteByte('"')
+	for i := 0; i < len(value); i++ {
+		switch c := value[i]; c {
+		case '\\', '"':
+			b.WriteByte('\\')
+			b.WriteByte(c)
+		default:
+			b.WriteByte(c)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// rejectNULBytes panics for values that can't be stored in an environment
+// variable
+func rejectNULBytes(value string) {
+	if strings.IndexByte(value, 0) >= 0 {
+		// Panic in a template function is captured as an error
+		// when the template is executed.
+		panic("value contains a NUL byte, which can't be stored in a variable")
+	}
+}
+
+// pemCerts is a custom template function that returns all of the
+// certificates contained in a PEM bundle, in their original order.
+func pemCertsTemplateFunc(bundle string) string {
+	return encodePEMBlocks(certificateBlocks(bundle))
+}
+
+// pemLeaf is a custom template function that returns the first certificate
+// contained in a PEM bundle.
+func pemLeafTemplateFunc(bundle string) string {
+	return encodePEMBlocks(certificateBlocks(bundle)[:1])
+}
+
+// pemChain is a custom template function that returns every certificate
+// after the first one contained in a PEM bundle. A bundle holding a single
+// certificate has an empty chain.
+func pemChainTemplateFunc(bundle string) string {
+	return encodePEMBlocks(certificateBlocks(bundle)[1:])
+}
+
+// pemKey is a custom template function that returns the first private key
+// contained in a PEM bundle.
+func pemKeyTemplateFunc(bundle string) string {
+	return encodePEMBlocks([]*pem.Block{privateKeyBlock(bundle)})
+}
+
+// certNotAfter is a custom template function that returns the expiry time of
+// the first certificate in a PEM bundle, formatted as RFC 3339 in UTC.
+func certNotAfterTemplateFunc(bundle string) string {
+	return parseLeafCertificate(bundle).NotAfter.UTC().Format(time.RFC3339)
+}
+
+// certSubject is a custom template function that returns the distinguished
+// name of the subject of the first certificate in a PEM bundle.
+func certSubjectTemplateFunc(bundle string) string {
+	return parseLeafCertificate(bundle).Subject.String()
+}
+
+// pkcs1ToPkcs8 is a custom template function that converts the first private
+// key in a PEM bundle from PKCS#1 ("RSA PRIVATE KEY") to PKCS#8 ("PRIVATE KEY").
+// Keys that are already PKCS#8 encoded are returned unchanged.
+func pkcs1ToPkcs8TemplateFunc(bundle string) string {
+	block := privateKeyBlock(bundle)
+	switch block.Type {
+	case pemPrivateKeyType:
+		if _, err := x509.ParsePKCS8PrivateKey(block.Bytes); err != nil {
+			panic("value does not contain a valid PKCS#8 private key")
+		}
+		return encodePEMBlocks([]*pem.Block{block})
+	case pemRSAKeyType:
+		key, err := x509.ParsePKCS1PrivateKey(block.Bytes)
+		if err != nil {
+			panic("value does not contain a valid PKCS#1 private key")
+		}
+		der, err := x509.MarshalPKCS8PrivateKey(key)
+		if err != nil {
+			panic("private key could not be converted to PKCS#8")
+		}
+		return encodePEMBlocks([]*pem.Block{{Type: pemPrivateKeyType, Bytes: der}})
+	default:
+		panic("value does not contain a PKCS#1 or PKCS#8 private key")
+	}
+}
+
+// decodePEMBlocks returns every PEM block found in a value. Any non-PEM
+// content surrounding the blocks is ignored.
+func decodePEMBlocks(value string) []*pem.Block {
+	var blocks []*pem.Block
+	rest := []byte(value)
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return blocks
+		}
+		blocks = append(blocks, block)
+	}
+}
+
+// certificateBlocks returns the certificate blocks of a PEM bundle, after
+// making sure each of them holds a valid X.509 certificate. It panics when
+// the bundle does not hold any certificate.
+func certificateBlocks(bundle string) []*pem.Block {
+	var certs []*pem.Block
+	for _, block := range decodePEMBlocks(bundle) {
+		if block.Type != pemCertificateType {
+			continue
+		}
+		if _, err := x509.ParseCertificate(block.Bytes); err != nil {
+			panic("value contains a malformed PEM certificate")
+		}
+		certs = append(certs, block)
+	}
+
+	if len(certs) == 0 {
+		panic("value does not contain a PEM certificate")
+	}
+	return certs
+}
+
+// privateKeyBlock returns the first private key block of a PEM bundle. It
+// panics when the bundle does not hold any private key.
+func privateKeyBlock(bundle string) *pem.Block {
+	for _, block := range decodePEMBlocks(bundle) {
+		if strings.HasSuffix(block.Type, pemPrivateKeyType) {
+			return block
+		}
+	}
+
+	panic("value does not contain a PEM private key")
+}
+
+func parseLeafCertificate(bundle string) *x509.Certificate {
+	// certificateBlocks has already made sure that the certificate parses
+	cert, _ := x509.ParseCertificate(certificateBlocks(bundle)[0].Bytes)
+	return cert
+}
+
+// skipWhenValidating returns a template function that returns its value
+// unchanged when validating a template, since placeholder secret values
+// can't be parsed, and calls fn otherwise.
+func skipWhenValidating(validating bool, fn func(string) string) func(string) string {
+	if !validating {
+		return fn
+	}
+	return func(value string) string {
+		return value
+	}
+}
+
+func encodePEMBlocks(blocks []*pem.Block) string {
+	buf := &bytes.Buffer{}
+	for _, block := range blocks {
+		// Drop any headers so that only the encoded material is rendered
+		_ = pem.Encode(buf, &pem.Block{Type: block.Type, Bytes: block.Bytes})
+	}
+	return buf.String()
+}
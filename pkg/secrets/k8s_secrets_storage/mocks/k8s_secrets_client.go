@@ -1,6 +1,7 @@
 package mocks
 
 import (
+	"context"
 	"errors"
 
 	"gopkg.in/yaml.v3"
@@ -79,7 +80,7 @@ func (c *KubeSecretsClient) AddSecret(
 
 // RetrieveSecret retrieves a Kubernetes Secret from the mock Kubernetes
 // Secrets client's database.
-func (c *KubeSecretsClient) RetrieveSecret(_ string, secretName string) (*v1.Secret, error) {
+func (c *KubeSecretsClient) RetrieveSecret(_ context.Context, _ string, secretName string) (*v1.Secret, error) {
 
 	if c.ErrOnRetrieve != nil {
 		return nil, c.ErrOnRetrieve
@@ -103,7 +104,7 @@ func (c *KubeSecretsClient) RetrieveSecret(_ string, secretName string) (*v1.Sec
 // UpdateSecret updates a Kubernetes Secret in the mock Kubernetes
 // Secrets client's database.
 func (c *KubeSecretsClient) UpdateSecret(
-	_ string, secretName string,
+	_ context.Context, _ string, secretName string,
 	originalK8sSecret *v1.Secret,
 	stringDataEntriesMap map[string][]byte) error {
 
@@ -131,7 +132,7 @@ func (c *KubeSecretsClient) UpdateSecret(
 
 // ListSecrets retrieves a Kubernetes Secrets list from the mock Kubernetes
 // Secrets client's database.
-func (c *KubeSecretsClient) ListSecrets(_ string) (*v1.SecretList, error) {
+func (c *KubeSecretsClient) ListSecrets(_ context.Context, _ string) (*v1.SecretList, error) {
 
 	if c.ErrOnRetrieve != nil {
 		return nil, c.ErrOnRetrieve
@@ -9,6 +9,7 @@ import (
 	"slices"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/cyberark/conjur-authn-k8s-client/pkg/log"
 	filetemplates "github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/file_templates"
@@ -33,6 +34,8 @@ type updateDestination struct {
 	k8sSecretName string
 	secretName    string
 	contentType   string
+	// field selects a single field of a secret with the "json" content type
+	field string
 }
 
 type k8sSecretsState struct {
@@ -46,6 +49,11 @@ type k8sSecretsState struct {
 	updateDestinations      map[string][]updateDestination
 	updateDestinationLookup map[string]map[destinationKey]struct{} // fast dedupe index
 
+	// Maps the name of each K8s Secret that is due for a refresh to its
+	// refresh interval, which is zero for the global refresh interval.
+	refreshIntervals map[string]time.Duration
+	// notDue counts the K8s Secrets that aren't due for a refresh
+	notDue int
 }
 
 type destinationKey struct {
@@ -93,8 +101,10 @@ type K8sProvider struct {
 	podNamespace       string
 	requiredK8sSecrets []string
 	secretsState       k8sSecretsState
-	traceContext       context.Context
 	sanitizeEnabled    bool
+	// requestTimeout is the timeout of each K8s API request, when it's
+	// greater than zero
+	requestTimeout time.Duration
 	//prevSecretsChecksums maps a k8s secret name to a sha256 checksum of the
 	// corresponding secret content. This is used to detect changes in
 	// secret content.
@@ -105,6 +115,13 @@ type K8sProvider struct {
 	mu sync.Mutex
 	// Maps template groups to corresponding K8S Secret
 	secretsGroups map[string][]*filetemplates.SecretGroup
+	// wiped is set once the K8s Secrets were blanked by Wipe, after which
+	// they're no longer updated
+	wiped bool
+	// schedule holds when each K8s Secret is due for a refresh, on the
+	// interval of its "conjur.org/secrets-refresh-interval" annotation or on
+	// the global one
+	schedule *utils.RefreshSchedule
 }
 
 // K8sProviderConfig provides config specific to Kubernetes Secrets provider
@@ -115,7 +132,6 @@ type K8sProviderConfig struct {
 
 // NewProvider creates a new secret provider for K8s Secrets mode.
 func NewProvider(
-	traceContext context.Context,
 	retrieveConjurSecrets conjur.RetrieveSecretsFunc,
 	sanitizeEnabled bool,
 	config K8sProviderConfig,
@@ -139,8 +155,7 @@ func NewProvider(
 			},
 		},
 		sanitizeEnabled,
-		config,
-		traceContext)
+		config)
 }
 
 // newProvider creates a new secret provider for K8s Secrets mode
@@ -150,7 +165,6 @@ func newProvider(
 	providerDeps k8sProviderDeps,
 	sanitizeEnabled bool,
 	config K8sProviderConfig,
-	traceContext context.Context,
 ) K8sProvider {
 	return K8sProvider{
 		k8s:                providerDeps.k8s,
@@ -163,62 +177,76 @@ func newProvider(
 			originalK8sSecrets:      map[string]*v1.Secret{},
 			updateDestinations:      map[string][]updateDestination{},
 			updateDestinationLookup: map[string]map[destinationKey]struct{}{},
+			refreshIntervals:        map[string]time.Duration{},
 		},
-		traceContext:         traceContext,
 		prevSecretsChecksums: map[string]utils.Checksum{},
 		secretsGroups:        map[string][]*filetemplates.SecretGroup{},
+		schedule:             utils.NewRefreshSchedule(),
 	}
 }
 
 // Provide implements a ProviderFunc to retrieve and push secrets to K8s secrets.
-func (p *K8sProvider) Provide() (bool, error) {
-	return p.ProvideWithCleanup(map[string][]string{})
+func (p *K8sProvider) Provide(ctx context.Context) (bool, error) {
+	return p.ProvideWithCleanup(ctx, map[string][]string{})
 }
 
 // ProvideWithCleanup removes specified keys from K8s secrets before updating them with Conjur values.
 // keysToRemove maps a K8s Secret name to specific keys that should be removed from a secret.
-func (p *K8sProvider) ProvideWithCleanup(keysToRemove map[string][]string) (bool, error) {
+// Only the K8s Secrets that are due for a refresh are updated, and their Conjur secrets are
+// retrieved at once.
+func (p *K8sProvider) ProvideWithCleanup(ctx context.Context, keysToRemove map[string][]string) (updated bool, err error) {
 	// Acquire lock to prevent concurrent execution.
 	// If another goroutine is executing, this will block until it completes.
 	p.mu.Lock()
 	defer func() {
-		// Always clear the secrets' state from memory. This prevents leakage of the secret values
-		// in `originalK8sSecrets` and prevents `updateDestinations` from growing each time
-		// the provider is run (e.g. during rotation).
-		p.secretsState = k8sSecretsState{
-			originalK8sSecrets:      map[string]*v1.Secret{},
-			updateDestinations:      map[string][]updateDestination{},
-			updateDestinationLookup: map[string]map[destinationKey]struct{}{},
-		}
-		// Also clear secretsGroups to prevent memory growth during rotation
-		p.secretsGroups = map[string][]*filetemplates.SecretGroup{}
+		p.clearSecretsState()
 		// Release lock after all cleanup is done
 		p.mu.Unlock()
 	}()
 
+	if p.wiped {
+		p.log.debug(messages.CSPFK018D)
+		return false, nil
+	}
+
 	// Use the global TracerProvider
 	tr := trace.NewOtelTracer(otel.Tracer("secrets-provider"))
 	// Retrieve required K8s Secrets and parse their Data fields.
-	if err := p.retrieveRequiredK8sSecrets(tr); err != nil {
-		return false, p.log.recordedError(messages.CSPFK021E)
+	now := time.Now()
+	if err := p.retrieveRequiredK8sSecrets(ctx, tr, now); err != nil {
+		p.schedule.FailedAll(now)
+		return false, utils.ClassifyAs(p.log.recordedError(messages.CSPFK021E), err)
+	}
+
+	// K8s Secrets that failed stay due, so that they're retried
+	refreshIntervals := p.secretsState.refreshIntervals
+	defer func() {
+		for k8sSecretName, interval := range refreshIntervals {
+			p.schedule.Refreshed(k8sSecretName, interval, now, err != nil)
+		}
+	}()
+
+	noUpdateableSecrets := len(p.secretsState.updateDestinations) == 0 && len(p.secretsGroups) == 0 && len(keysToRemove) == 0
+	if noUpdateableSecrets && len(refreshIntervals) == 0 && p.secretsState.notDue > 0 {
+		p.log.debug(messages.CSPFK020D)
+		return false, nil
 	}
 
 	// In label-based mode with no updateable secrets discovered, return gracefully.
-	if len(p.secretsState.updateDestinations) == 0 && len(p.secretsGroups) == 0 && len(keysToRemove) == 0 {
+	if noUpdateableSecrets {
 		p.log.warn(messages.CSPFK070E)
 		return false, nil
 	}
 
 	// Retrieve Conjur secrets for all K8s Secrets.
-	var updated bool
-	retrievedConjurSecrets, err := p.retrieveConjurSecrets(tr)
+	retrievedConjurSecrets, err := p.retrieveConjurSecrets(ctx, tr)
 	if err != nil {
 		// Delete K8s secrets for Conjur variables that no longer exist or the user no longer has permissions to.
 		// In the future we'll delete only the secrets that are revoked, but for now we delete all secrets in
 		// the group because we don't have a way to determine which secrets are revoked.
-		if (strings.Contains(err.Error(), "403") || strings.Contains(err.Error(), "404")) && p.sanitizeEnabled {
+		if utils.IsRevokedError(err) && p.sanitizeEnabled {
 			updated = true
-			rmErr := p.removeDeletedSecrets(tr)
+			rmErr := p.removeDeletedSecrets(ctx, tr)
 			if rmErr != nil {
 				p.log.recordedError(messages.CSPFK063E)
 				// Don't return here - continue processing
@@ -226,14 +254,14 @@ func (p *K8sProvider) ProvideWithCleanup(keysToRemove map[string][]string) (bool
 		}
 
 		p.log.logError(messages.CSPFK034E, err.Error())
-		return updated, p.log.recordedError(messages.CSPFK034E, err.Error())
+		return updated, utils.ClassifyAs(p.log.recordedError(messages.CSPFK034E, err.Error()), err)
 	}
 
 	// Update all K8s Secrets with the retrieved Conjur secrets.
-	updated, err = p.updateRequiredK8sSecretsWithCleanup(retrievedConjurSecrets, tr, keysToRemove)
+	updated, err = p.updateRequiredK8sSecretsWithCleanup(ctx, retrievedConjurSecrets, tr, keysToRemove)
 	if err != nil {
 		p.log.logError(messages.CSPFK023E, err.Error())
-		return updated, p.log.recordedError(messages.CSPFK023E)
+		return updated, utils.ClassifyAs(p.log.recordedError(messages.CSPFK023E), err)
 	}
 
 	if updated {
@@ -242,8 +270,46 @@ func (p *K8sProvider) ProvideWithCleanup(keysToRemove map[string][]string) (bool
 	return updated, nil
 }
 
-func (p *K8sProvider) removeDeletedSecrets(tr trace.Tracer) error {
+// Wipe blanks the keys of the K8s Secrets that are updated with Conjur
+// secrets, once the K8s Secrets being updated are written. The K8s Secrets
+// aren't updated anymore afterwards.
+func (p *K8sProvider) Wipe(ctx context.Context) error {
+	p.mu.Lock()
+	defer func() {
+		p.clearSecretsState()
+		p.mu.Unlock()
+	}()
+
+	p.wiped = true
+	tr := trace.NewOtelTracer(otel.Tracer("secrets-provider"))
+	if err := p.retrieveRequiredK8sSecrets(ctx, tr, time.Time{}); err != nil {
+		return p.log.recordedError(messages.CSPFK021E)
+	}
+	return p.clearSecrets(ctx, tr)
+}
+
+// clearSecretsState clears the secrets' state from memory. This prevents leakage of the secret
+// values in `originalK8sSecrets` and prevents `updateDestinations` from growing each time the
+// provider is run (e.g. during rotation).
+func (p *K8sProvider) clearSecretsState() {
+	p.secretsState = k8sSecretsState{
+		originalK8sSecrets:      map[string]*v1.Secret{},
+		updateDestinations:      map[string][]updateDestination{},
+		updateDestinationLookup: map[string]map[destinationKey]struct{}{},
+		refreshIntervals:        map[string]time.Duration{},
+	}
+	// Also clear secretsGroups to prevent memory growth during rotation
+	p.secretsGroups = map[string][]*filetemplates.SecretGroup{}
+}
+
+func (p *K8sProvider) removeDeletedSecrets(ctx context.Context, tr trace.Tracer) error {
 	log.Info(messages.CSPFK021I)
+	return p.clearSecrets(ctx, tr)
+}
+
+// clearSecrets replaces the Conjur secret values of the K8s Secrets with
+// empty values
+func (p *K8sProvider) clearSecrets(ctx context.Context, tr trace.Tracer) error {
 	emptySecrets := make(map[string][]byte)
 	variablesToDelete, err := p.listConjurSecretsToFetch()
 	if err != nil {
@@ -252,7 +318,7 @@ func (p *K8sProvider) removeDeletedSecrets(tr trace.Tracer) error {
 	for _, secret := range variablesToDelete {
 		emptySecrets[secret] = []byte("")
 	}
-	_, err = p.updateRequiredK8sSecrets(emptySecrets, tr)
+	_, err = p.updateRequiredK8sSecrets(ctx, emptySecrets, tr)
 	if err != nil {
 		return err
 	}
@@ -260,22 +326,40 @@ func (p *K8sProvider) removeDeletedSecrets(tr trace.Tracer) error {
 }
 
 // retrieveRequiredK8sSecrets retrieves all K8s Secrets that need to be
-// managed/updated by the Secrets Provider.
-func (p *K8sProvider) retrieveRequiredK8sSecrets(tracer trace.Tracer) error {
-	spanCtx, span := tracer.Start(p.traceContext, "Gather required K8s Secrets")
+// managed/updated by the Secrets Provider, and that are due for a refresh at
+// now. Every K8s Secret is retrieved when now is zero.
+func (p *K8sProvider) retrieveRequiredK8sSecrets(ctx context.Context, tracer trace.Tracer, now time.Time) error {
+	spanCtx, span := tracer.Start(ctx, "Gather required K8s Secrets")
 	defer span.End()
 
+	// K8s Secrets that are no longer managed are no longer scheduled
+	listed := map[string]struct{}{}
+	defer func() {
+		if !now.IsZero() {
+			p.schedule.Retain(func(name string) bool {
+				_, ok := listed[name]
+				return ok
+			})
+		}
+	}()
+
 	// If strict list of secrets is provided from config, process them.
 	// Otherwise, try to retrieve and process all labeled secrets.
 	if len(p.requiredK8sSecrets) > 0 {
 		for _, k8sSecretName := range p.requiredK8sSecrets {
 			_, childSpan := tracer.Start(spanCtx, "Retrieve K8s Secret")
 			defer childSpan.End()
-			k8sSecret, err := p.k8s.retrieveSecret(p.podNamespace, k8sSecretName)
+			requestCtx, cancel := p.requestContext(spanCtx)
+			k8sSecret, err := p.k8s.retrieveSecret(requestCtx, p.podNamespace, k8sSecretName)
+			cancel()
 			if err != nil {
 				childSpan.RecordErrorAndSetStatus(err)
 				span.RecordErrorAndSetStatus(err)
-				return p.log.recordedError(messages.CSPFK020E)
+				return utils.ClassifyAs(p.log.recordedError(messages.CSPFK020E), err)
+			}
+			listed[k8sSecret.Name] = struct{}{}
+			if !now.IsZero() && !p.isDue(k8sSecret, now) {
+				continue
 			}
 			if err := p.retrieveRequiredK8sSecret(k8sSecret, false); err != nil {
 				childSpan.RecordErrorAndSetStatus(err)
@@ -286,16 +370,22 @@ func (p *K8sProvider) retrieveRequiredK8sSecrets(tracer trace.Tracer) error {
 	} else {
 		_, childSpan := tracer.Start(spanCtx, "List Labeled K8s Secrets")
 		defer childSpan.End()
-		k8sSecrets, err := p.k8s.listLabeledSecrets(p.podNamespace)
+		requestCtx, cancel := p.requestContext(spanCtx)
+		k8sSecrets, err := p.k8s.listLabeledSecrets(requestCtx, p.podNamespace)
+		cancel()
 		if err != nil {
 			childSpan.RecordErrorAndSetStatus(err)
 			span.RecordErrorAndSetStatus(err)
-			return p.log.recordedError(messages.CSPFK024E)
+			return utils.ClassifyAs(p.log.recordedError(messages.CSPFK024E), err)
 		}
 
 		for _, k8sSecret := range k8sSecrets.Items {
 			_, childSpan := tracer.Start(spanCtx, "Process K8s Secret")
 			defer childSpan.End()
+			listed[k8sSecret.Name] = struct{}{}
+			if !now.IsZero() && !p.isDue(&k8sSecret, now) {
+				continue
+			}
 			if err := p.retrieveRequiredK8sSecret(&k8sSecret, true); err != nil {
 				// In label-based mode, skip invalid secrets and continue processing others
 				// instead of failing the entire operation. This makes the provider more
@@ -312,6 +402,66 @@ func (p *K8sProvider) retrieveRequiredK8sSecrets(tracer trace.Tracer) error {
 	return nil
 }
 
+// isDue reports whether a K8s Secret is due for a refresh at now, and records
+// the refresh interval of the K8s Secrets that are due
+func (p *K8sProvider) isDue(k8sSecret *v1.Secret, now time.Time) bool {
+	if !p.schedule.Due(k8sSecret.Name, now) {
+		p.secretsState.notDue++
+		return false
+	}
+
+	interval, err := config.ParseRefreshInterval(k8sSecret.Annotations[config.SecretsRefreshIntervalKey])
+	if err != nil {
+		p.log.warn(messages.CSPFK115E, config.SecretsRefreshIntervalKey, k8sSecret.Name, err.Error())
+	}
+	p.secretsState.refreshIntervals[k8sSecret.Name] = interval
+	return true
+}
+
+// SetRefreshInterval sets the refresh interval of the K8s Secrets without a
+// refresh interval of their own
+func (p *K8sProvider) SetRefreshInterval(interval time.Duration) {
+	p.schedule.SetDefaultInterval(interval)
+}
+
+// SetRefreshCron sets the cron schedule of the K8s Secrets without a refresh
+// interval of their own, instead of the global refresh interval
+func (p *K8sProvider) SetRefreshCron(cron *utils.CronSchedule) {
+	p.schedule.SetDefaultCron(cron)
+}
+
+// SetRefreshJitter sets the maximum random delay of refreshes
+func (p *K8sProvider) SetRefreshJitter(jitter time.Duration) {
+	p.schedule.SetJitter(jitter)
+}
+
+// SetRequestTimeout sets the timeout of each K8s API request
+func (p *K8sProvider) SetRequestTimeout(timeout time.Duration) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.requestTimeout = timeout
+}
+
+// requestContext returns the context of a K8s API request, which times out
+// after the request timeout when it's set
+func (p *K8sProvider) requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if p.requestTimeout <= 0 {
+		return ctx, func() {}
+	}
+	return context.WithTimeout(ctx, p.requestTimeout)
+}
+
+// NextRefresh returns the time until the next K8s Secret is due for a
+// refresh, or false when no refresh is scheduled
+func (p *K8sProvider) NextRefresh() (time.Duration, bool) {
+	return p.schedule.Next(time.Now())
+}
+
+// RefreshNow makes a K8s Secret due for a refresh, such as when it changed
+func (p *K8sProvider) RefreshNow(k8sSecretName string) {
+	p.schedule.Reset(k8sSecretName)
+}
+
 // retrieveRequiredK8sSecret retrieves an individual K8s Secrets that needs
 // to be managed/updated by the Secrets Provider.
 //
@@ -343,7 +493,7 @@ func (p *K8sProvider) retrieveRequiredK8sSecret(k8sSecret *v1.Secret, allowEmpty
 		} else {
 			conjurMapKey := config.ConjurMapKey
 			p.log.logError("At least one of %s data entry or %s annotations must be defined", conjurMapKey, "conjur.org/conjur-secrets.* & conjur.org/secret-file-template.*")
-			return p.log.recordedError(messages.CSPFK028E, k8sSecret.Name)
+			return utils.Classify(utils.FailureConfig, p.log.recordedError(messages.CSPFK028E, k8sSecret.Name))
 		}
 	}
 
@@ -427,7 +577,7 @@ func (p *K8sProvider) parseConjurSecretsYAML(k8sSecret *v1.Secret) (bool, error)
 		if len(p.secretsGroups) > 0 {
 			return false, nil
 		}
-		return false, p.log.recordedError(messages.CSPFK028E, k8sSecretName)
+		return false, utils.Classify(utils.FailureConfig, p.log.recordedError(messages.CSPFK028E, k8sSecretName))
 	}
 
 	// Check if the parsed map is empty
@@ -449,31 +599,37 @@ func (p *K8sProvider) parseConjurSecretsYAML(k8sSecret *v1.Secret) (bool, error)
 // with the Conjur secret variable ID, K8s secret, secret name, and
 // content-type as specified in the Conjur secrets mapping.
 // The key is an application secret name, the value can be either a
-// string (varID) or a map {id: varID (required), content-type: base64 (optional)}.
+// string (varID) or a map {id: varID (required), content-type: base64 or
+// json (optional), field: JSON field name (optional)}.
 func (p *K8sProvider) refreshUpdateDestinations(conjurMap map[string]interface{}, k8sSecretName string) error {
 	for secretName, contents := range conjurMap {
 		switch value := contents.(type) {
 		case string: //in that case contents is varID
-			dest := updateDestination{k8sSecretName, secretName, "text"}
+			dest := updateDestination{k8sSecretName, secretName, "text", ""}
 			p.appendDestination(value, dest)
 		case map[interface{}]interface{}:
 			varId, ok := value["id"].(string)
 			if !ok || varId == "" {
-				return p.log.recordedError(messages.CSPFK037E, secretName, k8sSecretName)
+				return utils.Classify(utils.FailureConfig, p.log.recordedError(messages.CSPFK037E, secretName, k8sSecretName))
 			}
 
 			contentType, ok := value["content-type"].(string)
 			if ok && contentType == "base64" {
 				p.log.info(messages.CSPFK022I, secretName, k8sSecretName)
-			} else {
+			} else if !ok || contentType != "json" {
 				contentType = "text"
 			}
 
-			dest := updateDestination{k8sSecretName, secretName, contentType}
+			field, _ := value["field"].(string)
+			if field != "" && contentType != "json" {
+				return utils.Classify(utils.FailureConfig, p.log.recordedError(messages.CSPFK098E, secretName, k8sSecretName))
+			}
+
+			dest := updateDestination{k8sSecretName, secretName, contentType, field}
 			p.appendDestination(varId, dest)
 
 		default:
-			return p.log.recordedError(messages.CSPFK028E, k8sSecretName)
+			return utils.Classify(utils.FailureConfig, p.log.recordedError(messages.CSPFK028E, k8sSecretName))
 		}
 	}
 	return nil
@@ -545,14 +701,14 @@ func (p *K8sProvider) listConjurSecretsToFetch() ([]string, error) {
 	}
 
 	if len(variableIDs) == 0 {
-		return nil, p.log.recordedError(messages.CSPFK025E)
+		return nil, utils.Classify(utils.FailureConfig, p.log.recordedError(messages.CSPFK025E))
 	}
 
 	return variableIDs, nil
 }
 
-func (p *K8sProvider) retrieveConjurSecrets(tracer trace.Tracer) (map[string][]byte, error) {
-	spanCtx, span := tracer.Start(p.traceContext, "Fetch Conjur Secrets")
+func (p *K8sProvider) retrieveConjurSecrets(ctx context.Context, tracer trace.Tracer) (map[string][]byte, error) {
+	spanCtx, span := tracer.Start(ctx, "Fetch Conjur Secrets")
 	defer span.End()
 
 	variableIDs, err := p.listConjurSecretsToFetch()
@@ -564,22 +720,22 @@ func (p *K8sProvider) retrieveConjurSecrets(tracer trace.Tracer) (map[string][]b
 	if err != nil {
 		span.RecordErrorAndSetStatus(err)
 		p.log.logError(messages.CSPFK034E, err.Error())
-		return retrievedConjurSecrets, p.log.recordedError(messages.CSPFK034E, err.Error())
+		return retrievedConjurSecrets, utils.ClassifyAs(p.log.recordedError(messages.CSPFK034E, err.Error()), err)
 	}
 	return retrievedConjurSecrets, nil
 }
 
 func (p *K8sProvider) updateRequiredK8sSecrets(
-	conjurSecrets map[string][]byte, tracer trace.Tracer) (bool, error) {
-	return p.updateRequiredK8sSecretsWithCleanup(conjurSecrets, tracer, map[string][]string{})
+	ctx context.Context, conjurSecrets map[string][]byte, tracer trace.Tracer) (bool, error) {
+	return p.updateRequiredK8sSecretsWithCleanup(ctx, conjurSecrets, tracer, map[string][]string{})
 }
 
 func (p *K8sProvider) updateRequiredK8sSecretsWithCleanup(
-	conjurSecrets map[string][]byte, tracer trace.Tracer, keysToRemove map[string][]string) (bool, error) {
+	ctx context.Context, conjurSecrets map[string][]byte, tracer trace.Tracer, keysToRemove map[string][]string) (bool, error) {
 
 	var updated bool
 
-	spanCtx, span := tracer.Start(p.traceContext, "Update K8s Secrets")
+	spanCtx, span := tracer.Start(ctx, "Update K8s Secrets")
 	defer span.End()
 
 	newSecretsDataMap := p.createSecretData(conjurSecrets)
@@ -623,16 +779,19 @@ func (p *K8sProvider) updateRequiredK8sSecretsWithCleanup(
 				}
 			}
 
+			requestCtx, cancel := p.requestContext(spanCtx)
 			err := p.k8s.updateSecret(
+				requestCtx,
 				p.podNamespace,
 				k8sSecretName,
 				originalSecret,
 				secretData)
+			cancel()
 			if err != nil {
 				// Error messages returned from K8s should be printed only in debug mode
 				p.log.debug(messages.CSPFK005D, err.Error())
 				childSpan.RecordErrorAndSetStatus(err)
-				return false, p.log.recordedError(messages.CSPFK022E)
+				return false, utils.ClassifyAs(p.log.recordedError(messages.CSPFK022E), err)
 			}
 			p.prevSecretsChecksums[k8sSecretName] = checksum
 			updated = true
@@ -647,7 +806,9 @@ func (p *K8sProvider) updateRequiredK8sSecretsWithCleanup(
 // createSecretData creates a map of entries to be added to the 'Data' fields
 // of each K8s Secret. Each entry will map an application secret name to a
 // value retrieved from Conjur. If a secret has a 'base64' content type, the
-// resulting secret value will be decoded.
+// resulting secret value will be decoded. If a secret has a 'json' content
+// type, either the selected field is added, or each key of the JSON object is
+// added as a separate entry.
 func (p *K8sProvider) createSecretData(conjurSecrets map[string][]byte) map[string]map[string][]byte {
 	_, isFetchAll := p.secretsState.updateDestinations["*"]
 
@@ -681,6 +842,20 @@ func (p *K8sProvider) createSecretData(conjurSecrets map[string][]byte) map[stri
 				}
 			}
 
+			// JSON secrets are expanded into their fields
+			if dest.contentType == "json" {
+				fields, err := filetemplates.ExpandJSONSecret(secretName, dest.field, secretValue)
+				if err != nil {
+					// Leave the entries of this destination as they are
+					p.log.logError(messages.CSPFK097E, variableID, k8sSecretName, err.Error())
+					continue
+				}
+				for _, field := range fields {
+					secretData[k8sSecretName][field.Alias] = []byte(field.Value)
+				}
+				continue
+			}
+
 			// Check if the secret value should be decoded in this K8s Secret
 			if dest.contentType == "base64" {
 				decodedSecretValue := make([]byte, base64.StdEncoding.DecodedLen(len(secretValue)))
@@ -722,6 +897,12 @@ func (p *K8sProvider) createSecretData(conjurSecrets map[string][]byte) map[stri
 					continue
 				}
 
+				if dest.contentType == "json" && dest.field == "" {
+					// The entries of a JSON secret without a field are only
+					// known from its value
+					continue
+				}
+
 				if conjurSecrets[varID] == nil {
 					// The secret does not exist in conjurSecrets, set the value to an empty string
 					if secretData[dest.k8sSecretName] == nil {
@@ -849,9 +1030,17 @@ func (p *K8sProvider) populateGroupTemplateSecretData(conjurSecrets map[string][
 		for _, secretGroup := range secretGroups {
 			for _, secSpec := range secretGroup.SecretSpecs {
 				// Check if the secret value was returned from Conjur
-				// If not, log an error and set the value to an empty string
+				// If not, log an error and set the value to an empty string.
+				// Optional secrets are replaced by their default, or left out.
 				bValue, ok := conjurSecrets[secSpec.Path]
-				if !ok {
+				if !ok && secSpec.Optional {
+					if secSpec.Default == nil {
+						p.log.warn(messages.CSPFK100E, secSpec.Path, secretGroup.Name)
+						continue
+					}
+					p.log.warn(messages.CSPFK099E, secSpec.Path, secretGroup.Name)
+					bValue = []byte(*secSpec.Default)
+				} else if !ok {
 					p.log.logError(messages.CSPFK087E, secretGroup.Name, secSpec.Alias)
 					bValue = []byte{}
 				}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"fmt"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"go.opentelemetry.io/otel"
@@ -90,8 +91,7 @@ func (m testMocks) newProvider(requiredSecrets []string) K8sProvider {
 		K8sProviderConfig{
 			RequiredK8sSecrets: requiredSecrets,
 			PodNamespace:       "someNamespace",
-		},
-		context.Background())
+		})
 }
 
 type assertFunc func(*testing.T, testMocks, bool, error, string)
@@ -647,6 +647,92 @@ func TestProvide(t *testing.T) {
 				assertLogged(true, "warn", messages.CSPFK064E, "secret1", "base64", "illegal base64 data"),
 			},
 		},
+		{
+			desc: "JSON secrets are expanded into K8s Secret keys",
+			k8sSecrets: k8sStorageMocks.K8sSecrets{
+				"k8s-secret1": {
+					"conjur-map": {
+						"db-password": map[string]interface{}{
+							"id":           "conjur/var/credentials",
+							"content-type": "json",
+							"field":        "password",
+						},
+						"credentials": map[string]interface{}{
+							"id":           "conjur/var/credentials",
+							"content-type": "json",
+						},
+					},
+				},
+			},
+			requiredSecrets: []string{"k8s-secret1"},
+			alternateConjurSecrets: map[string]string{
+				"conjur/var/credentials": `{"username": "admin", "password": "secret", "port": 5432}`,
+			},
+			asserts: []assertFunc{
+				assertSecretsUpdated(
+					expectedK8sSecrets{
+						"k8s-secret1": {
+							"db-password": "secret",
+							"username":    "admin",
+							"password":    "secret",
+							"port":        "5432",
+						},
+					},
+					expectedMissingValues{},
+					false,
+				),
+				assertNoErrorLogged(),
+			},
+		},
+		{
+			desc: "JSON secret with missing field is not written",
+			k8sSecrets: k8sStorageMocks.K8sSecrets{
+				"k8s-secret1": {
+					"conjur-map": {
+						"secret1": "conjur/var/path1",
+						"token": map[string]interface{}{
+							"id":           "conjur/var/credentials",
+							"content-type": "json",
+							"field":        "token",
+						},
+					},
+				},
+			},
+			requiredSecrets: []string{"k8s-secret1"},
+			alternateConjurSecrets: map[string]string{
+				"conjur/var/path1":       "secret-value1",
+				"conjur/var/credentials": `{"username": "admin", "password": "hunter2"}`,
+			},
+			asserts: []assertFunc{
+				assertSecretsUpdated(
+					expectedK8sSecrets{
+						"k8s-secret1": {"secret1": "secret-value1"},
+					},
+					expectedMissingValues{"k8s-secret1": {"admin", "hunter2"}},
+					false,
+				),
+				assertErrorLogged(messages.CSPFK097E, "conjur/var/credentials", "k8s-secret1",
+					`field "token" not present in JSON secret value for alias "token"`),
+			},
+		},
+		{
+			desc: "Field without JSON content-type",
+			k8sSecrets: k8sStorageMocks.K8sSecrets{
+				"k8s-secret1": {
+					"conjur-map": {
+						"db-password": map[string]interface{}{
+							"id":    "conjur/var/path1",
+							"field": "password",
+						},
+					},
+				},
+			},
+			requiredSecrets: []string{"k8s-secret1"},
+			asserts: []assertFunc{
+				assertErrorLogged(messages.CSPFK098E, "db-password", "k8s-secret1"),
+				assertErrorContains(messages.CSPFK021E, false),
+			},
+		},
 		{
 			desc: "Invalid or empty content-type is treated as text",
 			k8sSecrets: k8sStorageMocks.K8sSecrets{
@@ -1268,7 +1354,7 @@ func TestProvide(t *testing.T) {
 			provider := mocks.newProvider(tc.requiredSecrets)
 
 			// Run test case
-			updated, err := provider.Provide()
+			updated, err := provider.Provide(context.Background())
 
 			// Confirm results
 			for _, assert := range tc.asserts {
@@ -1301,7 +1387,7 @@ func TestSecretsContentChanges(t *testing.T) {
 		mocks.kubeClient.AddSecret(secretName, map[string]string{}, secretData)
 	}
 	provider := mocks.newProvider(requiredSecrets)
-	update, err := provider.Provide()
+	update, err := provider.Provide(context.Background())
 	assert.False(t, mocks.logger.DebugWasLogged("CSPFK016D"))
 	assertSecretsUpdated(
 		expectedK8sSecrets{
@@ -1314,7 +1400,7 @@ func TestSecretsContentChanges(t *testing.T) {
 	desc = "Verify secrets are not updated when there are no changes"
 	denyK8sUpdate = true
 	mocks.setPermissions(denyConjurRetrieve, denyK8sRetrieve, denyK8sUpdate)
-	update, err = provider.Provide()
+	update, err = provider.Provide(context.Background())
 	assert.NoError(t, err)
 	assert.True(t, mocks.logger.DebugWasLogged("CSPFK016D"))
 	// verify the same secret still exists
@@ -1328,14 +1414,14 @@ func TestSecretsContentChanges(t *testing.T) {
 	desc = "Verify new secrets are written when there are changes to the Conjur secret"
 	mocks.logger.ClearInfo()
 	mocks.logger.ClearDebug()
-	secrets, _ := mocks.kubeClient.RetrieveSecret("", "k8s-secret1")
+	secrets, _ := mocks.kubeClient.RetrieveSecret(context.Background(), "", "k8s-secret1")
 	var newMap = map[string][]byte{
 		"conjur-map": []byte("secret2: conjur/var/path2"),
 	}
 	denyK8sUpdate = false
 	mocks.setPermissions(denyConjurRetrieve, denyK8sRetrieve, denyK8sUpdate)
-	mocks.kubeClient.UpdateSecret("mock namespace", "k8s-secret1", secrets, newMap)
-	update, err = provider.Provide()
+	mocks.kubeClient.UpdateSecret(context.Background(), "mock namespace", "k8s-secret1", secrets, newMap)
+	update, err = provider.Provide(context.Background())
 	assert.NoError(t, err)
 	assertSecretsUpdated(
 		expectedK8sSecrets{
@@ -1346,7 +1432,7 @@ func TestSecretsContentChanges(t *testing.T) {
 
 	// call again with no changes
 	desc = "Verify again secrets are not updated when there are no changes"
-	update, err = provider.Provide()
+	update, err = provider.Provide(context.Background())
 	assert.NoError(t, err)
 	assert.True(t, mocks.logger.DebugWasLogged("CSPFK016D"))
 
@@ -1362,7 +1448,7 @@ func TestSecretsContentChanges(t *testing.T) {
 		"conjur/var/empty-secret": "",
 	}
 	mocks.conjurClient.AddSecrets(updateConjurSecrets)
-	update, err = provider.Provide()
+	update, err = provider.Provide(context.Background())
 	assert.False(t, mocks.logger.DebugWasLogged("CSPFK016D"))
 	assertSecretsUpdated(
 		expectedK8sSecrets{
@@ -1378,8 +1464,11 @@ func TestProvideSanitization(t *testing.T) {
 		requiredSecrets  []string
 		sanitizeEnabled  bool
 		retrieveErrorMsg string
-		deleteSecrets    []string
-		asserts          []assertFunc
+		// retrieveErrorClass classifies the retrieve error like the Conjur
+		// client does with the status code of the response
+		retrieveErrorClass utils.FailureClass
+		deleteSecrets      []string
+		asserts            []assertFunc
 	}{
 		{
 			desc:            "403 error",
@@ -1389,8 +1478,9 @@ func TestProvideSanitization(t *testing.T) {
 					"conjur-map": {"secret1": "conjur/var/path1"},
 				},
 			},
-			requiredSecrets:  []string{"k8s-secret1"},
-			retrieveErrorMsg: "403",
+			requiredSecrets:    []string{"k8s-secret1"},
+			retrieveErrorMsg:   "403",
+			retrieveErrorClass: utils.FailureAuthz,
 			asserts: []assertFunc{
 				assertErrorLogged(messages.CSPFK034E, "403"),
 				assertErrorContains(fmt.Sprintf(messages.CSPFK034E, "403"), true),
@@ -1411,8 +1501,9 @@ func TestProvideSanitization(t *testing.T) {
 					"conjur-map": {"secret1": "conjur/var/path1"},
 				},
 			},
-			requiredSecrets:  []string{"k8s-secret1"},
-			retrieveErrorMsg: "404",
+			requiredSecrets:    []string{"k8s-secret1"},
+			retrieveErrorMsg:   "404",
+			retrieveErrorClass: utils.FailureNotFound,
 			asserts: []assertFunc{
 				assertErrorLogged(messages.CSPFK034E, "404"),
 				assertErrorContains(fmt.Sprintf(messages.CSPFK034E, "404"), true),
@@ -1455,8 +1546,9 @@ func TestProvideSanitization(t *testing.T) {
 					"conjur-map": {"secret1": "conjur/var/path1"},
 				},
 			},
-			requiredSecrets:  []string{"k8s-secret1"},
-			retrieveErrorMsg: "403",
+			requiredSecrets:    []string{"k8s-secret1"},
+			retrieveErrorMsg:   "403",
+			retrieveErrorClass: utils.FailureAuthz,
 			asserts: []assertFunc{
 				assertErrorLogged(messages.CSPFK034E, "403"),
 				assertErrorContains(fmt.Sprintf(messages.CSPFK034E, "403"), false),
@@ -1581,7 +1673,7 @@ func TestProvideSanitization(t *testing.T) {
 		for secretName, secretData := range tc.k8sSecrets {
 			mocks.kubeClient.AddSecret(secretName, map[string]string{}, secretData)
 		}
-		updated, err := provider.Provide()
+		updated, err := provider.Provide(context.Background())
 		assert.NoError(t, err, tc.desc)
 		assert.True(t, updated)
 
@@ -1589,13 +1681,16 @@ func TestProvideSanitization(t *testing.T) {
 		// and removing any secrets that need to be deleted (for the fetch all)
 		if tc.retrieveErrorMsg != "" {
 			mocks.conjurClient.ErrOnExecute = errors.New(tc.retrieveErrorMsg)
+			if tc.retrieveErrorClass != utils.FailureUnknown {
+				mocks.conjurClient.ErrOnExecute = utils.Classify(tc.retrieveErrorClass, mocks.conjurClient.ErrOnExecute)
+			}
 		}
 		if len(tc.deleteSecrets) > 0 {
 			for _, secretName := range tc.deleteSecrets {
 				delete(mocks.conjurClient.Database, secretName)
 			}
 		}
-		updated, err = provider.Provide()
+		updated, err = provider.Provide(context.Background())
 
 		// Confirm results
 		for _, assert := range tc.asserts {
@@ -1777,7 +1872,7 @@ func TestProvideWithCleanup(t *testing.T) {
 			provider := mocks.newProvider([]string{})
 
 			// Run test case with cleanup (simulating informer trigger)
-			updated, err := provider.ProvideWithCleanup(tc.keysToRemove)
+			updated, err := provider.ProvideWithCleanup(context.Background(), tc.keysToRemove)
 
 			// Confirm results
 			for _, assert := range tc.asserts {
@@ -1827,7 +1922,7 @@ func TestUpdateRequiredK8sSecretsWithCleanupRemovesKeys(t *testing.T) {
 	}["k8s-secret1"])
 
 	// Retrieve the full Secret object from mock client
-	originalSecret, err := mocks.kubeClient.RetrieveSecret("", "k8s-secret1")
+	originalSecret, err := mocks.kubeClient.RetrieveSecret(context.Background(), "", "k8s-secret1")
 	assert.NoError(t, err, "should retrieve secret from mock client")
 
 	// Directly construct provider with pre-populated state (simulating informer behavior)
@@ -1862,7 +1957,6 @@ func TestUpdateRequiredK8sSecretsWithCleanupRemovesKeys(t *testing.T) {
 				},
 			},
 		},
-		traceContext:         context.Background(),
 		prevSecretsChecksums: map[string]utils.Checksum{},
 	}
 
@@ -1875,7 +1969,7 @@ func TestUpdateRequiredK8sSecretsWithCleanupRemovesKeys(t *testing.T) {
 		"k8s-secret1": {"secret2"},
 	}
 
-	updated, err := provider.updateRequiredK8sSecretsWithCleanup(conjurSecrets, tracer, keysToRemove)
+	updated, err := provider.updateRequiredK8sSecretsWithCleanup(context.Background(), conjurSecrets, tracer, keysToRemove)
 	assert.NoError(t, err, "updateRequiredK8sSecretsWithCleanup should succeed")
 	assert.True(t, updated, "updateRequiredK8sSecretsWithCleanup should report updates")
 
@@ -1982,6 +2076,7 @@ func TestParseConjurMap(t *testing.T) {
 	}
 }
 func TestCreateGroupTemplateSecretData(t *testing.T) {
+	defaultValue := "default-value"
 	testCases := []struct {
 		name               string
 		secretsGroups      map[string][]*filetemplates.SecretGroup
@@ -2057,6 +2152,39 @@ func TestCreateGroupTemplateSecretData(t *testing.T) {
 			},
 			expectedErrors: []string{"CSPFK087E"},
 		},
+		{
+			name: "optional missing secrets use their default or are left out",
+			secretsGroups: map[string][]*filetemplates.SecretGroup{
+				"k8s-secret1": {
+					{
+						Name: "group1",
+						SecretSpecs: []filetemplates.SecretSpec{
+							{Alias: "alias1", Path: "conjur/var/path1"},
+							{Alias: "alias2", Path: "conjur/var/missing2", Optional: true, Default: &defaultValue},
+							{Alias: "alias3", Path: "conjur/var/missing3", Optional: true},
+						},
+					},
+				},
+			},
+			originalK8sSecrets: map[string]*v1.Secret{
+				"k8s-secret1": {
+					ObjectMeta: metav1.ObjectMeta{
+						Annotations: map[string]string{
+							"conjur.org/secret-file-template.group1": "{{ secret \"alias1\" }}-{{ secret \"alias2\" }}-{{ with index .SecretsMap \"alias3\" }}{{ .Value }}{{ else }}unset{{ end }}",
+						},
+					},
+				},
+			},
+			conjurSecrets: map[string][]byte{
+				"conjur/var/path1": []byte("secret-value1"),
+			},
+			initialDataMap: make(map[string]map[string][]byte),
+			expectedResult: map[string]map[string][]byte{
+				"k8s-secret1": {
+					"group1": []byte("secret-value1-default-value-unset"),
+				},
+			},
+		},
 		{
 			name: "handles invalid template parse error gracefully",
 			secretsGroups: map[string][]*filetemplates.SecretGroup{
@@ -2578,3 +2706,97 @@ func TestMergeReAddedKeys(t *testing.T) {
 		assert.ElementsMatch(t, []string{"key2"}, result["secret-b"], "secret-b has no re-adds")
 	})
 }
+
+func TestWipe(t *testing.T) {
+	mocks := newTestMocks()
+	k8sSecrets := k8sStorageMocks.K8sSecrets{
+		"k8s-secret1": {
+			"conjur-map": {"secret1": "conjur/var/path1", "secret2": "conjur/var/path2"},
+		},
+	}
+	for secretName, secretData := range k8sSecrets {
+		mocks.kubeClient.AddSecret(secretName, map[string]string{}, secretData)
+	}
+	provider := mocks.newProvider([]string{"k8s-secret1"})
+	updated, err := provider.Provide(context.Background())
+	assertSecretsUpdated(
+		expectedK8sSecrets{
+			"k8s-secret1": {"secret1": "secret-value1", "secret2": "secret-value2"},
+		},
+		expectedMissingValues{}, false)(t, mocks, updated, err, "secrets are provided")
+
+	assert.NoError(t, provider.Wipe(context.Background()))
+	assertSecretsUpdated(
+		expectedK8sSecrets{
+			"k8s-secret1": {"secret1": "", "secret2": ""},
+		},
+		expectedMissingValues{}, false)(t, mocks, true, nil, "secrets are wiped")
+
+	// K8s Secrets aren't updated after they were wiped
+	updated, err = provider.Provide(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, updated)
+	assert.True(t, mocks.logger.DebugWasLogged("CSPFK018D"))
+	assertSecretsUpdated(
+		expectedK8sSecrets{
+			"k8s-secret1": {"secret1": "", "secret2": ""},
+		},
+		expectedMissingValues{}, false)(t, mocks, true, nil, "wiped secrets aren't provided")
+}
+
+func TestRefreshIntervals(t *testing.T) {
+	mocks := newTestMocks()
+	k8sSecrets := k8sStorageMocks.K8sSecrets{
+		"k8s-secret1": {
+			"conjur-map": {"secret1": "conjur/var/path1"},
+		},
+		"k8s-secret2": {
+			"conjur-map": {"secret2": "conjur/var/path2"},
+		},
+	}
+	intervals := map[string]string{
+		"k8s-secret1": "30s",
+		"k8s-secret2": "often",
+	}
+	for secretName, secretData := range k8sSecrets {
+		annotations := map[string]string{"conjur.org/secrets-refresh-interval": intervals[secretName]}
+		mocks.kubeClient.AddSecret(secretName, annotations, secretData)
+	}
+	provider := mocks.newProvider([]string{"k8s-secret1", "k8s-secret2"})
+	provider.SetRefreshInterval(time.Hour)
+
+	updated, err := provider.Provide(context.Background())
+	assertSecretsUpdated(
+		expectedK8sSecrets{
+			"k8s-secret1": {"secret1": "secret-value1"},
+			"k8s-secret2": {"secret2": "secret-value2"},
+		},
+		expectedMissingValues{}, false)(t, mocks, updated, err, "all K8s Secrets are provided")
+	assert.True(t, mocks.logger.WarningWasLogged(fmt.Sprintf(messages.CSPFK115E,
+		"conjur.org/secrets-refresh-interval", "k8s-secret2", `time: invalid duration "often"`)))
+
+	next, scheduled := provider.NextRefresh()
+	assert.True(t, scheduled)
+	assert.LessOrEqual(t, next, 30*time.Second)
+	assert.Greater(t, next, 29*time.Second)
+
+	// K8s Secrets aren't refreshed before they're due
+	mocks.conjurClient.AddSecrets(map[string]string{
+		"conjur/var/path1": "new-secret-value1",
+		"conjur/var/path2": "new-secret-value2",
+	})
+	updated, err = provider.Provide(context.Background())
+	assert.NoError(t, err)
+	assert.False(t, updated)
+	assert.True(t, mocks.logger.DebugWasLogged("CSPFK020D"))
+
+	// Changed K8s Secrets are refreshed regardless of their refresh interval
+	provider.RefreshNow("k8s-secret1")
+	updated, err = provider.Provide(context.Background())
+	assertSecretsUpdated(
+		expectedK8sSecrets{
+			"k8s-secret1": {"secret1": "new-secret-value1"},
+			"k8s-secret2": {"secret2": "secret-value2"},
+		},
+		expectedMissingValues{}, false)(t, mocks, updated, err, "due K8s Secrets are refreshed")
+}
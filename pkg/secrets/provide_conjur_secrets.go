@@ -2,11 +2,10 @@ package secrets
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"os"
-	"os/signal"
+	"sync"
 	"sync/atomic"
-	"syscall"
 	"time"
 
 	"github.com/cenkalti/backoff"
@@ -23,15 +22,26 @@ import (
 )
 
 const (
-	secretProviderGracePeriod = time.Duration(10 * time.Millisecond)
-	informerDebounceDelay     = time.Duration(200 * time.Millisecond)
-	informerDebounceMaxDelay  = time.Duration(10 * time.Second)
+	informerDebounceDelay    = time.Duration(200 * time.Millisecond)
+	informerDebounceMaxDelay = time.Duration(10 * time.Second)
+	scheduledRefreshMinDelay = time.Duration(1 * time.Second)
 )
 
 // CommonProviderConfig provides config that is common to all providers
 type CommonProviderConfig struct {
 	StoreType       string
 	SanitizeEnabled bool
+	// RefreshInterval is the refresh interval of the secrets without a
+	// refresh interval of their own
+	RefreshInterval time.Duration
+	// RefreshCron is the cron schedule of the secrets without a refresh
+	// interval of their own, instead of RefreshInterval
+	RefreshCron *utils.CronSchedule
+	// RefreshJitter is the maximum random delay of refreshes
+	RefreshJitter time.Duration
+	// RequestTimeout is the timeout of each retrieval of secrets from Conjur
+	// and of each K8s API request, when it's greater than zero
+	RequestTimeout time.Duration
 }
 
 // ProviderConfig provides the configuration necessary to create a secrets
@@ -45,7 +55,8 @@ type ProviderConfig struct {
 // ProviderFunc describes a function type responsible for providing secrets to
 // an unspecified target. It returns either an error, or a flag that indicates
 // whether any target secret files or Kubernetes Secrets have been updated.
-type ProviderFunc func() (updated bool, err error)
+// Providing secrets is abandoned when ctx is done.
+type ProviderFunc func(ctx context.Context) (updated bool, err error)
 
 // RepeatableProviderFunc describes a function type that is capable of looping
 // indefinitely while providing secrets to unspecified targets.
@@ -53,25 +64,57 @@ type RepeatableProviderFunc func() error
 
 // ProviderFactory defines a function type for creating a ProviderFunc given a
 // RetrieveSecretsFunc and ProviderConfig.
-type ProviderFactory func(traceContent context.Context, secretsRetrieverFunc conjur.RetrieveSecretsFunc, providerConfig ProviderConfig) (ProviderFunc, []error)
+type ProviderFactory func(secretsRetrieverFunc conjur.RetrieveSecretsFunc, providerConfig ProviderConfig) (ProviderFunc, []error)
 
 // K8sProviderInstance holds a reference to the K8s provider so we can dynamically manage its secrets
 var K8sProviderInstance *k8sSecretsStorage.K8sProvider
 
+// ConfigReloader describes a provider that can reload its configuration from
+// Pod annotations while it's running. ConfigFiles returns the other files the
+// configuration was read from, which are watched for changes as well.
+type ConfigReloader interface {
+	Reload(annotations map[string]string) (changed bool, errs []error)
+	ConfigFiles() []string
+}
+
+// P2FProviderInstance holds a reference to the Push to File provider so its
+// secret groups can be reloaded when the configuration changes
+var P2FProviderInstance ConfigReloader
+
+// RefreshScheduler describes a provider that refreshes its secrets on
+// refresh intervals of their own, such as Push to File secret groups or K8s
+// Secrets with a refresh interval annotation
+type RefreshScheduler interface {
+	NextRefresh() (time.Duration, bool)
+}
+
+// SecretsWiper describes a provider that can wipe the secrets it provided,
+// such as when the Secrets Provider exits
+type SecretsWiper interface {
+	Wipe(ctx context.Context) error
+}
+
 // NewProviderForType returns a ProviderFunc responsible for providing secrets in a given mode.
 func NewProviderForType(
-	traceContext context.Context,
 	secretsRetrieverFunc conjur.RetrieveSecretsFunc,
 	providerConfig ProviderConfig,
 ) (ProviderFunc, []error) {
+	secretsRetrieverFunc = conjur.RetrieveSecretsWithTimeout(
+		secretsRetrieverFunc,
+		providerConfig.CommonProviderConfig.RequestTimeout,
+	)
+
 	switch providerConfig.StoreType {
 	case config.K8s:
 		provider := k8sSecretsStorage.NewProvider(
-			traceContext,
 			secretsRetrieverFunc,
 			providerConfig.CommonProviderConfig.SanitizeEnabled,
 			providerConfig.K8sProviderConfig,
 		)
+		provider.SetRefreshInterval(providerConfig.CommonProviderConfig.RefreshInterval)
+		provider.SetRefreshCron(providerConfig.CommonProviderConfig.RefreshCron)
+		provider.SetRefreshJitter(providerConfig.CommonProviderConfig.RefreshJitter)
+		provider.SetRequestTimeout(providerConfig.CommonProviderConfig.RequestTimeout)
 		// Store a reference to the K8s provider so it can be accessed by the informer handler
 		K8sProviderInstance = &provider
 		return provider.Provide, nil
@@ -84,7 +127,10 @@ func NewProviderForType(
 		if err != nil {
 			return nil, err
 		}
-		provider.SetTraceContext(traceContext)
+		provider.SetRefreshInterval(providerConfig.CommonProviderConfig.RefreshInterval)
+		provider.SetRefreshCron(providerConfig.CommonProviderConfig.RefreshCron)
+		provider.SetRefreshJitter(providerConfig.CommonProviderConfig.RefreshJitter)
+		P2FProviderInstance = provider
 		return provider.Provide, nil
 	default:
 		return nil, []error{fmt.Errorf(
@@ -100,10 +146,13 @@ func NewProviderForType(
 type OnRetryFunc func(err error)
 
 // RetryableSecretProvider returns a new ProviderFunc, which wraps the provided ProviderFunc
-// in a limitedBackOff-restricted Retry call.
+// in a limitedBackOff-restricted Retry call. The retries back off exponentially, up to
+// retryMaxInterval, when it's greater than zero. Errors classified as permanent aren't
+// retried.
 func RetryableSecretProvider(
 	retryInterval time.Duration,
 	retryCountLimit int,
+	retryMaxInterval time.Duration,
 	provideSecrets ProviderFunc,
 	onRetry OnRetryFunc,
 ) ProviderFunc {
@@ -111,13 +160,23 @@ func RetryableSecretProvider(
 		retryInterval,
 		retryCountLimit,
 	)
+	if retryMaxInterval > 0 {
+		limitedBackOff = utils.NewExponentialLimitedBackOff(
+			retryInterval,
+			retryMaxInterval,
+			retryCountLimit,
+		)
+	}
 
-	return func() (bool, error) {
+	return func(ctx context.Context) (bool, error) {
 		var updated bool
 		var retErr error
 
 		op := func() error {
-			updated, retErr = provideSecrets()
+			updated, retErr = provideSecrets(ctx)
+			if utils.IsPermanent(retErr) {
+				return backoff.Permanent(retErr)
+			}
 			return retErr
 		}
 
@@ -133,9 +192,13 @@ func RetryableSecretProvider(
 			log.Warn(fmt.Sprintf(messages.CSPFK040E, next, retries, limit, err))
 		}
 
-		err := backoff.RetryNotify(op, limitedBackOff, notify)
+		err := backoff.RetryNotify(op, backoff.WithContext(limitedBackOff, ctx), notify)
 		if err != nil {
-			log.Error(messages.CSPFK038E)
+			if utils.IsPermanent(err) {
+				log.Error(messages.CSPFK118E, err)
+			} else {
+				log.Error(messages.CSPFK038E)
+			}
 			return updated, err
 		}
 		return updated, nil
@@ -152,29 +215,72 @@ type ProviderRefreshConfig struct {
 	InformerEvents        <-chan k8sinformer.SecretEvent
 	RetryInterval         time.Duration
 	RetryCountLimit       int
+	// RetryMaxInterval caps the exponential backoff of retries, which are
+	// retried on RetryInterval when it's zero
+	RetryMaxInterval time.Duration
+	// ProvideTimeout is the deadline of providing secrets, retries included,
+	// in init and application modes, when it's greater than zero
+	ProvideTimeout time.Duration
+	// ConfigReloads receives a value when the configuration of the provider
+	// was reloaded, and secrets need to be provided again
+	ConfigReloads <-chan struct{}
+	// Scheduler schedules the refreshes of secrets with refresh intervals of
+	// their own. Secrets are then refreshed when they're due, instead of on
+	// SecretRefreshInterval.
+	Scheduler RefreshScheduler
 }
 
-// RunSecretsProvider takes a retryable ProviderFunc, and runs it in one of four modes:
+// RunSecretsProvider takes a retryable ProviderFunc, and runs it in one of five modes.
+// Providing secrets stops when ctx is done, or when config.ProviderQuit is closed,
+// which cancels the secrets being provided:
 //   - Run once and return (for init or application container modes)
-//   - Run once and sleep forever (for sidecar mode without periodic refresh)
-//   - Run periodically (for sidecar mode with periodic refresh)
+//   - Run once and sleep until ctx is done (for sidecar mode without periodic refresh)
+//   - Run periodically (for sidecar mode with periodic refresh, or with
+//     secrets refreshed on intervals of their own)
 //   - Run on informer events (for sidecar mode with secret informer)
+//   - Run on configuration reloads (for sidecar mode with Push to File)
 func RunSecretsProvider(
+	ctx context.Context,
 	config ProviderRefreshConfig,
 	provideSecrets ProviderFunc,
 	status StatusUpdater,
 	httpServer *server.Server,
 ) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go func() {
+		select {
+		case <-config.ProviderQuit:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
 	var periodicQuit = make(chan struct{})
 	var periodicError = make(chan error)
+	// Wakes up the scheduled provider when secrets were provided on informer
+	// events or configuration reloads, since the schedule may have changed
+	var reschedule = make(chan struct{}, 1)
 	var ticker *time.Ticker
 	var err error
 	var readyFailures atomic.Int32
+	// Tracks the background goroutines, which are waited for on shutdown so
+	// that the secrets they're providing are written before returning, and
+	// before the secrets are wiped on exit
+	var providers sync.WaitGroup
 
 	setReady := func(err error) {
+		// Push to File secret groups that failed, while the other groups
+		// were provided, are reported as degraded
+		degraded := degradedGroups(err)
+		if statusErr := status.SetSecretsDegraded(degraded); statusErr != nil {
+			log.Warn(messages.CSPFK114E, statusErr)
+		}
+
 		if httpServer == nil {
 			return
 		}
+		httpServer.SetDegradedGroups(degraded)
 
 		// Succeeded on last attempt, reset failure count and set ready
 		if err == nil {
@@ -186,6 +292,13 @@ func RunSecretsProvider(
 		httpServer.SetReady(false)
 	}
 
+	// The next scheduled refresh is reported by the status endpoint
+	setNextRefresh := func(next time.Time) {
+		if httpServer != nil {
+			httpServer.SetNextRefresh(next)
+		}
+	}
+
 	// Wrap with retry logic when config specifies it; pass onRetry so readiness
 	// is set to false on each retry (critical when retries are unlimited and the
 	// call blocks—otherwise setReady(err) would never be reached)
@@ -197,6 +310,7 @@ func RunSecretsProvider(
 		provideSecrets = RetryableSecretProvider(
 			config.RetryInterval,
 			config.RetryCountLimit,
+			config.RetryMaxInterval,
 			provideSecrets,
 			onRetry,
 		)
@@ -206,7 +320,7 @@ func RunSecretsProvider(
 		return err
 	}
 
-	if _, err = provideSecrets(); err != nil && config.RunOnce {
+	if _, err = provideInitialSecrets(ctx, config, provideSecrets); err != nil && config.RunOnce {
 		// Return immediately upon error, except when running in sidecar/standalone mode
 		// In these modes the error may fixable without a container restart
 		return err
@@ -236,21 +350,48 @@ func RunSecretsProvider(
 				informerEvents: config.InformerEvents,
 				periodicQuit:   periodicQuit,
 				periodicError:  periodicError,
+				reschedule:     reschedule,
 				onSetReady:     setReady,
 			}
-			go informerTriggeredProvider(provideSecrets, informerCfg, status)
+			providers.Go(func() { informerTriggeredProvider(ctx, provideSecrets, informerCfg, status) })
+		}
+
+		// Start reload-triggered provider if configuration reloads are provided
+		if config.ConfigReloads != nil {
+			reloadCfg := reloadConfig{
+				configReloads: config.ConfigReloads,
+				periodicQuit:  periodicQuit,
+				periodicError: periodicError,
+				reschedule:    reschedule,
+				onSetReady:    setReady,
+			}
+			providers.Go(func() { reloadTriggeredProvider(ctx, provideSecrets, reloadCfg, status) })
 		}
 
-		// Start periodic refresh if interval is set
-		if config.SecretRefreshInterval > 0 {
+		// Start periodic refresh if interval is set, or refresh secrets
+		// when they're due if they're scheduled
+		if config.Scheduler != nil {
+			scheduledCfg := scheduledConfig{
+				scheduler:     config.Scheduler,
+				reschedule:    reschedule,
+				periodicQuit:  periodicQuit,
+				periodicError: periodicError,
+				onSetReady:    setReady,
+				onScheduled:   setNextRefresh,
+			}
+			providers.Go(func() { scheduledSecretProvider(ctx, provideSecrets, scheduledCfg, status) })
+		} else if config.SecretRefreshInterval > 0 {
 			ticker = time.NewTicker(config.SecretRefreshInterval)
+			setNextRefresh(time.Now().Add(config.SecretRefreshInterval))
 			periodicCfg := periodicConfig{
 				ticker:        ticker,
+				interval:      config.SecretRefreshInterval,
 				periodicQuit:  periodicQuit,
 				periodicError: periodicError,
 				onSetReady:    setReady,
+				onScheduled:   setNextRefresh,
 			}
-			go periodicSecretProvider(provideSecrets, periodicCfg, status)
+			providers.Go(func() { periodicSecretProvider(ctx, provideSecrets, periodicCfg, status) })
 		}
 		// If neither informer nor periodic refresh is configured,
 		// fall through to sleep forever
@@ -258,23 +399,32 @@ func RunSecretsProvider(
 
 	err = nil
 	// Wait here for a signal to quit providing secrets or an error
-	// from the periodicSecretProvider() or informerTriggeredProvider() function
-	if config.SecretRefreshInterval > 0 || config.InformerEvents != nil {
+	// from the periodicSecretProvider(), scheduledSecretProvider(),
+	// informerTriggeredProvider() or reloadTriggeredProvider() function
+	if config.SecretRefreshInterval > 0 || config.Scheduler != nil ||
+		config.InformerEvents != nil || config.ConfigReloads != nil {
 		// Wait on both quit signal and error channel if goroutines are running
-		select {
-		case <-config.ProviderQuit:
-			break
-		case err = <-periodicError:
-			// Periodic provider in standalone mode should keep working. Errors may be
-			// fixable without a container restart, and stopping the container could affect
-			// many workloads if it's a transient error.
-			if config.Mode != "standalone" {
-				break
+	wait:
+		for {
+			select {
+			case <-ctx.Done():
+				break wait
+			case err = <-periodicError:
+				// Periodic provider in standalone mode should keep working. Errors may be
+				// fixable without a container restart, and stopping the container could affect
+				// many workloads if it's a transient error. Sidecars keep working as well when
+				// only some Push to File secret groups failed, which are reported as degraded,
+				// so that the secret files of the other groups are still refreshed.
+				if config.Mode != "standalone" && !partialGroupsError(err) {
+					break wait
+				}
+				err = nil // reset error to keep running
 			}
-			err = nil // reset error to keep running
 		}
 
-		// Allow the background goroutines to gracefully shut down
+		// Allow the background goroutines to gracefully shut down, cancelling
+		// the secrets they're providing
+		cancel()
 		// Kill the ticker if running
 		if ticker != nil {
 			ticker.Stop()
@@ -282,33 +432,61 @@ func RunSecretsProvider(
 		// Close the channel so all goroutines listening to it will receive the signal
 		close(periodicQuit)
 		// Let the goroutines exit
-		time.Sleep(secretProviderGracePeriod)
+		providers.Wait()
 	} else {
 		// If no goroutines are running (no periodic refresh, no informer),
-		// wait for OS termination signal to keep the sidecar container running
+		// wait until ctx is done to keep the sidecar container running. The
+		// entrypoint cancels ctx on SIGINT and SIGTERM.
 		log.Debug(messages.CSPFK012D)
-		sigChan := make(chan os.Signal, 1)
-		signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
-		defer signal.Stop(sigChan)
-		select {
-		case <-config.ProviderQuit:
-			break
-		case sig := <-sigChan:
-			log.Debug(fmt.Sprintf("Received signal %v, shutting down gracefully", sig))
-			break
-		}
+		<-ctx.Done()
 	}
 	return err
 }
 
+// provideInitialSecrets provides secrets when the Secrets Provider starts,
+// within the provide timeout in init and application modes
+func provideInitialSecrets(ctx context.Context, config ProviderRefreshConfig, provideSecrets ProviderFunc) (bool, error) {
+	if !config.RunOnce || config.ProvideTimeout <= 0 {
+		return provideSecrets(ctx)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, config.ProvideTimeout)
+	defer cancel()
+	updated, err := provideSecrets(ctx)
+	if err != nil && errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return updated, fmt.Errorf(messages.CSPFK119E, config.ProvideTimeout, err)
+	}
+	return updated, err
+}
+
+// partialGroupsError reports whether err is returned when only some of the
+// Push to File secret groups failed
+func partialGroupsError(err error) bool {
+	var groupsErr *pushtofile.SecretGroupsError
+	return errors.As(err, &groupsErr) && groupsErr.Partial()
+}
+
+// degradedGroups returns the names of the Push to File secret groups that
+// failed with err
+func degradedGroups(err error) []string {
+	var groupsErr *pushtofile.SecretGroupsError
+	if errors.As(err, &groupsErr) {
+		return groupsErr.Groups()
+	}
+	return nil
+}
+
 type periodicConfig struct {
 	ticker        *time.Ticker
+	interval      time.Duration
 	periodicQuit  <-chan struct{}
 	periodicError chan<- error
 	onSetReady    func(error)
+	onScheduled   func(next time.Time)
 }
 
 func periodicSecretProvider(
+	ctx context.Context,
 	provideSecrets ProviderFunc,
 	config periodicConfig,
 	status StatusUpdater,
@@ -318,14 +496,139 @@ func periodicSecretProvider(
 		case <-config.periodicQuit:
 			return
 		case <-config.ticker.C:
-			updated, err := provideSecrets()
+			updated, err := provideSecrets(ctx)
+			// Secrets being provided on shutdown are canceled
+			if ctx.Err() != nil {
+				return
+			}
+			config.onSetReady(err)
+			if config.onScheduled != nil {
+				config.onScheduled(time.Now().Add(config.interval))
+			}
+
+			if err == nil && updated {
+				err = status.SetSecretsUpdated()
+			}
+			if err != nil {
+				reportError(config.periodicError, config.periodicQuit, err)
+			}
+		}
+	}
+}
+
+type scheduledConfig struct {
+	scheduler     RefreshScheduler
+	reschedule    <-chan struct{}
+	periodicQuit  <-chan struct{}
+	periodicError chan<- error
+	onSetReady    func(error)
+	onScheduled   func(next time.Time)
+}
+
+// scheduledSecretProvider provides secrets when the next secrets are due for
+// a refresh. The provider only refreshes the due secrets, and the secrets
+// that are due shortly after them, so that they're retrieved at once.
+func scheduledSecretProvider(
+	ctx context.Context,
+	provideSecrets ProviderFunc,
+	config scheduledConfig,
+	status StatusUpdater,
+) {
+	for {
+		var timer *time.Timer
+		var timerChan <-chan time.Time
+		var nextRefresh time.Time
+		if next, scheduled := config.scheduler.NextRefresh(); scheduled {
+			delay := max(next, scheduledRefreshMinDelay)
+			timer = time.NewTimer(delay)
+			timerChan = timer.C
+			nextRefresh = time.Now().Add(delay)
+		}
+		if config.onScheduled != nil {
+			config.onScheduled(nextRefresh)
+		}
+
+		select {
+		case <-config.periodicQuit:
+			if timer != nil {
+				timer.Stop()
+			}
+			return
+		case <-config.reschedule:
+			if timer != nil {
+				timer.Stop()
+			}
+		case <-timerChan:
+			updated, err := provideSecrets(ctx)
+			// Secrets being provided on shutdown are canceled
+			if ctx.Err() != nil {
+				return
+			}
+			config.onSetReady(err)
+
+			if err == nil && updated {
+				err = status.SetSecretsUpdated()
+			}
+			if err != nil {
+				reportError(config.periodicError, config.periodicQuit, err)
+			}
+		}
+	}
+}
+
+// reportError sends err to RunSecretsProvider, unless it's shutting down and
+// no longer waiting for errors
+func reportError(periodicError chan<- error, periodicQuit <-chan struct{}, err error) {
+	select {
+	case periodicError <- err:
+	case <-periodicQuit:
+	}
+}
+
+// notifyReschedule wakes up the scheduled secret provider, if it's running,
+// without blocking
+func notifyReschedule(reschedule chan<- struct{}) {
+	select {
+	case reschedule <- struct{}{}:
+	default:
+	}
+}
+
+type reloadConfig struct {
+	configReloads <-chan struct{}
+	periodicQuit  <-chan struct{}
+	periodicError chan<- error
+	reschedule    chan<- struct{}
+	onSetReady    func(error)
+}
+
+func reloadTriggeredProvider(
+	ctx context.Context,
+	provideSecrets ProviderFunc,
+	config reloadConfig,
+	status StatusUpdater,
+) {
+	for {
+		select {
+		case <-config.periodicQuit:
+			return
+		case _, ok := <-config.configReloads:
+			if !ok {
+				return
+			}
+			updated, err := provideSecrets(ctx)
+			// Secrets being provided on shutdown are canceled
+			if ctx.Err() != nil {
+				return
+			}
+			notifyReschedule(config.reschedule)
 			config.onSetReady(err)
 
 			if err == nil && updated {
 				err = status.SetSecretsUpdated()
 			}
 			if err != nil {
-				config.periodicError <- err
+				reportError(config.periodicError, config.periodicQuit, err)
 			}
 		}
 	}
@@ -335,10 +638,12 @@ type informerConfig struct {
 	informerEvents <-chan k8sinformer.SecretEvent
 	periodicQuit   <-chan struct{}
 	periodicError  chan<- error
+	reschedule     chan<- struct{}
 	onSetReady     func(error)
 }
 
 func informerTriggeredProvider(
+	ctx context.Context,
 	provideSecrets ProviderFunc,
 	config informerConfig,
 	status StatusUpdater,
@@ -360,18 +665,23 @@ func informerTriggeredProvider(
 		// ProvideWithCleanup is not wrapped with retry logic in the way the provideSecrets func is, so it will only
 		// attempt once if keys need removing
 		if len(keysToRemoveCumulative) > 0 {
-			updated, err = K8sProviderInstance.ProvideWithCleanup(keysToRemoveCumulative)
+			updated, err = K8sProviderInstance.ProvideWithCleanup(ctx, keysToRemoveCumulative)
 			keysToRemoveCumulative = make(map[string][]string)
 		} else {
-			updated, err = provideSecrets()
+			updated, err = provideSecrets(ctx)
 		}
+		// Secrets being provided on shutdown are canceled
+		if ctx.Err() != nil {
+			return
+		}
+		notifyReschedule(config.reschedule)
 		config.onSetReady(err)
 
 		if err == nil && updated {
 			err = status.SetSecretsUpdated()
 		}
 		if err != nil {
-			config.periodicError <- err
+			reportError(config.periodicError, config.periodicQuit, err)
 		}
 		debounceTimer = nil
 		timerChan = nil
@@ -387,6 +697,10 @@ func informerTriggeredProvider(
 			return
 		case event := <-config.informerEvents:
 			log.Info(messages.CSPFK028I, event.EventType, event.Secret.Namespace, event.Secret.Name)
+			// Changed K8s Secrets are refreshed regardless of their refresh interval
+			if K8sProviderInstance != nil {
+				K8sProviderInstance.RefreshNow(event.Secret.Name)
+			}
 			eventCount++
 			if eventCount == 1 {
 				firstEventTime = time.Now()
@@ -8,7 +8,7 @@ import (
 	"log"
 	"net/http"
 	"os"
-	"syscall"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -18,6 +18,7 @@ import (
 	k8sSecretsStorage "github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/k8s_secrets_storage"
 	"github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/pushtofile"
 	"github.com/cyberark/secrets-provider-for-k8s/pkg/server"
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/utils"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 	v1 "k8s.io/api/core/v1"
@@ -41,7 +42,7 @@ type mockProvider struct {
 	targetsUpdated       bool
 }
 
-func (m *mockProvider) provide() (bool, error) {
+func (m *mockProvider) provide(_ context.Context) (bool, error) {
 	m.calledCount++
 	switch {
 	case m.injectFailure && (m.calledCount >= m.failOnCountN):
@@ -142,11 +143,11 @@ func TestRetryableSecretProvider(t *testing.T) {
 			logger.InfoLogger = log.New(&logBuffer, "", 0)
 
 			retryableProvider := RetryableSecretProvider(
-				tc.interval, tc.limit, tc.provider.provide, nil,
+				tc.interval, tc.limit, 0, tc.provider.provide, nil,
 			)
 
 			start := time.Now()
-			updated, err := retryableProvider()
+			updated, err := retryableProvider(t.Context())
 			duration := time.Since(start)
 
 			logMessages := logBuffer.String()
@@ -183,10 +184,10 @@ func TestRetryableSecretProviderOnRetry(t *testing.T) {
 		}
 
 		retryableProvider := RetryableSecretProvider(
-			10*time.Millisecond, 5, provider.provide, onRetry,
+			10*time.Millisecond, 5, 0, provider.provide, onRetry,
 		)
 
-		_, err := retryableProvider()
+		_, err := retryableProvider(t.Context())
 		assert.NoError(t, err)
 		assert.Equal(t, 2, onRetryCalls, "onRetry should be called twice (before 2nd and 3rd attempt)")
 		assert.Error(t, lastOnRetryErr)
@@ -204,10 +205,10 @@ func TestRetryableSecretProviderOnRetry(t *testing.T) {
 		}
 
 		retryableProvider := RetryableSecretProvider(
-			10*time.Millisecond, 3, provider.provide, onRetry,
+			10*time.Millisecond, 3, 0, provider.provide, onRetry,
 		)
 
-		_, err := retryableProvider()
+		_, err := retryableProvider(t.Context())
 		assert.Error(t, err)
 		assert.Equal(t, 3, onRetryCalls, "onRetry should be called 3 times (before each retry)")
 	})
@@ -220,10 +221,10 @@ func TestRetryableSecretProviderOnRetry(t *testing.T) {
 		}
 
 		retryableProvider := RetryableSecretProvider(
-			10*time.Millisecond, 3, provider.provide, onRetry,
+			10*time.Millisecond, 3, 0, provider.provide, onRetry,
 		)
 
-		_, err := retryableProvider()
+		_, err := retryableProvider(t.Context())
 		assert.NoError(t, err)
 		assert.Equal(t, 0, onRetryCalls, "onRetry should not be called when provider succeeds immediately")
 	})
@@ -231,14 +232,56 @@ func TestRetryableSecretProviderOnRetry(t *testing.T) {
 	t.Run("nil onRetry does not panic", func(t *testing.T) {
 		provider := eventualProvider(2)
 		retryableProvider := RetryableSecretProvider(
-			10*time.Millisecond, 5, provider.provide, nil,
+			10*time.Millisecond, 5, 0, provider.provide, nil,
 		)
 
-		_, err := retryableProvider()
+		_, err := retryableProvider(t.Context())
 		assert.NoError(t, err)
 	})
 }
 
+func TestRetryableSecretProviderErrorClassification(t *testing.T) {
+	t.Run("permanent errors aren't retried", func(t *testing.T) {
+		var logBuffer bytes.Buffer
+		logger.ErrorLogger.SetOutput(&logBuffer)
+		defer logger.ErrorLogger.SetOutput(os.Stderr)
+
+		var calls int
+		permanentErr := utils.Permanent(errors.New("403 Forbidden"))
+		retryableProvider := RetryableSecretProvider(
+			10*time.Millisecond, -1, 0,
+			func(context.Context) (bool, error) {
+				calls++
+				return false, fmt.Errorf("failed to provide: %w", permanentErr)
+			},
+			nil,
+		)
+
+		_, err := retryableProvider(t.Context())
+		assert.ErrorIs(t, err, permanentErr)
+		assert.Equal(t, 1, calls)
+		assert.Contains(t, logBuffer.String(), "CSPFK118E")
+	})
+
+	t.Run("transient errors are retried with an exponential backoff", func(t *testing.T) {
+		provider := eventualProvider(3)
+		var calls int
+		retryableProvider := RetryableSecretProvider(
+			10*time.Millisecond, 5, 40*time.Millisecond,
+			func(ctx context.Context) (bool, error) {
+				calls++
+				updated, err := provider.provide(ctx)
+				return updated, utils.Transient(err)
+			},
+			nil,
+		)
+
+		_, err := retryableProvider(t.Context())
+		assert.NoError(t, err)
+		assert.Equal(t, 3, calls)
+	})
+}
+
 func TestRunSecretsProvider(t *testing.T) {
 	testCases := []struct {
 		description    string
@@ -387,7 +430,7 @@ func TestRunSecretsProvider(t *testing.T) {
 			mode:           "standalone",
 			interval:       time.Duration(100) * time.Millisecond,
 			testTime:       time.Duration(250) * time.Millisecond,
-			expectedCount:  2, // In standalone mode, the provider is called once at the beginning and once more after the first failure when it retries, then it fails
+			expectedCount:  3, // In standalone mode, the provider keeps being called on every interval after it fails
 			expectProvided: false,
 			expectUpdated:  false,
 			provider:       badProvider(),
@@ -442,7 +485,7 @@ func TestRunSecretsProvider(t *testing.T) {
 			// Run the secrets provider
 			testError := make(chan error)
 			go func() {
-				err := RunSecretsProvider(refreshConfig, tc.provider.provide, fileUpdater, httpServer)
+				err := RunSecretsProvider(t.Context(), refreshConfig, tc.provider.provide, fileUpdater, httpServer)
 				testError <- err
 			}()
 			select {
@@ -496,34 +539,34 @@ func TestRunSecretsProvider(t *testing.T) {
 // - The signal handler is properly registered and responds to OS signals
 // - The container stays running (blocks) until signaled
 // - Graceful shutdown occurs when receiving SIGTERM/SIGINT
-func TestRunSecretsProviderSidecarWithSignalHandling(t *testing.T) {
+func TestRunSecretsProviderSidecarWithoutRefresh(t *testing.T) {
 	provider := goodProvider()
 	updater, err := newTestStatusUpdater(injectErrs{})
 	require.NoError(t, err)
 	defer updater.cleanup()
 
-	providerQuit := make(chan struct{})
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
 	refreshConfig := ProviderRefreshConfig{
 		Mode:                  "sidecar",
 		RunOnce:               false,
 		SecretRefreshInterval: time.Duration(0), // No periodic refresh
-		ProviderQuit:          providerQuit,
-		InformerEvents:        nil, // No informer - triggers signal handler code path
+		InformerEvents:        nil,              // No informer - waits until ctx is done
 	}
 
 	// Run the secrets provider in a goroutine
 	done := make(chan error, 1)
 	go func() {
-		done <- RunSecretsProvider(refreshConfig, provider.provide, updater.fileUpdater, nil)
+		done <- RunSecretsProvider(ctx, refreshConfig, provider.provide, updater.fileUpdater, nil)
 	}()
 
-	// Give it time to complete initial provision and reach the signal handling block
+	// Give it time to complete initial provision and start waiting
 	time.Sleep(200 * time.Millisecond)
 
-	// Verify the provider is still running (blocking on signal handler)
+	// Verify the provider is still running (waiting until ctx is done)
 	select {
 	case <-done:
-		t.Fatal("RunSecretsProvider exited unexpectedly - should be blocking on signal handler")
+		t.Fatal("RunSecretsProvider exited unexpectedly - should be waiting until ctx is done")
 	case <-time.After(100 * time.Millisecond):
 		// Good - it's blocking as expected
 	}
@@ -532,19 +575,15 @@ func TestRunSecretsProviderSidecarWithSignalHandling(t *testing.T) {
 	assert.Equal(t, 1, provider.count(), "Provider should be called once initially")
 	assert.FileExists(t, updater.fileUpdater.providedFile)
 
-	// Send SIGTERM to trigger graceful shutdown
-	// Note: We send to current process; the signal handler in the goroutine will receive it
-	proc, err := os.FindProcess(os.Getpid())
-	require.NoError(t, err)
-	err = proc.Signal(syscall.SIGTERM)
-	require.NoError(t, err)
+	// Cancel ctx, as the entrypoint does on SIGTERM, to trigger graceful shutdown
+	cancel()
 
 	// Wait for graceful shutdown
 	select {
 	case err := <-done:
 		assert.NoError(t, err, "Should shut down gracefully without error")
 	case <-time.After(2 * time.Second):
-		t.Fatal("RunSecretsProvider did not respond to SIGTERM within timeout")
+		t.Fatal("RunSecretsProvider did not shut down within timeout when ctx was cancelled")
 	}
 }
 
@@ -575,7 +614,7 @@ func TestRunSecretsProviderStandaloneHealthEndpoints(t *testing.T) {
 
 	done := make(chan error, 1)
 	go func() {
-		done <- RunSecretsProvider(refreshConfig, provider.provide, updater.fileUpdater, httpServer)
+		done <- RunSecretsProvider(t.Context(), refreshConfig, provider.provide, updater.fileUpdater, httpServer)
 	}()
 
 	baseURL := "http://" + serverAddress
@@ -610,7 +649,7 @@ func TestRunSecretsProviderStandaloneHealthEndpoints(t *testing.T) {
 
 func TestRunSecretsProviderStandaloneReadinessRecoversAfterInitialFailure(t *testing.T) {
 	providerCallCount := 0
-	provider := func() (bool, error) {
+	provider := func(context.Context) (bool, error) {
 		providerCallCount++
 		if providerCallCount == 1 {
 			return false, errors.New("Failed to Provide")
@@ -640,7 +679,7 @@ func TestRunSecretsProviderStandaloneReadinessRecoversAfterInitialFailure(t *tes
 
 	done := make(chan error, 1)
 	go func() {
-		done <- RunSecretsProvider(refreshConfig, provider, updater.fileUpdater, httpServer)
+		done <- RunSecretsProvider(t.Context(), refreshConfig, provider, updater.fileUpdater, httpServer)
 	}()
 
 	baseURL := "http://" + httpServer.Address()
@@ -681,9 +720,229 @@ func TestRunSecretsProviderStandaloneReadinessRecoversAfterInitialFailure(t *tes
 	}
 }
 
+func TestRunSecretsProviderProvideTimeout(t *testing.T) {
+	updater, err := newTestStatusUpdater(injectErrs{})
+	require.NoError(t, err)
+	defer updater.cleanup()
+
+	refreshConfig := ProviderRefreshConfig{
+		Mode:           "init",
+		RunOnce:        true,
+		ProvideTimeout: 50 * time.Millisecond,
+	}
+	provider := func(ctx context.Context) (bool, error) {
+		<-ctx.Done()
+		return false, ctx.Err()
+	}
+
+	err = RunSecretsProvider(t.Context(), refreshConfig, provider, updater.fileUpdater, nil)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Contains(t, err.Error(), "CSPFK119E")
+	assert.NoFileExists(t, updater.fileUpdater.providedFile)
+}
+
+func TestRunSecretsProviderCancelsOnQuit(t *testing.T) {
+	updater, err := newTestStatusUpdater(injectErrs{})
+	require.NoError(t, err)
+	defer updater.cleanup()
+
+	providerQuit := make(chan struct{})
+	refreshConfig := ProviderRefreshConfig{
+		Mode:                  "sidecar",
+		SecretRefreshInterval: 20 * time.Millisecond,
+		ProviderQuit:          providerQuit,
+	}
+	refreshing := make(chan struct{})
+	canceled := make(chan struct{})
+	var calls atomic.Int32
+	provider := func(ctx context.Context) (bool, error) {
+		// Secrets are provided initially, then the refresh blocks
+		if calls.Add(1) > 1 {
+			close(refreshing)
+			<-ctx.Done()
+			close(canceled)
+			return false, ctx.Err()
+		}
+		return true, nil
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- RunSecretsProvider(t.Context(), refreshConfig, provider, updater.fileUpdater, nil)
+	}()
+
+	// Quitting while secrets are refreshed cancels the refresh
+	<-refreshing
+	close(providerQuit)
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Fatal("refresh was not canceled")
+	}
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+	case <-time.After(time.Second):
+		t.Fatal("RunSecretsProvider did not return")
+	}
+	assert.Equal(t, int32(2), calls.Load())
+}
+
+func TestRunSecretsProviderWaitsForRefreshOnQuit(t *testing.T) {
+	updater, err := newTestStatusUpdater(injectErrs{})
+	require.NoError(t, err)
+	defer updater.cleanup()
+
+	providerQuit := make(chan struct{})
+	refreshConfig := ProviderRefreshConfig{
+		Mode:                  "sidecar",
+		SecretRefreshInterval: 20 * time.Millisecond,
+		ProviderQuit:          providerQuit,
+	}
+	refreshing := make(chan struct{})
+	var calls atomic.Int32
+	var written atomic.Bool
+	provider := func(ctx context.Context) (bool, error) {
+		// The refresh keeps writing secrets for a while after it's
+		// canceled, longer than it takes the other goroutines to exit
+		if calls.Add(1) == 2 {
+			close(refreshing)
+			<-ctx.Done()
+			time.Sleep(200 * time.Millisecond)
+			written.Store(true)
+			return true, ctx.Err()
+		}
+		return true, nil
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		done <- RunSecretsProvider(t.Context(), refreshConfig, provider, updater.fileUpdater, nil)
+	}()
+
+	// Secrets are wiped on exit once RunSecretsProvider returns, so the
+	// refresh in flight must be done by then
+	<-refreshing
+	close(providerQuit)
+	select {
+	case err := <-done:
+		assert.NoError(t, err)
+		assert.True(t, written.Load(), "RunSecretsProvider returned before the refresh finished")
+	case <-time.After(2 * time.Second):
+		t.Fatal("RunSecretsProvider did not return")
+	}
+}
+
+func TestRunSecretsProviderSidecarKeepsRunningOnPartialGroupErrors(t *testing.T) {
+	updater, err := newTestStatusUpdater(injectErrs{})
+	require.NoError(t, err)
+	defer updater.cleanup()
+
+	// The "cache" group fails after it was first provided, while the "db"
+	// group keeps being refreshed
+	var cacheFails atomic.Bool
+	var dbRefreshes atomic.Int32
+	retrieve := func(variableIDs []string, _ context.Context) (map[string][]byte, error) {
+		values := map[string][]byte{}
+		for _, id := range variableIDs {
+			if id == "cache/token" && cacheFails.Load() {
+				return nil, utils.Classify(utils.FailureNotFound, errors.New("failed to retrieve cache/token"))
+			}
+			if id == "db/password" {
+				dbRefreshes.Add(1)
+			}
+			values[id] = []byte(id)
+		}
+		return values, nil
+	}
+	provider, errs := pushtofile.NewProvider(retrieve, false, pushtofile.P2FProviderConfig{
+		SecretFileBasePath:   t.TempDir(),
+		TemplateFileBasePath: t.TempDir(),
+		AnnotationsMap: map[string]string{
+			"conjur.org/conjur-secrets.db":    "- db/password",
+			"conjur.org/conjur-secrets.cache": "- cache/token",
+		},
+	})
+	require.Empty(t, errs)
+
+	providerQuit := make(chan struct{})
+	refreshConfig := ProviderRefreshConfig{
+		Mode:                  "sidecar",
+		SecretRefreshInterval: 20 * time.Millisecond,
+		ProviderQuit:          providerQuit,
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- RunSecretsProvider(t.Context(), refreshConfig, func(ctx context.Context) (bool, error) {
+			updated, err := provider.Provide(ctx)
+			cacheFails.Store(true)
+			return updated, err
+		}, updater.fileUpdater, nil)
+	}()
+
+	assert.Eventually(t, func() bool { return dbRefreshes.Load() >= 4 }, 2*time.Second, 10*time.Millisecond)
+	select {
+	case err := <-done:
+		t.Fatalf("RunSecretsProvider returned on a partial secret groups error: %v", err)
+	default:
+	}
+	close(providerQuit)
+	assert.NoError(t, <-done)
+}
+
+func TestRunSecretsProviderSidecarKeepsRunningOnPostHookFailure(t *testing.T) {
+	updater, err := newTestStatusUpdater(injectErrs{})
+	require.NoError(t, err)
+	defer updater.cleanup()
+
+	// The post hook of the "web" group fails, while the "db" group keeps
+	// being refreshed
+	var dbRefreshes atomic.Int32
+	retrieve := func(variableIDs []string, _ context.Context) (map[string][]byte, error) {
+		values := map[string][]byte{}
+		for _, id := range variableIDs {
+			if id == "db/password" {
+				dbRefreshes.Add(1)
+			}
+			values[id] = []byte(id)
+		}
+		return values, nil
+	}
+	provider, errs := pushtofile.NewProvider(retrieve, false, pushtofile.P2FProviderConfig{
+		SecretFileBasePath:   t.TempDir(),
+		TemplateFileBasePath: t.TempDir(),
+		AnnotationsMap: map[string]string{
+			"conjur.org/conjur-secrets.db":         "- db/password",
+			"conjur.org/conjur-secrets.web":        "- web/htpasswd",
+			"conjur.org/secret-file-post-hook.web": "exit 1",
+		},
+	})
+	require.Empty(t, errs)
+
+	ctx, cancel := context.WithCancel(t.Context())
+	defer cancel()
+	refreshConfig := ProviderRefreshConfig{
+		Mode:                  "sidecar",
+		SecretRefreshInterval: 20 * time.Millisecond,
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- RunSecretsProvider(ctx, refreshConfig, provider.Provide, updater.fileUpdater, nil)
+	}()
+
+	assert.Eventually(t, func() bool { return dbRefreshes.Load() >= 4 }, 2*time.Second, 10*time.Millisecond)
+	select {
+	case err := <-done:
+		t.Fatalf("RunSecretsProvider returned on a failing post hook: %v", err)
+	default:
+	}
+	cancel()
+	assert.NoError(t, <-done)
+}
+
 func TestNewProviderForType(t *testing.T) {
 	t.Run("Returns error for unknown provider type", func(t *testing.T) {
-		_, err := NewProviderForType(t.Context(), nil, ProviderConfig{
+		_, err := NewProviderForType(nil, ProviderConfig{
 			CommonProviderConfig: CommonProviderConfig{
 				StoreType: "unknown_type",
 			},
@@ -693,7 +952,7 @@ func TestNewProviderForType(t *testing.T) {
 	})
 
 	t.Run("Returns file provider for 'file' type", func(t *testing.T) {
-		provider, err := NewProviderForType(t.Context(), nil, ProviderConfig{
+		provider, err := NewProviderForType(nil, ProviderConfig{
 			CommonProviderConfig: CommonProviderConfig{
 				StoreType: "file",
 			},
@@ -704,7 +963,7 @@ func TestNewProviderForType(t *testing.T) {
 	})
 
 	t.Run("Returns k8s provider for 'k8s_secrets' type", func(t *testing.T) {
-		provider, err := NewProviderForType(t.Context(), nil, ProviderConfig{
+		provider, err := NewProviderForType(nil, ProviderConfig{
 			CommonProviderConfig: CommonProviderConfig{
 				StoreType: "k8s_secrets",
 			},
@@ -774,7 +1033,7 @@ func TestInformerTriggeredProviderBatching(t *testing.T) {
 				periodicError:  periodicError,
 				onSetReady:     func(error) {},
 			}
-			go informerTriggeredProvider(mockProv.provide, config, updater.fileUpdater)
+			go informerTriggeredProvider(t.Context(), mockProv.provide, config, updater.fileUpdater)
 
 			eventIndex := 0
 			for batchIdx, batchSize := range tt.batches {
@@ -818,3 +1077,90 @@ func TestInformerTriggeredProviderBatching(t *testing.T) {
 		})
 	}
 }
+
+func TestReloadTriggeredProvider(t *testing.T) {
+	mockProv := goodProviderTargetsUpdated()
+	updater, err := newTestStatusUpdater(injectErrs{})
+	require.NoError(t, err)
+	defer updater.cleanup()
+
+	configReloads := make(chan struct{})
+	periodicQuit := make(chan struct{})
+	periodicError := make(chan error, 1)
+	var readyErrs []error
+	config := reloadConfig{
+		configReloads: configReloads,
+		periodicQuit:  periodicQuit,
+		periodicError: periodicError,
+		onSetReady:    func(err error) { readyErrs = append(readyErrs, err) },
+	}
+	done := make(chan struct{})
+	go func() {
+		reloadTriggeredProvider(t.Context(), mockProv.provide, config, updater.fileUpdater)
+		close(done)
+	}()
+
+	// Each reload provides secrets again
+	configReloads <- struct{}{}
+	configReloads <- struct{}{}
+
+	// The provider stops once reloads are closed
+	close(configReloads)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("reloadTriggeredProvider did not return")
+	}
+	close(periodicQuit)
+
+	assert.Equal(t, 2, mockProv.count())
+	assert.Equal(t, []error{nil, nil}, readyErrs)
+	assert.Len(t, periodicError, 0)
+}
+
+type mockScheduler struct {
+	scheduled atomic.Bool
+}
+
+func (s *mockScheduler) NextRefresh() (time.Duration, bool) {
+	return 0, s.scheduled.Load()
+}
+
+func TestScheduledSecretProvider(t *testing.T) {
+	mockProv := goodProviderTargetsUpdated()
+	updater, err := newTestStatusUpdater(injectErrs{})
+	require.NoError(t, err)
+	defer updater.cleanup()
+
+	scheduler := &mockScheduler{}
+	reschedule := make(chan struct{}, 1)
+	periodicQuit := make(chan struct{})
+	periodicError := make(chan error, 1)
+	nextRefreshes := make(chan time.Time, 10)
+	config := scheduledConfig{
+		scheduler:     scheduler,
+		reschedule:    reschedule,
+		periodicQuit:  periodicQuit,
+		periodicError: periodicError,
+		onSetReady:    func(error) {},
+		onScheduled:   func(next time.Time) { nextRefreshes <- next },
+	}
+	go scheduledSecretProvider(t.Context(), mockProv.provide, config, updater.fileUpdater)
+	defer close(periodicQuit)
+
+	// Secrets aren't provided until a refresh is scheduled
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 0, mockProv.count())
+	assert.True(t, (<-nextRefreshes).IsZero())
+
+	// Refreshes that are due are delayed by the minimum delay
+	scheduler.scheduled.Store(true)
+	notifyReschedule(reschedule)
+	assert.WithinDuration(t, time.Now().Add(scheduledRefreshMinDelay), <-nextRefreshes, 100*time.Millisecond)
+	time.Sleep(scheduledRefreshMinDelay / 2)
+	assert.Equal(t, 0, mockProv.count())
+	assert.Eventually(t, func() bool {
+		return mockProv.count() == 1
+	}, scheduledRefreshMinDelay, 10*time.Millisecond)
+	assert.Len(t, periodicError, 0)
+}
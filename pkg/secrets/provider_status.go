@@ -5,6 +5,8 @@ import (
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 )
 
 const (
@@ -30,22 +32,42 @@ const (
 //
 //	a "baked-in" container directory into a volume that is
 //	potentially shared with application container(s).
+//
+// SetSecretsDegraded: A function that records the Push to File secret groups
+//
+//	whose secrets couldn't be provided, while the other
+//	groups were. The record is removed once no groups
+//	are degraded.
+//
+// SetExitCode:        A function that records the exit code of the secrets
+//
+//	provider, so that it can be read once the container
+//	exited.
+//
+// RemoveStatus:       Remove the recorded status, such as when the provided
+//
+//	secrets are wiped on exit.
 type StatusUpdater interface {
 	SetSecretsProvided() error
 	SetSecretsUpdated() error
+	SetSecretsDegraded(groups []string) error
+	SetExitCode(code int) error
 	CopyScripts() error
+	RemoveStatus() error
 }
 
 type chmodFunc func(string, os.FileMode) error
 type createFunc func(string) (*os.File, error)
 type openFunc func(string) (*os.File, error)
 type mkdirAllFunc func(string, os.FileMode) error
+type removeFunc func(string) error
 
 type osFuncs struct {
 	chmod    chmodFunc
 	create   createFunc
 	open     openFunc
 	mkdirAll mkdirAllFunc
+	remove   removeFunc
 }
 
 var stdOSFuncs = osFuncs{
@@ -53,6 +75,7 @@ var stdOSFuncs = osFuncs{
 	create:   os.Create,
 	open:     os.Open,
 	mkdirAll: os.MkdirAll,
+	remove:   os.Remove,
 }
 
 // StatusUpdaterFactory defines a function type for creating a StatusUpdater
@@ -64,6 +87,8 @@ func NewStatusUpdater() StatusUpdater {
 	return fileUpdater{
 		providedFile:  "/conjur/status/CONJUR_SECRETS_PROVIDED",
 		updatedFile:   "/conjur/status/CONJUR_SECRETS_UPDATED",
+		degradedFile:  "/conjur/status/CONJUR_SECRETS_DEGRADED",
+		exitCodeFile:  "/conjur/status/CONJUR_SECRETS_EXIT_CODE",
 		scripts:       []string{"conjur-secrets-unchanged.sh"},
 		scriptSrcDir:  "/usr/local/bin",
 		scriptDestDir: "/conjur/status",
@@ -76,6 +101,8 @@ func NewStatusUpdater() StatusUpdater {
 type fileUpdater struct {
 	providedFile  string
 	updatedFile   string
+	degradedFile  string
+	exitCodeFile  string
 	scripts       []string
 	scriptSrcDir  string
 	scriptDestDir string
@@ -99,6 +126,51 @@ func (f fileUpdater) SetSecretsUpdated() error {
 	return f.setStatus(f.updatedFile)
 }
 
+// SetSecretsDegraded records the names of the degraded groups, one per line
+func (f fileUpdater) SetSecretsDegraded(groups []string) error {
+	if len(groups) == 0 {
+		if err := f.os.remove(f.degradedFile); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+
+	file, err := f.os.create(f.degradedFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := io.WriteString(file, strings.Join(groups, "\n")+"\n"); err != nil {
+		return err
+	}
+	return f.os.chmod(file.Name(), statusFileMode)
+}
+
+// SetExitCode records the exit code
+func (f fileUpdater) SetExitCode(code int) error {
+	if err := f.os.mkdirAll(filepath.Dir(f.exitCodeFile), os.ModePerm); err != nil {
+		return err
+	}
+	file, err := f.os.create(f.exitCodeFile)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	if _, err := io.WriteString(file, strconv.Itoa(code)+"\n"); err != nil {
+		return err
+	}
+	return f.os.chmod(file.Name(), statusFileMode)
+}
+
+func (f fileUpdater) RemoveStatus() error {
+	for _, path := range []string{f.providedFile, f.updatedFile, f.degradedFile, f.exitCodeFile} {
+		if err := f.os.remove(path); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
+
 func (f fileUpdater) CopyScripts() error {
 
 	// Create the directory
@@ -50,6 +50,15 @@ func testMkdirAll(injectErr error) mkdirAllFunc {
 	}
 }
 
+func testRemove(injectErr error) removeFunc {
+	return func(path string) error {
+		if injectErr != nil {
+			return injectErr
+		}
+		return stdOSFuncs.remove(path)
+	}
+}
+
 // injectErrs defines a set of errors that should be injected for a given
 // test case.
 type injectErrs struct {
@@ -57,6 +66,7 @@ type injectErrs struct {
 	createErr error
 	openErr   error
 	mkDirErr  error
+	removeErr error
 }
 
 // testOSFuncs generates a set of OS functions for testing, each of which
@@ -67,6 +77,7 @@ func testOSFuncs(inject injectErrs) osFuncs {
 		create:   testCreate(inject.createErr),
 		open:     testOpen(inject.openErr),
 		mkdirAll: testMkdirAll(inject.mkDirErr),
+		remove:   testRemove(inject.removeErr),
 	}
 }
 
@@ -93,6 +104,8 @@ func newTestStatusUpdater(injectErrs injectErrs) (*testStatusUpdater, error) {
 	updater.fileUpdater = fileUpdater{
 		providedFile:  tempFile("CONJUR_SECRETS_PROVIDED"),
 		updatedFile:   tempFile("CONJUR_SECRETS_UPDATED"),
+		degradedFile:  tempFile("CONJUR_SECRETS_DEGRADED"),
+		exitCodeFile:  tempFile("CONJUR_SECRETS_EXIT_CODE"),
 		scripts:       []string{"conjur-secrets-unchanged.sh"},
 		scriptSrcDir:  "../../bin/run-time-scripts",
 		scriptDestDir: tempDir,
@@ -258,3 +271,72 @@ func TestCopyScripts(t *testing.T) {
 		})
 	}
 }
+
+func TestRemoveStatus(t *testing.T) {
+	t.Run("Happy path", func(t *testing.T) {
+		updater, err := newTestStatusUpdater(injectErrs{})
+		assert.NoError(t, err)
+		defer updater.cleanup()
+
+		fileUpdater := updater.fileUpdater
+		assert.NoError(t, fileUpdater.SetSecretsProvided())
+		assert.NoError(t, fileUpdater.SetSecretsUpdated())
+		assert.NoError(t, fileUpdater.RemoveStatus())
+		assert.NoFileExists(t, fileUpdater.providedFile)
+		assert.NoFileExists(t, fileUpdater.updatedFile)
+
+		// Missing status files are ignored
+		assert.NoError(t, fileUpdater.RemoveStatus())
+	})
+
+	t.Run("Error on status file remove", func(t *testing.T) {
+		updater, err := newTestStatusUpdater(injectErrs{removeErr: os.ErrPermission})
+		assert.NoError(t, err)
+		defer updater.cleanup()
+
+		err = updater.fileUpdater.RemoveStatus()
+		assert.True(t, os.IsPermission(err))
+	})
+}
+
+func TestSetSecretsDegraded(t *testing.T) {
+	updater, err := newTestStatusUpdater(injectErrs{})
+	assert.NoError(t, err)
+	defer updater.cleanup()
+
+	fileUpdater := updater.fileUpdater
+	assert.NoError(t, fileUpdater.SetSecretsDegraded([]string{"cache", "db"}))
+	content, err := os.ReadFile(fileUpdater.degradedFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "cache\ndb\n", string(content))
+	info, err := os.Stat(fileUpdater.degradedFile)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(statusFileMode), info.Mode())
+
+	// The record is removed once no groups are degraded
+	assert.NoError(t, fileUpdater.SetSecretsDegraded(nil))
+	assert.NoFileExists(t, fileUpdater.degradedFile)
+	assert.NoError(t, fileUpdater.SetSecretsDegraded(nil))
+}
+
+func TestSetExitCode(t *testing.T) {
+	updater, err := newTestStatusUpdater(injectErrs{})
+	assert.NoError(t, err)
+	defer updater.cleanup()
+
+	fileUpdater := updater.fileUpdater
+	assert.NoError(t, fileUpdater.SetExitCode(4))
+	content, err := os.ReadFile(fileUpdater.exitCodeFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "4\n", string(content))
+
+	// The exit code is removed with the status
+	assert.NoError(t, fileUpdater.RemoveStatus())
+	assert.NoFileExists(t, fileUpdater.exitCodeFile)
+
+	updater, err = newTestStatusUpdater(injectErrs{createErr: os.ErrPermission})
+	assert.NoError(t, err)
+	defer updater.cleanup()
+	err = updater.fileUpdater.SetExitCode(1)
+	assert.True(t, os.IsPermission(err))
+}
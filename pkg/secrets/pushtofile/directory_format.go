@@ -0,0 +1,119 @@
+package pushtofile
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/cyberark/conjur-authn-k8s-client/pkg/log"
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/log/messages"
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/file_templates"
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/utils"
+)
+
+// directoryFileFormat writes each secret of a group to its own file, named
+// after the secret alias, in a directory owned by the group. This is the
+// same layout as a Kubernetes Secret mounted as a volume.
+const directoryFileFormat = "directory"
+
+// validateDirectoryAlias ensures that a secret alias can be used as the name
+// of a file in a secret group directory
+func validateDirectoryAlias(alias string) error {
+	switch {
+	case alias == "" || alias == "." || alias == "..":
+		return fmt.Errorf("secret alias %q is not a valid file name", alias)
+	case strings.ContainsAny(alias, "/\x00"):
+		return fmt.Errorf("secret alias %q is not a valid file name, it must not contain '/'", alias)
+	case strings.HasPrefix(alias, ".."):
+		// Reserved for bookkeeping entries, as in Kubernetes Secret volumes
+		return fmt.Errorf("secret alias %q is not a valid file name, it must not start with '..'", alias)
+	case len(alias) > maxFilenameLen:
+		return fmt.Errorf("secret alias %q is not a valid file name, it must not be longer than %d characters", alias, maxFilenameLen)
+	}
+
+	return nil
+}
+
+// pushToDirectory writes each secret of the group to a file named after its
+// alias, and removes files for aliases that are no longer part of the group.
+// Secret values are written byte-for-byte, so values decoded from base64 can
+// hold binary content. Only the files that changed are written.
+func (sg *SecretGroup) pushToDirectory(
+	depOpenWriteCloser openWriteCloserFunc,
+	checksums *fileChecksums,
+	secrets []*filetemplates.Secret,
+) (bool, error) {
+	for _, s := range secrets {
+		if err := validateDirectoryAlias(s.Alias); err != nil {
+			return false, fmt.Errorf("unable to push secret group %q to directory: %s", sg.Name, err)
+		}
+	}
+
+	updated := false
+	aliases := map[string]struct{}{}
+	for _, s := range secrets {
+		aliases[s.Alias] = struct{}{}
+
+		path := filepath.Join(sg.FilePath, s.Alias)
+		key := sg.Name + "/" + s.Alias
+		checksum, _ := utils.FileChecksum(bytes.NewBufferString(s.Value))
+		if !checksums.changed(sg, key, path, checksum, nil) {
+			continue
+		}
+
+		wc, err := depOpenWriteCloser(path, sg.FilePermissions, sg.FileOwner)
+		if err != nil {
+			return false, err
+		}
+		if _, err = wc.Write([]byte(s.Value)); err != nil {
+			_ = wc.Close()
+			return false, err
+		}
+		if err = wc.Close(); err != nil {
+			return false, fmt.Errorf("unable to write secret file for alias %q of secret group %q: %s", s.Alias, sg.Name, err)
+		}
+		checksums.wrote(key, checksum, nil)
+		updated = true
+	}
+
+	removed, err := removeStaleDirectoryFiles(sg.FilePath, aliases)
+	if err != nil {
+		return false, fmt.Errorf("unable to remove stale secret files of secret group %q: %s", sg.Name, err)
+	}
+	for _, alias := range removed {
+		checksums.forget(sg.Name + "/" + alias)
+	}
+	if !updated && len(removed) == 0 {
+		log.Info(messages.CSPFK018I)
+		return false, nil
+	}
+
+	return true, nil
+}
+
+func removeStaleDirectoryFiles(dir string, aliases map[string]struct{}) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	for _, entry := range entries {
+		name := entry.Name()
+		if _, ok := aliases[name]; ok || entry.IsDir() || strings.HasPrefix(name, "..") {
+			continue
+		}
+
+		err := os.Remove(filepath.Join(dir, name))
+		if err != nil && !os.IsNotExist(err) {
+			return removed, err
+		}
+		removed = append(removed, name)
+	}
+
+	return removed, nil
+}
@@ -0,0 +1,183 @@
+package pushtofile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/file_templates"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateDirectoryAlias(t *testing.T) {
+	testCases := []struct {
+		alias  string
+		errMsg string
+	}{
+		{alias: "password"},
+		{alias: "tls.crt"},
+		{alias: ".hidden"},
+		{alias: "", errMsg: `secret alias "" is not a valid file name`},
+		{alias: ".", errMsg: `secret alias "." is not a valid file name`},
+		{alias: "..", errMsg: `secret alias ".." is not a valid file name`},
+		{alias: "db/password", errMsg: `secret alias "db/password" is not a valid file name, it must not contain '/'`},
+		{alias: "..data", errMsg: `secret alias "..data" is not a valid file name, it must not start with '..'`},
+		{alias: strings.Repeat("a", 256), errMsg: "it must not be longer than 255 characters"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.alias, func(t *testing.T) {
+			err := validateDirectoryAlias(tc.alias)
+			if tc.errMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.ErrorContains(t, err, tc.errMsg)
+			}
+		})
+	}
+}
+
+func TestNewSecretGroups_DirectoryFormat(t *testing.T) {
+	t.Run("default directory path", func(t *testing.T) {
+		groups, errs := NewSecretGroups("/basepath", "", map[string]string{
+			"conjur.org/conjur-secrets.db": `
+- username: db/username
+- password: db/password
+`,
+			"conjur.org/secret-file-format.db": "directory",
+		})
+
+		assert.Len(t, errs, 0)
+		require.Len(t, groups, 1)
+		assert.Equal(t, "/basepath/db", groups[0].FilePath)
+	})
+
+	t.Run("directory path with trailing slash", func(t *testing.T) {
+		groups, errs := NewSecretGroups("/basepath", "", map[string]string{
+			"conjur.org/conjur-secrets.db":     `- password: db/password`,
+			"conjur.org/secret-file-format.db": "directory",
+			"conjur.org/secret-file-path.db":   "credentials/",
+		})
+
+		assert.Len(t, errs, 0)
+		require.Len(t, groups, 1)
+		assert.Equal(t, "/basepath/credentials/db", groups[0].FilePath)
+	})
+
+	t.Run("directory path is the base path", func(t *testing.T) {
+		_, errs := NewSecretGroups("/basepath", "", map[string]string{
+			"conjur.org/conjur-secrets.db":     `- password: db/password`,
+			"conjur.org/secret-file-format.db": "directory",
+			"conjur.org/secret-file-path.db":   ".",
+		})
+
+		require.Len(t, errs, 1)
+		assert.Contains(t, errs[0].Error(), "must be a directory below the secrets base path")
+	})
+
+	t.Run("invalid alias", func(t *testing.T) {
+		_, errs := NewSecretGroups("/basepath", "", map[string]string{
+			"conjur.org/conjur-secrets.db":     `- ..data: db/password`,
+			"conjur.org/secret-file-format.db": "directory",
+		})
+
+		require.Len(t, errs, 1)
+		assert.Contains(t, errs[0].Error(), `unable to process group "db" into file format "directory"`)
+	})
+
+	t.Run("group file inside directory", func(t *testing.T) {
+		_, errs := NewSecretGroups("/basepath", "", map[string]string{
+			"conjur.org/conjur-secrets.db":         `- password: db/password`,
+			"conjur.org/secret-file-format.db":     "directory",
+			"conjur.org/conjur-secrets.config":     `- url: db/url`,
+			"conjur.org/secret-file-path.config":   "db/config.yaml",
+			"conjur.org/secret-file-format.config": "yaml",
+		})
+
+		require.Len(t, errs, 1)
+		assert.EqualError(t, errs[0], `filepath "/basepath/db/config.yaml" for group "config" is inside the directory of group "db"`)
+	})
+}
+
+func TestSecretGroup_PushToFile_Directory(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "db")
+
+	group := SecretGroup{
+		Name:            "db",
+		FilePath:        dir,
+		FileFormat:      "directory",
+		FilePermissions: 0640,
+		SecretSpecs: []filetemplates.SecretSpec{
+			{Alias: "username", Path: "db/username"},
+			{Alias: "password", Path: "db/password"},
+			{Alias: "keystore", Path: "db/keystore", ContentType: "base64"},
+		},
+	}
+	binaryValue := string([]byte{0x00, 0xff, '\n', 0xfe})
+
+	updated, err := group.PushToFile([]*filetemplates.Secret{
+		{Alias: "username", Value: "admin"},
+		{Alias: "password", Value: "secret\n"},
+		{Alias: "keystore", Value: binaryValue},
+	})
+	assert.NoError(t, err)
+	assert.True(t, updated)
+
+	for alias, value := range map[string]string{
+		"username": "admin",
+		"password": "secret\n",
+		"keystore": binaryValue,
+	} {
+		content, err := os.ReadFile(filepath.Join(dir, alias))
+		assert.NoError(t, err)
+		assert.Equal(t, []byte(value), content)
+
+		f, err := os.Stat(filepath.Join(dir, alias))
+		assert.NoError(t, err)
+		assert.EqualValues(t, 0640, f.Mode())
+	}
+
+	// Files for aliases that are no longer in the group are removed
+	group.SecretSpecs = group.SecretSpecs[:2]
+	updated, err = group.PushToFile([]*filetemplates.Secret{
+		{Alias: "username", Value: "admin"},
+		{Alias: "password", Value: "rotated"},
+	})
+	assert.NoError(t, err)
+	assert.True(t, updated)
+	assert.NoFileExists(t, filepath.Join(dir, "keystore"))
+	content, err := os.ReadFile(filepath.Join(dir, "password"))
+	assert.NoError(t, err)
+	assert.Equal(t, "rotated", string(content))
+
+	// Unchanged secret values don't rewrite the files
+	updated, err = group.PushToFile([]*filetemplates.Secret{
+		{Alias: "username", Value: "admin"},
+		{Alias: "password", Value: "rotated"},
+	})
+	assert.NoError(t, err)
+	assert.False(t, updated)
+
+	t.Run("invalid alias from fetch all", func(t *testing.T) {
+		group := SecretGroup{
+			Name:            "all",
+			FilePath:        filepath.Join(t.TempDir(), "all"),
+			FileFormat:      "directory",
+			FilePermissions: 0640,
+			SecretSpecs:     []filetemplates.SecretSpec{{Alias: "*", Path: "*"}},
+		}
+
+		_, err := group.PushToFile([]*filetemplates.Secret{
+			{Alias: "db/password", Value: "secret"},
+		})
+		assert.EqualError(t, err, `unable to push secret group "all" to directory: secret alias "db/password" is not a valid file name, it must not contain '/'`)
+	})
+
+	t.Run("remove group directory", func(t *testing.T) {
+		assert.NoError(t, group.removeFiles())
+		assert.NoDirExists(t, dir)
+		assert.NoError(t, group.removeFiles())
+	})
+}
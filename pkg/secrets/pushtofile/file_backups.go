@@ -0,0 +1,117 @@
+package pushtofile
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/utils"
+)
+
+const secretGroupFileBackupsPrefix = "conjur.org/secret-file-backups."
+
+// maxFileBackups limits the generations of a secret file kept as backups
+const maxFileBackups = 10
+
+// parseFileBackups parses the number of generations of a secret file kept as
+// backups. An empty value is parsed as zero, for which no backups are kept.
+func parseFileBackups(backups string) (int, error) {
+	if len(backups) == 0 {
+		return 0, nil
+	}
+
+	n, err := strconv.Atoi(backups)
+	if err != nil {
+		return 0, fmt.Errorf("backups must be a number, got %q", backups)
+	}
+	if n < 0 || n > maxFileBackups {
+		return 0, fmt.Errorf("backups must be between 0 and %d, got %d", maxFileBackups, n)
+	}
+	return n, nil
+}
+
+// backupPath returns the path of a generation of the secret file of the
+// group, such as "{file}.1" for the previous secret file
+func (sg *SecretGroup) backupPath(generation int) string {
+	return fmt.Sprintf("%s.%d", sg.FilePath, generation)
+}
+
+// backupFile keeps the secret file of the group as its first generation,
+// before it's replaced by a file with checksum. Older generations are
+// shifted, and generations beyond the backups of the group are removed. The
+// secret file isn't kept when it's missing, empty or already has checksum.
+func (sg *SecretGroup) backupFile(checksum utils.Checksum) error {
+	if err := sg.removeBackups(sg.Backups + 1); err != nil {
+		return err
+	}
+	if sg.Backups == 0 {
+		return nil
+	}
+
+	current, info, err := pathChecksum(sg.FilePath)
+	if err != nil {
+		return err
+	}
+	// A new secret file is created empty when it's opened
+	if current == nil || info.Size() == 0 || bytes.Equal(current, checksum) {
+		return nil
+	}
+
+	for generation := sg.Backups - 1; generation > 0; generation-- {
+		err := os.Rename(sg.backupPath(generation), sg.backupPath(generation+1))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return sg.copyFile(sg.FilePath, sg.backupPath(1))
+}
+
+// rollback replaces the secret file of the group with a generation kept by
+// backupFile
+func (sg *SecretGroup) rollback(generation int) error {
+	if sg.Backups == 0 {
+		return fmt.Errorf("secret group %q keeps no backups", sg.Name)
+	}
+	if generation < 1 || generation > sg.Backups {
+		return fmt.Errorf("backup generation of secret group %q must be between 1 and %d, got %d", sg.Name, sg.Backups, generation)
+	}
+
+	path := sg.backupPath(generation)
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		return fmt.Errorf("secret group %q has no backup generation %d", sg.Name, generation)
+	}
+	return sg.copyFile(path, sg.FilePath)
+}
+
+// copyFile copies src to dst, with the permissions and owner of the group
+func (sg *SecretGroup) copyFile(src, dst string) error {
+	r, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer r.Close()
+
+	wc, err := openFileAsWriteCloser(dst, sg.FilePermissions, sg.FileOwner)
+	if err != nil {
+		return err
+	}
+	if _, err = io.Copy(wc, r); err != nil {
+		_ = wc.Close()
+		return err
+	}
+	return wc.Close()
+}
+
+// removeBackups removes the generations of the secret file of the group from
+// the generation from on
+func (sg *SecretGroup) removeBackups(from int) error {
+	for generation := from; generation <= maxFileBackups; generation++ {
+		err := os.Remove(sg.backupPath(generation))
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,190 @@
+package pushtofile
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/file_templates"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSecretGroups_FileBackups(t *testing.T) {
+	testCases := []struct {
+		description string
+		annotations map[string]string
+		backups     int
+		errMsg      string
+	}{
+		{
+			description: "no backups by default",
+			annotations: map[string]string{
+				"conjur.org/conjur-secrets.db": "- db/password",
+			},
+		},
+		{
+			description: "backups",
+			annotations: map[string]string{
+				"conjur.org/conjur-secrets.db":      "- db/password",
+				"conjur.org/secret-file-backups.db": "3",
+			},
+			backups: 3,
+		},
+		{
+			description: "too many backups",
+			annotations: map[string]string{
+				"conjur.org/conjur-secrets.db":      "- db/password",
+				"conjur.org/secret-file-backups.db": "11",
+			},
+			errMsg: `unable to parse annotation "conjur.org/secret-file-backups.db": backups must be between 0 and 10, got 11`,
+		},
+		{
+			description: "invalid backups",
+			annotations: map[string]string{
+				"conjur.org/conjur-secrets.db":      "- db/password",
+				"conjur.org/secret-file-backups.db": "all",
+			},
+			errMsg: `unable to parse annotation "conjur.org/secret-file-backups.db": backups must be a number, got "all"`,
+		},
+		{
+			description: "directory format",
+			annotations: map[string]string{
+				"conjur.org/conjur-secrets.db":      "- db/password",
+				"conjur.org/secret-file-format.db":  "directory",
+				"conjur.org/secret-file-backups.db": "1",
+			},
+			errMsg: `unable to process group "db" into file format "directory": backups are not supported`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			groups, errs := NewSecretGroups("/basepath", "", tc.annotations)
+			if tc.errMsg != "" {
+				require.Len(t, errs, 1)
+				assert.EqualError(t, errs[0], tc.errMsg)
+				return
+			}
+			require.Len(t, errs, 0)
+			require.Len(t, groups, 1)
+			assert.Equal(t, tc.backups, groups[0].Backups)
+		})
+	}
+}
+
+func TestSecretGroup_FileBackups(t *testing.T) {
+	newGroup := func(t *testing.T, format string, backups int) *SecretGroup {
+		return &SecretGroup{
+			Name:            "db",
+			FilePath:        filepath.Join(t.TempDir(), "db"),
+			FileFormat:      format,
+			FilePermissions: 0600,
+			Backups:         backups,
+			SecretSpecs:     []filetemplates.SecretSpec{{Alias: "password", Path: "db/password"}},
+		}
+	}
+	push := func(t *testing.T, group *SecretGroup, checksums *fileChecksums, value string) bool {
+		updated, err := group.pushToFileWithDeps(
+			openFileAsWriteCloser,
+			pushToWriter,
+			checksums,
+			[]*filetemplates.Secret{{Alias: "password", Value: value}},
+		)
+		require.NoError(t, err)
+		return updated
+	}
+	assertContent := func(t *testing.T, path, expected string) {
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, expected, string(content))
+	}
+
+	t.Run("generations are shifted", func(t *testing.T) {
+		group := newGroup(t, "dotenv", 2)
+		checksums := newFileChecksums()
+		for _, value := range []string{"v1", "v2", "v3", "v4"} {
+			assert.True(t, push(t, group, checksums, value))
+		}
+		// Unchanged files aren't kept as backups
+		assert.False(t, push(t, group, checksums, "v4"))
+		checksums.rewriteGroup(group.Name)
+		assert.True(t, push(t, group, checksums, "v4"))
+
+		assertContent(t, group.FilePath, "password='v4'")
+		assertContent(t, group.backupPath(1), "password='v3'")
+		assertContent(t, group.backupPath(2), "password='v2'")
+		assert.NoFileExists(t, group.backupPath(3))
+
+		info, err := os.Stat(group.backupPath(1))
+		require.NoError(t, err)
+		assert.Equal(t, group.FilePermissions, info.Mode().Perm())
+	})
+
+	t.Run("generations beyond the backups are removed", func(t *testing.T) {
+		group := newGroup(t, "dotenv", 2)
+		checksums := newFileChecksums()
+		for _, value := range []string{"v1", "v2", "v3"} {
+			push(t, group, checksums, value)
+		}
+
+		group.Backups = 0
+		assert.True(t, push(t, group, checksums, "v4"))
+		assert.NoFileExists(t, group.backupPath(1))
+		assert.NoFileExists(t, group.backupPath(2))
+	})
+
+	t.Run("keystores are kept", func(t *testing.T) {
+		key, cert := newTestKeyAndCert(t)
+		group := newGroup(t, "jks", 1)
+		group.SecretSpecs = keystoreSpecs("key", "cert", "password")
+		checksums := newFileChecksums()
+		push := func(password string) {
+			_, err := group.pushToFileWithDeps(
+				openFileAsWriteCloser,
+				pushToWriter,
+				checksums,
+				[]*filetemplates.Secret{
+					{Alias: "key", Value: key},
+					{Alias: "cert", Value: cert},
+					{Alias: "password", Value: password},
+				},
+			)
+			require.NoError(t, err)
+		}
+
+		push("changeit")
+		first, err := os.ReadFile(group.FilePath)
+		require.NoError(t, err)
+		push("changed")
+		assertContent(t, group.backupPath(1), string(first))
+	})
+
+	t.Run("rollback", func(t *testing.T) {
+		group := newGroup(t, "dotenv", 2)
+		checksums := newFileChecksums()
+		push(t, group, checksums, "v1")
+		assert.EqualError(t, group.rollback(1), `secret group "db" has no backup generation 1`)
+
+		push(t, group, checksums, "v2")
+		require.NoError(t, group.rollback(1))
+		assertContent(t, group.FilePath, "password='v1'")
+		assertContent(t, group.backupPath(1), "password='v1'")
+
+		assert.EqualError(t, group.rollback(0), `backup generation of secret group "db" must be between 1 and 2, got 0`)
+		group.Backups = 0
+		assert.EqualError(t, group.rollback(1), `secret group "db" keeps no backups`)
+	})
+
+	t.Run("backups are removed with the secret file", func(t *testing.T) {
+		group := newGroup(t, "dotenv", 2)
+		checksums := newFileChecksums()
+		push(t, group, checksums, "v1")
+		push(t, group, checksums, "v2")
+		require.FileExists(t, group.backupPath(1))
+
+		require.NoError(t, group.removeFiles())
+		assert.NoFileExists(t, group.FilePath)
+		assert.NoFileExists(t, group.backupPath(1))
+	})
+}
@@ -0,0 +1,220 @@
+package pushtofile
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"io"
+	"maps"
+	"os"
+	"strings"
+
+	"github.com/cyberark/conjur-authn-k8s-client/pkg/log"
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/log/messages"
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/utils"
+)
+
+// writtenFile is the checksum of a secret file written by the Secrets
+// Provider. Keystores are salted on every build, so their changes are
+// detected on the checksum of the secret values they are built from.
+type writtenFile struct {
+	content utils.Checksum
+	inputs  utils.Checksum
+}
+
+// fileChecksums detects changes of the secret files of a provider. Secret
+// files are compared to the files on disk, so that unchanged files aren't
+// written again after a restart. The checksums of written files are kept to
+// detect files modified or removed outside of the Secrets Provider, which
+// are restored. A nil *fileChecksums detects no changes, so every file is
+// written.
+type fileChecksums struct {
+	// written maps the key of a secret file, which is the name of its group
+	// or "{group}/{alias}" for the files of a directory, to its checksum
+	written map[string]writtenFile
+	// rewrite holds the names of the groups written again on the next
+	// refresh, even when their files are unchanged
+	rewrite map[string]struct{}
+	// tampered counts the secret files that were restored
+	tampered int
+}
+
+func newFileChecksums() *fileChecksums {
+	return &fileChecksums{
+		written: map[string]writtenFile{},
+		rewrite: map[string]struct{}{},
+	}
+}
+
+// changed reports whether the secret file at path of the group is written
+// with content, or built from inputs for keystores. Files modified or
+// removed since they were last written are restored, and files with other
+// permissions or another owner than those of the group are written again.
+func (c *fileChecksums) changed(sg *SecretGroup, key, path string, content, inputs utils.Checksum) bool {
+	if c == nil {
+		return true
+	}
+	if _, ok := c.rewrite[sg.Name]; ok {
+		return true
+	}
+
+	onDisk, info, err := pathChecksum(path)
+	if err != nil {
+		return true
+	}
+	last, known := c.written[key]
+	if known && !bytes.Equal(onDisk, last.content) {
+		c.tampered++
+		log.Warn(messages.CSPFK107E, path, sg.Name, c.tampered)
+		return true
+	}
+	if info == nil || info.Mode().Perm() != sg.FilePermissions || !sg.FileOwner.Owns(info) {
+		return true
+	}
+
+	if inputs != nil {
+		return !known || !bytes.Equal(inputs, last.inputs)
+	}
+	if !bytes.Equal(onDisk, content) {
+		return true
+	}
+	// An unchanged file is known from now on, such as after a restart
+	c.written[key] = writtenFile{content: content}
+	return false
+}
+
+// wrote records the checksum of a secret file written with content
+func (c *fileChecksums) wrote(key string, content, inputs utils.Checksum) {
+	if c == nil {
+		return
+	}
+	c.written[key] = writtenFile{content: content, inputs: inputs}
+}
+
+// forget forgets a secret file that was removed
+func (c *fileChecksums) forget(key string) {
+	if c == nil {
+		return
+	}
+	delete(c.written, key)
+}
+
+// rewriteGroup makes the files of the group be written again on the next
+// refresh, such as when its configuration changed
+func (c *fileChecksums) rewriteGroup(group string) {
+	c.rewrite[group] = struct{}{}
+	c.rewrite[group+secretFilesSuffix] = struct{}{}
+}
+
+// rewritten records that the files of the group were written
+func (c *fileChecksums) rewritten(group string) {
+	delete(c.rewrite, group)
+	delete(c.rewrite, group+secretFilesSuffix)
+}
+
+// forgetGroup forgets the files of a group whose files were removed
+func (c *fileChecksums) forgetGroup(group string) {
+	for key := range c.written {
+		name, _, _ := strings.Cut(key, "/")
+		if name == group || name == group+secretFilesSuffix {
+			delete(c.written, key)
+		}
+	}
+	c.rewritten(group)
+}
+
+// restoreGroup restores the checksums of the files of a group copied by
+// clone, when the files of the group were restored
+func (c *fileChecksums) restoreGroup(prev *fileChecksums, group string) {
+	c.forgetGroup(group)
+	for key, file := range prev.written {
+		name, _, _ := strings.Cut(key, "/")
+		if name == group || name == group+secretFilesSuffix {
+			c.written[key] = file
+		}
+	}
+	for _, key := range []string{group, group + secretFilesSuffix} {
+		if _, ok := prev.rewrite[key]; ok {
+			c.rewrite[key] = struct{}{}
+		}
+	}
+}
+
+// forgetAll forgets the files of every group
+func (c *fileChecksums) forgetAll() {
+	c.written = map[string]writtenFile{}
+	c.rewrite = map[string]struct{}{}
+}
+
+// clone copies the checksums, so they can be restored when a refresh fails
+func (c *fileChecksums) clone() *fileChecksums {
+	return &fileChecksums{
+		written:  maps.Clone(c.written),
+		rewrite:  maps.Clone(c.rewrite),
+		tampered: c.tampered,
+	}
+}
+
+// restore restores checksums copied by clone. Restored files are still
+// counted.
+func (c *fileChecksums) restore(prev *fileChecksums) {
+	c.written = prev.written
+	c.rewrite = prev.rewrite
+}
+
+// pathChecksum returns the sha256 checksum and the info of the file at path,
+// or nil when it doesn't exist
+func pathChecksum(path string) (utils.Checksum, os.FileInfo, error) {
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil, nil, nil
+	} else if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, nil, err
+	}
+	hash := sha256.New()
+	if _, err := io.Copy(hash, f); err != nil {
+		return nil, nil, err
+	}
+	return hash.Sum(nil), info, nil
+}
+
+// contentChecker is implemented by writers of secret files that detect
+// whether content changes the file
+type contentChecker interface {
+	contentChanged(content []byte) bool
+}
+
+// checksumWriter writes a secret file of a group, detecting whether its
+// content changed with checksums
+type checksumWriter struct {
+	io.WriteCloser
+	checksums *fileChecksums
+	group     *SecretGroup
+	checksum  utils.Checksum
+}
+
+// contentChanged also keeps the secret file as a backup when it changes,
+// since it's replaced when the writer is closed
+func (w *checksumWriter) contentChanged(content []byte) bool {
+	w.checksum, _ = utils.FileChecksum(bytes.NewBuffer(content))
+	if !w.checksums.changed(w.group, w.group.Name, w.group.FilePath, w.checksum, nil) {
+		return false
+	}
+	if err := w.group.backupFile(w.checksum); err != nil {
+		log.Error(messages.CSPFK108E, w.group.Name, err)
+	}
+	return true
+}
+
+func (w *checksumWriter) Close() error {
+	err := w.WriteCloser.Close()
+	if err == nil && w.checksum != nil {
+		w.checksums.wrote(w.group.Name, w.checksum, nil)
+	}
+	return err
+}
@@ -0,0 +1,123 @@
+package pushtofile
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cyberark/conjur-authn-k8s-client/pkg/log"
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/file_templates"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileChecksums(t *testing.T) {
+	secrets := []*filetemplates.Secret{{Alias: "password", Value: "secret"}}
+	newGroup := func(t *testing.T, format string) *SecretGroup {
+		group := &SecretGroup{
+			Name:            "db",
+			FilePath:        filepath.Join(t.TempDir(), "db"),
+			FileFormat:      format,
+			FilePermissions: 0644,
+			SecretSpecs:     []filetemplates.SecretSpec{{Alias: "password", Path: "db/password"}},
+		}
+		if format == "template" {
+			group.FileTemplate = `{{ secret "password" }}`
+		}
+		return group
+	}
+	push := func(t *testing.T, group *SecretGroup, checksums *fileChecksums) bool {
+		updated, err := group.pushToFileWithDeps(openFileAsWriteCloser, pushToWriter, checksums, secrets)
+		require.NoError(t, err)
+		return updated
+	}
+	captureWarnings := func(t *testing.T) *bytes.Buffer {
+		buf := &bytes.Buffer{}
+		log.WarnLogger.SetOutput(buf)
+		t.Cleanup(func() { log.WarnLogger.SetOutput(os.Stdout) })
+		return buf
+	}
+
+	t.Run("unchanged file isn't written again after a restart", func(t *testing.T) {
+		group := newGroup(t, "template")
+		require.NoError(t, os.WriteFile(group.FilePath, []byte("secret"), 0644))
+		info, err := os.Stat(group.FilePath)
+		require.NoError(t, err)
+
+		assert.False(t, push(t, group, newFileChecksums()))
+		after, err := os.Stat(group.FilePath)
+		require.NoError(t, err)
+		assert.True(t, os.SameFile(info, after))
+	})
+
+	t.Run("modified file is restored", func(t *testing.T) {
+		warnings := captureWarnings(t)
+		group := newGroup(t, "template")
+		checksums := newFileChecksums()
+		assert.True(t, push(t, group, checksums))
+
+		require.NoError(t, os.WriteFile(group.FilePath, []byte("modified"), 0644))
+		assert.True(t, push(t, group, checksums))
+		content, err := os.ReadFile(group.FilePath)
+		require.NoError(t, err)
+		assert.Equal(t, "secret", string(content))
+		assert.Equal(t, 1, checksums.tampered)
+		assert.Contains(t, warnings.String(), "CSPFK107E")
+
+		assert.False(t, push(t, group, checksums))
+		assert.Equal(t, 1, checksums.tampered)
+	})
+
+	t.Run("removed file is restored", func(t *testing.T) {
+		captureWarnings(t)
+		group := newGroup(t, "template")
+		checksums := newFileChecksums()
+		assert.True(t, push(t, group, checksums))
+
+		require.NoError(t, os.Remove(group.FilePath))
+		assert.True(t, push(t, group, checksums))
+		assert.FileExists(t, group.FilePath)
+		assert.Equal(t, 1, checksums.tampered)
+	})
+
+	t.Run("modified directory file is restored", func(t *testing.T) {
+		captureWarnings(t)
+		group := newGroup(t, "directory")
+		checksums := newFileChecksums()
+		assert.True(t, push(t, group, checksums))
+
+		path := filepath.Join(group.FilePath, "password")
+		require.NoError(t, os.WriteFile(path, []byte("modified"), 0644))
+		assert.True(t, push(t, group, checksums))
+		content, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Equal(t, "secret", string(content))
+		assert.Equal(t, 1, checksums.tampered)
+	})
+
+	t.Run("group to rewrite is written until it's rewritten", func(t *testing.T) {
+		group := newGroup(t, "template")
+		checksums := newFileChecksums()
+		assert.True(t, push(t, group, checksums))
+
+		checksums.rewriteGroup(group.Name)
+		assert.True(t, push(t, group, checksums))
+		assert.True(t, push(t, group, checksums))
+
+		checksums.rewritten(group.Name)
+		assert.False(t, push(t, group, checksums))
+	})
+
+	t.Run("forgotten group isn't restored", func(t *testing.T) {
+		captureWarnings(t)
+		group := newGroup(t, "template")
+		checksums := newFileChecksums()
+		assert.True(t, push(t, group, checksums))
+
+		checksums.forgetGroup(group.Name)
+		require.NoError(t, os.Remove(group.FilePath))
+		assert.True(t, push(t, group, checksums))
+		assert.Equal(t, 0, checksums.tampered)
+	})
+}
@@ -0,0 +1,129 @@
+package pushtofile
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/file_templates"
+)
+
+// fileNestingSeparators maps the values of the file nesting annotation to the
+// separator that splits secret aliases into nested keys
+var fileNestingSeparators = map[string]string{
+	"dot":   ".",
+	"slash": "/",
+}
+
+// secretTree holds the secrets of a group as a mapping, with keys in the
+// order they were added. Each key maps to either a secret or a nested
+// secretTree.
+type secretTree struct {
+	keys     []string
+	secrets  map[string]*filetemplates.Secret
+	children map[string]*secretTree
+	// aliases maps each key to the first alias that added it, for errors
+	aliases map[string]string
+}
+
+func newEmptySecretTree() *secretTree {
+	return &secretTree{
+		secrets:  map[string]*filetemplates.Secret{},
+		children: map[string]*secretTree{},
+		aliases:  map[string]string{},
+	}
+}
+
+// newSecretTree builds the mapping for the secrets of a group. Aliases are
+// split into nested keys on the separator. With an empty separator, each
+// alias is a top-level key.
+func newSecretTree(secrets []*filetemplates.Secret, separator string) (*secretTree, error) {
+	tree := newEmptySecretTree()
+	for _, s := range secrets {
+		if err := tree.add(s, separator); err != nil {
+			return nil, err
+		}
+	}
+	return tree, nil
+}
+
+func (t *secretTree) add(secret *filetemplates.Secret, separator string) error {
+	keys := []string{secret.Alias}
+	if separator != "" {
+		keys = strings.Split(secret.Alias, separator)
+		for _, key := range keys {
+			if key == "" {
+				return fmt.Errorf("secret alias %q has an empty key when nested on %q", secret.Alias, separator)
+			}
+		}
+	}
+
+	node := t
+	for i, key := range keys {
+		isLeaf := i == len(keys)-1
+		_, isSecret := node.secrets[key]
+		child, isChild := node.children[key]
+		if isLeaf && isSecret {
+			if separator == "" {
+				// Duplicate flat aliases are allowed, the last value wins
+				// as it does for parsers reading duplicate keys
+				node.secrets[key] = secret
+				return nil
+			}
+			return fmt.Errorf("secret aliases %q and %q are both nested as %q", node.aliases[key], secret.Alias, secret.Alias)
+		}
+		if isSecret || (isLeaf && isChild) {
+			return fmt.Errorf(
+				"secret aliases %q and %q conflict, %q can't hold both a value and nested keys",
+				node.aliases[key], secret.Alias, strings.Join(keys[:i+1], separator),
+			)
+		}
+
+		if !isChild {
+			node.keys = append(node.keys, key)
+			node.aliases[key] = secret.Alias
+		}
+		if isLeaf {
+			node.secrets[key] = secret
+			return nil
+		}
+		if !isChild {
+			child = newEmptySecretTree()
+			node.children[key] = child
+		}
+		node = child
+	}
+	return nil
+}
+
+// validateFileNesting ensures that the file nesting of a group is supported
+// by its file format, and that the secret spec aliases can be nested without
+// conflicts. In "Fetch All" mode, aliases are only known once secrets are
+// fetched, so conflicts are reported when the file is written. The same goes
+// for the keys of JSON secrets without a field.
+func validateFileNesting(
+	fileNesting string,
+	fileFormat string,
+	fileTemplate string,
+	secretSpecs []filetemplates.SecretSpec,
+) error {
+	separator, ok := fileNestingSeparators[fileNesting]
+	if !ok {
+		return fmt.Errorf(`invalid file nesting %q, must be "dot" or "slash"`, fileNesting)
+	}
+	if len(fileTemplate) > 0 || !standardTemplates[fileFormat].nestable {
+		return fmt.Errorf(`file nesting is only supported for the "yaml" and "json" file formats`)
+	}
+
+	var secrets []*filetemplates.Secret
+	for _, s := range secretSpecs {
+		if s.Path == "*" {
+			return nil
+		}
+		if s.ExpandsJSON() {
+			continue
+		}
+		secrets = append(secrets, &filetemplates.Secret{Alias: s.Alias})
+	}
+	_, err := newSecretTree(secrets, separator)
+	return err
+}
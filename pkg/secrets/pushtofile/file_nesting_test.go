@@ -0,0 +1,197 @@
+package pushtofile
+
+import (
+	"testing"
+
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/file_templates"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var nestedSecrets = []*filetemplates.Secret{
+	{Alias: "db.primary.password", Value: "primary-password"},
+	{Alias: "db.primary.url", Value: "postgresql://primary:5432"},
+	{Alias: "api_key", Value: "key"},
+	{Alias: "db.replica.url", Value: "postgresql://replica:5432"},
+}
+
+func TestEncodeNested(t *testing.T) {
+	t.Run("json", func(t *testing.T) {
+		content, err := encodeJSON(nestedSecrets, ".")
+		assert.NoError(t, err)
+		assert.Equal(t,
+			`{"db":{"primary":{"password":"primary-password","url":"postgresql://primary:5432"},"replica":{"url":"postgresql://replica:5432"}},"api_key":"key"}`,
+			string(content),
+		)
+	})
+
+	t.Run("yaml", func(t *testing.T) {
+		content, err := encodeYAML(nestedSecrets, ".")
+		assert.NoError(t, err)
+		assert.Equal(t, `"db":
+  "primary":
+    "password": "primary-password"
+    "url": "postgresql://primary:5432"
+  "replica":
+    "url": "postgresql://replica:5432"
+"api_key": "key"`,
+			string(content),
+		)
+	})
+
+	t.Run("slash separator", func(t *testing.T) {
+		content, err := encodeJSON([]*filetemplates.Secret{
+			{Alias: "prod/db/password", Value: "secret"},
+			{Alias: "prod/db.url", Value: "url"},
+		}, "/")
+		assert.NoError(t, err)
+		assert.Equal(t, `{"prod":{"db":{"password":"secret"},"db.url":"url"}}`, string(content))
+	})
+
+	t.Run("flat keys without separator", func(t *testing.T) {
+		content, err := encodeJSON(nestedSecrets[:2], "")
+		assert.NoError(t, err)
+		assert.Equal(t, `{"db.primary.password":"primary-password","db.primary.url":"postgresql://primary:5432"}`, string(content))
+	})
+}
+
+func TestNewSecretTree(t *testing.T) {
+	testCases := []struct {
+		description string
+		aliases     []string
+		separator   string
+		errMsg      string
+	}{
+		{
+			description: "siblings",
+			aliases:     []string{"a.b", "a.c", "d"},
+			separator:   ".",
+		},
+		{
+			description: "value then nested keys",
+			aliases:     []string{"a", "a.b"},
+			separator:   ".",
+			errMsg:      `secret aliases "a" and "a.b" conflict, "a" can't hold both a value and nested keys`,
+		},
+		{
+			description: "nested keys then value",
+			aliases:     []string{"a.b.c", "a.b"},
+			separator:   ".",
+			errMsg:      `secret aliases "a.b.c" and "a.b" conflict, "a.b" can't hold both a value and nested keys`,
+		},
+		{
+			description: "duplicate nested keys",
+			aliases:     []string{"a/b", "c", "a/b"},
+			separator:   "/",
+			errMsg:      `secret aliases "a/b" and "a/b" are both nested as "a/b"`,
+		},
+		{
+			description: "duplicate flat keys",
+			aliases:     []string{"a", "a"},
+			separator:   "",
+		},
+		{
+			description: "empty key",
+			aliases:     []string{"a..b"},
+			separator:   ".",
+			errMsg:      `secret alias "a..b" has an empty key when nested on "."`,
+		},
+		{
+			description: "leading separator",
+			aliases:     []string{"/a"},
+			separator:   "/",
+			errMsg:      `secret alias "/a" has an empty key when nested on "/"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			var secrets []*filetemplates.Secret
+			for _, alias := range tc.aliases {
+				secrets = append(secrets, &filetemplates.Secret{Alias: alias})
+			}
+
+			_, err := newSecretTree(secrets, tc.separator)
+			if tc.errMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.errMsg)
+			}
+		})
+	}
+}
+
+func TestNewSecretGroups_FileNesting(t *testing.T) {
+	t.Run("nested json", func(t *testing.T) {
+		groups, errs := NewSecretGroups("/basepath", "", map[string]string{
+			"conjur.org/conjur-secrets.app": `
+- db.primary.url: db/url
+- db.primary.password: db/password
+`,
+			"conjur.org/secret-file-format.app":  "json",
+			"conjur.org/secret-file-nesting.app": "dot",
+		})
+
+		assert.Len(t, errs, 0)
+		require.Len(t, groups, 1)
+		assert.Equal(t, "dot", groups[0].FileNesting)
+	})
+
+	for _, tc := range []struct {
+		description string
+		annotations map[string]string
+		errMsg      string
+	}{
+		{
+			description: "conflicting aliases",
+			annotations: map[string]string{
+				"conjur.org/conjur-secrets.app": `
+- db: db/name
+- db.password: db/password
+`,
+				"conjur.org/secret-file-nesting.app": "dot",
+			},
+			errMsg: `unable to process group "app" into file format "yaml": secret aliases "db" and "db.password" conflict, "db" can't hold both a value and nested keys`,
+		},
+		{
+			description: "unsupported file format",
+			annotations: map[string]string{
+				"conjur.org/conjur-secrets.app":      `- password: db/password`,
+				"conjur.org/secret-file-format.app":  "dotenv",
+				"conjur.org/secret-file-nesting.app": "dot",
+			},
+			errMsg: `unable to process group "app" into file format "dotenv": file nesting is only supported for the "yaml" and "json" file formats`,
+		},
+		{
+			description: "invalid nesting",
+			annotations: map[string]string{
+				"conjur.org/conjur-secrets.app":      `- password: db/password`,
+				"conjur.org/secret-file-nesting.app": "colon",
+			},
+			errMsg: `unable to process group "app" into file format "yaml": invalid file nesting "colon", must be "dot" or "slash"`,
+		},
+	} {
+		t.Run(tc.description, func(t *testing.T) {
+			_, errs := NewSecretGroups("/basepath", "", tc.annotations)
+			require.Len(t, errs, 1)
+			assert.EqualError(t, errs[0], tc.errMsg)
+		})
+	}
+}
+
+func TestSecretGroup_PushToFile_FetchAllConflict(t *testing.T) {
+	group := SecretGroup{
+		Name:            "all",
+		FilePath:        t.TempDir() + "/all.json",
+		FileFormat:      "json",
+		FileNesting:     "slash",
+		FilePermissions: 0640,
+		SecretSpecs:     []filetemplates.SecretSpec{{Alias: "*", Path: "*"}},
+	}
+
+	_, err := group.PushToFile([]*filetemplates.Secret{
+		{Alias: "prod/db", Value: "name"},
+		{Alias: "prod/db/password", Value: "secret"},
+	})
+	assert.EqualError(t, err, `failed to encode secret group "all" into file format "json": secret aliases "prod/db" and "prod/db/password" conflict, "prod/db" can't hold both a value and nested keys`)
+}
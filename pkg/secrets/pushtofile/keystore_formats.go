@@ -0,0 +1,99 @@
+package pushtofile
+
+import (
+	"fmt"
+
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/keystore"
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/file_templates"
+)
+
+// Secret spec aliases for keystore file formats. Each alias names the role
+// its secret value plays in the keystore.
+const (
+	keystoreKeyAlias      = "key"
+	keystoreCertAlias     = "cert"
+	keystoreCAAlias       = "ca"
+	keystorePasswordAlias = "password"
+)
+
+type keystoreFormat struct {
+	fileExtension string
+	requiresKey   bool
+	encode        func(material keystore.Material, password string) ([]byte, error)
+}
+
+// keystoreFormats are the binary file formats that are built from PEM key and
+// certificate material, rather than rendered from a template
+var keystoreFormats = map[string]keystoreFormat{
+	"pkcs12": {fileExtension: "p12", requiresKey: true, encode: keystore.EncodePKCS12},
+	"jks":    {fileExtension: "jks", requiresKey: false, encode: keystore.EncodeJKS},
+}
+
+func isKeystoreFormat(fileFormat string) bool {
+	_, ok := keystoreFormats[fileFormat]
+	return ok
+}
+
+// validateKeystoreSpecs ensures that the secret spec aliases of a group map
+// to keystore roles, and that the roles required by the file format are
+// present. A "jks" group without a "key" produces a truststore.
+func validateKeystoreSpecs(
+	fileFormat string,
+	secretSpecs []filetemplates.SecretSpec,
+) error {
+	format, ok := keystoreFormats[fileFormat]
+	if !ok {
+		return fmt.Errorf(`unrecognized keystore file format, "%s"`, fileFormat)
+	}
+
+	roles := map[string]bool{}
+	for _, s := range secretSpecs {
+		switch s.Alias {
+		case keystoreKeyAlias, keystoreCertAlias, keystoreCAAlias, keystorePasswordAlias:
+			roles[s.Alias] = true
+		default:
+			return fmt.Errorf(
+				"secret spec alias %q is not a keystore role, must be one of %q, %q, %q or %q",
+				s.Alias, keystoreKeyAlias, keystoreCertAlias, keystoreCAAlias, keystorePasswordAlias,
+			)
+		}
+	}
+
+	switch {
+	case !roles[keystorePasswordAlias]:
+		return fmt.Errorf("secret spec with alias %q is required for the store password", keystorePasswordAlias)
+	case format.requiresKey && !roles[keystoreKeyAlias]:
+		return fmt.Errorf("secret spec with alias %q is required", keystoreKeyAlias)
+	case roles[keystoreKeyAlias] && !roles[keystoreCertAlias]:
+		return fmt.Errorf("secret spec with alias %q is required with alias %q", keystoreCertAlias, keystoreKeyAlias)
+	case !roles[keystoreCertAlias] && !roles[keystoreCAAlias]:
+		return fmt.Errorf("secret spec with alias %q or %q is required", keystoreCertAlias, keystoreCAAlias)
+	}
+
+	return nil
+}
+
+// renderKeystore builds the binary content of a keystore file from the
+// secret values of a group. Errors never include secret values.
+func renderKeystore(
+	groupName string,
+	fileFormat string,
+	secrets []*filetemplates.Secret,
+) ([]byte, error) {
+	values := map[string]string{}
+	for _, s := range secrets {
+		values[s.Alias] = s.Value
+	}
+
+	material, err := keystore.NewMaterial(
+		groupName,
+		values[keystoreKeyAlias],
+		values[keystoreCertAlias],
+		values[keystoreCAAlias],
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return keystoreFormats[fileFormat].encode(material, values[keystorePasswordAlias])
+}
@@ -0,0 +1,214 @@
+package pushtofile
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/file_templates"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func newTestKeyAndCert(t *testing.T) (string, string) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "app.example.com"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	return string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})),
+		string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: certDER}))
+}
+
+func keystoreSpecs(aliases ...string) []filetemplates.SecretSpec {
+	var specs []filetemplates.SecretSpec
+	for _, alias := range aliases {
+		specs = append(specs, filetemplates.SecretSpec{Alias: alias, Path: "path/to/" + alias})
+	}
+	return specs
+}
+
+func TestValidateKeystoreSpecs(t *testing.T) {
+	testCases := []struct {
+		description string
+		fileFormat  string
+		aliases     []string
+		errMsg      string
+	}{
+		{
+			description: "pkcs12 keystore",
+			fileFormat:  "pkcs12",
+			aliases:     []string{"key", "cert", "ca", "password"},
+		},
+		{
+			description: "jks keystore",
+			fileFormat:  "jks",
+			aliases:     []string{"key", "cert", "password"},
+		},
+		{
+			description: "jks truststore",
+			fileFormat:  "jks",
+			aliases:     []string{"ca", "password"},
+		},
+		{
+			description: "unknown role",
+			fileFormat:  "jks",
+			aliases:     []string{"ca", "password", "chain"},
+			errMsg:      `secret spec alias "chain" is not a keystore role, must be one of "key", "cert", "ca" or "password"`,
+		},
+		{
+			description: "missing password",
+			fileFormat:  "jks",
+			aliases:     []string{"ca"},
+			errMsg:      `secret spec with alias "password" is required for the store password`,
+		},
+		{
+			description: "pkcs12 truststore",
+			fileFormat:  "pkcs12",
+			aliases:     []string{"ca", "password"},
+			errMsg:      `secret spec with alias "key" is required`,
+		},
+		{
+			description: "key without cert",
+			fileFormat:  "jks",
+			aliases:     []string{"key", "ca", "password"},
+			errMsg:      `secret spec with alias "cert" is required with alias "key"`,
+		},
+		{
+			description: "no certificates",
+			fileFormat:  "jks",
+			aliases:     []string{"password"},
+			errMsg:      `secret spec with alias "cert" or "ca" is required`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			err := validateKeystoreSpecs(tc.fileFormat, keystoreSpecs(tc.aliases...))
+			if tc.errMsg == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.errMsg)
+			}
+		})
+	}
+}
+
+func TestNewSecretGroups_KeystoreFormats(t *testing.T) {
+	t.Run("default keystore filenames", func(t *testing.T) {
+		groups, errs := NewSecretGroups("/basepath", "", map[string]string{
+			"conjur.org/conjur-secrets.keystore": `
+- key: tls/key
+- cert: tls/cert
+- password: tls/keystore-password
+`,
+			"conjur.org/secret-file-format.keystore": "pkcs12",
+			"conjur.org/conjur-secrets.truststore": `
+- ca: tls/ca
+- password: tls/truststore-password
+`,
+			"conjur.org/secret-file-format.truststore": "jks",
+		})
+
+		assert.Len(t, errs, 0)
+		require.Len(t, groups, 2)
+		assert.Equal(t, "/basepath/keystore.p12", groups[0].FilePath)
+		assert.Equal(t, "/basepath/truststore.jks", groups[1].FilePath)
+	})
+
+	t.Run("invalid keystore roles", func(t *testing.T) {
+		_, errs := NewSecretGroups("/basepath", "", map[string]string{
+			"conjur.org/conjur-secrets.keystore": `
+- cert: tls/cert
+- password: tls/keystore-password
+`,
+			"conjur.org/secret-file-format.keystore": "pkcs12",
+		})
+
+		require.Len(t, errs, 1)
+		assert.Contains(t, errs[0].Error(), `unable to process group "keystore" into file format "pkcs12"`)
+	})
+}
+
+func TestSecretGroup_PushToFile_Keystore(t *testing.T) {
+	dir := t.TempDir()
+	key, cert := newTestKeyAndCert(t)
+
+	group := SecretGroup{
+		Name:            "keystore",
+		FilePath:        path.Join(dir, "keystore.jks"),
+		FileFormat:      "jks",
+		FilePermissions: 0600,
+		SecretSpecs:     keystoreSpecs("key", "cert", "password"),
+	}
+	secrets := []*filetemplates.Secret{
+		{Alias: "key", Value: key},
+		{Alias: "cert", Value: cert},
+		{Alias: "password", Value: "changeit"},
+	}
+
+	// Keystores are compared to the secret values they were last built from
+	checksums := newFileChecksums()
+	push := func(secrets []*filetemplates.Secret) (bool, error) {
+		return group.pushToFileWithDeps(openFileAsWriteCloser, pushToWriter, checksums, secrets)
+	}
+
+	updated, err := push(secrets)
+	assert.NoError(t, err)
+	assert.True(t, updated)
+
+	content, err := os.ReadFile(group.FilePath)
+	assert.NoError(t, err)
+	assert.True(t, bytes.HasPrefix(content, []byte{0xfe, 0xed, 0xfe, 0xed}))
+	f, err := os.Stat(group.FilePath)
+	assert.NoError(t, err)
+	assert.EqualValues(t, 0600, f.Mode())
+
+	// Unchanged secret values don't rewrite the keystore
+	updated, err = push(secrets)
+	assert.NoError(t, err)
+	assert.False(t, updated)
+
+	// A modified keystore is restored
+	assert.NoError(t, os.WriteFile(group.FilePath, []byte("modified"), 0600))
+	updated, err = push(secrets)
+	assert.NoError(t, err)
+	assert.True(t, updated)
+	assert.Equal(t, 1, checksums.tampered)
+
+	// Changed secret values do
+	secrets[2] = &filetemplates.Secret{Alias: "password", Value: "changed"}
+	updated, err = push(secrets)
+	assert.NoError(t, err)
+	assert.True(t, updated)
+
+	t.Run("malformed PEM input", func(t *testing.T) {
+		group := group
+		group.FilePath = path.Join(dir, "malformed.jks")
+
+		_, err := group.PushToFile([]*filetemplates.Secret{
+			{Alias: "key", Value: "not-a-key"},
+			{Alias: "cert", Value: cert},
+			{Alias: "password", Value: "changeit"},
+		})
+		assert.EqualError(t, err, `failed to build jks keystore for secret group "keystore": value does not contain a PEM private key`)
+		assert.NoFileExists(t, group.FilePath)
+	})
+}
@@ -0,0 +1,107 @@
+package pushtofile
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/cyberark/conjur-authn-k8s-client/pkg/log"
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/log/messages"
+)
+
+const secretGroupPostHookPrefix = "conjur.org/secret-file-post-hook."
+const secretGroupPostHookTimeoutPrefix = "conjur.org/secret-file-post-hook-timeout."
+
+const defaultPostHookTimeout = 30 * time.Second
+
+// maxPostHookOutputLen limits the output of a post hook written to the debug
+// logs
+const maxPostHookOutputLen = 4096
+
+// postHookShell runs post hook commands, which can use pipes and multiple
+// commands like the command of a Kubernetes exec probe run through a shell
+var postHookShell = []string{"/bin/sh", "-c"}
+
+// parsePostHookTimeout parses the timeout of a post hook. An empty timeout is
+// parsed as zero, for which defaultPostHookTimeout is used.
+func parsePostHookTimeout(timeout string) (time.Duration, error) {
+	if len(timeout) == 0 {
+		return 0, nil
+	}
+
+	duration, err := time.ParseDuration(timeout)
+	if err != nil {
+		return 0, err
+	}
+	if duration <= 0 {
+		return 0, fmt.Errorf("timeout must be greater than zero, got %q", timeout)
+	}
+	return duration, nil
+}
+
+// runPostHook runs the post hook command of the group after its secret file
+// changed. The command gets the name of the group and the path of its secret
+// file, but not the environment of the Secrets Provider, so it can't read its
+// credentials. Its exit status and duration are logged, and its output is
+// only logged with debug logging, since it may hold secret values. The
+// command is killed when ctx is done, like when the Secrets Provider shuts
+// down.
+func (sg *SecretGroup) runPostHook(ctx context.Context) error {
+	timeout := sg.PostHookTimeout
+	if timeout <= 0 {
+		timeout = defaultPostHookTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	args := append(postHookShell[1:], sg.PostHook)
+	cmd := exec.CommandContext(ctx, postHookShell[0], args...)
+	cmd.Env = []string{
+		"PATH=" + os.Getenv("PATH"),
+		"SECRET_GROUP=" + sg.Name,
+		"SECRET_FILE_PATH=" + sg.FilePath,
+	}
+	// Background processes started by the command may keep its output open
+	cmd.WaitDelay = time.Second
+
+	start := time.Now()
+	output, err := cmd.CombinedOutput()
+	duration := time.Since(start).Round(time.Millisecond)
+	switch ctx.Err() {
+	case context.DeadlineExceeded:
+		err = fmt.Errorf("timed out after %s", timeout)
+	case context.Canceled:
+		err = fmt.Errorf("canceled after %s", duration)
+	}
+	log.Debug(messages.CSPFK022D, sg.Name, postHookOutput(output))
+	if err != nil {
+		log.Error(messages.CSPFK104E, sg.Name, duration, err)
+		return err
+	}
+
+	log.Info(messages.CSPFK044I, sg.Name, duration)
+	return nil
+}
+
+func postHookOutput(output []byte) string {
+	text := strings.TrimSpace(string(output))
+	if len(text) > maxPostHookOutputLen {
+		text = text[:maxPostHookOutputLen] + "... (truncated)"
+	}
+	return text
+}
+
+// runPostHooks runs the post hooks of the groups, recording the groups with
+// a failing post hook in groupErrs. They're written again on the next
+// refresh, so that their post hook is retried.
+func runPostHooks(ctx context.Context, groups []*SecretGroup, checksums *fileChecksums, groupErrs *SecretGroupsError) {
+	for _, group := range groups {
+		if err := group.runPostHook(ctx); err != nil {
+			checksums.rewriteGroup(group.Name)
+			groupErrs.add(group.Name, fmt.Errorf(messages.CSPFK105E, err))
+		}
+	}
+}
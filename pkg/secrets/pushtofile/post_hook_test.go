@@ -0,0 +1,285 @@
+package pushtofile
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/cyberark/conjur-authn-k8s-client/pkg/log"
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/atomicwriter"
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/log/messages"
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/file_templates"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewSecretGroups_PostHook(t *testing.T) {
+	t.Run("post hook and timeout", func(t *testing.T) {
+		groups, errs := NewSecretGroups("/basepath", "", map[string]string{
+			"conjur.org/conjur-secrets.web":                "- htpasswd",
+			"conjur.org/secret-file-post-hook.web":         "nginx -s reload",
+			"conjur.org/secret-file-post-hook-timeout.web": "5s",
+		})
+		require.Len(t, errs, 0)
+		require.Len(t, groups, 1)
+		assert.Equal(t, "nginx -s reload", groups[0].PostHook)
+		assert.Equal(t, 5*time.Second, groups[0].PostHookTimeout)
+	})
+
+	t.Run("invalid timeout", func(t *testing.T) {
+		_, errs := NewSecretGroups("/basepath", "", map[string]string{
+			"conjur.org/conjur-secrets.web":                "- htpasswd",
+			"conjur.org/secret-file-post-hook.web":         "nginx -s reload",
+			"conjur.org/secret-file-post-hook-timeout.web": "-5s",
+		})
+		require.Len(t, errs, 1)
+		assert.EqualError(t, errs[0], `unable to parse annotation "conjur.org/secret-file-post-hook-timeout.web": timeout must be greater than zero, got "-5s"`)
+	})
+}
+
+func TestSecretGroup_RunPostHook(t *testing.T) {
+	testCases := []struct {
+		description string
+		postHook    string
+		timeout     time.Duration
+		errMsg      string
+		logMsg      string
+		debugMsg    string
+	}{
+		{
+			description: "successful command",
+			postHook:    `echo "reloaded $SECRET_GROUP from $SECRET_FILE_PATH"`,
+			logMsg:      messages.CSPFK044I[:9],
+			debugMsg:    "reloaded web from /basepath/web.yaml",
+		},
+		{
+			description: "failing command",
+			postHook:    "echo config error >&2; exit 3",
+			errMsg:      "exit status 3",
+			logMsg:      "exit status 3",
+			debugMsg:    "config error",
+		},
+		{
+			description: "environment of the Secrets Provider isn't passed",
+			postHook:    `test -z "$POST_HOOK_TEST_CREDENTIAL" || echo leaked`,
+			logMsg:      messages.CSPFK044I[:9],
+		},
+		{
+			description: "timeout",
+			postHook:    "sleep 10",
+			timeout:     100 * time.Millisecond,
+			errMsg:      "timed out after 100ms",
+		},
+	}
+
+	t.Setenv("POST_HOOK_TEST_CREDENTIAL", "secret")
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			buf := &bytes.Buffer{}
+			log.InfoLogger.SetOutput(buf)
+			log.ErrorLogger.SetOutput(buf)
+			defer log.InfoLogger.SetOutput(os.Stdout)
+			defer log.ErrorLogger.SetOutput(os.Stderr)
+			debugBuf := &bytes.Buffer{}
+			log.DebugLogger.SetOutput(debugBuf)
+			defer log.DebugLogger.SetOutput(io.Discard)
+
+			group := &SecretGroup{
+				Name:            "web",
+				FilePath:        "/basepath/web.yaml",
+				PostHook:        tc.postHook,
+				PostHookTimeout: tc.timeout,
+			}
+			err := group.runPostHook(t.Context())
+			if tc.errMsg != "" {
+				assert.EqualError(t, err, tc.errMsg)
+				assert.Contains(t, buf.String(), "CSPFK104E")
+			} else {
+				assert.NoError(t, err)
+				assert.NotContains(t, buf.String(), "leaked")
+			}
+			assert.Contains(t, buf.String(), tc.logMsg)
+			// The output of the command is only logged with debug logging
+			if tc.debugMsg != "" {
+				assert.NotContains(t, buf.String(), tc.debugMsg)
+				assert.Contains(t, debugBuf.String(), tc.debugMsg)
+			}
+		})
+	}
+}
+
+func TestSecretGroup_RunPostHookCanceled(t *testing.T) {
+	buf := &bytes.Buffer{}
+	log.ErrorLogger.SetOutput(buf)
+	defer log.ErrorLogger.SetOutput(os.Stderr)
+
+	// The hook is killed when the provide ctx is done, before its own timeout
+	ctx, cancel := context.WithCancel(t.Context())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	group := &SecretGroup{Name: "web", FilePath: "/basepath/web.yaml", PostHook: "sleep 10"}
+
+	start := time.Now()
+	err := group.runPostHook(ctx)
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "canceled after")
+	assert.Less(t, time.Since(start), 5*time.Second)
+	assert.Contains(t, buf.String(), "CSPFK104E")
+}
+
+func TestProvideWithDeps_PostHook(t *testing.T) {
+	basePath := t.TempDir()
+	dataDir := atomicwriter.NewDataDir(basePath)
+	checksums := newFileChecksums()
+
+	hookOutput := filepath.Join(t.TempDir(), "hook")
+	groups := []*SecretGroup{{
+		Name:            "web",
+		FilePath:        filepath.Join(basePath, "web.yaml"),
+		FileFormat:      "yaml",
+		FilePermissions: 0644,
+		SecretSpecs:     []filetemplates.SecretSpec{{Alias: "htpasswd", Path: "web/htpasswd"}},
+		// The hook reads the published file, and fails while a marker file exists
+		PostHook: `cat "$SECRET_FILE_PATH" >> ` + hookOutput + `; test ! -e ` + hookOutput + `.fail`,
+	}}
+
+	version := "1"
+	provide := func() (bool, error) {
+		return provideWithDeps(
+			context.Background(),
+			groups,
+			nil,
+			false,
+			dataDir,
+			checksums,
+			fileProviderDepFuncs{
+				retrieveSecretsFunc: func(variableIDs []string, ctx context.Context) (map[string][]byte, error) {
+					return map[string][]byte{"web/htpasswd": []byte(version)}, nil
+				},
+				depOpenWriteCloser: openFileAsWriteCloser,
+				depPushToWriter:    pushToWriter,
+			},
+		)
+	}
+	assertHookOutput := func(expected string) {
+		content, err := os.ReadFile(hookOutput)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, string(content))
+	}
+
+	updated, err := provide()
+	assert.NoError(t, err)
+	assert.True(t, updated)
+	assertHookOutput(`"htpasswd": "1"`)
+
+	// The hook doesn't run when the file is unchanged
+	updated, err = provide()
+	assert.NoError(t, err)
+	assert.False(t, updated)
+	assertHookOutput(`"htpasswd": "1"`)
+
+	// A failing hook fails the refresh, and runs again on the next refresh
+	require.NoError(t, os.WriteFile(hookOutput+".fail", nil, 0644))
+	version = "2"
+	_, err = provide()
+	var groupsErr *SecretGroupsError
+	require.ErrorAs(t, err, &groupsErr)
+	assert.Equal(t, []string{"web"}, groupsErr.Groups())
+	assert.ErrorContains(t, err, "CSPFK105E Post hook failed: exit status 1")
+	assertHookOutput(`"htpasswd": "1""htpasswd": "2"`)
+
+	require.NoError(t, os.Remove(hookOutput+".fail"))
+	updated, err = provide()
+	assert.NoError(t, err)
+	assert.True(t, updated)
+	assertHookOutput(`"htpasswd": "1""htpasswd": "2""htpasswd": "2"`)
+}
+
+func TestProvide_PostHookFailsOnlyItsGroup(t *testing.T) {
+	var mutex sync.Mutex
+	retrieved := map[string]int{}
+	retrieve := func(variableIDs []string, ctx context.Context) (map[string][]byte, error) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		values := map[string][]byte{}
+		for _, id := range variableIDs {
+			retrieved[id]++
+			values[id] = []byte(id)
+		}
+		return values, nil
+	}
+	provider, errs := NewProvider(retrieve, false, P2FProviderConfig{
+		SecretFileBasePath:   t.TempDir(),
+		TemplateFileBasePath: t.TempDir(),
+		AnnotationsMap: map[string]string{
+			"conjur.org/conjur-secrets.web":          "- web/htpasswd",
+			"conjur.org/secret-file-post-hook.web":   "exit 1",
+			"conjur.org/conjur-secrets.db":           "- db/password",
+			"conjur.org/secret-file-post-hook.db":    "true",
+			"conjur.org/conjur-secrets.cache":        "- cache/token",
+			"conjur.org/secret-file-post-hook.cache": "true",
+		},
+	})
+	require.Empty(t, errs)
+	provider.SetRefreshInterval(time.Hour)
+
+	updated, err := provider.Provide(t.Context())
+	assert.True(t, updated)
+	var groupsErr *SecretGroupsError
+	require.ErrorAs(t, err, &groupsErr)
+	assert.Equal(t, []string{"web"}, groupsErr.Groups())
+	assert.True(t, groupsErr.Partial())
+	assert.ErrorContains(t, err, "CSPFK105E Post hook failed: exit status 1")
+
+	// Only the group with the failing post hook is refreshed again, and its
+	// file is written again so that its post hook is retried
+	updated, err = provider.Provide(t.Context())
+	assert.True(t, updated)
+	require.ErrorAs(t, err, &groupsErr)
+	assert.Equal(t, []string{"web"}, groupsErr.Groups())
+	assert.Equal(t, map[string]int{"web/htpasswd": 2, "db/password": 1, "cache/token": 1}, retrieved)
+}
+
+func TestFileProvider_RollbackPostHook(t *testing.T) {
+	retrieve := func(variableIDs []string, ctx context.Context) (map[string][]byte, error) {
+		return map[string][]byte{"db/password": []byte(time.Now().String())}, nil
+	}
+	p, errs := NewProvider(retrieve, false, P2FProviderConfig{
+		SecretFileBasePath:   t.TempDir(),
+		TemplateFileBasePath: t.TempDir(),
+		AnnotationsMap: map[string]string{
+			"conjur.org/conjur-secrets.db":      "- db/password",
+			"conjur.org/secret-file-backups.db": "1",
+		},
+	})
+	require.Empty(t, errs)
+	for range 2 {
+		_, err := p.Provide(t.Context())
+		require.NoError(t, err)
+	}
+	p.secretGroups[0].PostHook = "sleep 10"
+
+	// The post hook runs with the ctx of the rollback, without blocking the
+	// other requests to the provider
+	ctx, cancel := context.WithCancel(t.Context())
+	done := make(chan error, 1)
+	go func() {
+		done <- p.Rollback(ctx, "db", 1)
+	}()
+	assert.Eventually(t, func() bool {
+		return p.Resume("db") == nil
+	}, 5*time.Second, 10*time.Millisecond)
+
+	cancel()
+	select {
+	case err := <-done:
+		assert.ErrorContains(t, err, "CSPFK105E Post hook failed")
+		assert.ErrorContains(t, err, "canceled after")
+	case <-time.After(5 * time.Second):
+		t.Fatal("the post hook wasn't canceled with the ctx of the rollback")
+	}
+}
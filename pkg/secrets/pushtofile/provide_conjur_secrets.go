@@ -2,21 +2,48 @@ package pushtofile
 
 import (
 	"context"
-	"os"
-	"strings"
+	"errors"
+	"fmt"
+	"reflect"
+	"strconv"
+	"sync"
+	"time"
 
 	"github.com/cyberark/conjur-authn-k8s-client/pkg/log"
 	"github.com/cyberark/conjur-opentelemetry-tracer/pkg/trace"
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/atomicwriter"
 	"github.com/cyberark/secrets-provider-for-k8s/pkg/log/messages"
 	"github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/clients/conjur"
+	secretsConfig "github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/config"
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/utils"
 	"go.opentelemetry.io/otel"
 )
 
 type fileProvider struct {
 	retrieveSecretsFunc conjur.RetrieveSecretsFunc
 	secretGroups        []*SecretGroup
-	traceContext        context.Context
 	sanitizeEnabled     bool
+	dataDir             *atomicwriter.DataDir
+	secretsBasePath     string
+	templatesBasePath   string
+	// manifestPath is the path of the secret groups manifest, if any
+	manifestPath string
+	// removedGroups are groups dropped by Reload, whose files are removed
+	// on the next Provide
+	removedGroups []*SecretGroup
+	checksums     *fileChecksums
+	// paused holds the names of the groups whose secret file was rolled
+	// back, which aren't refreshed until they're resumed
+	paused map[string]struct{}
+	// wiped is set once the secret files were wiped by Wipe, after which
+	// secrets are no longer provided
+	wiped bool
+	// schedule holds when each group is due for a refresh, on its own
+	// refresh interval or on the global one
+	schedule *utils.RefreshSchedule
+	// mutex serializes providing secrets, reloading secret groups and
+	// rolling them back
+	mutex *sync.Mutex
 }
 
 type fileProviderDepFuncs struct {
@@ -45,84 +72,491 @@ func NewProvider(
 		return nil, err
 	}
 
+	// With atomic file updates, the secret files of a refresh are published
+	// all at once through a symlinked "..data" directory
+	var dataDir *atomicwriter.DataDir
+	if atomic, _ := strconv.ParseBool(config.AnnotationsMap[secretsConfig.AtomicFileUpdatesKey]); atomic {
+		dataDir = atomicwriter.NewDataDir(config.SecretFileBasePath)
+	}
+
 	return &fileProvider{
 		retrieveSecretsFunc: retrieveSecretsFunc,
 		secretGroups:        secretGroups,
-		traceContext:        nil,
 		sanitizeEnabled:     sanitizeEnabled,
+		dataDir:             dataDir,
+		secretsBasePath:     config.SecretFileBasePath,
+		templatesBasePath:   config.TemplateFileBasePath,
+		manifestPath:        ManifestPath(config.TemplateFileBasePath, config.AnnotationsMap),
+		checksums:           newFileChecksums(),
+		paused:              map[string]struct{}{},
+		schedule:            utils.NewRefreshSchedule(),
+		mutex:               &sync.Mutex{},
 	}, nil
 }
 
-// Provide implements a ProviderFunc to retrieve and push secrets to the filesystem.
-func (p fileProvider) Provide() (bool, error) {
-	return provideWithDeps(
-		p.traceContext,
-		p.secretGroups,
+// Provide implements a ProviderFunc to retrieve and push secrets to the
+// filesystem. Only the groups that are due for a refresh are provided, and
+// their secrets are retrieved at once.
+func (p *fileProvider) Provide(ctx context.Context) (bool, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if p.wiped {
+		log.Debug(messages.CSPFK018D)
+		return false, nil
+	}
+
+	now := time.Now()
+	groups := p.dueGroups(now)
+	if len(groups) == 0 && len(p.removedGroups) == 0 {
+		log.Debug(messages.CSPFK020D)
+		return false, nil
+	}
+
+	updated, err := provideWithDeps(
+		ctx,
+		groups,
+		p.removedGroups,
 		p.sanitizeEnabled,
+		p.dataDir,
+		p.checksums,
 		fileProviderDepFuncs{
 			retrieveSecretsFunc: p.retrieveSecretsFunc,
 			depOpenWriteCloser:  openFileAsWriteCloser,
 			depPushToWriter:     pushToWriter,
 		},
 	)
+	// The files of removed groups are removed even when some groups fail
+	var groupsErr *SecretGroupsError
+	isGroupsErr := errors.As(err, &groupsErr)
+	if err == nil || isGroupsErr {
+		p.removedGroups = nil
+	}
+	if isGroupsErr {
+		groupsErr.groupCount = len(p.secretGroups)
+	}
+
+	// Groups that failed stay due, so that they're retried
+	for _, group := range groups {
+		failed := err != nil && (!isGroupsErr || groupsErr.failed(group.Name))
+		p.schedule.Refreshed(group.Name, group.RefreshInterval, now, failed)
+	}
+	return updated, err
+}
+
+// SetRefreshInterval sets the refresh interval of the groups without a
+// refresh interval of their own
+func (p *fileProvider) SetRefreshInterval(interval time.Duration) {
+	p.schedule.SetDefaultInterval(interval)
+}
+
+// SetRefreshCron sets the cron schedule of the groups without a refresh
+// interval of their own, instead of the global refresh interval
+func (p *fileProvider) SetRefreshCron(cron *utils.CronSchedule) {
+	p.schedule.SetDefaultCron(cron)
+}
+
+// SetRefreshJitter sets the maximum random delay of refreshes
+func (p *fileProvider) SetRefreshJitter(jitter time.Duration) {
+	p.schedule.SetJitter(jitter)
+}
+
+// NextRefresh returns the time until the next group is due for a refresh,
+// or false when no refresh is scheduled
+func (p *fileProvider) NextRefresh() (time.Duration, bool) {
+	return p.schedule.Next(time.Now())
+}
+
+// Reload replaces the secret groups of the provider with the groups defined
+// by annotations, and by the manifest and templates of the templates base
+// path. The current groups are kept when the new ones are invalid. Files of
+// groups that are removed, or written to another path, are removed on the
+// next Provide. Returns whether the secret groups changed.
+func (p *fileProvider) Reload(annotations map[string]string) (bool, []error) {
+	secretGroups, errs := NewSecretGroups(p.secretsBasePath, p.templatesBasePath, annotations)
+	if len(errs) > 0 {
+		return false, errs
+	}
+
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.manifestPath = ManifestPath(p.templatesBasePath, annotations)
+	if reflect.DeepEqual(p.secretGroups, secretGroups) {
+		return false, nil
+	}
+
+	newGroups := map[string]*SecretGroup{}
+	for _, group := range secretGroups {
+		newGroups[group.Name] = group
+	}
+	for _, group := range p.secretGroups {
+		newGroup, ok := newGroups[group.Name]
+		if ok && reflect.DeepEqual(group, newGroup) {
+			continue
+		}
+		// Changed groups are written again, even when their secret
+		// values haven't changed or they aren't due for a refresh
+		p.checksums.rewriteGroup(group.Name)
+		p.schedule.Reset(group.Name)
+		if !ok || !group.sameFiles(newGroup) {
+			p.removedGroups = append(p.removedGroups, group)
+		}
+	}
+
+	// Groups that were removed are no longer paused or scheduled
+	for name := range p.paused {
+		if _, ok := newGroups[name]; !ok {
+			delete(p.paused, name)
+		}
+	}
+	p.schedule.Retain(func(name string) bool {
+		_, ok := newGroups[name]
+		return ok
+	})
+
+	p.secretGroups = secretGroups
+	return true, nil
+}
+
+// ConfigFiles returns the paths of the configuration files the secret groups
+// were read from, other than the annotations and the secret file templates:
+// the secret groups manifest and the Summon secrets.yml files
+func (p *fileProvider) ConfigFiles() []string {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	var files []string
+	if len(p.manifestPath) > 0 {
+		files = append(files, p.manifestPath)
+	}
+	for _, group := range p.secretGroups {
+		if len(group.SecretsYmlPath) > 0 {
+			files = append(files, group.SecretsYmlPath)
+		}
+	}
+	return files
+}
+
+// Rollback replaces the secret file of a group with a generation of its
+// backups, and pauses the refreshes of the group until it's resumed, so that
+// a bad secret value isn't written again. The post hook of the group is run
+// with ctx once the file is replaced.
+func (p *fileProvider) Rollback(ctx context.Context, name string, generation int) error {
+	group, err := p.rollbackGroup(name, generation)
+	if err != nil {
+		return err
+	}
+
+	// The post hook is run without holding the mutex, so that a slow hook
+	// doesn't block refreshes while the admin request waits for it
+	if len(group.PostHook) > 0 {
+		if err := group.runPostHook(ctx); err != nil {
+			return fmt.Errorf(messages.CSPFK105E, err)
+		}
+	}
+	return nil
+}
+
+// rollbackGroup replaces the secret file of a group with a generation of its
+// backups and pauses the group, returning the group
+func (p *fileProvider) rollbackGroup(name string, generation int) (*SecretGroup, error) {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	group := p.group(name)
+	if group == nil {
+		return nil, fmt.Errorf("unknown secret group %q", name)
+	}
+
+	target := group
+	if p.dataDir != nil {
+		if err := p.dataDir.Stage(); err != nil {
+			return nil, err
+		}
+		defer p.dataDir.Abort()
+		staged, err := stagedGroup(p.dataDir, group)
+		if err != nil {
+			return nil, err
+		}
+		target = staged
+	}
+	if err := target.rollback(generation); err != nil {
+		return nil, err
+	}
+	if p.dataDir != nil {
+		if err := p.dataDir.Commit(); err != nil {
+			return nil, err
+		}
+	}
+
+	// The rolled back file isn't restored, and it's written again once the
+	// group is resumed
+	p.checksums.forgetGroup(name)
+	p.paused[name] = struct{}{}
+	p.schedule.Reset(name)
+	log.Info(messages.CSPFK045I, name, generation)
+	return group, nil
+}
+
+// Resume resumes the refreshes of a group paused by Rollback. Its secret
+// file is written again on the next refresh.
+func (p *fileProvider) Resume(name string) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	if _, ok := p.paused[name]; !ok {
+		return fmt.Errorf("secret group %q is not paused", name)
+	}
+	delete(p.paused, name)
+	p.checksums.rewriteGroup(name)
+	log.Info(messages.CSPFK046I, name)
+	return nil
+}
+
+// Wipe overwrites and removes the secret files of every group, once the
+// secret files being written are published. Secrets aren't provided anymore
+// afterwards.
+func (p *fileProvider) Wipe(_ context.Context) error {
+	p.mutex.Lock()
+	defer p.mutex.Unlock()
+
+	p.wiped = true
+	var errs []error
+	for _, groups := range [][]*SecretGroup{p.removedGroups, p.secretGroups} {
+		for _, group := range groups {
+			if err := group.wipeFiles(); err != nil {
+				errs = append(errs, err)
+			}
+		}
+	}
+	if p.dataDir != nil {
+		if err := p.dataDir.RemoveAll(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	p.checksums.forgetAll()
+	return errors.Join(errs...)
+}
+
+func (p *fileProvider) group(name string) *SecretGroup {
+	for _, group := range p.secretGroups {
+		if group.Name == name {
+			return group
+		}
+	}
+	return nil
+}
+
+// dueGroups returns the active groups that are due for a refresh at now
+func (p *fileProvider) dueGroups(now time.Time) []*SecretGroup {
+	var groups []*SecretGroup
+	for _, group := range p.activeGroups() {
+		if p.schedule.Due(group.Name, now) {
+			groups = append(groups, group)
+		}
+	}
+	return groups
 }
 
-func (p *fileProvider) SetTraceContext(ctx context.Context) {
-	p.traceContext = ctx
+// activeGroups returns the secret groups that aren't paused
+func (p *fileProvider) activeGroups() []*SecretGroup {
+	if len(p.paused) == 0 {
+		return p.secretGroups
+	}
+
+	var groups []*SecretGroup
+	for _, group := range p.secretGroups {
+		if _, ok := p.paused[group.Name]; ok {
+			log.Info(messages.CSPFK047I, group.Name)
+			continue
+		}
+		groups = append(groups, group)
+	}
+	return groups
 }
 
+// provideWithDeps fetches and writes the secrets of each group on its own, so
+// that a failing group doesn't keep the other groups from being provided. The
+// errors of the failing groups are returned as a *SecretGroupsError. With
+// atomic file updates, the files of the groups that succeeded are published
+// at once, along with the last published files of the failing groups.
 func provideWithDeps(
-	traceContext context.Context,
+	ctx context.Context,
 	groups []*SecretGroup,
+	removedGroups []*SecretGroup,
 	sanitizeEnabled bool,
+	dataDir *atomicwriter.DataDir,
+	checksums *fileChecksums,
 	depFuncs fileProviderDepFuncs,
 ) (bool, error) {
 	// Use the global TracerProvider
 	tr := trace.NewOtelTracer(otel.Tracer("secrets-provider"))
-	spanCtx, span := tr.Start(traceContext, "Fetch Conjur Secrets")
+	spanCtx, span := tr.Start(ctx, "Fetch Conjur Secrets")
 	var updated bool
-	secretsByGroup, err := FetchSecretsForGroups(depFuncs.retrieveSecretsFunc, groups, spanCtx)
-	if err != nil {
-		// Delete secret files for variables that no longer exist or the user no longer has permissions to.
-		// In the future we'll delete only the secrets that are revoked, but for now we delete all secrets in
-		// the group because we don't have a way to determine which secrets are revoked.
-		if (strings.Contains(err.Error(), "403") || strings.Contains(err.Error(), "404") || strings.Contains(err.Error(), "CSPFK068E")) && sanitizeEnabled {
-			updated = true
-			for _, group := range groups {
-				log.Info(messages.CSPFK019I)
-				rmErr := os.Remove(group.FilePath)
-				if rmErr != nil && !os.IsNotExist(rmErr) {
-					log.Error(messages.CSPFK062E, rmErr)
-				}
-			}
-		}
-
+	groupErrs := &SecretGroupsError{}
+	secretsByGroup := fetchSecretsForEachGroup(depFuncs.retrieveSecretsFunc, groups, spanCtx, groupErrs)
+	if err := groupErrs.orNil(); err != nil {
 		span.RecordErrorAndSetStatus(err)
-		span.End()
-		return updated, err
 	}
 	span.End()
 
-	spanCtx, span = tr.Start(traceContext, "Write Secret Files")
+	spanCtx, span = tr.Start(ctx, "Write Secret Files")
 	defer span.End()
+
+	// With atomic file updates, group files are written to a staged generation,
+	// which is seeded with the published files and published once every group
+	// has been written. On failure, the checksums are restored so the groups
+	// are written again on the next refresh.
+	var prevChecksums *fileChecksums
+	if dataDir != nil {
+		if err := dataDir.Stage(); err != nil {
+			span.RecordErrorAndSetStatus(err)
+			return false, utils.Classify(utils.FailureWrite, err)
+		}
+		defer dataDir.Abort()
+		prevChecksums = checksums.clone()
+	}
+
+	// Files of groups that are no longer defined are removed before the
+	// files of the current groups are written, which may take their place
+	for _, group := range removedGroups {
+		log.Info(messages.CSPFK042I, group.Name)
+		target := group
+		if dataDir != nil {
+			staged, err := stagedGroup(dataDir, group)
+			if err != nil {
+				checksums.restore(prevChecksums)
+				span.RecordErrorAndSetStatus(err)
+				return false, err
+			}
+			target = staged
+		}
+		if err := target.removeFiles(); err != nil {
+			log.Error(messages.CSPFK062E, err)
+		}
+		checksums.forgetGroup(group.Name)
+		updated = true
+	}
+
+	var hookGroups []*SecretGroup
 	for _, group := range groups {
+		target := group
+		if dataDir != nil {
+			staged, err := stagedGroup(dataDir, group)
+			if err != nil {
+				checksums.restore(prevChecksums)
+				span.RecordErrorAndSetStatus(err)
+				return false, err
+			}
+			target = staged
+		}
+
+		// Groups whose secrets couldn't be fetched keep their files, unless
+		// the secrets no longer exist or the host can no longer access them.
+		// Every file of the group is removed, since the revoked secrets
+		// can't be told apart.
+		if groupErrs.failed(group.Name) {
+			if sanitizeEnabled && utils.IsRevokedError(groupErrs.errs[group.Name]) {
+				log.Info(messages.CSPFK019I)
+				if err := target.removeFiles(); err != nil {
+					log.Error(messages.CSPFK062E, err)
+				}
+				checksums.forgetGroup(group.Name)
+				updated = true
+			}
+			continue
+		}
+
 		_, childSpan := tr.Start(spanCtx, "Write Secret Files for group")
 		defer childSpan.End()
-		groupUpdated, err := group.pushToFileWithDeps(
-			depFuncs.depOpenWriteCloser,
+
+		groupUpdated, err := target.pushToFileWithDeps(
+			classifyWriteFailures(depFuncs.depOpenWriteCloser),
 			depFuncs.depPushToWriter,
+			checksums,
 			secretsByGroup[group.Name],
 		)
 		if err != nil {
+			// Failures other than writing files are of the templates and
+			// file formats of the group
+			if utils.FailureClassOf(err) == utils.FailureUnknown {
+				err = utils.Classify(utils.FailureConfig, err)
+			}
+			groupErrs.add(group.Name, err)
 			childSpan.RecordErrorAndSetStatus(err)
-			span.RecordErrorAndSetStatus(err)
-			return updated, err
+			// Files of the group that were staged before it failed are
+			// replaced with the published ones
+			if dataDir != nil {
+				if err := restoreStagedGroup(dataDir, group); err != nil {
+					checksums.restore(prevChecksums)
+					span.RecordErrorAndSetStatus(err)
+					return false, utils.Classify(utils.FailureWrite, err)
+				}
+				checksums.restoreGroup(prevChecksums, group.Name)
+			}
+			continue
 		}
+		checksums.rewritten(group.Name)
 		if groupUpdated {
 			updated = true
+			if len(group.PostHook) > 0 {
+				hookGroups = append(hookGroups, group)
+			}
 		}
 	}
 
+	if dataDir != nil && updated {
+		if err := dataDir.Commit(); err != nil {
+			checksums.restore(prevChecksums)
+			span.RecordErrorAndSetStatus(err)
+			return false, utils.Classify(utils.FailureWrite, err)
+		}
+	}
+
+	// Post hooks run once the files of their group are published
+	runPostHooks(ctx, hookGroups, checksums, groupErrs)
+	if err := groupErrs.orNil(); err != nil {
+		span.RecordErrorAndSetStatus(err)
+		return updated, err
+	}
+
 	log.Info(messages.CSPFK015I)
 	return updated, nil
 }
+
+// restoreStagedGroup replaces the staged files of the group with the
+// published ones
+func restoreStagedGroup(dataDir *atomicwriter.DataDir, group *SecretGroup) error {
+	paths := []string{group.FilePath}
+	for generation := 1; generation <= group.Backups; generation++ {
+		paths = append(paths, group.backupPath(generation))
+	}
+	if len(group.SecretFilesPath) > 0 {
+		paths = append(paths, group.SecretFilesPath)
+	}
+	for _, path := range paths {
+		if err := dataDir.Restore(path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// stagedGroup returns a copy of the group that writes its files to the staged
+// generation of dataDir
+func stagedGroup(dataDir *atomicwriter.DataDir, group *SecretGroup) (*SecretGroup, error) {
+	stagedPath, err := dataDir.StagedPath(group.FilePath)
+	if err != nil {
+		return nil, err
+	}
+	staged := *group
+	staged.FilePath = stagedPath
+	if len(group.SecretFilesPath) > 0 {
+		staged.stagedSecretFilesPath, err = dataDir.StagedPath(group.SecretFilesPath)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return &staged, nil
+}
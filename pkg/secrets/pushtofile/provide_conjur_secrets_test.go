@@ -4,11 +4,19 @@ import (
 	"context"
 	"fmt"
 	"github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/file_templates"
+	"io"
 	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/atomicwriter"
 	"github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/clients/conjur"
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/utils"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 func retrieve(variableIDs []string, ctx context.Context) (map[string][]byte, error) {
@@ -20,7 +28,7 @@ func retrieve(variableIDs []string, ctx context.Context) (map[string][]byte, err
 }
 
 func retrieveWith403(variableIDs []string, ctx context.Context) (map[string][]byte, error) {
-	return nil, fmt.Errorf("403")
+	return nil, utils.Classify(utils.FailureAuthz, fmt.Errorf("403"))
 }
 
 func retrieveWithGenericError(variableIDs []string, ctx context.Context) (map[string][]byte, error) {
@@ -119,7 +127,8 @@ func TestProvideWithDeps(t *testing.T) {
 				spyOpenWriteCloser openWriteCloserSpy,
 			) {
 				assert.True(t, updated)
-				assert.Equal(t, closableBuf, spyPushToWriter.args.writer)
+				// YAML is encoded and written without a template
+				assert.Equal(t, `"password": "value-path1"`, closableBuf.String())
 				assert.Equal(t, spyOpenWriteCloser.args.path, p.secretGroups[0].FilePath)
 				assert.Nil(t, err)
 			},
@@ -215,7 +224,10 @@ func TestProvideWithDeps(t *testing.T) {
 			updated, err := provideWithDeps(
 				context.Background(),
 				tc.provider.secretGroups,
+				nil,
 				tc.sanitizeEnabled,
+				nil,
+				newFileChecksums(),
 				fileProviderDepFuncs{
 					retrieveSecretsFunc: tc.provider.retrieveSecretsFunc,
 					depOpenWriteCloser:  spyOpenWriteCloser.Call,
@@ -227,3 +239,442 @@ func TestProvideWithDeps(t *testing.T) {
 		})
 	}
 }
+
+func TestProvideWithDeps_AtomicFileUpdates(t *testing.T) {
+	basePath := t.TempDir()
+	dataDir := atomicwriter.NewDataDir(basePath)
+	checksums := newFileChecksums()
+
+	groups := []*SecretGroup{
+		{
+			Name:            "cert",
+			FilePath:        filepath.Join(basePath, "cert.yaml"),
+			FileFormat:      "yaml",
+			FilePermissions: 0644,
+			SecretSpecs:     []filetemplates.SecretSpec{{Alias: "cert", Path: "tls/cert"}},
+		},
+		{
+			Name:            "key",
+			FilePath:        filepath.Join(basePath, "key.yaml"),
+			FileFormat:      "yaml",
+			FilePermissions: 0644,
+			SecretSpecs:     []filetemplates.SecretSpec{{Alias: "key", Path: "tls/key"}},
+		},
+	}
+
+	version := "1"
+	retrieveVersion := func(variableIDs []string, ctx context.Context) (map[string][]byte, error) {
+		values := make(map[string][]byte)
+		for _, id := range variableIDs {
+			values[id] = []byte(id + "-" + version)
+		}
+		return values, nil
+	}
+	failKeyGroup := false
+	openWriteCloserOrFail := func(path string, permissions os.FileMode, owner *atomicwriter.FileOwner) (io.WriteCloser, error) {
+		if failKeyGroup && filepath.Base(path) == "key.yaml" {
+			return nil, fmt.Errorf("failed to open")
+		}
+		return openFileAsWriteCloser(path, permissions, owner)
+	}
+	provide := func() (bool, error) {
+		return provideWithDeps(
+			context.Background(),
+			groups,
+			nil,
+			false,
+			dataDir,
+			checksums,
+			fileProviderDepFuncs{
+				retrieveSecretsFunc: retrieveVersion,
+				depOpenWriteCloser:  openWriteCloserOrFail,
+				depPushToWriter:     pushToWriter,
+			},
+		)
+	}
+	assertFiles := func(version string) {
+		for _, name := range []string{"cert", "key"} {
+			content, err := os.ReadFile(filepath.Join(basePath, name+".yaml"))
+			assert.NoError(t, err)
+			assert.Equal(t, fmt.Sprintf(`"%s": "tls/%s-%s"`, name, name, version), string(content))
+		}
+	}
+
+	updated, err := provide()
+	assert.NoError(t, err)
+	assert.True(t, updated)
+	assertFiles("1")
+
+	// A failing group keeps its published file, while the files of the
+	// other groups are published
+	version = "2"
+	failKeyGroup = true
+	updated, err = provide()
+	var groupsErr *SecretGroupsError
+	require.ErrorAs(t, err, &groupsErr)
+	assert.Equal(t, []string{"key"}, groupsErr.Groups())
+	assert.True(t, updated)
+	content, err := os.ReadFile(filepath.Join(basePath, "cert.yaml"))
+	assert.NoError(t, err)
+	assert.Equal(t, `"cert": "tls/cert-2"`, string(content))
+	content, err = os.ReadFile(filepath.Join(basePath, "key.yaml"))
+	assert.NoError(t, err)
+	assert.Equal(t, `"key": "tls/key-1"`, string(content))
+
+	// The next refresh writes the failing group again
+	failKeyGroup = false
+	updated, err = provide()
+	assert.NoError(t, err)
+	assert.True(t, updated)
+	assertFiles("2")
+
+	// Unchanged secrets don't publish a new generation
+	generation, err := os.Readlink(filepath.Join(basePath, "..data"))
+	assert.NoError(t, err)
+	updated, err = provide()
+	assert.NoError(t, err)
+	assert.False(t, updated)
+	current, err := os.Readlink(filepath.Join(basePath, "..data"))
+	assert.NoError(t, err)
+	assert.Equal(t, generation, current)
+}
+
+func TestProvideWithDeps_GroupErrors(t *testing.T) {
+	// Like Conjur, retrieving a batch fails when any secret is missing
+	retrieveExisting := func(variableIDs []string, ctx context.Context) (map[string][]byte, error) {
+		for _, id := range variableIDs {
+			if strings.HasPrefix(id, "missing/") {
+				return nil, utils.Classify(utils.FailureNotFound, fmt.Errorf("404 Not Found: %s", id))
+			}
+		}
+		return retrieve(variableIDs, ctx)
+	}
+	openWriteCloserOrFail := func(path string, permissions os.FileMode, owner *atomicwriter.FileOwner) (io.WriteCloser, error) {
+		if filepath.Base(path) == "api.yaml" {
+			return nil, fmt.Errorf("failed to open")
+		}
+		return openFileAsWriteCloser(path, permissions, owner)
+	}
+	newGroups := func(basePath string) []*SecretGroup {
+		var groups []*SecretGroup
+		for name, path := range map[string]string{"db": "db/password", "cache": "missing/token", "api": "api/key"} {
+			groups = append(groups, &SecretGroup{
+				Name:            name,
+				FilePath:        filepath.Join(basePath, name+".yaml"),
+				FileFormat:      "yaml",
+				FilePermissions: 0644,
+				SecretSpecs:     []filetemplates.SecretSpec{{Alias: "value", Path: path}},
+			})
+		}
+		return groups
+	}
+
+	for _, atomic := range []bool{false, true} {
+		t.Run(fmt.Sprintf("atomic file updates %t", atomic), func(t *testing.T) {
+			basePath := t.TempDir()
+			var dataDir *atomicwriter.DataDir
+			if atomic {
+				dataDir = atomicwriter.NewDataDir(basePath)
+			}
+
+			updated, err := provideWithDeps(
+				context.Background(),
+				newGroups(basePath),
+				nil,
+				true,
+				dataDir,
+				newFileChecksums(),
+				fileProviderDepFuncs{
+					retrieveSecretsFunc: retrieveExisting,
+					depOpenWriteCloser:  openWriteCloserOrFail,
+					depPushToWriter:     pushToWriter,
+				},
+			)
+
+			var groupsErr *SecretGroupsError
+			require.ErrorAs(t, err, &groupsErr)
+			assert.Contains(t, err.Error(), `secret group "cache": `)
+			assert.NoFileExists(t, filepath.Join(basePath, "cache.yaml"))
+			assert.NoFileExists(t, filepath.Join(basePath, "api.yaml"))
+
+			// The other groups are provided regardless
+			assert.Equal(t, []string{"api", "cache"}, groupsErr.Groups())
+			assert.Contains(t, err.Error(), `secret group "api": failed to open`)
+			assert.True(t, updated)
+			content, err := os.ReadFile(filepath.Join(basePath, "db.yaml"))
+			require.NoError(t, err)
+			assert.Equal(t, `"value": "value-db/password"`, string(content))
+		})
+	}
+}
+
+func TestProvideWithDeps_RevokedGroup(t *testing.T) {
+	revoked := false
+	retrieveUnlessRevoked := func(variableIDs []string, ctx context.Context) (map[string][]byte, error) {
+		for _, id := range variableIDs {
+			if revoked && id == "cache/token" {
+				return nil, utils.Classify(utils.FailureAuthz, fmt.Errorf("403 Forbidden: %s", id))
+			}
+		}
+		return retrieve(variableIDs, ctx)
+	}
+
+	for _, atomic := range []bool{false, true} {
+		t.Run(fmt.Sprintf("atomic file updates %t", atomic), func(t *testing.T) {
+			revoked = false
+			basePath := t.TempDir()
+			var dataDir *atomicwriter.DataDir
+			if atomic {
+				dataDir = atomicwriter.NewDataDir(basePath)
+			}
+			var groups []*SecretGroup
+			for name, path := range map[string]string{"db": "db/password", "cache": "cache/token"} {
+				groups = append(groups, &SecretGroup{
+					Name:            name,
+					FilePath:        filepath.Join(basePath, name+".yaml"),
+					FileFormat:      "yaml",
+					FilePermissions: 0644,
+					SecretSpecs:     []filetemplates.SecretSpec{{Alias: "value", Path: path}},
+				})
+			}
+			provide := func() (bool, error) {
+				return provideWithDeps(
+					context.Background(),
+					groups,
+					nil,
+					true,
+					dataDir,
+					newFileChecksums(),
+					fileProviderDepFuncs{
+						retrieveSecretsFunc: retrieveUnlessRevoked,
+						depOpenWriteCloser:  openFileAsWriteCloser,
+						depPushToWriter:     pushToWriter,
+					},
+				)
+			}
+
+			_, err := provide()
+			require.NoError(t, err)
+			assert.FileExists(t, filepath.Join(basePath, "cache.yaml"))
+
+			// Only the files of the revoked group are removed
+			revoked = true
+			updated, err := provide()
+			var groupsErr *SecretGroupsError
+			require.ErrorAs(t, err, &groupsErr)
+			assert.Equal(t, []string{"cache"}, groupsErr.Groups())
+			assert.True(t, updated)
+			assert.NoFileExists(t, filepath.Join(basePath, "cache.yaml"))
+			content, err := os.ReadFile(filepath.Join(basePath, "db.yaml"))
+			require.NoError(t, err)
+			assert.Equal(t, `"value": "value-db/password"`, string(content))
+		})
+	}
+}
+
+func TestFileProvider_Reload(t *testing.T) {
+	for _, atomic := range []string{"false", "true"} {
+		t.Run("atomic file updates "+atomic, func(t *testing.T) {
+			basePath := t.TempDir()
+			annotations := map[string]string{
+				"conjur.org/atomic-file-updates-enabled": atomic,
+				"conjur.org/conjur-secrets.db":           "- db/password",
+				"conjur.org/conjur-secrets.cache":        "- cache/token",
+			}
+
+			p, errs := NewProvider(retrieve, false, P2FProviderConfig{
+				SecretFileBasePath:   basePath,
+				TemplateFileBasePath: t.TempDir(),
+				AnnotationsMap:       annotations,
+			})
+			require.Empty(t, errs)
+			updated, err := p.Provide(context.Background())
+			require.NoError(t, err)
+			assert.True(t, updated)
+			assert.FileExists(t, filepath.Join(basePath, "db.yaml"))
+			assert.FileExists(t, filepath.Join(basePath, "cache.yaml"))
+
+			// Invalid groups keep the current groups
+			changed, errs := p.Reload(map[string]string{
+				"conjur.org/conjur-secrets.db":     "- db/password",
+				"conjur.org/secret-file-format.db": "xml",
+			})
+			assert.False(t, changed)
+			assert.Len(t, errs, 1)
+			assert.Len(t, p.secretGroups, 2)
+
+			// Unchanged groups aren't reloaded
+			changed, errs = p.Reload(annotations)
+			assert.False(t, changed)
+			assert.Empty(t, errs)
+
+			// Files of removed and moved groups are removed, and the files
+			// of changed groups are written again
+			changed, errs = p.Reload(map[string]string{
+				"conjur.org/atomic-file-updates-enabled": atomic,
+				"conjur.org/conjur-secrets.db":           "- db/password",
+				"conjur.org/secret-file-path.db":         "config/db.yaml",
+				"conjur.org/conjur-secrets.api":          "- api/key",
+			})
+			assert.True(t, changed)
+			assert.Empty(t, errs)
+
+			updated, err = p.Provide(context.Background())
+			require.NoError(t, err)
+			assert.True(t, updated)
+			assert.NoFileExists(t, filepath.Join(basePath, "db.yaml"))
+			assert.NoFileExists(t, filepath.Join(basePath, "cache.yaml"))
+			assert.FileExists(t, filepath.Join(basePath, "config", "db.yaml"))
+			assert.FileExists(t, filepath.Join(basePath, "api.yaml"))
+			assert.Empty(t, p.removedGroups)
+
+			updated, err = p.Provide(context.Background())
+			require.NoError(t, err)
+			assert.False(t, updated)
+		})
+	}
+}
+
+func TestFileProvider_ConfigFiles(t *testing.T) {
+	templatesBasePath := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(templatesBasePath, "secrets.yml"), []byte("DB_PASSWORD: !var db/password\n"), 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(templatesBasePath, "secret-groups.yaml"), []byte(`
+groups:
+  - name: cache
+    secretsYml: secrets.yml
+`), 0644))
+
+	p, errs := NewProvider(retrieve, false, P2FProviderConfig{
+		SecretFileBasePath:   t.TempDir(),
+		TemplateFileBasePath: templatesBasePath,
+		AnnotationsMap: map[string]string{
+			manifestAnnotation:                  "secret-groups.yaml",
+			"conjur.org/conjur-secrets-yml.app": "secrets.yml",
+			"conjur.org/conjur-secrets.db":      "- db/password",
+		},
+	})
+	require.Empty(t, errs)
+	assert.ElementsMatch(t, []string{
+		filepath.Join(templatesBasePath, "secret-groups.yaml"),
+		filepath.Join(templatesBasePath, "secrets.yml"),
+		filepath.Join(templatesBasePath, "secrets.yml"),
+	}, p.ConfigFiles())
+
+	// The files of the reloaded configuration are returned
+	changed, errs := p.Reload(map[string]string{
+		"conjur.org/conjur-secrets.db": "- db/password",
+	})
+	require.Empty(t, errs)
+	assert.True(t, changed)
+	assert.Empty(t, p.ConfigFiles())
+}
+
+func TestFileProvider_Rollback(t *testing.T) {
+	for _, atomic := range []string{"false", "true"} {
+		t.Run("atomic file updates "+atomic, func(t *testing.T) {
+			basePath := t.TempDir()
+			filePath := filepath.Join(basePath, "db.yaml")
+			version := ""
+			retrieveVersion := func(variableIDs []string, ctx context.Context) (map[string][]byte, error) {
+				return map[string][]byte{"db/password": []byte(version)}, nil
+			}
+			p, errs := NewProvider(retrieveVersion, false, P2FProviderConfig{
+				SecretFileBasePath:   basePath,
+				TemplateFileBasePath: t.TempDir(),
+				AnnotationsMap: map[string]string{
+					"conjur.org/atomic-file-updates-enabled": atomic,
+					"conjur.org/conjur-secrets.db":           "- db/password",
+					"conjur.org/secret-file-backups.db":      "2",
+				},
+			})
+			require.Empty(t, errs)
+
+			provide := func(v string) bool {
+				version = v
+				updated, err := p.Provide(context.Background())
+				require.NoError(t, err)
+				return updated
+			}
+			assertVersion := func(path, expected string) {
+				content, err := os.ReadFile(path)
+				require.NoError(t, err)
+				assert.Equal(t, `"password": "`+expected+`"`, string(content))
+			}
+
+			assert.True(t, provide("v1"))
+			assert.True(t, provide("v2"))
+			assert.True(t, provide("v3"))
+			assertVersion(filePath, "v3")
+			assertVersion(filePath+".1", "v2")
+			assertVersion(filePath+".2", "v1")
+
+			// A rolled back group isn't refreshed until it's resumed
+			require.NoError(t, p.Rollback(context.Background(), "db", 2))
+			assertVersion(filePath, "v1")
+			assert.False(t, provide("v4"))
+			assertVersion(filePath, "v1")
+
+			require.NoError(t, p.Resume("db"))
+			assert.True(t, provide("v4"))
+			assertVersion(filePath, "v4")
+			assertVersion(filePath+".1", "v1")
+			assertVersion(filePath+".2", "v2")
+
+			assert.EqualError(t, p.Rollback(context.Background(), "cache", 1), `unknown secret group "cache"`)
+			assert.EqualError(t, p.Rollback(context.Background(), "db", 3), `backup generation of secret group "db" must be between 1 and 2, got 3`)
+			assert.EqualError(t, p.Resume("db"), `secret group "db" is not paused`)
+		})
+	}
+}
+
+func TestFileProvider_RefreshIntervals(t *testing.T) {
+	var retrieved [][]string
+	retrieveRecorded := func(variableIDs []string, ctx context.Context) (map[string][]byte, error) {
+		ids := append([]string{}, variableIDs...)
+		sort.Strings(ids)
+		retrieved = append(retrieved, ids)
+		return retrieve(variableIDs, ctx)
+	}
+	p, errs := NewProvider(retrieveRecorded, false, P2FProviderConfig{
+		SecretFileBasePath:   t.TempDir(),
+		TemplateFileBasePath: t.TempDir(),
+		AnnotationsMap: map[string]string{
+			"conjur.org/conjur-secrets.db":            "- db/password",
+			"conjur.org/secrets-refresh-interval.db":  "30s",
+			"conjur.org/conjur-secrets.api":           "- api/key",
+			"conjur.org/secrets-refresh-interval.api": "30s",
+			"conjur.org/conjur-secrets.cache":         "- cache/token",
+		},
+	})
+	require.Empty(t, errs)
+	p.SetRefreshInterval(time.Hour)
+
+	updated, err := p.Provide(context.Background())
+	require.NoError(t, err)
+	assert.True(t, updated)
+	assert.Equal(t, [][]string{{"api/key", "cache/token", "db/password"}}, retrieved)
+
+	// Groups aren't refreshed before they're due
+	updated, err = p.Provide(context.Background())
+	require.NoError(t, err)
+	assert.False(t, updated)
+	assert.Len(t, retrieved, 1)
+
+	next, scheduled := p.NextRefresh()
+	assert.True(t, scheduled)
+	assert.LessOrEqual(t, next, 30*time.Second)
+	assert.Greater(t, next, 29*time.Second)
+
+	groupNames := func(groups []*SecretGroup) []string {
+		var names []string
+		for _, group := range groups {
+			names = append(names, group.Name)
+		}
+		return names
+	}
+	// Groups due together are refreshed together, and groups without a
+	// refresh interval of their own are refreshed on the global interval
+	now := time.Now()
+	assert.ElementsMatch(t, []string{"api", "db"}, groupNames(p.dueGroups(now.Add(30*time.Second))))
+	assert.ElementsMatch(t, []string{"api", "cache", "db"}, groupNames(p.dueGroups(now.Add(time.Hour))))
+}
@@ -4,14 +4,16 @@ import (
 	"bytes"
 	"fmt"
 	"github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/file_templates"
-	"github.com/cyberark/secrets-provider-for-k8s/pkg/utils"
 	"io"
 	"os"
 	"path/filepath"
+	"sort"
+	"text/template"
 
 	"github.com/cyberark/conjur-authn-k8s-client/pkg/log"
 	"github.com/cyberark/secrets-provider-for-k8s/pkg/atomicwriter"
 	"github.com/cyberark/secrets-provider-for-k8s/pkg/log/messages"
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/utils"
 )
 
 // pushToWriterFunc is the func definition for pushToWriter. It allows switching out pushToWriter
@@ -28,15 +30,12 @@ type pushToWriterFunc func(
 type openWriteCloserFunc func(
 	path string,
 	permissions os.FileMode,
+	owner *atomicwriter.FileOwner,
 ) (io.WriteCloser, error)
 
-// prevFileChecksums maps a secret group name to a sha256 checksum of the
-// corresponding secret file content. This is used to detect changes in
-// secret file content.
-var prevFileChecksums = map[string]utils.Checksum{}
-
-// openFileAsWriteCloser opens a file to write-to with some permissions.
-func openFileAsWriteCloser(path string, permissions os.FileMode) (io.WriteCloser, error) {
+// openFileAsWriteCloser opens a file to write-to with some permissions, and
+// an owner when owner isn't nil.
+func openFileAsWriteCloser(path string, permissions os.FileMode, owner *atomicwriter.FileOwner) (io.WriteCloser, error) {
 	dir := filepath.Dir(path)
 
 	// Create the file here to capture any errors, instead of in atomicWriter.Close() which may be deferred and ignored
@@ -45,18 +44,52 @@ func openFileAsWriteCloser(path string, permissions os.FileMode) (io.WriteCloser
 		return nil, fmt.Errorf("unable to mkdir when opening file to write at %q: %s", path, err)
 	}
 
-	wc, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE, permissions)
-	if err != nil {
+	// An existing file isn't opened, since it may be read-only or owned by
+	// another user. The atomic writer replaces it with a rename.
+	wc, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, permissions)
+	if os.IsExist(err) {
+		if info, statErr := os.Stat(path); statErr == nil && info.IsDir() {
+			return nil, fmt.Errorf("unable to open file to write at %q: is a directory", path)
+		}
+	} else if err != nil {
 		return nil, fmt.Errorf("unable to open file to write at %q: %s", path, err)
+	} else {
+		wc.Close()
 	}
-	wc.Close()
 
 	// Return an instance of an atomic writer
-	atomicWriter := atomicwriter.NewAtomicWriter(path, permissions)
+	atomicWriter := atomicwriter.NewAtomicWriterWithOwner(path, permissions, owner)
 
 	return atomicWriter, nil
 }
 
+// classifyWriteFailures returns an openWriteCloserFunc whose failures to
+// open, write and close files are classified as write failures
+func classifyWriteFailures(depOpenWriteCloser openWriteCloserFunc) openWriteCloserFunc {
+	return func(path string, permissions os.FileMode, owner *atomicwriter.FileOwner) (io.WriteCloser, error) {
+		wc, err := depOpenWriteCloser(path, permissions, owner)
+		if err != nil {
+			return nil, utils.Classify(utils.FailureWrite, err)
+		}
+		return writeFailureCloser{wc}, nil
+	}
+}
+
+// writeFailureCloser classifies the failures of a WriteCloser as write
+// failures
+type writeFailureCloser struct {
+	io.WriteCloser
+}
+
+func (w writeFailureCloser) Write(p []byte) (int, error) {
+	n, err := w.WriteCloser.Write(p)
+	return n, utils.Classify(utils.FailureWrite, err)
+}
+
+func (w writeFailureCloser) Close() error {
+	return utils.Classify(utils.FailureWrite, w.WriteCloser.Close())
+}
+
 // pushToWriter takes a (group's) path, template and secrets, and processes the template
 // to generate text content that is pushed to a writer. push-to-file wraps around this.
 func pushToWriter(
@@ -65,14 +98,41 @@ func pushToWriter(
 	groupTemplate string,
 	groupSecrets []*filetemplates.Secret,
 ) (bool, error) {
+	fileContent, err := renderTemplate(filetemplates.GetTemplate, groupName, groupTemplate, groupSecrets)
+	if err != nil {
+		return false, err
+	}
+
+	return writeContent(writer, fileContent)
+}
+
+// validateTemplate renders a group's template with placeholder secret values,
+// to validate it before any secrets are retrieved.
+func validateTemplate(
+	groupName string,
+	groupTemplate string,
+	placeholderSecrets []*filetemplates.Secret,
+) error {
+	_, err := renderTemplate(filetemplates.GetValidationTemplate, groupName, groupTemplate, placeholderSecrets)
+	return err
+}
+
+// renderTemplate renders a group's template, created by getTemplate, with
+// the group's secrets.
+func renderTemplate(
+	getTemplate func(string, map[string]*filetemplates.Secret) *template.Template,
+	groupName string,
+	groupTemplate string,
+	groupSecrets []*filetemplates.Secret,
+) (*bytes.Buffer, error) {
 	secretsMap := map[string]*filetemplates.Secret{}
 	for _, s := range groupSecrets {
 		secretsMap[s.Alias] = s
 	}
 
-	tpl, err := filetemplates.GetTemplate(groupName, secretsMap).Parse(groupTemplate)
+	tpl, err := getTemplate(groupName, secretsMap).Parse(groupTemplate)
 	if err != nil {
-		return false, err
+		return nil, err
 	}
 
 	// Render the secret file content
@@ -80,34 +140,44 @@ func pushToWriter(
 		SecretsArray: groupSecrets,
 		SecretsMap:   secretsMap,
 	}
-	fileContent, err := filetemplates.RenderFile(tpl, tplData)
-	if err != nil {
-		return false, err
-	}
-
-	return writeContent(writer, fileContent, groupName)
+	return filetemplates.RenderFile(tpl, tplData)
 }
 
-func writeContent(writer io.Writer, fileContent *bytes.Buffer, groupName string) (bool, error) {
+// writeContent writes the content of a secret file. Writers that detect
+// changes, such as the writers of secret group files, skip unchanged content.
+func writeContent(writer io.Writer, fileContent *bytes.Buffer) (bool, error) {
 	if writer == io.Discard {
 		_, err := writer.Write(fileContent.Bytes())
 		return false, err
 	}
 
-	// Calculate a sha256 checksum on the content
-	checksum, _ := utils.FileChecksum(fileContent)
-
 	// If file contents haven't changed, don't rewrite the file
-	if !utils.ContentHasChanged(groupName, checksum, prevFileChecksums) {
+	if checker, ok := writer.(contentChecker); ok && !checker.contentChanged(fileContent.Bytes()) {
 		log.Info(messages.CSPFK018I)
 		return false, nil
 	}
 
-	// Write the file and update the checksum
 	if _, err := writer.Write(fileContent.Bytes()); err != nil {
 		return false, err
 	}
-	prevFileChecksums[groupName] = checksum
 
 	return true, nil
 }
+
+// secretInputs serializes the file format and secret values of a group
+// deterministically. It is used for change detection of secret files whose
+// content can't be checksummed directly, such as salted keystores.
+func secretInputs(fileFormat string, secrets []*filetemplates.Secret) *bytes.Buffer {
+	sorted := make([]*filetemplates.Secret, len(secrets))
+	copy(sorted, secrets)
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Alias < sorted[j].Alias
+	})
+
+	buf := &bytes.Buffer{}
+	fmt.Fprintf(buf, "%s\x00", fileFormat)
+	for _, s := range sorted {
+		fmt.Fprintf(buf, "%d:%s%d:%s", len(s.Alias), s.Alias, len(s.Value), s.Value)
+	}
+	return buf
+}
@@ -3,9 +3,13 @@ package pushtofile
 import (
 	"bytes"
 	"github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/file_templates"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
+
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/utils"
 )
 
 type pushToWriterTestCase struct {
@@ -155,59 +159,87 @@ func Test_pushToWriter(t *testing.T) {
 
 func Test_pushToWriter_contentChanges(t *testing.T) {
 	t.Run("content changes", func(t *testing.T) {
-		// Call pushToWriter with a simple template and secret.
+		// Call pushToWriter with a simple template and secret, writing
+		// to a secret file that detects changes.
 		secrets := []*filetemplates.Secret{{Alias: "alias", Value: "secret value"}}
-		groupName := "group path"
 		template := `{{secret "alias"}}`
+		group := &SecretGroup{
+			Name:            "group path",
+			FilePath:        filepath.Join(t.TempDir(), "file"),
+			FilePermissions: 0644,
+		}
+		checksums := newFileChecksums()
+		push := func(template string, secrets []*filetemplates.Secret) (bool, error) {
+			wc, err := group.openFile(openFileAsWriteCloser, checksums)
+			if err != nil {
+				return false, err
+			}
+			defer wc.Close()
+			return pushToWriter(wc, group.Name, template, secrets)
+		}
+		assertContent := func(expected string) {
+			content, err := os.ReadFile(group.FilePath)
+			assert.NoError(t, err)
+			assert.Equal(t, expected, string(content))
+		}
 
-		buf := new(bytes.Buffer)
-		updated, err := pushToWriter(
-			buf,
-			groupName,
-			template,
-			secrets,
-		)
+		updated, err := push(template, secrets)
 		assert.NoError(t, err)
 		assert.True(t, updated)
-		assert.Equal(t, "secret value", buf.String())
-
-		// Now clear the buffer and call pushToWriter again. Since the secret is the same,
-		// it should not update the buffer.
-		buf.Reset()
-		updated, err = pushToWriter(
-			buf,
-			groupName,
-			template,
-			secrets,
-		)
+		assertContent("secret value")
 
+		// Call pushToWriter again. Since the secret is the same, it should
+		// not update the file.
+		updated, err = push(template, secrets)
 		assert.NoError(t, err)
 		assert.False(t, updated)
-		assert.Zero(t, buf.Len())
+		assertContent("secret value")
 
-		// Now change the secret and call pushToWriter again. This time, the buffer should
+		// Now change the secret and call pushToWriter again. This time, the file should
 		// be updated because the secret has changed.
-		updated, err = pushToWriter(
-			buf,
-			groupName,
-			template,
-			[]*filetemplates.Secret{{Alias: "alias", Value: "secret changed"}},
-		)
+		updated, err = push(template, []*filetemplates.Secret{{Alias: "alias", Value: "secret changed"}})
 		assert.NoError(t, err)
 		assert.True(t, updated)
-		assert.Equal(t, "secret changed", buf.String())
+		assertContent("secret changed")
 
-		// Repeat the test but this time change the template instead of the secret. The buffer should still
+		// Repeat the test but this time change the template instead of the secret. The file should still
 		// be updated because the rendered output should be different.
-		buf.Reset()
-		updated, err = pushToWriter(
-			buf,
-			groupName,
-			`- {{secret "alias"}}`,
-			[]*filetemplates.Secret{{Alias: "alias", Value: "secret changed"}},
-		)
+		updated, err = push(`- {{secret "alias"}}`, []*filetemplates.Secret{{Alias: "alias", Value: "secret changed"}})
 		assert.NoError(t, err)
 		assert.True(t, updated)
-		assert.Equal(t, "- secret changed", buf.String())
+		assertContent("- secret changed")
 	})
+
+	t.Run("writers without change detection are always written", func(t *testing.T) {
+		secrets := []*filetemplates.Secret{{Alias: "alias", Value: "secret value"}}
+		for i := 0; i < 2; i++ {
+			buf := new(bytes.Buffer)
+			updated, err := pushToWriter(buf, "group path", `{{secret "alias"}}`, secrets)
+			assert.NoError(t, err)
+			assert.True(t, updated)
+			assert.Equal(t, "secret value", buf.String())
+		}
+	})
+}
+
+func Test_classifyWriteFailures(t *testing.T) {
+	dir := t.TempDir()
+	openWriteCloser := classifyWriteFailures(openFileAsWriteCloser)
+
+	// Failures to open files are write failures
+	assert.NoError(t, os.Mkdir(filepath.Join(dir, "group.yaml"), 0755))
+	_, err := openWriteCloser(filepath.Join(dir, "group.yaml"), 0644, nil)
+	assert.Error(t, err)
+	assert.Equal(t, utils.FailureWrite, utils.FailureClassOf(err))
+
+	// Files are written as usual
+	path := filepath.Join(dir, "group.json")
+	wc, err := openWriteCloser(path, 0644, nil)
+	assert.NoError(t, err)
+	_, err = wc.Write([]byte("{}"))
+	assert.NoError(t, err)
+	assert.NoError(t, wc.Close())
+	content, err := os.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "{}", string(content))
 }
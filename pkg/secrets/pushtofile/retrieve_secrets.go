@@ -11,6 +11,7 @@ import (
 	"github.com/cyberark/conjur-authn-k8s-client/pkg/log"
 	"github.com/cyberark/secrets-provider-for-k8s/pkg/log/messages"
 	"github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/clients/conjur"
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/utils"
 )
 
 // FetchSecretsForGroups fetches the secrets for all the groups and returns
@@ -19,13 +20,12 @@ import (
 func FetchSecretsForGroups(
 	depRetrieveSecrets conjur.RetrieveSecretsFunc,
 	secretGroups []*SecretGroup,
-	traceContext context.Context,
+	ctx context.Context,
 ) (map[string][]*filetemplates.Secret, error) {
 	var err error
 	secretsByGroup := map[string][]*filetemplates.Secret{}
 
-	secretPaths := getAllPaths(secretGroups)
-	secretValueById, err := depRetrieveSecrets(secretPaths, traceContext)
+	secretValueById, err := retrieveSecrets(depRetrieveSecrets, secretGroups, ctx)
 	if err != nil {
 		return nil, err
 	}
@@ -40,6 +40,15 @@ func FetchSecretsForGroups(
 
 	for _, group := range secretGroups {
 		for _, spec := range group.SecretSpecs {
+			// Literal secrets are provided as is
+			if spec.Literal != nil {
+				secretsByGroup[group.Name] = append(
+					secretsByGroup[group.Name],
+					&filetemplates.Secret{Alias: spec.Alias, Value: *spec.Literal},
+				)
+				continue
+			}
+
 			paths := []string{spec.Path}
 			// If the path is "*", then we should populate all the fetched secrets
 			if spec.Path == "*" {
@@ -61,8 +70,31 @@ func FetchSecretsForGroups(
 				// with a path that is not present in the fetched secrets. In this case, we should imitate the behavior
 				// of a missing secret in non-Fetch All mode - i.e., return an error. This will allow the caller to
 				// decide whether to leave the secret files as is or to delete them (if sanitize is enabled).
+				if err != nil && spec.Optional && spec.Path != "*" {
+					// Optional secrets are replaced by their default, or left out
+					if spec.Default == nil {
+						log.Warn(messages.CSPFK100E, path, group.Name)
+						continue
+					}
+					log.Warn(messages.CSPFK099E, path, group.Name)
+					secretsByGroup[group.Name] = append(
+						secretsByGroup[group.Name],
+						&filetemplates.Secret{Alias: spec.Alias, Value: *spec.Default},
+					)
+					continue
+				}
 				if err != nil {
-					return nil, fmt.Errorf(messages.CSPFK068E, path, group.Name)
+					return nil, utils.Classify(utils.FailureNotFound, fmt.Errorf(messages.CSPFK068E, path, group.Name))
+				}
+
+				// JSON secrets are expanded into their fields
+				if spec.ContentType == "json" {
+					fields, err := filetemplates.ExpandJSONSecret(secret.Alias, spec.Field, []byte(secret.Value))
+					if err != nil {
+						return nil, utils.Classify(utils.FailureConfig, fmt.Errorf(messages.CSPFK096E, path, group.Name, err))
+					}
+					secretsByGroup[group.Name] = append(secretsByGroup[group.Name], fields...)
+					continue
 				}
 				secretsByGroup[group.Name] = append(secretsByGroup[group.Name], secret)
 			}
@@ -72,6 +104,151 @@ func FetchSecretsForGroups(
 	return secretsByGroup, err
 }
 
+// fetchSecretsForEachGroup fetches the secrets for all the groups at once,
+// like FetchSecretsForGroups. Fetching fails when any secret is missing or
+// can't be accessed, so when that happens the secrets of each group are
+// fetched on their own, and the groups that can't be fetched are recorded in
+// groupErrs. Other failures, such as Conjur being unavailable, are recorded
+// for every group right away, instead of sending a request for each group to
+// a server that's already failing.
+func fetchSecretsForEachGroup(
+	depRetrieveSecrets conjur.RetrieveSecretsFunc,
+	secretGroups []*SecretGroup,
+	ctx context.Context,
+	groupErrs *SecretGroupsError,
+) map[string][]*filetemplates.Secret {
+	secretsByGroup, err := FetchSecretsForGroups(depRetrieveSecrets, secretGroups, ctx)
+	if err == nil {
+		return secretsByGroup
+	}
+	if len(secretGroups) == 1 || !isGroupError(err) {
+		for _, group := range secretGroups {
+			groupErrs.add(group.Name, err)
+		}
+		return nil
+	}
+	log.Debug(messages.CSPFK019D, err)
+
+	secretsByGroup = map[string][]*filetemplates.Secret{}
+	for _, group := range secretGroups {
+		groupSecrets, err := FetchSecretsForGroups(depRetrieveSecrets, []*SecretGroup{group}, ctx)
+		if err != nil {
+			groupErrs.add(group.Name, err)
+			continue
+		}
+		secretsByGroup[group.Name] = groupSecrets[group.Name]
+	}
+	return secretsByGroup
+}
+
+// isGroupError reports whether err, of fetching the secrets of several groups
+// at once, may be caused by only some of the groups: secrets that are missing
+// or can't be accessed, or the secret specs of a group
+func isGroupError(err error) bool {
+	return utils.IsRevokedError(err) || utils.FailureClassOf(err) == utils.FailureConfig || utils.IsPermanent(err)
+}
+
+// retrieveSecrets retrieves the secrets of all the groups. Retrieving a batch
+// fails when any of its secrets is missing or can't be accessed, so when that
+// happens and some secrets are optional, the required secrets are retrieved
+// in a batch of their own, and the optional secrets with
+// retrieveOptionalSecrets. Optional secrets that are missing or can't be
+// accessed are left out of the result. Other failures, such as Conjur being
+// unavailable, are returned, so that optional secrets aren't replaced by
+// their default.
+func retrieveSecrets(
+	depRetrieveSecrets conjur.RetrieveSecretsFunc,
+	secretGroups []*SecretGroup,
+	ctx context.Context,
+) (map[string][]byte, error) {
+	// Groups may only hold literal secrets
+	allPaths := getAllPaths(secretGroups)
+	if len(allPaths) == 0 {
+		return map[string][]byte{}, nil
+	}
+
+	secretValueById, err := depRetrieveSecrets(allPaths, ctx)
+	optionalPaths := getOptionalPaths(secretGroups)
+	if err == nil || len(optionalPaths) == 0 || !utils.IsRevokedError(err) {
+		return secretValueById, err
+	}
+	log.Warn(messages.CSPFK101E, err.Error())
+
+	var requiredPaths []string
+	for _, path := range allPaths {
+		if _, ok := optionalPaths[path]; !ok {
+			requiredPaths = append(requiredPaths, path)
+		}
+	}
+
+	secretValueById = map[string][]byte{}
+	if len(requiredPaths) > 0 {
+		secretValueById, err = depRetrieveSecrets(requiredPaths, ctx)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	paths := make([]string, 0, len(optionalPaths))
+	for path := range optionalPaths {
+		paths = append(paths, path)
+	}
+	slices.Sort(paths)
+	retrieveOptional := retrieveOptionalSecrets
+	if len(requiredPaths) == 0 {
+		// The batch that failed only held optional secrets
+		retrieveOptional = retrieveOptionalSecretHalves
+	}
+	if err := retrieveOptional(depRetrieveSecrets, paths, ctx, secretValueById); err != nil {
+		return nil, err
+	}
+	return secretValueById, nil
+}
+
+// retrieveOptionalSecrets retrieves optional secrets into secretValueById.
+// When a batch fails because some of its secrets are missing or can't be
+// accessed, it's split in halves that are retrieved on their own, so that a
+// few missing secrets only take a few more requests. Other failures are
+// returned.
+func retrieveOptionalSecrets(
+	depRetrieveSecrets conjur.RetrieveSecretsFunc,
+	paths []string,
+	ctx context.Context,
+	secretValueById map[string][]byte,
+) error {
+	values, err := depRetrieveSecrets(paths, ctx)
+	switch {
+	case err == nil:
+		for id, value := range values {
+			secretValueById[id] = value
+		}
+		return nil
+	case !utils.IsRevokedError(err):
+		return err
+	}
+	return retrieveOptionalSecretHalves(depRetrieveSecrets, paths, ctx, secretValueById)
+}
+
+// retrieveOptionalSecretHalves retrieves each half of a batch of optional
+// secrets that failed with retrieveOptionalSecrets
+func retrieveOptionalSecretHalves(
+	depRetrieveSecrets conjur.RetrieveSecretsFunc,
+	paths []string,
+	ctx context.Context,
+	secretValueById map[string][]byte,
+) error {
+	if len(paths) == 1 {
+		// Reported when the secret is found missing from the result
+		return nil
+	}
+
+	half := len(paths) / 2
+	if err := retrieveOptionalSecrets(depRetrieveSecrets, paths[:half], ctx, secretValueById); err != nil {
+		return err
+	}
+	return retrieveOptionalSecrets(depRetrieveSecrets, paths[half:], ctx, secretValueById)
+}
+
 func getSecretValueByID(secretValuesByID map[string][]byte, spec filetemplates.SecretSpec, path string) (*filetemplates.Secret, error) {
 	alias := spec.Alias
 	if alias == "" || alias == "*" {
@@ -125,11 +302,37 @@ func (s secretPathSet) Add(path string) {
 	s[path] = struct{}{}
 }
 
+// getOptionalPaths returns the paths for which every secret spec is optional
+func getOptionalPaths(secretGroups []*SecretGroup) secretPathSet {
+	optional := secretPathSet{}
+	required := secretPathSet{}
+	for _, group := range secretGroups {
+		for _, spec := range group.SecretSpecs {
+			if spec.Literal != nil {
+				continue
+			}
+			if spec.Optional && spec.Path != "*" {
+				optional.Add(spec.Path)
+			} else {
+				required.Add(spec.Path)
+			}
+		}
+	}
+	for path := range required {
+		delete(optional, path)
+	}
+	return optional
+}
+
 func getAllPaths(secretGroups []*SecretGroup) []string {
 	// Create a mathematical set of all secret paths
 	pathSet := secretPathSet{}
 	for _, group := range secretGroups {
 		for _, spec := range group.SecretSpecs {
+			// Literal secrets aren't retrieved from Conjur
+			if spec.Literal != nil {
+				continue
+			}
 			// If the path is "*", then we should fetch all secrets
 			if spec.Path == "*" {
 				return []string{"*"}
@@ -3,11 +3,14 @@ package pushtofile
 import (
 	"context"
 	"encoding/base64"
+	"errors"
+	"fmt"
 	"testing"
 
 	"github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/clients/conjur"
 	conjurMocks "github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/clients/conjur/mocks"
 	"github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/file_templates"
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/utils"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -278,6 +281,286 @@ func TestRetrieveSecrets(t *testing.T) {
 	}
 }
 
+func TestRetrieveSecrets_JSON(t *testing.T) {
+	m := newMockSecretFetcher()
+	m.conjurMockClient.AddSecrets(map[string]string{
+		"dev/openshift/credentials": `{"username": "admin", "password": "open-$e$ame", "port": 5432}`,
+	})
+
+	testCases := []retrieveSecretsTestCase{
+		{
+			description: "JSON field",
+			secretSpecs: map[string][]filetemplates.SecretSpec{
+				"db": {
+					{Alias: "api-url", Path: "dev/openshift/api-url"},
+					{Alias: "db-password", Path: "dev/openshift/credentials", ContentType: "json", Field: "password"},
+				},
+			},
+			assert: assertGoodResults(map[string][]*filetemplates.Secret{
+				"db": {
+					{Alias: "api-url", Value: "https://postgres.example.com"},
+					{Alias: "db-password", Value: "open-$e$ame"},
+				},
+			}),
+		},
+		{
+			description: "JSON keys",
+			secretSpecs: map[string][]filetemplates.SecretSpec{
+				"db": {
+					{Alias: "credentials", Path: "dev/openshift/credentials", ContentType: "json"},
+					{Alias: "api-url", Path: "dev/openshift/api-url"},
+				},
+			},
+			assert: assertGoodResults(map[string][]*filetemplates.Secret{
+				"db": {
+					{Alias: "username", Value: "admin"},
+					{Alias: "password", Value: "open-$e$ame"},
+					{Alias: "port", Value: "5432"},
+					{Alias: "api-url", Value: "https://postgres.example.com"},
+				},
+			}),
+		},
+		{
+			description: "Missing JSON field",
+			secretSpecs: map[string][]filetemplates.SecretSpec{
+				"db": {
+					{Alias: "token", Path: "dev/openshift/credentials", ContentType: "json", Field: "token"},
+				},
+			},
+			assert: func(t *testing.T, result map[string][]*filetemplates.Secret, err error) {
+				assert.EqualError(t, err, `CSPFK096E Failed to read JSON secret 'dev/openshift/credentials' for secret group 'db': field "token" not present in JSON secret value for alias "token"`)
+			},
+		},
+		{
+			description: "Not a JSON object",
+			secretSpecs: map[string][]filetemplates.SecretSpec{
+				"db": {
+					{Alias: "password", Path: "dev/openshift/password", ContentType: "json"},
+				},
+			},
+			assert: func(t *testing.T, result map[string][]*filetemplates.Secret, err error) {
+				assert.EqualError(t, err, `CSPFK096E Failed to read JSON secret 'dev/openshift/password' for secret group 'db': secret value for alias "password" is not a JSON object`)
+				assert.NotContains(t, err.Error(), "open-$e$ame")
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc.Run(t, m.Fetch)
+	}
+}
+
+func TestRetrieveSecrets_Optional(t *testing.T) {
+	m := newMockSecretFetcher()
+	defaultValue := "default"
+
+	testCases := []retrieveSecretsTestCase{
+		{
+			description: "Missing optional secrets",
+			secretSpecs: map[string][]filetemplates.SecretSpec{
+				"app": {
+					{Alias: "api-url", Path: "dev/openshift/api-url"},
+					{Alias: "flag", Path: "dev/openshift/flag", Optional: true, Default: &defaultValue},
+					{Alias: "username", Path: "dev/openshift/username", Optional: true},
+					{Alias: "other-flag", Path: "dev/openshift/other-flag", Optional: true},
+				},
+			},
+			assert: assertGoodResults(map[string][]*filetemplates.Secret{
+				"app": {
+					{Alias: "api-url", Value: "https://postgres.example.com"},
+					{Alias: "flag", Value: "default"},
+					{Alias: "username", Value: "admin"},
+				},
+			}),
+		},
+		{
+			description: "Only optional secrets",
+			secretSpecs: map[string][]filetemplates.SecretSpec{
+				"app": {
+					{Alias: "flag", Path: "dev/openshift/flag", Optional: true},
+				},
+			},
+			assert: func(t *testing.T, result map[string][]*filetemplates.Secret, err error) {
+				assert.NoError(t, err)
+				assert.Empty(t, result["app"])
+			},
+		},
+		{
+			description: "Missing required secret",
+			secretSpecs: map[string][]filetemplates.SecretSpec{
+				"app": {
+					{Alias: "api-url", Path: "dev/openshift/missing"},
+					{Alias: "flag", Path: "dev/openshift/flag", Optional: true},
+				},
+			},
+			assert: func(t *testing.T, result map[string][]*filetemplates.Secret, err error) {
+				assert.EqualError(t, err, "no_conjur_secret_error")
+			},
+		},
+		{
+			description: "Secret required by another group",
+			secretSpecs: map[string][]filetemplates.SecretSpec{
+				"app": {
+					{Alias: "flag", Path: "dev/openshift/flag", Optional: true},
+				},
+				"other": {
+					{Alias: "flag", Path: "dev/openshift/flag"},
+				},
+			},
+			assert: func(t *testing.T, result map[string][]*filetemplates.Secret, err error) {
+				assert.EqualError(t, err, "no_conjur_secret_error")
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		tc.Run(t, m.Fetch)
+	}
+}
+
+func TestRetrieveSecrets_OptionalFailures(t *testing.T) {
+	defaultValue := "default"
+	secretSpecs := map[string][]filetemplates.SecretSpec{
+		"app": {
+			{Alias: "api-url", Path: "dev/openshift/api-url"},
+			{Alias: "flag", Path: "dev/openshift/flag", Optional: true, Default: &defaultValue},
+			{Alias: "username", Path: "dev/openshift/username", Optional: true},
+		},
+	}
+
+	t.Run("Conjur unavailable", func(t *testing.T) {
+		m := newMockSecretFetcher()
+		m.conjurMockClient.ErrOnExecute = utils.Transient(errors.New("503 Service Unavailable"))
+
+		_, err := FetchSecretsForGroups(m.Fetch, createSecretGroups(secretSpecs), context.Background())
+		assert.EqualError(t, err, "503 Service Unavailable")
+	})
+
+	t.Run("Conjur unavailable when retrieving optional secrets", func(t *testing.T) {
+		m := newMockSecretFetcher()
+		calls := 0
+		fetch := func(paths []string, ctx context.Context) (map[string][]byte, error) {
+			calls++
+			if calls > 2 {
+				return nil, utils.Transient(errors.New("503 Service Unavailable"))
+			}
+			return m.Fetch(paths, ctx)
+		}
+
+		_, err := FetchSecretsForGroups(fetch, createSecretGroups(secretSpecs), context.Background())
+		assert.EqualError(t, err, "503 Service Unavailable")
+	})
+
+	t.Run("optional secret not permitted", func(t *testing.T) {
+		m := newMockSecretFetcher()
+		fetch := func(paths []string, ctx context.Context) (map[string][]byte, error) {
+			for _, path := range paths {
+				if path == "dev/openshift/username" {
+					return nil, utils.Classify(utils.FailureAuthz, errors.New("403 Forbidden"))
+				}
+			}
+			return m.Fetch(paths, ctx)
+		}
+		m.conjurMockClient.AddSecrets(map[string]string{"dev/openshift/flag": "enabled"})
+
+		result, err := FetchSecretsForGroups(fetch, createSecretGroups(secretSpecs), context.Background())
+		assert.NoError(t, err)
+		assert.Equal(t, []*filetemplates.Secret{
+			{Alias: "api-url", Value: "https://postgres.example.com"},
+			{Alias: "flag", Value: "enabled"},
+		}, result["app"])
+	})
+
+	t.Run("few requests for a missing optional secret", func(t *testing.T) {
+		m := newMockSecretFetcher()
+		specs := []filetemplates.SecretSpec{{Alias: "flag", Path: "dev/openshift/flag", Optional: true}}
+		for i := 0; i < 16; i++ {
+			path := fmt.Sprintf("dev/openshift/optional-%d", i)
+			m.conjurMockClient.AddSecrets(map[string]string{path: "value"})
+			specs = append(specs, filetemplates.SecretSpec{Alias: path, Path: path, Optional: true})
+		}
+		calls := 0
+		fetch := func(paths []string, ctx context.Context) (map[string][]byte, error) {
+			calls++
+			return m.Fetch(paths, ctx)
+		}
+
+		result, err := FetchSecretsForGroups(fetch, createSecretGroups(map[string][]filetemplates.SecretSpec{"app": specs}), context.Background())
+		assert.NoError(t, err)
+		assert.Len(t, result["app"], 16)
+		// One batch of all the secrets, and a request for each half on the
+		// way down to the missing secret
+		assert.Equal(t, 1+2*4, calls)
+	})
+}
+
+func TestFetchSecretsForEachGroup(t *testing.T) {
+	secretSpecs := map[string][]filetemplates.SecretSpec{
+		"db":    {{Alias: "password", Path: "dev/db/password"}},
+		"cache": {{Alias: "token", Path: "dev/cache/token"}},
+		"api":   {{Alias: "key", Path: "dev/api/key"}},
+	}
+	values := map[string][]byte{
+		"dev/db/password": []byte("password"),
+		"dev/api/key":     []byte("key"),
+	}
+
+	testCases := []struct {
+		description    string
+		err            error
+		expectedCalls  int
+		expectedFailed []string
+	}{
+		{
+			description:    "missing secret",
+			err:            utils.Permanent(utils.Classify(utils.FailureNotFound, errors.New("404 Not Found"))),
+			expectedCalls:  1 + 3,
+			expectedFailed: []string{"cache"},
+		},
+		{
+			description:    "secret not permitted",
+			err:            utils.Permanent(utils.Classify(utils.FailureAuthz, errors.New("403 Forbidden"))),
+			expectedCalls:  1 + 3,
+			expectedFailed: []string{"cache"},
+		},
+		{
+			description:    "Conjur unavailable",
+			err:            utils.Transient(errors.New("503 Service Unavailable")),
+			expectedCalls:  1,
+			expectedFailed: []string{"api", "cache", "db"},
+		},
+		{
+			description:    "timeout",
+			err:            context.DeadlineExceeded,
+			expectedCalls:  1,
+			expectedFailed: []string{"api", "cache", "db"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			calls := 0
+			fetch := func(paths []string, ctx context.Context) (map[string][]byte, error) {
+				calls++
+				result := map[string][]byte{}
+				for _, path := range paths {
+					value, ok := values[path]
+					if !ok {
+						return nil, tc.err
+					}
+					result[path] = value
+				}
+				return result, nil
+			}
+
+			groupErrs := &SecretGroupsError{}
+			fetchSecretsForEachGroup(fetch, createSecretGroups(secretSpecs), context.Background(), groupErrs)
+			assert.Equal(t, tc.expectedCalls, calls)
+			assert.Equal(t, tc.expectedFailed, groupErrs.Groups())
+		})
+	}
+}
+
 func TestGetAllPaths(t *testing.T) {
 	// Define test cases
 	testCases := []struct {
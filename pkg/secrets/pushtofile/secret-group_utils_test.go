@@ -5,6 +5,8 @@ import (
 	"github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/file_templates"
 	"io"
 	"os"
+
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/atomicwriter"
 )
 
 type ClosableBuffer struct {
@@ -55,6 +57,7 @@ func (spy *pushToWriterSpy) Call(
 type openWriteCloserArgs struct {
 	path        string
 	permissions os.FileMode
+	owner       *atomicwriter.FileOwner
 }
 
 type openWriteCloserSpy struct {
@@ -64,7 +67,7 @@ type openWriteCloserSpy struct {
 	_calls      int
 }
 
-func (spy *openWriteCloserSpy) Call(path string, permissions os.FileMode) (io.WriteCloser, error) {
+func (spy *openWriteCloserSpy) Call(path string, permissions os.FileMode, owner *atomicwriter.FileOwner) (io.WriteCloser, error) {
 	spy._calls++
 	// This is to ensure the spy is only ever used once!
 	if spy._calls > 1 {
@@ -74,6 +77,7 @@ func (spy *openWriteCloserSpy) Call(path string, permissions os.FileMode) (io.Wr
 	spy.args = openWriteCloserArgs{
 		path:        path,
 		permissions: permissions,
+		owner:       owner,
 	}
 
 	return spy.writeCloser, spy.err
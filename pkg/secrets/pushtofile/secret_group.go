@@ -1,6 +1,7 @@
 package pushtofile
 
 import (
+	"bytes"
 	"fmt"
 	"github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/file_templates"
 	"io"
@@ -8,16 +9,24 @@ import (
 	"path"
 	"path/filepath"
 	"sort"
+	"strconv"
 	"strings"
+	"time"
 
 	"github.com/cyberark/conjur-authn-k8s-client/pkg/log"
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/atomicwriter"
 	"github.com/cyberark/secrets-provider-for-k8s/pkg/log/messages"
+	secretsConfig "github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/config"
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/utils"
 )
 
 const secretGroupPolicyPathPrefix = "conjur.org/conjur-secrets-policy-path."
 const secretGroupFilePathPrefix = "conjur.org/secret-file-path."
 const secretGroupFileFormatPrefix = "conjur.org/secret-file-format."
 const secretGroupFilePermissionsPrefix = "conjur.org/secret-file-permissions."
+const secretGroupFileNestingPrefix = "conjur.org/secret-file-nesting."
+const secretGroupFileOwnerPrefix = "conjur.org/secret-file-owner."
+const secretGroupRefreshIntervalPrefix = "conjur.org/secrets-refresh-interval."
 
 const defaultFilePermissions os.FileMode = 0644
 const maxFilenameLen = 255
@@ -28,8 +37,11 @@ const maxFilenameLen = 255
 type Config struct {
 	secretsBasePath   string
 	templatesBasePath string
-	openReadCloser    openReadCloserFunc
-	pullFromReader    pullFromReaderFunc
+	// manifestPath is the path of the secret groups manifest, see
+	// secretGroupsManifest. No manifest is read when it's empty.
+	manifestPath   string
+	openReadCloser openReadCloserFunc
+	pullFromReader pullFromReaderFunc
 }
 
 // SecretGroup incorporates all of the information about a secret group
@@ -39,9 +51,31 @@ type SecretGroup struct {
 	FilePath         string
 	FileTemplate     string
 	FileFormat       string
+	FileNesting      string
 	PolicyPathPrefix string
 	FilePermissions  os.FileMode
-	SecretSpecs      []filetemplates.SecretSpec
+	// FileOwner is the owner of the secret files, which are owned by the
+	// Secrets Provider when it's nil
+	FileOwner *atomicwriter.FileOwner
+	// Backups is the number of previous generations of the secret file kept
+	// next to it, see backupFile
+	Backups     int
+	SecretSpecs []filetemplates.SecretSpec
+	// SecretFilesPath is the directory holding the secrets that are written
+	// to a file of their own, see filetemplates.SecretSpec.AsFile
+	SecretFilesPath string
+	// stagedSecretFilesPath is where those files are written when secret
+	// files are updated atomically
+	stagedSecretFilesPath string
+	// PostHook is a command run after the files of the group changed
+	PostHook        string
+	PostHookTimeout time.Duration
+	// RefreshInterval is how often the secrets of the group are refreshed,
+	// instead of the global refresh interval when it's not zero
+	RefreshInterval time.Duration
+	// SecretsYmlPath is the path of the Summon secrets.yml the secret specs
+	// were read from, if any, which is watched for configuration reloads
+	SecretsYmlPath string
 }
 
 // ResolvedSecretSpecs resolves all of the secret paths for a secret
@@ -50,6 +84,10 @@ type SecretGroup struct {
 func resolvedSecretSpecs(policyPathPrefix string, secretSpecs []filetemplates.SecretSpec) []filetemplates.SecretSpec {
 	if len(policyPathPrefix) != 0 {
 		for i := range secretSpecs {
+			// Literal secrets have no path
+			if secretSpecs[i].Literal != nil {
+				continue
+			}
 			secretSpecs[i].Path = strings.TrimSuffix(policyPathPrefix, "/") +
 				"/" + strings.TrimPrefix(secretSpecs[i].Path, "/")
 		}
@@ -59,14 +97,16 @@ func resolvedSecretSpecs(policyPathPrefix string, secretSpecs []filetemplates.Se
 }
 
 // PushToFile uses the configuration on a secret group to inject secrets into a template
-// and write the result to a file.
+// and write the result to a file. Changes are detected against the files on disk only,
+// so keystores are always written.
 func (sg *SecretGroup) PushToFile(secrets []*filetemplates.Secret) (bool, error) {
-	return sg.pushToFileWithDeps(openFileAsWriteCloser, pushToWriter, secrets)
+	return sg.pushToFileWithDeps(openFileAsWriteCloser, pushToWriter, newFileChecksums(), secrets)
 }
 
 func (sg *SecretGroup) pushToFileWithDeps(
 	depOpenWriteCloser openWriteCloserFunc,
 	depPushToWriter pushToWriterFunc,
+	checksums *fileChecksums,
 	secrets []*filetemplates.Secret,
 ) (updated bool, err error) {
 	// Make sure all the secret specs are accounted for
@@ -74,6 +114,46 @@ func (sg *SecretGroup) pushToFileWithDeps(
 		return false, err
 	}
 
+	// Aliases of JSON secrets without a field are only known once secrets are
+	// fetched, so they are validated against the file format here
+	if len(sg.FileTemplate) == 0 && hasExpandingSpecs(sg.SecretSpecs) {
+		if err = validateSecretAliases(sg.FileFormat, secrets); err != nil {
+			return false, fmt.Errorf("unable to push secret group %q into file format %q: %s", sg.Name, sg.FileFormat, err)
+		}
+	}
+
+	// Secrets written to a file of their own are replaced by the path of
+	// their file in the secret file of the group
+	if len(sg.SecretFilesPath) > 0 {
+		stagedPath := sg.SecretFilesPath
+		if len(sg.stagedSecretFilesPath) > 0 {
+			stagedPath = sg.stagedSecretFilesPath
+		}
+		var filesUpdated bool
+		filesUpdated, secrets, err = sg.pushSecretFiles(depOpenWriteCloser, checksums, stagedPath, secrets)
+		if err != nil {
+			return false, err
+		}
+		defer func() {
+			if err == nil && filesUpdated {
+				updated = true
+			}
+		}()
+	}
+
+	// Keystore and directory formats are built from the secret values, not from a template
+	if len(sg.FileTemplate) == 0 && isKeystoreFormat(sg.FileFormat) {
+		return sg.pushKeystoreToFile(depOpenWriteCloser, checksums, secrets)
+	}
+	if len(sg.FileTemplate) == 0 && sg.FileFormat == directoryFileFormat {
+		return sg.pushToDirectory(depOpenWriteCloser, checksums, secrets)
+	}
+
+	// YAML, JSON and properties are encoded from the secret values, not rendered from a template
+	if encode := fileEncoderForFormat(sg.FileTemplate, sg.FileFormat); encode != nil {
+		return sg.pushEncodedToFile(depOpenWriteCloser, checksums, encode, secrets)
+	}
+
 	// Determine file template from
 	// 1. File template
 	// 2. File format
@@ -88,7 +168,7 @@ func (sg *SecretGroup) pushToFileWithDeps(
 	}
 
 	//// Open and push to file
-	wc, err := depOpenWriteCloser(sg.FilePath, sg.FilePermissions)
+	wc, err := sg.openFile(depOpenWriteCloser, checksums)
 	if err != nil {
 		return false, err
 	}
@@ -114,6 +194,100 @@ func (sg *SecretGroup) pushToFileWithDeps(
 	return updated, err
 }
 
+// openFile opens the secret file of the group, detecting whether its
+// content changed with checksums
+func (sg *SecretGroup) openFile(
+	depOpenWriteCloser openWriteCloserFunc,
+	checksums *fileChecksums,
+) (io.WriteCloser, error) {
+	wc, err := depOpenWriteCloser(sg.FilePath, sg.FilePermissions, sg.FileOwner)
+	if err != nil || checksums == nil {
+		return wc, err
+	}
+	return &checksumWriter{
+		WriteCloser: wc,
+		checksums:   checksums,
+		group:       sg,
+	}, nil
+}
+
+func (sg *SecretGroup) pushEncodedToFile(
+	depOpenWriteCloser openWriteCloserFunc,
+	checksums *fileChecksums,
+	encode encodeFunc,
+	secrets []*filetemplates.Secret,
+) (updated bool, err error) {
+	maskError := fmt.Errorf("failed to encode secret values on push to file for secret group %q", sg.Name)
+	defer func() {
+		if r := recover(); r != nil {
+			err = maskError
+		}
+	}()
+	content, err := encode(secrets, fileNestingSeparators[sg.FileNesting])
+	if err != nil {
+		return false, fmt.Errorf("failed to encode secret group %q into file format %q: %s", sg.Name, sg.FileFormat, err)
+	}
+
+	//// Open and push to file
+	wc, err := sg.openFile(depOpenWriteCloser, checksums)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = wc.Close()
+	}()
+
+	return writeContent(wc, bytes.NewBuffer(content))
+}
+
+func (sg *SecretGroup) pushKeystoreToFile(
+	depOpenWriteCloser openWriteCloserFunc,
+	checksums *fileChecksums,
+	secrets []*filetemplates.Secret,
+) (updated bool, err error) {
+	// Keystores are salted on every build, so changes are detected
+	// on the secret values instead of the file content
+	inputs, _ := utils.FileChecksum(secretInputs(sg.FileFormat, secrets))
+	if !checksums.changed(sg, sg.Name, sg.FilePath, nil, inputs) {
+		log.Info(messages.CSPFK018I)
+		return false, nil
+	}
+	if err := sg.backupFile(nil); err != nil {
+		log.Error(messages.CSPFK108E, sg.Name, err)
+	}
+
+	maskError := fmt.Errorf("failed to build %s keystore, with secret values, on push to file for secret group %q", sg.FileFormat, sg.Name)
+	defer func() {
+		if r := recover(); r != nil {
+			err = maskError
+		}
+	}()
+	content, err := renderKeystore(sg.Name, sg.FileFormat, secrets)
+	if err != nil {
+		return false, fmt.Errorf("failed to build %s keystore for secret group %q: %s", sg.FileFormat, sg.Name, err)
+	}
+
+	//// Open and push to file
+	wc, err := depOpenWriteCloser(sg.FilePath, sg.FilePermissions, sg.FileOwner)
+	if err != nil {
+		return false, err
+	}
+	defer func() {
+		_ = wc.Close()
+	}()
+
+	if _, err = wc.Write(content); err != nil {
+		return false, err
+	}
+	if err = wc.Close(); err != nil {
+		return false, err
+	}
+	checksum, _ := utils.FileChecksum(bytes.NewBuffer(content))
+	checksums.wrote(sg.Name, checksum, inputs)
+
+	return true, nil
+}
+
 func (sg *SecretGroup) absoluteFilePath(secretsBasePath string) (string, error) {
 	groupName := sg.Name
 	filePath := sg.FilePath
@@ -130,10 +304,18 @@ func (sg *SecretGroup) absoluteFilePath(secretsBasePath string) (string, error)
 
 	pathContainsFilename := !strings.HasSuffix(filePath, "/") && len(filePath) > 0
 
-	if !pathContainsFilename {
+	if fileExt == directoryFileFormat && len(fileTemplate) == 0 {
+		// Directory name defaults to "{groupName}"
+		if !pathContainsFilename {
+			filePath = path.Join(filePath, groupName)
+		}
+	} else if !pathContainsFilename {
 		if len(fileTemplate) > 0 {
 			// Template filename defaults to "{groupName}.out"
 			fileExt = "out"
+		} else if format, ok := keystoreFormats[fileExt]; ok {
+			// Keystore filenames use the conventional extension
+			fileExt = format.fileExtension
 		}
 
 		// For all other formats, the filename defaults to "{groupName}.{fileFormat}"
@@ -156,6 +338,14 @@ func (sg *SecretGroup) absoluteFilePath(secretsBasePath string) (string, error)
 		)
 	}
 
+	// A secret group directory is owned by the group, so it can't be the base path
+	if fileExt == directoryFileFormat && absoluteFilePath == path.Clean(secretsBasePath) {
+		return "", fmt.Errorf(
+			"provided filepath %q for secret group %q must be a directory below the secrets base path",
+			filePath, groupName,
+		)
+	}
+
 	// Filename cannot be longer than allowed by the filesystem
 	_, filename := path.Split(absoluteFilePath)
 	if len(filename) > maxFilenameLen {
@@ -180,7 +370,41 @@ func (sg *SecretGroup) validate() []error {
 		return errors
 	}
 
-	if len(fileFormat) > 0 && fileFormat != "template" {
+	if isKeystoreFormat(fileFormat) {
+		if err := validateKeystoreSpecs(fileFormat, secretSpecs); err != nil {
+			return []error{
+				fmt.Errorf(
+					"unable to process group %q into file format %q: %s",
+					groupName,
+					fileFormat,
+					err,
+				),
+			}
+		}
+	} else if fileFormat == directoryFileFormat {
+		if sg.Backups > 0 && len(fileTemplate) == 0 {
+			return []error{
+				fmt.Errorf("unable to process group %q into file format %q: backups are not supported", groupName, fileFormat),
+			}
+		}
+		for _, secretSpec := range secretSpecs {
+			// In "Fetch All" mode and for JSON secrets without a field, aliases
+			// are only known once secrets are fetched
+			if secretSpec.Path == "*" || secretSpec.ExpandsJSON() {
+				continue
+			}
+			if err := validateDirectoryAlias(secretSpec.Alias); err != nil {
+				return []error{
+					fmt.Errorf(
+						"unable to process group %q into file format %q: %s",
+						groupName,
+						fileFormat,
+						err,
+					),
+				}
+			}
+		}
+	} else if len(fileFormat) > 0 && fileFormat != "template" {
 		_, err := FileTemplateForFormat(fileFormat, secretSpecs)
 		if err != nil {
 			return []error{
@@ -194,17 +418,44 @@ func (sg *SecretGroup) validate() []error {
 		}
 	}
 
+	if fileFormat != directoryFileFormat || len(fileTemplate) > 0 {
+		for _, secretSpec := range secretSpecs {
+			if !secretSpec.AsFile {
+				continue
+			}
+			if err := validateDirectoryAlias(secretSpec.Alias); err != nil {
+				return []error{
+					fmt.Errorf("unable to write secret group %q secrets to files: %s", groupName, err),
+				}
+			}
+		}
+	}
+
+	if len(sg.FileNesting) > 0 {
+		if err := validateFileNesting(sg.FileNesting, fileFormat, fileTemplate, secretSpecs); err != nil {
+			return []error{
+				fmt.Errorf(
+					"unable to process group %q into file format %q: %s",
+					groupName,
+					fileFormat,
+					err,
+				),
+			}
+		}
+	}
+
 	// First-pass at provided template rendering with dummy secret values
 	// This first-pass is limited for templates that branch conditionally on secret values
 	// Relying logically on specific secret values should be avoided
-	if len(fileTemplate) > 0 {
+	// Templates of groups with JSON secrets without a field can refer to keys
+	// that are only known once secrets are fetched, so they aren't checked
+	if len(fileTemplate) > 0 && !hasExpandingSpecs(secretSpecs) {
 		dummySecrets := []*filetemplates.Secret{}
 		for _, secretSpec := range secretSpecs {
-			dummySecrets = append(dummySecrets, &filetemplates.Secret{Alias: secretSpec.Alias, Value: "REDACTED"})
+			dummySecrets = append(dummySecrets, &filetemplates.Secret{Alias: secretSpec.Alias, Value: filetemplates.RedactedSecretValue})
 		}
 
-		_, err := pushToWriter(io.Discard, groupName, fileTemplate, dummySecrets)
-		if err != nil {
+		if err := validateTemplate(groupName, fileTemplate, dummySecrets); err != nil {
 			return []error{fmt.Errorf(
 				`unable to use file template for secret group %q: %s`,
 				groupName,
@@ -226,7 +477,17 @@ func validateSecretsAgainstSpecs(
 		return nil
 	}
 
-	if len(secrets) != len(specs) {
+	// JSON secrets without a field expand into a secret for each of their
+	// keys, and optional secrets may be left out, so the number of secrets
+	// isn't known from the specs
+	exactCount := true
+	for _, spec := range specs {
+		if spec.ExpandsJSON() || spec.Optional {
+			exactCount = false
+		}
+	}
+
+	if exactCount && len(secrets) != len(specs) {
 		return fmt.Errorf(
 			"number of secrets (%d) does not match number of secret specs (%d)",
 			len(secrets),
@@ -242,6 +503,9 @@ func validateSecretsAgainstSpecs(
 
 	var missingAliases []string
 	for _, spec := range specs {
+		if spec.ExpandsJSON() || spec.Optional {
+			continue
+		}
 		if _, ok := aliasInSecrets[spec.Alias]; !ok {
 			missingAliases = append(missingAliases, spec.Alias)
 		}
@@ -257,6 +521,17 @@ func validateSecretsAgainstSpecs(
 	return nil
 }
 
+// hasExpandingSpecs returns whether any of the specs is a JSON secret without
+// a field, which expands into a secret for each of its keys
+func hasExpandingSpecs(specs []filetemplates.SecretSpec) bool {
+	for _, spec := range specs {
+		if spec.ExpandsJSON() {
+			return true
+		}
+	}
+	return false
+}
+
 func maybeFileTemplateFromFormat(
 	fileTemplate string,
 	fileFormat string,
@@ -293,6 +568,7 @@ func NewSecretGroups(
 	c := Config{
 		secretsBasePath:   secretsBasePath,
 		templatesBasePath: templatesBasePath,
+		manifestPath:      ManifestPath(templatesBasePath, annotations),
 		openReadCloser:    openFileAsReadCloser,
 		pullFromReader:    pullFromReader,
 	}
@@ -305,8 +581,17 @@ func newSecretGroupsWithDeps(annotations map[string]string, c Config) ([]*Secret
 
 	var errors []error
 	for key := range annotations {
+		groupName := ""
 		if strings.HasPrefix(key, filetemplates.SecretGroupPrefix) {
-			groupName := strings.TrimPrefix(key, filetemplates.SecretGroupPrefix)
+			groupName = strings.TrimPrefix(key, filetemplates.SecretGroupPrefix)
+		} else if strings.HasPrefix(key, secretGroupSecretsYmlPrefix) {
+			groupName = strings.TrimPrefix(key, secretGroupSecretsYmlPrefix)
+			// Groups with both annotations are created once
+			if _, ok := annotations[filetemplates.SecretGroupPrefix+groupName]; ok {
+				continue
+			}
+		}
+		if len(groupName) > 0 {
 			sg, errs := newSecretGroup(groupName, annotations, c)
 			if errs != nil {
 				errors = append(errors, errs...)
@@ -316,6 +601,13 @@ func newSecretGroupsWithDeps(annotations map[string]string, c Config) ([]*Secret
 		}
 	}
 
+	// Groups from the manifest are added to the groups from annotations
+	if len(c.manifestPath) > 0 {
+		manifestGroups, errs := newSecretGroupsFromManifest(c.manifestPath, annotations, c)
+		errors = append(errors, errs...)
+		sgs = append(sgs, manifestGroups...)
+	}
+
 	errors = append(errors, validateGroupFilePaths(sgs)...)
 
 	if len(errors) > 0 {
@@ -331,13 +623,14 @@ func newSecretGroupsWithDeps(annotations map[string]string, c Config) ([]*Secret
 }
 
 func newSecretGroup(groupName string, annotations map[string]string, c Config) (*SecretGroup, []error) {
-	groupSecrets := annotations[filetemplates.SecretGroupPrefix+groupName]
 	filePath := annotations[secretGroupFilePathPrefix+groupName]
 	fileFormat := annotations[secretGroupFileFormatPrefix+groupName]
 
 	policyPathPrefix := annotations[secretGroupPolicyPathPrefix+groupName]
 	policyPathPrefix = strings.TrimPrefix(policyPathPrefix, "/")
 	filePermissions := annotations[secretGroupFilePermissionsPrefix+groupName]
+	fileNesting := annotations[secretGroupFileNestingPrefix+groupName]
+	postHook := annotations[secretGroupPostHookPrefix+groupName]
 
 	var err error
 	var fileTemplate string
@@ -359,9 +652,32 @@ func newSecretGroup(groupName string, annotations map[string]string, c Config) (
 		return nil, []error{err}
 	}
 
-	secretSpecs, err := filetemplates.NewSecretSpecs([]byte(groupSecrets))
+	fileOwner, err := parseFileOwner(annotations[secretGroupFileOwnerPrefix+groupName])
+	if err != nil {
+		err = fmt.Errorf(`unable to parse annotation "%s": %s`, secretGroupFileOwnerPrefix+groupName, err)
+		return nil, []error{err}
+	}
+
+	backups, err := parseFileBackups(annotations[secretGroupFileBackupsPrefix+groupName])
+	if err != nil {
+		err = fmt.Errorf(`unable to parse annotation "%s": %s`, secretGroupFileBackupsPrefix+groupName, err)
+		return nil, []error{err}
+	}
+
+	postHookTimeout, err := parsePostHookTimeout(annotations[secretGroupPostHookTimeoutPrefix+groupName])
+	if err != nil {
+		err = fmt.Errorf(`unable to parse annotation "%s": %s`, secretGroupPostHookTimeoutPrefix+groupName, err)
+		return nil, []error{err}
+	}
+
+	refreshInterval, err := secretsConfig.ParseRefreshInterval(annotations[secretGroupRefreshIntervalPrefix+groupName])
+	if err != nil {
+		err = fmt.Errorf(`unable to parse annotation "%s": %s`, secretGroupRefreshIntervalPrefix+groupName, err)
+		return nil, []error{err}
+	}
+
+	secretSpecs, err := newSecretSpecsForGroup(groupName, annotations, c)
 	if err != nil {
-		err = fmt.Errorf(`unable to create secret specs from annotation "%s": %s`, filetemplates.SecretGroupPrefix+groupName, err)
 		return nil, []error{err}
 	}
 	secretSpecs = resolvedSecretSpecs(policyPathPrefix, secretSpecs)
@@ -371,21 +687,66 @@ func newSecretGroup(groupName string, annotations map[string]string, c Config) (
 		FilePath:         filePath,
 		FileTemplate:     fileTemplate,
 		FileFormat:       fileFormat,
+		FileNesting:      fileNesting,
 		FilePermissions:  *fileMode,
+		FileOwner:        fileOwner,
+		Backups:          backups,
 		PolicyPathPrefix: policyPathPrefix,
 		SecretSpecs:      secretSpecs,
+		PostHook:         postHook,
+		PostHookTimeout:  postHookTimeout,
+		RefreshInterval:  refreshInterval,
+	}
+	if secretsYmlPath, ok := annotations[secretGroupSecretsYmlPrefix+groupName]; ok {
+		sg.SecretsYmlPath = secretsYmlFilePath(secretsYmlPath, c)
 	}
 
+	return completeSecretGroup(sg, c)
+}
+
+// newSecretSpecsForGroup creates the secret specs of a group, either from the
+// "conjur.org/conjur-secrets.{secret-group}" annotation, or from the Summon
+// secrets.yml of the "conjur.org/conjur-secrets-yml.{secret-group}" annotation
+func newSecretSpecsForGroup(groupName string, annotations map[string]string, c Config) ([]filetemplates.SecretSpec, error) {
+	groupSecrets, hasSecrets := annotations[filetemplates.SecretGroupPrefix+groupName]
+	secretsYmlPath, hasSecretsYml := annotations[secretGroupSecretsYmlPrefix+groupName]
+	if hasSecrets && hasSecretsYml {
+		return nil, fmt.Errorf(
+			"secrets for group %q cannot be provided both by annotation %q and by annotation %q",
+			groupName, filetemplates.SecretGroupPrefix+groupName, secretGroupSecretsYmlPrefix+groupName,
+		)
+	}
+
+	if hasSecretsYml {
+		secretSpecs, err := readSecretsYml(secretsYmlPath, c)
+		if err != nil {
+			return nil, fmt.Errorf(`unable to create secret specs from annotation "%s": %s`, secretGroupSecretsYmlPrefix+groupName, err)
+		}
+		return secretSpecs, nil
+	}
+
+	secretSpecs, err := filetemplates.NewSecretSpecs([]byte(groupSecrets))
+	if err != nil {
+		return nil, fmt.Errorf(`unable to create secret specs from annotation "%s": %s`, filetemplates.SecretGroupPrefix+groupName, err)
+	}
+	return secretSpecs, nil
+}
+
+// completeSecretGroup validates a secret group, and resolves its file path
+// against the secrets base path
+func completeSecretGroup(sg *SecretGroup, c Config) (*SecretGroup, []error) {
 	errors := sg.validate()
 	if len(errors) > 0 {
 		return nil, errors
 	}
 
 	// Generate absolute file path
+	var err error
 	sg.FilePath, err = sg.absoluteFilePath(c.secretsBasePath)
 	if err != nil {
 		return nil, []error{err}
 	}
+	sg.SecretFilesPath = sg.secretFilesPath()
 
 	return sg, nil
 }
@@ -449,9 +810,10 @@ func permStrToFileMode(perms string) (*os.FileMode, error) {
 		return nil, invalidFormatErr
 	}
 
-	invalidPermissionsErr := fmt.Errorf("Invalid permissions: '%s', owner permissions must atleast have read and write (-rw-------)", perms)
-	//User Group should atleast have read/write permissions
-	if perms[0] != 'r' || perms[1] != 'w' {
+	invalidPermissionsErr := fmt.Errorf("Invalid permissions: '%s', owner permissions must atleast have read (-r--------)", perms)
+	// User Group should atleast have read permissions. Files are replaced
+	// with a rename, so owner write permissions aren't required.
+	if perms[0] != 'r' {
 		return nil, invalidPermissionsErr
 	}
 
@@ -479,6 +841,30 @@ func permStrToFileMode(perms string) (*os.FileMode, error) {
 	return &fileMode, nil
 }
 
+// parseFileOwner parses a numeric owner of secret files, formatted as
+// "{uid}:{gid}", or "{uid}" to keep the group of the Secrets Provider. Names
+// aren't accepted, since they can differ between the images of containers.
+func parseFileOwner(owner string) (*atomicwriter.FileOwner, error) {
+	if owner == "" {
+		return nil, nil
+	}
+
+	invalidFormatErr := fmt.Errorf("Invalid owner format: '%s', expected numeric 'uid:gid' or 'uid'", owner)
+	uidStr, gidStr, hasGID := strings.Cut(owner, ":")
+	uid, err := strconv.Atoi(uidStr)
+	if err != nil || uid < 0 {
+		return nil, invalidFormatErr
+	}
+	gid := -1
+	if hasGID {
+		gid, err = strconv.Atoi(gidStr)
+		if err != nil || gid < 0 {
+			return nil, invalidFormatErr
+		}
+	}
+	return &atomicwriter.FileOwner{UID: uid, GID: gid}, nil
+}
+
 func validateGroupFilePaths(secretGroups []*SecretGroup) []error {
 	// Iterate over the secret groups and group any that have the same file path
 	groupFilePaths := make(map[string][]string)
@@ -500,5 +886,65 @@ func validateGroupFilePaths(secretGroups []*SecretGroup) []error {
 			))
 		}
 	}
+
+	// Secret group directories are owned by their group, so no other group
+	// can write its files into one
+	for _, dirGroup := range secretGroups {
+		if dirGroup.FileFormat == directoryFileFormat && len(dirGroup.FileTemplate) == 0 {
+			errors = append(errors, validateOwnedDirectory(secretGroups, dirGroup, dirGroup.FilePath)...)
+		}
+		if len(dirGroup.SecretFilesPath) > 0 {
+			errors = append(errors, validateOwnedDirectory(secretGroups, dirGroup, dirGroup.SecretFilesPath)...)
+		}
+	}
+	return errors
+}
+
+// validateOwnedDirectory ensures that no other group writes its files into a
+// directory owned by dirGroup
+func validateOwnedDirectory(secretGroups []*SecretGroup, dirGroup *SecretGroup, dir string) []error {
+	var errors []error
+	for _, sg := range secretGroups {
+		if sg == dirGroup {
+			continue
+		}
+		if sg.FilePath == dir || strings.HasPrefix(sg.FilePath, dir+"/") {
+			errors = append(errors, fmt.Errorf(
+				"filepath %q for group %q is inside the directory of group %q",
+				sg.FilePath, sg.Name, dirGroup.Name,
+			))
+		}
+	}
 	return errors
 }
+
+// sameFiles returns whether the group writes the same files as other, so
+// that its files are replaced by the files of other
+func (sg *SecretGroup) sameFiles(other *SecretGroup) bool {
+	isDirectory := sg.FileFormat == directoryFileFormat && len(sg.FileTemplate) == 0
+	otherIsDirectory := other.FileFormat == directoryFileFormat && len(other.FileTemplate) == 0
+	return sg.FilePath == other.FilePath &&
+		sg.SecretFilesPath == other.SecretFilesPath &&
+		isDirectory == otherIsDirectory
+}
+
+// removeFiles removes the secret file of the group, or the secret group
+// directory for the "directory" file format
+func (sg *SecretGroup) removeFiles() error {
+	var err error
+	if sg.FileFormat == directoryFileFormat && len(sg.FileTemplate) == 0 {
+		err = os.RemoveAll(sg.FilePath)
+	} else {
+		err = os.Remove(sg.FilePath)
+		if err == nil || os.IsNotExist(err) {
+			err = sg.removeBackups(1)
+		}
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if len(sg.SecretFilesPath) > 0 {
+		return os.RemoveAll(sg.SecretFilesPath)
+	}
+	return nil
+}
@@ -10,9 +10,10 @@ import (
 	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/atomicwriter"
 	"github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/file_templates"
-	"github.com/cyberark/secrets-provider-for-k8s/pkg/utils"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -71,6 +72,7 @@ func (tc *pushToFileWithDepsTestCase) Run(t *testing.T) {
 		updated, err := group.pushToFileWithDeps(
 			spyOpenWriteCloser.Call,
 			pushToWriterFunc,
+			nil,
 			secrets)
 
 		tc.assert(t, spyOpenWriteCloser, closableBuf, spyPushToWriter, updated, err)
@@ -602,6 +604,10 @@ func TestNewSecretGroups(t *testing.T) {
 			description: "File perms '-rwxrwxrwx'",
 			permStr:     "-rwxrwxrwx",
 			assertFunc:  assertExpectedFileMode(os.FileMode(0777)),
+		}, {
+			description: "File perms '-r--------' (owner read-only)",
+			permStr:     "-r--------",
+			assertFunc:  assertExpectedFileMode(os.FileMode(0400)),
 		},
 		// Unhappy path test cases
 		{
@@ -611,7 +617,11 @@ func TestNewSecretGroups(t *testing.T) {
 		}, {
 			description: "File perms '----------' (0000)",
 			permStr:     "----------",
-			assertFunc:  assertExpectedErr("owner permissions must atleast have read and write"),
+			assertFunc:  assertExpectedErr("owner permissions must atleast have read"),
+		}, {
+			description: "File perms '--w-------' (owner write-only)",
+			permStr:     "--w-------",
+			assertFunc:  assertExpectedErr("owner permissions must atleast have read"),
 		}, {
 			description: "File permission string with invalid character",
 			permStr:     "-rw-r--U--",
@@ -638,6 +648,135 @@ func TestNewSecretGroups(t *testing.T) {
 	}
 }
 
+func TestNewSecretGroups_FileOwner(t *testing.T) {
+	testCases := []struct {
+		description string
+		owner       string
+		expected    *atomicwriter.FileOwner
+		errMsg      string
+	}{
+		{
+			description: "no owner",
+		},
+		{
+			description: "uid and gid",
+			owner:       "1000:2000",
+			expected:    &atomicwriter.FileOwner{UID: 1000, GID: 2000},
+		},
+		{
+			description: "uid only",
+			owner:       "1000",
+			expected:    &atomicwriter.FileOwner{UID: 1000, GID: -1},
+		},
+		{
+			description: "user name",
+			owner:       "nginx:nginx",
+			errMsg:      `unable to parse annotation "conjur.org/secret-file-owner.first": Invalid owner format: 'nginx:nginx', expected numeric 'uid:gid' or 'uid'`,
+		},
+		{
+			description: "negative gid",
+			owner:       "1000:-1",
+			errMsg:      `unable to parse annotation "conjur.org/secret-file-owner.first": Invalid owner format: '1000:-1', expected numeric 'uid:gid' or 'uid'`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			annotations := map[string]string{
+				"conjur.org/conjur-secrets.first": `- path/to/secret/first1`,
+			}
+			if tc.owner != "" {
+				annotations["conjur.org/secret-file-owner.first"] = tc.owner
+			}
+			groups, errs := NewSecretGroups("", "", annotations)
+			if tc.errMsg != "" {
+				assert.Len(t, errs, 1)
+				assert.EqualError(t, errs[0], tc.errMsg)
+				return
+			}
+			assert.Len(t, errs, 0)
+			assert.Len(t, groups, 1)
+			assert.Equal(t, tc.expected, groups[0].FileOwner)
+		})
+	}
+}
+
+func TestNewSecretGroups_RefreshInterval(t *testing.T) {
+	testCases := []struct {
+		description string
+		interval    string
+		expected    time.Duration
+		errMsg      string
+	}{
+		{
+			description: "no interval",
+		},
+		{
+			description: "valid interval",
+			interval:    "30s",
+			expected:    30 * time.Second,
+		},
+		{
+			description: "interval below the minimum",
+			interval:    "500ms",
+			errMsg:      `unable to parse annotation "conjur.org/secrets-refresh-interval.first": refresh interval must be at least one second, got "500ms"`,
+		},
+		{
+			description: "invalid interval",
+			interval:    "often",
+			errMsg:      `unable to parse annotation "conjur.org/secrets-refresh-interval.first": time: invalid duration "often"`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			annotations := map[string]string{
+				"conjur.org/conjur-secrets.first": `- path/to/secret/first1`,
+			}
+			if tc.interval != "" {
+				annotations["conjur.org/secrets-refresh-interval.first"] = tc.interval
+			}
+			groups, errs := NewSecretGroups("", "", annotations)
+			if tc.errMsg != "" {
+				assert.Len(t, errs, 1)
+				assert.EqualError(t, errs[0], tc.errMsg)
+				return
+			}
+			assert.Len(t, errs, 0)
+			assert.Len(t, groups, 1)
+			assert.Equal(t, tc.expected, groups[0].RefreshInterval)
+		})
+	}
+}
+
+func TestSecretGroup_PushToFile_ReadOnlyOwner(t *testing.T) {
+
+	filePath := filepath.Join(t.TempDir(), "app.yaml")
+	group := SecretGroup{
+		Name:            "app",
+		FilePath:        filePath,
+		FileFormat:      "yaml",
+		FilePermissions: 0400,
+		FileOwner:       &atomicwriter.FileOwner{UID: os.Getuid(), GID: os.Getgid()},
+		SecretSpecs:     []filetemplates.SecretSpec{{Alias: "password", Path: "db/password"}},
+	}
+
+	// A read-only secret file is replaced on the next refresh
+	for _, value := range []string{"first", "second"} {
+		updated, err := group.PushToFile([]*filetemplates.Secret{{Alias: "password", Value: value}})
+		assert.NoError(t, err)
+		assert.True(t, updated)
+
+		content, err := os.ReadFile(filePath)
+		assert.NoError(t, err)
+		assert.Equal(t, `"password": "`+value+`"`, string(content))
+	}
+
+	info, err := os.Stat(filePath)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0400), info.Mode().Perm())
+}
+
 var pushToFileWithDepsTestCases = []pushToFileWithDepsTestCase{
 	{
 		description:          "happy path, no targets updated",
@@ -755,8 +894,9 @@ var pushToFileWithDepsTestCases = []pushToFileWithDepsTestCase{
 			if !assert.NoError(t, err) {
 				return
 			}
-			// Defaults to yaml
-			spyPushToWriter.args.groupTemplate = yamlTemplate
+			// Defaults to yaml, which is encoded rather than rendered from a template
+			assert.Zero(t, spyPushToWriter._calls)
+			assert.Equal(t, "path/to/file", spyOpenWriteCloser.args.path)
 		},
 	},
 	{
@@ -890,7 +1030,7 @@ func TestSecretGroup_pushToFileWithDeps(t *testing.T) {
 		tc.Run(t)
 	}
 
-	for _, format := range []string{"json", "yaml", "bash", "dotenv"} {
+	for _, format := range []string{"bash", "dotenv"} {
 		tc := pushToFileWithDepsTestCase{
 			description: fmt.Sprintf("%s format", format),
 			group: modifyGoodGroup(func(group SecretGroup) SecretGroup {
@@ -918,6 +1058,63 @@ func TestSecretGroup_pushToFileWithDeps(t *testing.T) {
 
 		tc.Run(t)
 	}
+
+	for format, expected := range map[string]string{
+		"json":       `{"alias1":"value-path1","alias2":"value-path2"}`,
+		"yaml":       "\"alias1\": \"value-path1\"\n\"alias2\": \"value-path2\"",
+		"properties": "alias1=value-path1\nalias2=value-path2",
+	} {
+		// Reset the P2F cache so the content is written
+
+		tc := pushToFileWithDepsTestCase{
+			description: fmt.Sprintf("%s format", format),
+			group: modifyGoodGroup(func(group SecretGroup) SecretGroup {
+				group.FileTemplate = ""
+				group.FileFormat = format
+
+				return group
+			}),
+			overrideSecrets: nil,
+			assert: func(
+				t *testing.T,
+				spyOpenWriteCloser openWriteCloserSpy,
+				closableBuf *ClosableBuffer,
+				spyPushToWriter pushToWriterSpy,
+				updated bool,
+				err error,
+			) {
+				// Assertions
+				if !assert.NoError(t, err) {
+					return
+				}
+				assert.True(t, updated)
+				assert.Zero(t, spyPushToWriter._calls)
+				assert.Equal(t, expected, closableBuf.String())
+			},
+		}
+
+		tc.Run(t)
+	}
+
+	t.Run("encoding error is reported without secret values", func(t *testing.T) {
+		group := modifyGoodGroup(func(group SecretGroup) SecretGroup {
+			group.FileTemplate = ""
+			group.FileFormat = "json"
+
+			return group
+		})
+
+		_, err := group.pushToFileWithDeps(
+			(&openWriteCloserSpy{writeCloser: new(ClosableBuffer)}).Call,
+			(&pushToWriterSpy{}).Call,
+			nil,
+			[]*filetemplates.Secret{
+				{Alias: "alias1", Value: "value1"},
+				{Alias: "alias2", Value: "binary\xff"},
+			},
+		)
+		assert.EqualError(t, err, `failed to encode secret group "groupname" into file format "json": secret value for alias "alias2" is not valid UTF-8, which JSON can't represent`)
+	})
 }
 
 func TestSecretGroup_PushToFile(t *testing.T) {
@@ -938,9 +1135,6 @@ func TestSecretGroup_PushToFile(t *testing.T) {
 		t.Run(tc.description, func(t *testing.T) {
 			absoluteFilePath := path.Join(dir, tc.path)
 
-			// Reset the P2F cache so the files will be written even if the values haven't changed
-			prevFileChecksums = map[string]utils.Checksum{}
-
 			// Create a group, and push to file
 			group := SecretGroup{
 				Name:            "groupname",
@@ -1052,4 +1246,133 @@ func TestSecretGroup_PushToFile(t *testing.T) {
 		assert.Error(t, err)
 		assert.Contains(t, err.Error(), "unable to open file")
 	})
+
+	t.Run("malformed PEM input is masked", func(t *testing.T) {
+		group := SecretGroup{
+			Name:            "groupname",
+			FilePath:        path.Join(dir, "cert.pem"),
+			FileTemplate:    `{{ secret "alias1" | pemLeaf }}`,
+			FileFormat:      "template",
+			FilePermissions: 0644,
+			SecretSpecs: []filetemplates.SecretSpec{
+				{
+					Alias: "alias1",
+					Path:  "path1",
+				},
+			},
+		}
+		// The first-pass validation renders the template with redacted values
+		assert.Empty(t, group.validate())
+
+		_, err = group.PushToFile([]*filetemplates.Secret{
+			{
+				Alias: "alias1",
+				Value: "not-a-certificate",
+			},
+		})
+		assert.Error(t, err)
+		assert.Contains(t, err.Error(), `failed to execute template, with secret values, on push to file for secret group "groupname"`)
+		assert.NotContains(t, err.Error(), "not-a-certificate")
+	})
+}
+
+func TestSecretGroup_PushToFile_JSONSecrets(t *testing.T) {
+	specs := []filetemplates.SecretSpec{
+		{Alias: "credentials", Path: "db/credentials", ContentType: "json"},
+		{Alias: "DB_URL", Path: "db/url"},
+	}
+
+	t.Run("keys are validated once fetched", func(t *testing.T) {
+
+		group := SecretGroup{
+			Name:            "db",
+			FilePath:        path.Join(t.TempDir(), "db.env"),
+			FileFormat:      "bash",
+			FilePermissions: 0640,
+			SecretSpecs:     specs,
+		}
+
+		_, err := group.PushToFile([]*filetemplates.Secret{
+			{Alias: "DB_USERNAME", Value: "admin"},
+			{Alias: "DB_PASSWORD", Value: "secret"},
+			{Alias: "DB_URL", Value: "postgresql://db:5432"},
+		})
+		assert.NoError(t, err)
+		content, err := os.ReadFile(group.FilePath)
+		assert.NoError(t, err)
+		assert.Equal(t, `export DB_USERNAME='admin'
+export DB_PASSWORD='secret'
+export DB_URL='postgresql://db:5432'`, string(content))
+
+		_, err = group.PushToFile([]*filetemplates.Secret{
+			{Alias: "db-password", Value: "secret"},
+			{Alias: "DB_URL", Value: "postgresql://db:5432"},
+		})
+		assert.EqualError(t, err, `unable to push secret group "db" into file format "bash": invalid alias "db-password": variable names can only include alphanumerics and underscores, with first char being a non-digit`)
+	})
+
+	t.Run("specs that aren't expanded must be present", func(t *testing.T) {
+		group := SecretGroup{
+			Name:            "db",
+			FilePath:        path.Join(t.TempDir(), "db.yaml"),
+			FileFormat:      "yaml",
+			FilePermissions: 0640,
+			SecretSpecs:     specs,
+		}
+
+		_, err := group.PushToFile([]*filetemplates.Secret{
+			{Alias: "DB_USERNAME", Value: "admin"},
+		})
+		assert.EqualError(t, err, `some secret specs are not present in secrets "DB_URL"`)
+	})
+
+	t.Run("templates can refer to keys", func(t *testing.T) {
+		groups, errs := NewSecretGroups("/basepath", "", map[string]string{
+			"conjur.org/conjur-secrets.db": `
+- credentials: db/credentials
+  content-type: json
+`,
+			"conjur.org/secret-file-template.db": `{{ secret "username" }}:{{ secret "password" }}`,
+		})
+		assert.Len(t, errs, 0)
+		assert.Len(t, groups, 1)
+	})
+
+	t.Run("field requires the json content-type", func(t *testing.T) {
+		_, errs := NewSecretGroups("/basepath", "", map[string]string{
+			"conjur.org/conjur-secrets.db": `
+- password: db/credentials
+  field: password
+`,
+		})
+		assert.Len(t, errs, 1)
+		assert.EqualError(t, errs[0], "Secret group db: field 'password' of secret alias 'password' requires the 'json' content-type")
+	})
+}
+
+func TestSecretGroup_PushToFile_OptionalSecrets(t *testing.T) {
+
+	group := SecretGroup{
+		Name:            "app",
+		FilePath:        path.Join(t.TempDir(), "app.json"),
+		FileFormat:      "json",
+		FilePermissions: 0640,
+		SecretSpecs: []filetemplates.SecretSpec{
+			{Alias: "url", Path: "app/url"},
+			{Alias: "flag", Path: "app/flag", Optional: true},
+		},
+	}
+
+	_, err := group.PushToFile([]*filetemplates.Secret{
+		{Alias: "url", Value: "https://example.com"},
+	})
+	assert.NoError(t, err)
+	content, err := os.ReadFile(group.FilePath)
+	assert.NoError(t, err)
+	assert.Equal(t, `{"url":"https://example.com"}`, string(content))
+
+	_, err = group.PushToFile([]*filetemplates.Secret{
+		{Alias: "flag", Value: "on"},
+	})
+	assert.EqualError(t, err, `some secret specs are not present in secrets "url"`)
 }
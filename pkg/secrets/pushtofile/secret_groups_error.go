@@ -0,0 +1,74 @@
+package pushtofile
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/cyberark/conjur-authn-k8s-client/pkg/log"
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/log/messages"
+)
+
+// SecretGroupsError is returned when the secrets of some secret groups can't
+// be provided. Each group is provided on its own, so the other groups are
+// provided regardless, and the failing groups are reported as degraded.
+type SecretGroupsError struct {
+	errs map[string]error
+	// groupCount is the number of secret groups of the provider
+	groupCount int
+}
+
+// add records the error of a group, and logs it
+func (e *SecretGroupsError) add(group string, err error) {
+	if e.errs == nil {
+		e.errs = map[string]error{}
+	}
+	e.errs[group] = err
+	log.Error(messages.CSPFK113E, group, err)
+}
+
+// failed reports whether the group failed
+func (e *SecretGroupsError) failed(group string) bool {
+	_, ok := e.errs[group]
+	return ok
+}
+
+// orNil returns e when a group failed, or nil otherwise
+func (e *SecretGroupsError) orNil() error {
+	if len(e.errs) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Partial reports whether only some of the secret groups of the provider
+// failed, while the files of the other groups are provided
+func (e *SecretGroupsError) Partial() bool {
+	return len(e.errs) < e.groupCount
+}
+
+// Groups returns the sorted names of the failing groups
+func (e *SecretGroupsError) Groups() []string {
+	groups := make([]string, 0, len(e.errs))
+	for group := range e.errs {
+		groups = append(groups, group)
+	}
+	sort.Strings(groups)
+	return groups
+}
+
+func (e *SecretGroupsError) Error() string {
+	var reasons []string
+	for _, group := range e.Groups() {
+		reasons = append(reasons, fmt.Sprintf("secret group %q: %s", group, e.errs[group]))
+	}
+	return fmt.Sprintf("failed to provide secrets of %d secret groups: %s", len(reasons), strings.Join(reasons, "; "))
+}
+
+func (e *SecretGroupsError) Unwrap() []error {
+	var errs []error
+	for _, group := range e.Groups() {
+		errs = append(errs, e.errs[group])
+	}
+	return errs
+}
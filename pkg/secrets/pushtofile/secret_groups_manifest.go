@@ -0,0 +1,236 @@
+package pushtofile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	secretsConfig "github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/config"
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/file_templates"
+	"gopkg.in/yaml.v3"
+)
+
+// ManifestPath returns the path of the secret groups manifest of the
+// "conjur.org/secret-groups-manifest" annotation, or an empty path when
+// there's no manifest. Relative paths are relative to the templates base
+// path, so the manifest can be mounted from the same ConfigMap as secret file
+// templates.
+func ManifestPath(templatesBasePath string, annotations map[string]string) string {
+	manifestPath := annotations[secretsConfig.SecretGroupsManifestKey]
+	if len(manifestPath) == 0 || filepath.IsAbs(manifestPath) {
+		return manifestPath
+	}
+	return filepath.Join(templatesBasePath, manifestPath)
+}
+
+// secretGroupsManifest describes secret groups in a YAML file, as an
+// alternative to annotations for Pods with many secret groups
+type secretGroupsManifest struct {
+	Groups []manifestSecretGroup `yaml:"groups"`
+}
+
+// manifestSecretGroup holds the fields of a SecretGroup. Secret specs and file
+// permissions take the same values as their annotations.
+type manifestSecretGroup struct {
+	Name             string    `yaml:"name"`
+	FilePath         string    `yaml:"filePath"`
+	FileTemplate     string    `yaml:"fileTemplate"`
+	FileFormat       string    `yaml:"fileFormat"`
+	FileNesting      string    `yaml:"fileNesting"`
+	PolicyPathPrefix string    `yaml:"policyPathPrefix"`
+	FilePermissions  string    `yaml:"filePermissions"`
+	FileOwner        string    `yaml:"fileOwner"`
+	Backups          string    `yaml:"backups"`
+	SecretSpecs      yaml.Node `yaml:"secretSpecs"`
+	SecretsYml       string    `yaml:"secretsYml"`
+	PostHook         string    `yaml:"postHook"`
+	PostHookTimeout  string    `yaml:"postHookTimeout"`
+	RefreshInterval  string    `yaml:"refreshInterval"`
+}
+
+// newSecretGroupsFromManifest creates the secret groups described by the
+// manifest at manifestPath. Groups are validated in the same way as groups
+// from annotations, and group names must not be used by annotations or by
+// other groups of the manifest.
+func newSecretGroupsFromManifest(
+	manifestPath string,
+	annotations map[string]string,
+	c Config,
+) ([]*SecretGroup, []error) {
+	content, err := os.ReadFile(manifestPath)
+	if err != nil {
+		return nil, []error{fmt.Errorf("unable to read secret groups manifest %q: %s", manifestPath, err)}
+	}
+
+	manifest, err := parseSecretGroupsManifest(content)
+	if err != nil {
+		return nil, []error{fmt.Errorf("unable to parse secret groups manifest %q: %s", manifestPath, err)}
+	}
+
+	var sgs []*SecretGroup
+	var errs []error
+	names := map[string]struct{}{}
+	for _, group := range manifest.Groups {
+		if len(group.Name) == 0 {
+			errs = append(errs, fmt.Errorf("secret groups manifest %q has a group without a name", manifestPath))
+			continue
+		}
+		_, hasSecrets := annotations[filetemplates.SecretGroupPrefix+group.Name]
+		_, hasSecretsYml := annotations[secretGroupSecretsYmlPrefix+group.Name]
+		if hasSecrets || hasSecretsYml {
+			errs = append(errs, fmt.Errorf(
+				"secret group %q of manifest %q is also defined by annotations", group.Name, manifestPath,
+			))
+			continue
+		}
+		if _, ok := names[group.Name]; ok {
+			errs = append(errs, fmt.Errorf(
+				"secret group %q is defined more than once in manifest %q", group.Name, manifestPath,
+			))
+			continue
+		}
+		names[group.Name] = struct{}{}
+
+		sg, groupErrs := group.newSecretGroup(c)
+		if groupErrs != nil {
+			errs = append(errs, groupErrs...)
+			continue
+		}
+		sgs = append(sgs, sg)
+	}
+
+	return sgs, errs
+}
+
+func parseSecretGroupsManifest(content []byte) (*secretGroupsManifest, error) {
+	manifest := &secretGroupsManifest{}
+
+	dec := yaml.NewDecoder(bytes.NewReader(content))
+	dec.KnownFields(true)
+	if err := dec.Decode(manifest); err != nil && !errors.Is(err, io.EOF) {
+		return nil, err
+	}
+	return manifest, nil
+}
+
+func (g manifestSecretGroup) newSecretGroup(c Config) (*SecretGroup, []error) {
+	fileFormat := g.FileFormat
+	fileTemplate := ""
+	if fileFormat == "template" {
+		var err error
+		fileTemplate, err = collectTemplate(
+			g.Name,
+			map[string]string{filetemplates.SecretGroupFileTemplatePrefix + g.Name: g.FileTemplate},
+			c,
+		)
+		if err != nil {
+			return nil, []error{err}
+		}
+	} else if len(g.FileTemplate) > 0 {
+		return nil, []error{fmt.Errorf(
+			`secret group %q of the manifest has a fileTemplate, which requires fileFormat "template"`, g.Name,
+		)}
+	}
+
+	// Default to "yaml" file format
+	if len(fileFormat) == 0 {
+		fileFormat = "yaml"
+	}
+
+	fileMode, err := permStrToFileMode(g.FilePermissions)
+	if err != nil {
+		return nil, []error{fmt.Errorf(
+			"unable to create fileMode from filePermissions of secret group %q: %s", g.Name, err,
+		)}
+	}
+
+	fileOwner, err := parseFileOwner(g.FileOwner)
+	if err != nil {
+		return nil, []error{fmt.Errorf(
+			"unable to parse fileOwner of secret group %q: %s", g.Name, err,
+		)}
+	}
+
+	backups, err := parseFileBackups(g.Backups)
+	if err != nil {
+		return nil, []error{fmt.Errorf(
+			"unable to parse backups of secret group %q: %s", g.Name, err,
+		)}
+	}
+
+	postHookTimeout, err := parsePostHookTimeout(g.PostHookTimeout)
+	if err != nil {
+		return nil, []error{fmt.Errorf(
+			"unable to parse postHookTimeout of secret group %q: %s", g.Name, err,
+		)}
+	}
+
+	refreshInterval, err := secretsConfig.ParseRefreshInterval(g.RefreshInterval)
+	if err != nil {
+		return nil, []error{fmt.Errorf(
+			"unable to parse refreshInterval of secret group %q: %s", g.Name, err,
+		)}
+	}
+
+	secretSpecs, err := g.secretSpecs(c)
+	if err != nil {
+		return nil, []error{fmt.Errorf(
+			"unable to create secret specs from secretSpecs of secret group %q: %s", g.Name, err,
+		)}
+	}
+	policyPathPrefix := strings.TrimPrefix(g.PolicyPathPrefix, "/")
+	secretSpecs = resolvedSecretSpecs(policyPathPrefix, secretSpecs)
+
+	var secretsYmlPath string
+	if len(g.SecretsYml) > 0 {
+		secretsYmlPath = secretsYmlFilePath(g.SecretsYml, c)
+	}
+
+	return completeSecretGroup(&SecretGroup{
+		Name:             g.Name,
+		FilePath:         g.FilePath,
+		FileTemplate:     fileTemplate,
+		FileFormat:       fileFormat,
+		FileNesting:      g.FileNesting,
+		FilePermissions:  *fileMode,
+		FileOwner:        fileOwner,
+		Backups:          backups,
+		PolicyPathPrefix: policyPathPrefix,
+		SecretSpecs:      secretSpecs,
+		PostHook:         g.PostHook,
+		PostHookTimeout:  postHookTimeout,
+		RefreshInterval:  refreshInterval,
+		SecretsYmlPath:   secretsYmlPath,
+	}, c)
+}
+
+// secretSpecs parses the secret specs of the group, which are written in the
+// same way as the "conjur.org/conjur-secrets.{secret-group}" annotation, or
+// read from a Summon secrets.yml. Decoding the node keeps the line numbers of
+// the manifest in errors.
+func (g manifestSecretGroup) secretSpecs(c Config) ([]filetemplates.SecretSpec, error) {
+	if len(g.SecretsYml) > 0 {
+		if g.SecretSpecs.Kind != 0 {
+			return nil, fmt.Errorf("secretSpecs and secretsYml can't both be set")
+		}
+		return readSecretsYml(g.SecretsYml, c)
+	}
+
+	// Support just the string "*" instead of a list
+	if g.SecretSpecs.Kind == yaml.ScalarNode {
+		return filetemplates.NewSecretSpecs([]byte(g.SecretSpecs.Value))
+	}
+
+	var secretSpecs []filetemplates.SecretSpec
+	if g.SecretSpecs.Kind == 0 {
+		return secretSpecs, nil
+	}
+	if err := g.SecretSpecs.Decode(&secretSpecs); err != nil {
+		return nil, err
+	}
+	return secretSpecs, nil
+}
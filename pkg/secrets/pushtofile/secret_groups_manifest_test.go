@@ -0,0 +1,215 @@
+package pushtofile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/file_templates"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// manifestAnnotation is the annotation for the manifest written by
+// writeManifest, relative to the templates base path
+const manifestAnnotation = "conjur.org/secret-groups-manifest"
+
+func writeManifest(t *testing.T, content string) string {
+	templatesBasePath := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(templatesBasePath, "secret-groups.yaml"), []byte(content), 0644))
+	return templatesBasePath
+}
+
+func TestNewSecretGroups_Manifest(t *testing.T) {
+	t.Run("groups are merged with annotations", func(t *testing.T) {
+		templatesBasePath := writeManifest(t, `
+groups:
+  - name: db
+    fileFormat: json
+    filePath: config/
+    policyPathPrefix: /apps/db
+    filePermissions: -rw-r-----
+    secretSpecs:
+      - url
+      - password: password
+        content-type: base64
+  - name: cache
+    fileFormat: template
+    fileTemplate: '{{ secret "token" }}'
+    secretSpecs:
+      - token: cache/token
+  - name: all
+    fileFormat: yaml
+    fileNesting: slash
+    secretSpecs: "*"
+`)
+
+		groups, errs := NewSecretGroups("/basepath", templatesBasePath, map[string]string{
+			"conjur.org/conjur-secrets.api": `- key: api/key`,
+			manifestAnnotation:              "secret-groups.yaml",
+		})
+		require.Len(t, errs, 0)
+		require.Len(t, groups, 4)
+
+		assert.Equal(t, &SecretGroup{
+			Name:             "db",
+			FilePath:         "/basepath/config/db.json",
+			FileFormat:       "json",
+			FilePermissions:  0640,
+			PolicyPathPrefix: "apps/db",
+			SecretSpecs: []filetemplates.SecretSpec{
+				{Alias: "url", Path: "apps/db/url", ContentType: "text"},
+				{Alias: "password", Path: "apps/db/password", ContentType: "base64"},
+			},
+		}, groups[3])
+
+		assert.Equal(t, "all", groups[0].Name)
+		assert.Equal(t, []filetemplates.SecretSpec{{Alias: "*", Path: "*", ContentType: "text"}}, groups[0].SecretSpecs)
+		assert.Equal(t, "api", groups[1].Name)
+		assert.Equal(t, "cache", groups[2].Name)
+		assert.Equal(t, `{{ secret "token" }}`, groups[2].FileTemplate)
+	})
+
+	t.Run("no manifest", func(t *testing.T) {
+		templatesBasePath := writeManifest(t, `
+groups:
+  - name: db
+    secretSpecs: ["db/url"]
+`)
+
+		// The manifest is only read when the annotation is set
+		groups, errs := NewSecretGroups("/basepath", templatesBasePath, map[string]string{
+			"conjur.org/conjur-secrets.api": `- key: api/key`,
+		})
+		assert.Len(t, errs, 0)
+		assert.Len(t, groups, 1)
+	})
+
+	t.Run("absolute manifest path", func(t *testing.T) {
+		manifestPath := filepath.Join(writeManifest(t, `
+groups:
+  - name: db
+    secretSpecs: ["db/url"]
+`), "secret-groups.yaml")
+
+		groups, errs := NewSecretGroups("/basepath", "", map[string]string{
+			manifestAnnotation: manifestPath,
+		})
+		require.Len(t, errs, 0)
+		require.Len(t, groups, 1)
+		assert.Equal(t, "db", groups[0].Name)
+	})
+
+	t.Run("missing manifest", func(t *testing.T) {
+		templatesBasePath := t.TempDir()
+
+		_, errs := NewSecretGroups("/basepath", templatesBasePath, map[string]string{
+			manifestAnnotation: "secret-groups.yaml",
+		})
+		require.Len(t, errs, 1)
+		assert.Contains(t, errs[0].Error(), fmt.Sprintf(
+			`unable to read secret groups manifest "%s"`, filepath.Join(templatesBasePath, "secret-groups.yaml"),
+		))
+	})
+
+	for _, tc := range []struct {
+		description string
+		manifest    string
+		errMsgs     []string
+	}{
+		{
+			description: "group defined by annotations",
+			manifest: `
+groups:
+  - name: api
+    secretSpecs: ["api/key"]
+`,
+			errMsgs: []string{`secret group "api" of manifest "%s" is also defined by annotations`},
+		},
+		{
+			description: "group defined twice",
+			manifest: `
+groups:
+  - name: db
+    secretSpecs: ["db/url"]
+  - name: db
+    secretSpecs: ["db/password"]
+`,
+			errMsgs: []string{`secret group "db" is defined more than once in manifest "%s"`},
+		},
+		{
+			description: "group without a name",
+			manifest: `
+groups:
+  - secretSpecs: ["db/url"]
+`,
+			errMsgs: []string{`secret groups manifest "%s" has a group without a name`},
+		},
+		{
+			description: "unknown field",
+			manifest: `
+groups:
+  - name: db
+    fileformat: json
+`,
+			errMsgs: []string{`unable to parse secret groups manifest "%s": yaml: unmarshal errors:
+  line 4: field fileformat not found in type pushtofile.manifestSecretGroup`},
+		},
+		{
+			description: "invalid secret specs",
+			manifest: `
+groups:
+  - name: db
+    secretSpecs:
+      - db/url
+      - - db/password
+`,
+			errMsgs: []string{`unable to create secret specs from secretSpecs of secret group "db": expected a "string (path)" or "single entry map of string to string (alias to path)" on line 6`},
+		},
+		{
+			description: "template without template format",
+			manifest: `
+groups:
+  - name: db
+    fileTemplate: '{{ secret "url" }}'
+    secretSpecs: ["db/url"]
+`,
+			errMsgs: []string{`secret group "db" of the manifest has a fileTemplate, which requires fileFormat "template"`},
+		},
+		{
+			description: "groups are validated",
+			manifest: `
+groups:
+  - name: db
+    fileFormat: xml
+    secretSpecs: ["db/url"]
+  - name: cache
+    filePath: ../cache.yaml
+    secretSpecs: ["cache/token"]
+`,
+			errMsgs: []string{
+				`unable to process group "db" into file format "xml": unrecognized standard file format, "xml"`,
+				`provided filepath "../cache.yaml" for secret group "cache" must be relative to secrets base path`,
+			},
+		},
+	} {
+		t.Run(tc.description, func(t *testing.T) {
+			templatesBasePath := writeManifest(t, tc.manifest)
+			manifestPath := filepath.Join(templatesBasePath, "secret-groups.yaml")
+
+			_, errs := NewSecretGroups("/basepath", templatesBasePath, map[string]string{
+				"conjur.org/conjur-secrets.api": `- key: api/key`,
+				manifestAnnotation:              "secret-groups.yaml",
+			})
+			require.Len(t, errs, len(tc.errMsgs))
+			for i, errMsg := range tc.errMsgs {
+				if strings.Contains(errMsg, "%s") {
+					errMsg = fmt.Sprintf(errMsg, manifestPath)
+				}
+				assert.EqualError(t, errs[i], errMsg)
+			}
+		})
+	}
+}
@@ -9,8 +9,17 @@ type standardTemplate struct {
 	template      string
 	fileFormat    string
 	validateAlias func(alias string) error
+	// encode marshals secrets into the file content, for formats that
+	// aren't rendered from a template
+	encode encodeFunc
+	// nestable formats split aliases into nested keys, see fileNestingSeparators
+	nestable bool
 }
 
+// encodeFunc marshals the secrets of a group into file content. Nestable
+// formats split aliases into nested keys on the separator, when it is set.
+type encodeFunc func(secrets []*filetemplates.Secret, separator string) ([]byte, error)
+
 func (s standardTemplate) ValidateAlias(alias string) error {
 	if s.validateAlias == nil {
 		return nil
@@ -20,15 +29,16 @@ func (s standardTemplate) ValidateAlias(alias string) error {
 }
 
 var standardTemplates = map[string]standardTemplate{
-	"yaml":       {template: yamlTemplate, validateAlias: validateYAMLKey},
-	"json":       {template: jsonTemplate, validateAlias: validateJSONKey},
+	"yaml":       {encode: encodeYAML, nestable: true},
+	"json":       {encode: encodeJSON, nestable: true},
 	"dotenv":     {template: dotenvTemplate, validateAlias: validateBashVarName},
-	"properties": {template: dotenvTemplate, validateAlias: validatePropertyVarName},
+	"properties": {encode: encodeProperties, validateAlias: validatePropertyVarName},
 	"bash":       {template: bashTemplate, validateAlias: validateBashVarName},
 }
 
 // FileTemplateForFormat returns the template for a file format, after ensuring the
-// standard template exists and validating secret spec aliases against it
+// standard template exists and validating secret spec aliases against it. The
+// template is empty for formats that are encoded, see fileEncoderForFormat.
 func FileTemplateForFormat(
 	fileFormat string,
 	secretSpecs []filetemplates.SecretSpec,
@@ -39,6 +49,10 @@ func FileTemplateForFormat(
 	}
 
 	for _, s := range secretSpecs {
+		// Keys of JSON secrets without a field are validated once fetched
+		if s.ExpandsJSON() {
+			continue
+		}
 		err := stdTemplate.ValidateAlias(s.Alias)
 		if err != nil {
 			return "", err
@@ -47,3 +61,36 @@ func FileTemplateForFormat(
 
 	return stdTemplate.template, nil
 }
+
+// fileEncoderForFormat returns the encoder of a standard file format, or nil
+// when the file content is rendered from a template
+func fileEncoderForFormat(
+	fileTemplate string,
+	fileFormat string,
+) encodeFunc {
+	if len(fileTemplate) > 0 {
+		return nil
+	}
+	// Default to "yaml" file format
+	if len(fileFormat) == 0 {
+		fileFormat = "yaml"
+	}
+
+	return standardTemplates[fileFormat].encode
+}
+
+// validateSecretAliases validates the aliases of fetched secrets against a
+// standard file format, for aliases that aren't known from the secret specs
+func validateSecretAliases(fileFormat string, secrets []*filetemplates.Secret) error {
+	stdTemplate, ok := standardTemplates[fileFormat]
+	if !ok {
+		return nil
+	}
+
+	for _, s := range secrets {
+		if err := stdTemplate.ValidateAlias(s.Alias); err != nil {
+			return err
+		}
+	}
+	return nil
+}
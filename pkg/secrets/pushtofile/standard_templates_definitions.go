@@ -1,26 +1,213 @@
 package pushtofile
 
-const jsonTemplate = `{
-{{- range $index, $secret := .SecretsArray }}
-{{- if $index }},{{ end }}
-{{- printf "%q" $secret.Alias }}:{{ printf "%q" $secret.Value }}
-{{- end -}}
-}`
-const yamlTemplate = `
-{{- range $index, $secret := .SecretsArray }}
-{{- if $index }}{{ "\n" }}{{ end }}
-{{- printf "%q" $secret.Alias }}: {{ printf "%q" $secret.Value -}}
-{{- end -}}
-`
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/file_templates"
+	"gopkg.in/yaml.v3"
+)
+
+// encodeJSON marshals the secrets of a group into a single-line JSON object,
+// with keys in the order of the secrets. JSON strings can only hold valid
+// UTF-8, so binary secret values are rejected rather than altered.
+func encodeJSON(secrets []*filetemplates.Secret, separator string) ([]byte, error) {
+	tree, err := newSecretTree(secrets, separator)
+	if err != nil {
+		return nil, err
+	}
+
+	buf := &bytes.Buffer{}
+	enc := json.NewEncoder(buf)
+	// Values are written as is, HTML escaping only matters for embedding in HTML
+	enc.SetEscapeHTML(false)
+	writeString := func(str string) error {
+		if err := enc.Encode(str); err != nil {
+			return err
+		}
+		// Encode terminates each value with a newline
+		buf.Truncate(buf.Len() - 1)
+		return nil
+	}
+
+	var writeTree func(t *secretTree) error
+	writeTree = func(t *secretTree) error {
+		buf.WriteByte('{')
+		for i, key := range t.keys {
+			if i > 0 {
+				buf.WriteByte(',')
+			}
+			if err := writeString(key); err != nil {
+				return err
+			}
+			buf.WriteByte(':')
+
+			if child, ok := t.children[key]; ok {
+				if err := writeTree(child); err != nil {
+					return err
+				}
+				continue
+			}
+			s := t.secrets[key]
+			if !utf8.ValidString(s.Value) {
+				return fmt.Errorf("secret value for alias %q is not valid UTF-8, which JSON can't represent", s.Alias)
+			}
+			if err := writeString(s.Value); err != nil {
+				return err
+			}
+		}
+		buf.WriteByte('}')
+		return nil
+	}
+	if err := writeTree(tree); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// encodeYAML marshals the secrets of a group into a YAML mapping, with keys
+// in the order of the secrets. Keys and values are double-quoted, with
+// characters outside of the YAML character set escaped. Values that aren't
+// valid UTF-8 are written as !!binary.
+func encodeYAML(secrets []*filetemplates.Secret, separator string) ([]byte, error) {
+	tree, err := newSecretTree(secrets, separator)
+	if err != nil {
+		return nil, err
+	}
+
+	var mappingNode func(t *secretTree) *yaml.Node
+	mappingNode = func(t *secretTree) *yaml.Node {
+		node := &yaml.Node{Kind: yaml.MappingNode}
+		for _, key := range t.keys {
+			value, ok := t.children[key]
+			if ok {
+				node.Content = append(node.Content, yamlStringNode(key), mappingNode(value))
+			} else {
+				node.Content = append(node.Content, yamlStringNode(key), yamlStringNode(t.secrets[key].Value))
+			}
+		}
+		return node
+	}
+
+	buf := &bytes.Buffer{}
+	enc := yaml.NewEncoder(buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(mappingNode(tree)); err != nil {
+		return nil, err
+	}
+	if err := enc.Close(); err != nil {
+		return nil, err
+	}
+	// Secret files are written without a trailing newline
+	return bytes.TrimSuffix(buf.Bytes(), []byte("\n")), nil
+}
+
+// yamlStringNode builds the scalar node for a string. The node is built
+// directly rather than with Node.Encode, which picks a block style for
+// multi-line strings that drops leading newlines.
+func yamlStringNode(value string) *yaml.Node {
+	if !utf8.ValidString(value) {
+		return &yaml.Node{
+			Kind:  yaml.ScalarNode,
+			Tag:   "!!binary",
+			Value: base64.StdEncoding.EncodeToString([]byte(value)),
+		}
+	}
+
+	return &yaml.Node{
+		Kind:  yaml.ScalarNode,
+		Tag:   "!!str",
+		Value: value,
+		Style: yaml.DoubleQuotedStyle,
+	}
+}
+
+// encodeProperties writes the secrets of a group as a Java .properties file,
+// one "key=value" entry per secret, readable by java.util.Properties.load.
+// Newlines in values are written as escapes followed by a continuation line,
+// so multi-line values such as PEM certificates stay readable.
+func encodeProperties(secrets []*filetemplates.Secret, _ string) ([]byte, error) {
+	buf := &bytes.Buffer{}
+	for i, s := range secrets {
+		if !utf8.ValidString(s.Value) {
+			return nil, fmt.Errorf("secret value for alias %q is not valid UTF-8, which properties files can't represent", s.Alias)
+		}
+		if i > 0 {
+			buf.WriteByte('\n')
+		}
+		writePropertiesString(buf, s.Alias, true)
+		buf.WriteByte('=')
+		for j, line := range strings.Split(s.Value, "\n") {
+			if j > 0 {
+				// The escaped newline is part of the value, the escaped line
+				// break continues the value on the next line
+				buf.WriteString("\\n\\\n")
+				if len(line) > 0 {
+					buf.WriteString(propertiesContinuationIndent)
+				}
+			}
+			writePropertiesString(buf, line, false)
+		}
+	}
+
+	return buf.Bytes(), nil
+}
+
+// propertiesContinuationIndent indents continuation lines. Leading whitespace
+// of a continuation line isn't part of the value.
+const propertiesContinuationIndent = "    "
+
+// writePropertiesString escapes a key or a line of a value as
+// java.util.Properties.store does. Leading whitespace is escaped, since it
+// would otherwise be dropped, and so is whitespace anywhere in keys, where it
+// would end the key. Characters outside of printable ASCII are written as
+// \uXXXX escapes of their UTF-16 code units.
+func writePropertiesString(buf *bytes.Buffer, str string, isKey bool) {
+	for i, r := range str {
+		switch r {
+		case ' ':
+			if i == 0 || isKey {
+				buf.WriteByte('\\')
+			}
+			buf.WriteByte(' ')
+		case '\t':
+			buf.WriteString(`\t`)
+		case '\n':
+			buf.WriteString(`\n`)
+		case '\r':
+			buf.WriteString(`\r`)
+		case '\f':
+			buf.WriteString(`\f`)
+		case '\\', '=', ':', '#', '!':
+			buf.WriteByte('\\')
+			buf.WriteRune(r)
+		default:
+			if r < 0x20 || r > 0x7e {
+				for _, unit := range utf16.Encode([]rune{r}) {
+					fmt.Fprintf(buf, `\u%04X`, unit)
+				}
+				continue
+			}
+			buf.WriteRune(r)
+		}
+	}
+}
+
 const dotenvTemplate = `
 {{- range $index, $secret := .SecretsArray -}}
 {{- if $index }}{{ "\n" }}{{ end }}
-{{- $secret.Alias }}={{ printf "%q" $secret.Value }}
+{{- $secret.Alias }}={{ dotenvQuote $secret.Value }}
 {{- end -}}
 `
 const bashTemplate = `
 {{- range $index, $secret := .SecretsArray -}}
 {{- if $index }}{{ "\n" }}{{ end }}
-{{- ""}}export {{ $secret.Alias }}={{ printf "%q" $secret.Value }}
+{{- ""}}export {{ $secret.Alias }}={{ shellQuote $secret.Value }}
 {{- end -}}
 `
@@ -1,19 +1,21 @@
 package pushtofile
 
 import (
+	"bytes"
+	"encoding/json"
 	"fmt"
+	"math/rand"
 	"strings"
 	"testing"
+	"unicode/utf16"
 
 	"github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/file_templates"
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v3"
 )
 
 const (
-	invalidYAMLChar        = "invalid YAML character"
-	invalidJSONChar        = "invalid JSON character"
-	yamlAliasTooLong       = "too long for YAML"
-	jsonAliasTooLong       = "too long for JSON"
 	invalidPropertyVarName = "can only include alphanumerics, dots and underscores"
 	invalidBashVarName     = "can only include alphanumerics and underscores"
 	validConjurPath        = "valid/conjur/variable/path"
@@ -35,25 +37,6 @@ func assertErrorContains(expErrStr string) assertErrorFunc {
 }
 
 var standardTemplateTestCases = []pushToWriterTestCase{
-	{
-		description: "json",
-		template:    standardTemplates["json"].template,
-		secrets: []*filetemplates.Secret{
-			{Alias: "alias 1", Value: "secret value 1"},
-			{"alias 2", "secret value 2"},
-		},
-		assert: assertGoodOutput(`{"alias 1":"secret value 1","alias 2":"secret value 2"}`),
-	},
-	{
-		description: "yaml",
-		template:    standardTemplates["yaml"].template,
-		secrets: []*filetemplates.Secret{
-			{Alias: "alias 1", Value: "secret value 1"},
-			{"alias 2", "secret value 2"},
-		},
-		assert: assertGoodOutput(`"alias 1": "secret value 1"
-"alias 2": "secret value 2"`),
-	},
 	{
 		description: "dotenv",
 		template:    standardTemplates["dotenv"].template,
@@ -61,18 +44,8 @@ var standardTemplateTestCases = []pushToWriterTestCase{
 			{Alias: "alias1", Value: "secret value 1"},
 			{"alias2", "secret value 2"},
 		},
-		assert: assertGoodOutput(`alias1="secret value 1"
-alias2="secret value 2"`),
-	},
-	{
-		description: "properties",
-		template:    standardTemplates["properties"].template,
-		secrets: []*filetemplates.Secret{
-			{Alias: "alias.1", Value: "secret value 1"},
-			{"alias2", "secret value 2"},
-		},
-		assert: assertGoodOutput(`alias.1="secret value 1"
-alias2="secret value 2"`),
+		assert: assertGoodOutput(`alias1='secret value 1'
+alias2='secret value 2'`),
 	},
 	{
 		description: "bash",
@@ -81,8 +54,8 @@ alias2="secret value 2"`),
 			{Alias: "alias1", Value: "secret value 1"},
 			{"alias2", "secret value 2"},
 		},
-		assert: assertGoodOutput(`export alias1="secret value 1"
-export alias2="secret value 2"`),
+		assert: assertGoodOutput(`export alias1='secret value 1'
+export alias2='secret value 2'`),
 	},
 }
 
@@ -92,145 +65,313 @@ func Test_standardTemplates(t *testing.T) {
 	}
 }
 
-type aliasCharTestCase struct {
-	description string
-	testChar    rune
-	assert      assertErrorFunc
-}
-
-func (tc *aliasCharTestCase) Run(t *testing.T, fileFormat string) {
-	t.Run(tc.description, func(t *testing.T) {
-		// Set up test case
-		desc := fmt.Sprintf("%s file format, key containing %s character",
-			fileFormat, tc.description)
-		alias := "key_containing_" + string(tc.testChar) + "_character"
-		secretSpecs := []filetemplates.SecretSpec{{Alias: alias, Path: validConjurPath}}
-
-		// Run test case
-		_, err := FileTemplateForFormat(fileFormat, secretSpecs)
-
-		// Check result
-		tc.assert(t, err, desc)
-	})
-}
-
-type aliasLenTestCase struct {
-	description string
-	alias       string
-	assert      assertErrorFunc
-}
-
-func (tc *aliasLenTestCase) Run(t *testing.T, fileFormat string) {
-	t.Run(tc.description, func(t *testing.T) {
-		// Set up test case
-		desc := fmt.Sprintf("%s file format, %s", fileFormat, tc.description)
-		secretSpecs := []filetemplates.SecretSpec{{Alias: tc.alias, Path: validConjurPath}}
-
-		// Run test case
-		_, err := FileTemplateForFormat(fileFormat, secretSpecs)
-
-		// Check result
-		tc.assert(t, err, desc)
-	})
-}
-
-func TestValidateAliasForYAML(t *testing.T) {
-	testValidateAliasCharForYAML(t)
-	testValidateAliasLenForYAML(t)
-}
+func TestEncodedFormats(t *testing.T) {
+	testCases := []struct {
+		description string
+		fileFormat  string
+		secrets     []*filetemplates.Secret
+		expected    string
+	}{
+		{
+			description: "json",
+			fileFormat:  "json",
+			secrets: []*filetemplates.Secret{
+				{Alias: "alias 1", Value: "secret value 1"},
+				{Alias: "alias 2", Value: "secret value 2"},
+			},
+			expected: `{"alias 1":"secret value 1","alias 2":"secret value 2"}`,
+		},
+		{
+			description: "json with characters Go quoting doesn't produce for JSON",
+			fileFormat:  "json",
+			secrets: []*filetemplates.Secret{
+				{Alias: "control\x00", Value: "a\x00b\ac\U0001F600 & <tag> \"quoted\" \\"},
+			},
+			expected: `{"control\u0000":"a\u0000b\u0007c😀 & <tag> \"quoted\" \\"}`,
+		},
+		{
+			description: "json without secrets",
+			fileFormat:  "json",
+			secrets:     []*filetemplates.Secret{},
+			expected:    `{}`,
+		},
+		{
+			description: "yaml",
+			fileFormat:  "yaml",
+			secrets: []*filetemplates.Secret{
+				{Alias: "alias 1", Value: "secret value 1"},
+				{Alias: "alias 2", Value: "secret value 2"},
+			},
+			expected: `"alias 1": "secret value 1"
+"alias 2": "secret value 2"`,
+		},
+		{
+			description: "yaml with characters outside of the YAML character set",
+			fileFormat:  "yaml",
+			secrets: []*filetemplates.Secret{
+				{Alias: "control\x00", Value: "a\x00b\x7f\u0086\ufffe\U0001F600\nline 2"},
+			},
+			expected: `"control\0": "a\0b\x7F\x86\uFFFE\U0001F600\nline 2"`,
+		},
+		{
+			description: "yaml with binary value",
+			fileFormat:  "yaml",
+			secrets: []*filetemplates.Secret{
+				{Alias: "binary", Value: "\xff\xfe"},
+			},
+			expected: `"binary": !!binary //4=`,
+		},
+		{
+			description: "properties",
+			fileFormat:  "properties",
+			secrets: []*filetemplates.Secret{
+				{Alias: "alias.1", Value: "secret value 1"},
+				{Alias: "alias2", Value: "secret value 2"},
+			},
+			expected: `alias.1=secret value 1
+alias2=secret value 2`,
+		},
+		{
+			description: "properties with separators and comment characters",
+			fileFormat:  "properties",
+			secrets: []*filetemplates.Secret{
+				{Alias: "url", Value: "jdbc:postgresql://db:5432/app?ssl=true#frag"},
+				{Alias: "password", Value: `!p@ss\word`},
+			},
+			expected: `url=jdbc\:postgresql\://db\:5432/app?ssl\=true\#frag
+password=\!p@ss\\word`,
+		},
+		{
+			description: "properties with leading whitespace",
+			fileFormat:  "properties",
+			secrets: []*filetemplates.Secret{
+				{Alias: "space", Value: "  padded "},
+				{Alias: "tab", Value: "\tindented"},
+			},
+			expected: `space=\  padded 
+tab=\tindented`,
+		},
+		{
+			description: "properties with non-ASCII characters",
+			fileFormat:  "properties",
+			secrets: []*filetemplates.Secret{
+				{Alias: "greeting", Value: "héllo 💙\x00\x7f"},
+			},
+			expected: `greeting=h\u00E9llo \uD83D\uDC99\u0000\u007F`,
+		},
+		{
+			description: "properties with multi-line values",
+			fileFormat:  "properties",
+			secrets: []*filetemplates.Secret{
+				{Alias: "cert", Value: "-----BEGIN CERTIFICATE-----\nMIIB\n  indented\r\n-----END CERTIFICATE-----\n"},
+				{Alias: "next", Value: "value"},
+			},
+			expected: `cert=-----BEGIN CERTIFICATE-----\n\
+    MIIB\n\
+    \  indented\r\n\
+    -----END CERTIFICATE-----\n\
 
-func testValidateAliasCharForYAML(t *testing.T) {
-	testCases := []aliasCharTestCase{
-		// YAML file format, 8-bit characters
-		{"printable ASCII", '\u003F', assertNoError()},
-		{"heart emoji", '💙', assertNoError()},
-		{"dog emoji", '🐶', assertNoError()},
-		{"ASCII NULL", '\u0000', assertErrorContains(invalidYAMLChar)},
-		{"ASCII BS", '\u0008', assertErrorContains(invalidYAMLChar)},
-		{"ASCII tab", '\u0009', assertNoError()},
-		{"ASCII LF", '\u000A', assertNoError()},
-		{"ASCII VT", '\u000B', assertErrorContains(invalidYAMLChar)},
-		{"ASCII CR", '\u000D', assertNoError()},
-		{"ASCII space", '\u0020', assertNoError()},
-		{"ASCII tilde", '\u007E', assertNoError()},
-		{"ASCII DEL", '\u007F', assertErrorContains(invalidYAMLChar)},
-		// YAML file format, 16-bit Unicode
-		{"Unicode NEL", '\u0085', assertNoError()},
-		{"Unicode 0x86", '\u0086', assertErrorContains(invalidYAMLChar)},
-		{"Unicode 0x9F", '\u009F', assertErrorContains(invalidYAMLChar)},
-		{"Unicode 0xA0", '\u00A0', assertNoError()},
-		{"Unicode 0xD7FF", '\uD7FF', assertNoError()},
-		{"Unicode 0xE000", '\uE000', assertNoError()},
-		{"Unicode 0xFFFD", '\uFFFD', assertNoError()},
-		{"Unicode 0xFFFE", '\uFFFE', assertErrorContains(invalidYAMLChar)},
-		// YAML file format, 32-bit Unicode
-		{"Unicode 0x10000", '\U00010000', assertNoError()},
-		{"Unicode 0x10FFFF", '\U0010FFFF', assertNoError()},
+next=value`,
+		},
 	}
 
 	for _, tc := range testCases {
-		tc.Run(t, "yaml")
+		t.Run(tc.description, func(t *testing.T) {
+			content, err := standardTemplates[tc.fileFormat].encode(tc.secrets, "")
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, string(content))
+		})
 	}
 }
 
-func testValidateAliasLenForYAML(t *testing.T) {
-	maxLenAlias := strings.Repeat("a", maxYAMLKeyLen)
-
-	testCases := []aliasLenTestCase{
-		{"single char alias", "a", assertNoError()},
-		{"maximum length alias", maxLenAlias, assertNoError()},
-		{"oversized alias", maxLenAlias + "a", assertErrorContains(yamlAliasTooLong)},
+// randomSecrets returns secrets with random aliases and values, biased towards
+// control characters, quotes and characters outside of the YAML character set
+func randomSecrets(r *rand.Rand, validUTF8 bool) []*filetemplates.Secret {
+	runes := []rune("\x00\x01\a\b\t\n\v\f\r\x1b\"'\\:#-{}[]&*!|>%@` \x7f\u0085\u0086\u00a0\u2028\ufeff\ufffe\U0001F600")
+	randomString := func() string {
+		var b strings.Builder
+		for i := r.Intn(40); i > 0; i-- {
+			switch r.Intn(4) {
+			case 0:
+				b.WriteRune(runes[r.Intn(len(runes))])
+			case 1:
+				if !validUTF8 {
+					b.WriteByte(byte(0x80 + r.Intn(0x80)))
+					continue
+				}
+				fallthrough
+			default:
+				b.WriteRune(rune(0x20 + r.Intn(0x5f)))
+			}
+		}
+		return b.String()
 	}
 
-	for _, tc := range testCases {
-		tc.Run(t, "yaml")
+	secrets := []*filetemplates.Secret{}
+	aliases := map[string]struct{}{}
+	for i := r.Intn(10); i > 0; i-- {
+		alias := randomString()
+		if _, ok := aliases[alias]; ok {
+			continue
+		}
+		aliases[alias] = struct{}{}
+		secrets = append(secrets, &filetemplates.Secret{Alias: alias, Value: randomString()})
 	}
+	return secrets
 }
 
-func TestValidateAliasForJSON(t *testing.T) {
-	testValidateAliasCharForJSON(t)
-	testValidateAliasLenForJSON(t)
-}
+func TestEncodedFormats_RoundTrip(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+
+	t.Run("json", func(t *testing.T) {
+		for i := 0; i < 500; i++ {
+			secrets := randomSecrets(r, true)
+			content, err := encodeJSON(secrets, "")
+			require.NoError(t, err)
+
+			dec := json.NewDecoder(bytes.NewReader(content))
+			tok, err := dec.Token()
+			require.NoError(t, err, "%q", content)
+			require.Equal(t, json.Delim('{'), tok)
+			for _, s := range secrets {
+				var alias, value string
+				require.NoError(t, dec.Decode(&alias), "%q", content)
+				require.NoError(t, dec.Decode(&value), "%q", content)
+				assert.Equal(t, s.Alias, alias)
+				assert.Equal(t, s.Value, value)
+			}
+			assert.False(t, dec.More())
+		}
+	})
 
-func testValidateAliasCharForJSON(t *testing.T) {
-	testCases := []aliasCharTestCase{
-		// JSON file format, valid characters
-		{"ASCII space", '\u0020', assertNoError()},
-		{"ASCII tilde", '~', assertNoError()},
-		{"heart emoji", '💙', assertNoError()},
-		{"dog emoji", '🐶', assertNoError()},
-		{"Unicode 0x10000", '\U00010000', assertNoError()},
-		{"Unicode 0x10FFFF", '\U0010FFFF', assertNoError()},
-		// JSON file format, invalid characters
-		{"ASCII NUL", '\u0000', assertErrorContains(invalidJSONChar)},
-		{"ASCII 0x1F", '\u001F', assertErrorContains(invalidJSONChar)},
-		{"ASCII NULL", '\u0000', assertErrorContains(invalidJSONChar)},
-		{"ASCII BS", '\u0008', assertErrorContains(invalidJSONChar)},
-		{"ASCII tab", '\u0009', assertErrorContains(invalidJSONChar)},
-		{"ASCII LF", '\u000A', assertErrorContains(invalidJSONChar)},
-		{"ASCII VT", '\u000B', assertErrorContains(invalidJSONChar)},
-		{"ASCII DEL", '\u007F', assertErrorContains(invalidJSONChar)},
-		{"ASCII quote", '"', assertErrorContains(invalidJSONChar)},
-		{"ASCII backslash", '\\', assertErrorContains(invalidJSONChar)},
-	}
+	t.Run("properties", func(t *testing.T) {
+		for i := 0; i < 500; i++ {
+			secrets := randomSecrets(r, true)
+			for _, s := range secrets {
+				// Keys are restricted by validatePropertyVarName
+				s.Alias = fmt.Sprintf("key.%d", len(s.Alias))
+			}
+			content, err := encodeProperties(secrets, "")
+			require.NoError(t, err)
+
+			expected := map[string]string{}
+			for _, s := range secrets {
+				expected[s.Alias] = s.Value
+			}
+			assert.Equal(t, expected, loadJavaProperties(string(content)), "%q", content)
+		}
+	})
 
-	for _, tc := range testCases {
-		tc.Run(t, "json")
-	}
+	t.Run("yaml", func(t *testing.T) {
+		for i := 0; i < 500; i++ {
+			secrets := randomSecrets(r, i%2 == 0)
+			content, err := encodeYAML(secrets, "")
+			require.NoError(t, err)
+
+			var doc yaml.Node
+			require.NoError(t, yaml.Unmarshal(content, &doc), "%q", content)
+			require.Len(t, doc.Content, 1, "%q", content)
+			mapping := doc.Content[0]
+			require.Equal(t, yaml.MappingNode, mapping.Kind, "%q", content)
+			require.Len(t, mapping.Content, 2*len(secrets), "%q", content)
+			for j, s := range secrets {
+				var alias, value string
+				require.NoError(t, mapping.Content[2*j].Decode(&alias), "%q", content)
+				require.NoError(t, mapping.Content[2*j+1].Decode(&value), "%q", content)
+				assert.Equal(t, s.Alias, alias)
+				assert.Equal(t, s.Value, value)
+			}
+		}
+	})
 }
 
-func testValidateAliasLenForJSON(t *testing.T) {
-	maxLenAlias := strings.Repeat("a", maxJSONKeyLen)
+// loadJavaProperties parses properties following the grammar of
+// java.util.Properties.load(Reader)
+func loadJavaProperties(content string) map[string]string {
+	isWhitespace := func(c byte) bool { return c == ' ' || c == '\t' || c == '\f' }
+	// Natural lines end with "\n", "\r" or "\r\n"
+	naturalLines := strings.Split(strings.ReplaceAll(strings.ReplaceAll(content, "\r\n", "\n"), "\r", "\n"), "\n")
+
+	properties := map[string]string{}
+	for i := 0; i < len(naturalLines); i++ {
+		line := strings.TrimLeft(naturalLines[i], " \t\f")
+		if len(line) == 0 || line[0] == '#' || line[0] == '!' {
+			continue
+		}
 
-	testCases := []aliasLenTestCase{
-		{"single-char alias", "a", assertNoError()},
-		{"maximum length alias", maxLenAlias, assertNoError()},
-		{"oversized alias", maxLenAlias + "a", assertErrorContains(jsonAliasTooLong)},
+		// An odd number of trailing backslashes continues the logical line
+		for {
+			backslashes := len(line) - len(strings.TrimRight(line, "\\"))
+			if backslashes%2 == 0 || i+1 >= len(naturalLines) {
+				if backslashes%2 == 1 {
+					line = line[:len(line)-1]
+				}
+				break
+			}
+			i++
+			line = line[:len(line)-1] + strings.TrimLeft(naturalLines[i], " \t\f")
+		}
+
+		// The key ends at the first unescaped separator or whitespace
+		keyEnd := 0
+		for keyEnd < len(line) {
+			c := line[keyEnd]
+			if c == '\\' {
+				keyEnd += 2
+				continue
+			}
+			if c == '=' || c == ':' || isWhitespace(c) {
+				break
+			}
+			keyEnd++
+		}
+		if keyEnd > len(line) {
+			keyEnd = len(line)
+		}
+		valueStart := keyEnd
+		for valueStart < len(line) && isWhitespace(line[valueStart]) {
+			valueStart++
+		}
+		if valueStart < len(line) && (line[valueStart] == '=' || line[valueStart] == ':') {
+			valueStart++
+		}
+		for valueStart < len(line) && isWhitespace(line[valueStart]) {
+			valueStart++
+		}
+
+		properties[unescapeJavaProperties(line[:keyEnd])] = unescapeJavaProperties(line[valueStart:])
 	}
+	return properties
+}
 
-	for _, tc := range testCases {
-		tc.Run(t, "json")
+func unescapeJavaProperties(escaped string) string {
+	var units []uint16
+	for i := 0; i < len(escaped); i++ {
+		c := escaped[i]
+		if c != '\\' || i+1 == len(escaped) {
+			units = append(units, uint16(c))
+			continue
+		}
+		i++
+		switch escaped[i] {
+		case 't':
+			units = append(units, '\t')
+		case 'n':
+			units = append(units, '\n')
+		case 'r':
+			units = append(units, '\r')
+		case 'f':
+			units = append(units, '\f')
+		case 'u':
+			var unit uint16
+			fmt.Sscanf(escaped[i+1:i+5], "%04X", &unit)
+			units = append(units, unit)
+			i += 4
+		default:
+			units = append(units, uint16(escaped[i]))
+		}
 	}
+	return string(utf16.Decode(units))
 }
 
 func TestValidateAliasForProperties(t *testing.T) {
@@ -0,0 +1,202 @@
+package pushtofile
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/file_templates"
+	"gopkg.in/yaml.v3"
+)
+
+const secretGroupSecretsYmlPrefix = "conjur.org/conjur-secrets-yml."
+
+// secretFilesSuffix names the directory holding the files of the "!var:file"
+// entries of a group, next to the secret file of the group
+const secretFilesSuffix = ".d"
+
+// readSecretsYml reads the secret specs of a group from a Summon secrets.yml.
+// Relative paths are relative to the templates base path, so the file can be
+// mounted from the same ConfigMap as secret file templates.
+func readSecretsYml(secretsYmlPath string, c Config) ([]filetemplates.SecretSpec, error) {
+	secretsYmlPath = secretsYmlFilePath(secretsYmlPath, c)
+
+	rc, err := c.openReadCloser(secretsYmlPath)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read secrets.yml %q: %s", secretsYmlPath, err)
+	}
+	defer func() {
+		_ = rc.Close()
+	}()
+
+	content, err := c.pullFromReader(rc)
+	if err != nil {
+		return nil, fmt.Errorf("unable to read secrets.yml %q: %s", secretsYmlPath, err)
+	}
+
+	secretSpecs, err := parseSecretsYml([]byte(content))
+	if err != nil {
+		return nil, fmt.Errorf("unable to parse secrets.yml %q: %s", secretsYmlPath, err)
+	}
+	return secretSpecs, nil
+}
+
+// secretsYmlFilePath resolves a relative secrets.yml path against the
+// templates base path
+func secretsYmlFilePath(secretsYmlPath string, c Config) string {
+	if filepath.IsAbs(secretsYmlPath) {
+		return secretsYmlPath
+	}
+	return filepath.Join(c.templatesBasePath, secretsYmlPath)
+}
+
+// parseSecretsYml creates secret specs from the entries of a Summon
+// secrets.yml, in the order of the file:
+//   - "KEY: !var path" retrieves the Conjur variable at path
+//   - "KEY: !str value" and "KEY: value" provide value as is
+//   - the ":file" suffix, as in "KEY: !var:file path", writes the value to a
+//     file of its own, and provides the path of that file instead
+//   - "KEY: !file value" is the same as "KEY: !str:file value"
+//
+// Environment sections and "$VARIABLE" interpolation aren't supported.
+func parseSecretsYml(content []byte) ([]filetemplates.SecretSpec, error) {
+	var doc yaml.Node
+	if err := yaml.Unmarshal(content, &doc); err != nil {
+		return nil, err
+	}
+	if len(doc.Content) == 0 {
+		return nil, fmt.Errorf("no secrets defined")
+	}
+	root := doc.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return nil, fmt.Errorf("expected a mapping of keys to secrets on line %d", root.Line)
+	}
+
+	var secretSpecs []filetemplates.SecretSpec
+	keys := map[string]struct{}{}
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		keyNode, valueNode := root.Content[i], root.Content[i+1]
+		key := keyNode.Value
+		if _, ok := keys[key]; ok {
+			return nil, fmt.Errorf("key %q is defined more than once on line %d", key, keyNode.Line)
+		}
+		keys[key] = struct{}{}
+
+		if valueNode.Kind != yaml.ScalarNode {
+			return nil, fmt.Errorf(
+				"expected a string for key %q on line %d, environment sections aren't supported",
+				key, valueNode.Line,
+			)
+		}
+
+		secretSpec, err := newSecretSpecFromSecretsYml(key, valueNode)
+		if err != nil {
+			return nil, err
+		}
+		secretSpecs = append(secretSpecs, secretSpec)
+	}
+	return secretSpecs, nil
+}
+
+func newSecretSpecFromSecretsYml(key string, node *yaml.Node) (filetemplates.SecretSpec, error) {
+	secretSpec := filetemplates.SecretSpec{Alias: key, ContentType: "text"}
+
+	// Untagged values are literals, and "~" or an empty value is an empty string
+	if strings.HasPrefix(node.Tag, "!!") {
+		value := node.Value
+		if node.Tag == "!!null" {
+			value = ""
+		}
+		secretSpec.Literal = &value
+		return secretSpec, nil
+	}
+
+	isVar, isStr := false, false
+	unsupportedTagErr := fmt.Errorf("unsupported tag %q for key %q on line %d", node.Tag, key, node.Line)
+	for _, tag := range strings.Split(strings.TrimPrefix(node.Tag, "!"), ":") {
+		switch tag {
+		case "var":
+			isVar = true
+		case "str":
+			isStr = true
+		case "file":
+			secretSpec.AsFile = true
+		default:
+			return secretSpec, unsupportedTagErr
+		}
+	}
+	if isVar && isStr {
+		return secretSpec, unsupportedTagErr
+	}
+
+	if isVar {
+		secretSpec.Path = node.Value
+	} else {
+		value := node.Value
+		secretSpec.Literal = &value
+	}
+	return secretSpec, nil
+}
+
+// secretFilesPath returns the directory holding the files of the "!var:file"
+// entries of the group, or an empty string when there are none. With the
+// "directory" file format, every secret is already written to its own file.
+func (sg *SecretGroup) secretFilesPath() string {
+	if sg.FileFormat == directoryFileFormat && len(sg.FileTemplate) == 0 {
+		return ""
+	}
+	for _, spec := range sg.SecretSpecs {
+		if spec.AsFile {
+			return sg.FilePath + secretFilesSuffix
+		}
+	}
+	return ""
+}
+
+// pushSecretFiles writes the "!var:file" secrets of the group to a file of
+// their own, and returns the secrets of the group with the values of those
+// secrets replaced by the path of their file. Files are written to
+// stagedPath, which differs from the published SecretFilesPath when secret
+// files are updated atomically.
+func (sg *SecretGroup) pushSecretFiles(
+	depOpenWriteCloser openWriteCloserFunc,
+	checksums *fileChecksums,
+	stagedPath string,
+	secrets []*filetemplates.Secret,
+) (bool, []*filetemplates.Secret, error) {
+	asFile := map[string]struct{}{}
+	for _, spec := range sg.SecretSpecs {
+		if spec.AsFile {
+			asFile[spec.Alias] = struct{}{}
+		}
+	}
+
+	var fileSecrets []*filetemplates.Secret
+	groupSecrets := make([]*filetemplates.Secret, 0, len(secrets))
+	for _, s := range secrets {
+		if _, ok := asFile[s.Alias]; !ok {
+			groupSecrets = append(groupSecrets, s)
+			continue
+		}
+		fileSecrets = append(fileSecrets, s)
+		groupSecrets = append(groupSecrets, &filetemplates.Secret{
+			Alias: s.Alias,
+			Value: filepath.Join(sg.SecretFilesPath, s.Alias),
+		})
+	}
+
+	// The files are written as a secret group directory, with change
+	// detection of its own
+	filesGroup := &SecretGroup{
+		Name:            sg.Name + secretFilesSuffix,
+		FilePath:        stagedPath,
+		FileFormat:      directoryFileFormat,
+		FilePermissions: sg.FilePermissions,
+		FileOwner:       sg.FileOwner,
+	}
+	updated, err := filesGroup.pushToDirectory(depOpenWriteCloser, checksums, fileSecrets)
+	if err != nil {
+		return false, nil, err
+	}
+	return updated, groupSecrets, nil
+}
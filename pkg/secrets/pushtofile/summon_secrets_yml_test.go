@@ -0,0 +1,245 @@
+package pushtofile
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/secrets/file_templates"
+	"github.com/cyberark/secrets-provider-for-k8s/pkg/testutils"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseSecretsYml(t *testing.T) {
+	testCases := []struct {
+		description string
+		content     string
+		expected    []filetemplates.SecretSpec
+		errMsg      string
+	}{
+		{
+			description: "variables and literals in file order",
+			content: `
+DB_PASSWORD: !var prod/db/password
+DB_USER: !str admin
+DB_PORT: 5432
+SSL_CERT: !var:file prod/db/cert
+BANNER: !file welcome
+EMPTY:
+`,
+			expected: []filetemplates.SecretSpec{
+				{Alias: "DB_PASSWORD", Path: "prod/db/password", ContentType: "text"},
+				{Alias: "DB_USER", Literal: testutils.StringPtr("admin"), ContentType: "text"},
+				{Alias: "DB_PORT", Literal: testutils.StringPtr("5432"), ContentType: "text"},
+				{Alias: "SSL_CERT", Path: "prod/db/cert", ContentType: "text", AsFile: true},
+				{Alias: "BANNER", Literal: testutils.StringPtr("welcome"), ContentType: "text", AsFile: true},
+				{Alias: "EMPTY", Literal: testutils.StringPtr(""), ContentType: "text"},
+			},
+		},
+		{
+			description: "empty file",
+			content:     "",
+			errMsg:      "no secrets defined",
+		},
+		{
+			description: "list of secrets",
+			content:     "- !var prod/db/password",
+			errMsg:      "expected a mapping of keys to secrets on line 1",
+		},
+		{
+			description: "environment section",
+			content: `
+production:
+  DB_PASSWORD: !var prod/db/password
+`,
+			errMsg: `expected a string for key "production" on line 3, environment sections aren't supported`,
+		},
+		{
+			description: "duplicate key",
+			content: `
+DB_PASSWORD: !var prod/db/password
+DB_PASSWORD: !var dev/db/password
+`,
+			errMsg: `key "DB_PASSWORD" is defined more than once on line 3`,
+		},
+		{
+			description: "unsupported tag",
+			content:     "DB_PASSWORD: !default prod/db/password",
+			errMsg:      `unsupported tag "!default" for key "DB_PASSWORD" on line 1`,
+		},
+		{
+			description: "variable and literal tags",
+			content:     "DB_PASSWORD: !var:str prod/db/password",
+			errMsg:      `unsupported tag "!var:str" for key "DB_PASSWORD" on line 1`,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			secretSpecs, err := parseSecretsYml([]byte(tc.content))
+			if tc.errMsg != "" {
+				assert.EqualError(t, err, tc.errMsg)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, secretSpecs)
+		})
+	}
+}
+
+func TestNewSecretGroups_SecretsYml(t *testing.T) {
+	templatesBasePath := t.TempDir()
+	require.NoError(t, os.WriteFile(filepath.Join(templatesBasePath, "secrets.yml"), []byte(`
+DB_PASSWORD: !var db/password
+DB_USER: admin
+SSL_CERT: !var:file db/cert
+`), 0644))
+
+	t.Run("secret specs from secrets.yml", func(t *testing.T) {
+		groups, errs := NewSecretGroups("/basepath", templatesBasePath, map[string]string{
+			"conjur.org/conjur-secrets-yml.app":         "secrets.yml",
+			"conjur.org/conjur-secrets-policy-path.app": "prod",
+			"conjur.org/secret-file-format.app":         "dotenv",
+		})
+		require.Len(t, errs, 0)
+		require.Len(t, groups, 1)
+
+		assert.Equal(t, &SecretGroup{
+			Name:             "app",
+			FilePath:         "/basepath/app.dotenv",
+			FileFormat:       "dotenv",
+			FilePermissions:  0644,
+			PolicyPathPrefix: "prod",
+			SecretSpecs: []filetemplates.SecretSpec{
+				{Alias: "DB_PASSWORD", Path: "prod/db/password", ContentType: "text"},
+				{Alias: "DB_USER", Literal: testutils.StringPtr("admin"), ContentType: "text"},
+				{Alias: "SSL_CERT", Path: "prod/db/cert", ContentType: "text", AsFile: true},
+			},
+			SecretFilesPath: "/basepath/app.dotenv.d",
+			SecretsYmlPath:  filepath.Join(templatesBasePath, "secrets.yml"),
+		}, groups[0])
+	})
+
+	t.Run("directory format writes every secret to a file", func(t *testing.T) {
+		groups, errs := NewSecretGroups("/basepath", templatesBasePath, map[string]string{
+			"conjur.org/conjur-secrets-yml.app": filepath.Join(templatesBasePath, "secrets.yml"),
+			"conjur.org/secret-file-format.app": "directory",
+		})
+		require.Len(t, errs, 0)
+		require.Len(t, groups, 1)
+		assert.Empty(t, groups[0].SecretFilesPath)
+	})
+
+	t.Run("secrets from both annotations", func(t *testing.T) {
+		_, errs := NewSecretGroups("/basepath", templatesBasePath, map[string]string{
+			"conjur.org/conjur-secrets-yml.app": "secrets.yml",
+			"conjur.org/conjur-secrets.app":     "- db/password",
+		})
+		require.Len(t, errs, 1)
+		assert.EqualError(t, errs[0], `secrets for group "app" cannot be provided both by annotation "conjur.org/conjur-secrets.app" and by annotation "conjur.org/conjur-secrets-yml.app"`)
+	})
+
+	t.Run("missing secrets.yml", func(t *testing.T) {
+		_, errs := NewSecretGroups("/basepath", templatesBasePath, map[string]string{
+			"conjur.org/conjur-secrets-yml.app": "missing.yml",
+		})
+		require.Len(t, errs, 1)
+		assert.ErrorContains(t, errs[0], `unable to create secret specs from annotation "conjur.org/conjur-secrets-yml.app": unable to read secrets.yml`)
+	})
+
+	t.Run("group file inside secret files directory", func(t *testing.T) {
+		_, errs := NewSecretGroups("/basepath", templatesBasePath, map[string]string{
+			"conjur.org/conjur-secrets-yml.app": "secrets.yml",
+			"conjur.org/conjur-secrets.db":      "- db/url",
+			"conjur.org/secret-file-path.db":    "app.yaml.d/db.yaml",
+		})
+		require.Len(t, errs, 1)
+		assert.EqualError(t, errs[0], `filepath "/basepath/app.yaml.d/db.yaml" for group "db" is inside the directory of group "app"`)
+	})
+
+	t.Run("manifest group", func(t *testing.T) {
+		require.NoError(t, os.WriteFile(filepath.Join(templatesBasePath, "secret-groups.yaml"), []byte(`
+groups:
+  - name: app
+    fileFormat: json
+    secretsYml: secrets.yml
+`), 0644))
+		defer os.Remove(filepath.Join(templatesBasePath, "secret-groups.yaml"))
+
+		groups, errs := NewSecretGroups("/basepath", templatesBasePath, map[string]string{
+			"conjur.org/secret-groups-manifest": "secret-groups.yaml",
+		})
+		require.Len(t, errs, 0)
+		require.Len(t, groups, 1)
+		assert.Len(t, groups[0].SecretSpecs, 3)
+		assert.Equal(t, "/basepath/app.json.d", groups[0].SecretFilesPath)
+	})
+}
+
+func TestSecretGroup_PushToFile_SecretFiles(t *testing.T) {
+
+	filePath := filepath.Join(t.TempDir(), "app.dotenv")
+	group := SecretGroup{
+		Name:            "app",
+		FilePath:        filePath,
+		FileFormat:      "dotenv",
+		FilePermissions: 0640,
+		SecretSpecs: []filetemplates.SecretSpec{
+			{Alias: "DB_USER", Literal: testutils.StringPtr("admin")},
+			{Alias: "SSL_CERT", Path: "db/cert", AsFile: true},
+		},
+		SecretFilesPath: filePath + ".d",
+	}
+
+	updated, err := group.PushToFile([]*filetemplates.Secret{
+		{Alias: "DB_USER", Value: "admin"},
+		{Alias: "SSL_CERT", Value: "-----BEGIN CERTIFICATE-----\n"},
+	})
+	assert.NoError(t, err)
+	assert.True(t, updated)
+
+	content, err := os.ReadFile(filePath)
+	assert.NoError(t, err)
+	assert.Equal(t, "DB_USER='admin'\nSSL_CERT='"+filePath+".d/SSL_CERT'", string(content))
+	content, err = os.ReadFile(filePath + ".d/SSL_CERT")
+	assert.NoError(t, err)
+	assert.Equal(t, "-----BEGIN CERTIFICATE-----\n", string(content))
+
+	// A new value of a secret file updates the group, even though the
+	// secret file of the group is unchanged
+	updated, err = group.PushToFile([]*filetemplates.Secret{
+		{Alias: "DB_USER", Value: "admin"},
+		{Alias: "SSL_CERT", Value: "rotated"},
+	})
+	assert.NoError(t, err)
+	assert.True(t, updated)
+	content, err = os.ReadFile(filePath + ".d/SSL_CERT")
+	assert.NoError(t, err)
+	assert.Equal(t, "rotated", string(content))
+
+	assert.NoError(t, group.removeFiles())
+	assert.NoFileExists(t, filePath)
+	assert.NoDirExists(t, filePath+".d")
+}
+
+func TestFetchSecretsForGroups_Literals(t *testing.T) {
+	groups := []*SecretGroup{{
+		Name: "app",
+		SecretSpecs: []filetemplates.SecretSpec{
+			{Alias: "DB_USER", Literal: testutils.StringPtr("admin")},
+		},
+	}}
+
+	secretsByGroup, err := FetchSecretsForGroups(
+		func(variableIDs []string, ctx context.Context) (map[string][]byte, error) {
+			t.Fatalf("unexpected retrieval of %v", variableIDs)
+			return nil, nil
+		},
+		groups,
+		context.Background(),
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, []*filetemplates.Secret{{Alias: "DB_USER", Value: "admin"}}, secretsByGroup["app"])
+}
@@ -0,0 +1,73 @@
+package pushtofile
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// wipeFiles overwrites the secret files of the group, its backups and the
+// files of its secrets written to a file of their own, before they're
+// removed, so that secret values don't remain in the removed files
+func (sg *SecretGroup) wipeFiles() error {
+	roots := []string{sg.FilePath}
+	for generation := 1; generation <= maxFileBackups; generation++ {
+		roots = append(roots, sg.backupPath(generation))
+	}
+	if len(sg.SecretFilesPath) > 0 {
+		roots = append(roots, sg.SecretFilesPath)
+	}
+
+	for _, root := range roots {
+		if err := overwriteFiles(root); err != nil {
+			return err
+		}
+	}
+	return sg.removeFiles()
+}
+
+// overwriteFiles overwrites the regular files at root, or below root when
+// it's a directory, with zeros. Symlinks are followed, such as the symlinks
+// through "..data" of atomic secret file updates.
+func overwriteFiles(root string) error {
+	resolved, err := filepath.EvalSymlinks(root)
+	if os.IsNotExist(err) {
+		return nil
+	} else if err != nil {
+		return err
+	}
+
+	return filepath.WalkDir(resolved, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+		return overwriteFile(path)
+	})
+}
+
+func overwriteFile(path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	// Secret files can be read-only
+	if info.Mode().Perm()&0200 == 0 {
+		if err := os.Chmod(path, info.Mode().Perm()|0200); err != nil {
+			return err
+		}
+	}
+
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if _, err := f.Write(make([]byte, info.Size())); err != nil {
+		return err
+	}
+	return f.Sync()
+}
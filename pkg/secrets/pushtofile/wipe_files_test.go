@@ -0,0 +1,137 @@
+package pushtofile
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileProvider_Wipe(t *testing.T) {
+	for _, atomic := range []string{"false", "true"} {
+		t.Run("atomic file updates "+atomic, func(t *testing.T) {
+			basePath := t.TempDir()
+			version := "v1"
+			retrieveVersion := func(variableIDs []string, ctx context.Context) (map[string][]byte, error) {
+				values := map[string][]byte{}
+				for _, id := range variableIDs {
+					values[id] = []byte(version)
+				}
+				return values, nil
+			}
+			p, errs := NewProvider(retrieveVersion, false, P2FProviderConfig{
+				SecretFileBasePath:   basePath,
+				TemplateFileBasePath: t.TempDir(),
+				AnnotationsMap: map[string]string{
+					"conjur.org/atomic-file-updates-enabled": atomic,
+					"conjur.org/conjur-secrets.db":           "- db/password",
+					"conjur.org/secret-file-backups.db":      "1",
+					"conjur.org/secret-file-permissions.db":  "-r--------",
+					"conjur.org/conjur-secrets.certs":        "- tls.crt: certs/crt",
+					"conjur.org/secret-file-format.certs":    "directory",
+				},
+			})
+			require.Empty(t, errs)
+
+			_, err := p.Provide(context.Background())
+			require.NoError(t, err)
+			version = "v2"
+			_, err = p.Provide(context.Background())
+			require.NoError(t, err)
+
+			// Links keep the content of the wiped files readable
+			linkDir := t.TempDir()
+			links := map[string]string{}
+			for _, path := range []string{"db.yaml", "db.yaml.1", "certs/tls.crt"} {
+				resolved, err := filepath.EvalSymlinks(filepath.Join(basePath, path))
+				require.NoError(t, err)
+				link := filepath.Join(linkDir, filepath.Base(path))
+				require.NoError(t, os.Link(resolved, link))
+				links[path] = link
+			}
+
+			require.NoError(t, p.Wipe(context.Background()))
+			entries, err := os.ReadDir(basePath)
+			require.NoError(t, err)
+			assert.Empty(t, entries)
+			for path, link := range links {
+				content, err := os.ReadFile(link)
+				require.NoError(t, err)
+				assert.NotEmpty(t, content, path)
+				assert.Equal(t, make([]byte, len(content)), content, path)
+			}
+
+			// Secrets aren't provided after they were wiped
+			updated, err := p.Provide(context.Background())
+			assert.NoError(t, err)
+			assert.False(t, updated)
+			entries, err = os.ReadDir(basePath)
+			require.NoError(t, err)
+			assert.Empty(t, entries)
+		})
+	}
+}
+
+func TestFileProvider_WipeWaitsForProvide(t *testing.T) {
+	basePath := t.TempDir()
+	retrieving := make(chan struct{})
+	release := make(chan struct{})
+	retrieveBlocking := func(variableIDs []string, ctx context.Context) (map[string][]byte, error) {
+		close(retrieving)
+		<-release
+		return map[string][]byte{"db/password": []byte("secret")}, nil
+	}
+	p, errs := NewProvider(retrieveBlocking, false, P2FProviderConfig{
+		SecretFileBasePath:   basePath,
+		TemplateFileBasePath: t.TempDir(),
+		AnnotationsMap: map[string]string{
+			"conjur.org/conjur-secrets.db": "- db/password",
+		},
+	})
+	require.Empty(t, errs)
+
+	provided := make(chan error, 1)
+	go func() {
+		_, err := p.Provide(context.Background())
+		provided <- err
+	}()
+	<-retrieving
+
+	// Secrets being provided when the Secrets Provider is stopped are
+	// written before they're wiped
+	wiped := make(chan error, 1)
+	go func() {
+		wiped <- p.Wipe(context.Background())
+	}()
+	select {
+	case <-wiped:
+		t.Fatal("secrets were wiped while they were being provided")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	close(release)
+	require.NoError(t, <-provided)
+	require.NoError(t, <-wiped)
+	entries, err := os.ReadDir(basePath)
+	require.NoError(t, err)
+	assert.Empty(t, entries)
+}
+
+func TestOverwriteFiles(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "secret")
+	require.NoError(t, os.WriteFile(path, []byte("secret"), 0400))
+
+	require.NoError(t, overwriteFiles(path))
+	content, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(make([]byte, 6), content))
+
+	// Missing files are ignored
+	assert.NoError(t, overwriteFiles(filepath.Join(dir, "missing")))
+}
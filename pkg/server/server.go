@@ -2,11 +2,15 @@ package server
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
 	"net"
 	"net/http"
+	"strconv"
+	"strings"
 	"sync/atomic"
+	"time"
 
 	"github.com/cyberark/conjur-authn-k8s-client/pkg/log"
 	"github.com/cyberark/secrets-provider-for-k8s/pkg/log/messages"
@@ -14,11 +18,37 @@ import (
 
 const DefaultAddress = ":8080"
 
+// DefaultAdminAddress is the default address of the admin server, which only
+// listens on the loopback interface of the Pod
+const DefaultAdminAddress = "127.0.0.1:8081"
+
+// SecretGroupAdmin rolls back the secret files of Push to File secret
+// groups, pausing their refreshes until they're resumed
+type SecretGroupAdmin interface {
+	Rollback(ctx context.Context, group string, generation int) error
+	Resume(group string) error
+}
+
 type Server struct {
 	listener   net.Listener
 	httpServer *http.Server
+	mux        *http.ServeMux
 	isHealthy  atomic.Bool
 	isReady    atomic.Bool
+	// degradedGroups holds the Push to File secret groups that couldn't be
+	// provided, which are reported by the readiness endpoint
+	degradedGroups atomic.Value
+	// nextRefresh holds the time of the next scheduled refresh of secrets,
+	// which is reported by the status endpoint
+	nextRefresh atomic.Value
+}
+
+// Status is the response of the status endpoint
+type Status struct {
+	Healthy        bool       `json:"healthy"`
+	Ready          bool       `json:"ready"`
+	DegradedGroups []string   `json:"degradedGroups,omitempty"`
+	NextRefresh    *time.Time `json:"nextRefresh,omitempty"`
 }
 
 func NewServer(address string) (*Server, error) {
@@ -31,11 +61,11 @@ func NewServer(address string) (*Server, error) {
 		return nil, err
 	}
 
-	server := &Server{listener: listener}
-	mux := http.NewServeMux()
-	mux.HandleFunc("/healthz", server.healthHandler)
-	mux.HandleFunc("/readyz", server.readyHandler)
-	server.httpServer = &http.Server{Handler: mux}
+	server := &Server{listener: listener, mux: http.NewServeMux()}
+	server.mux.HandleFunc("/healthz", server.healthHandler)
+	server.mux.HandleFunc("/readyz", server.readyHandler)
+	server.mux.HandleFunc("GET /status", server.statusHandler)
+	server.httpServer = &http.Server{Handler: server.mux}
 
 	return server, nil
 }
@@ -78,10 +108,101 @@ func (s *Server) SetReady(ready bool) {
 	log.Info(messages.CSPFK040I, ready)
 }
 
+// SetDegradedGroups sets the secret groups that couldn't be provided, while
+// the other groups were
+func (s *Server) SetDegradedGroups(groups []string) {
+	s.degradedGroups.Store(groups)
+}
+
 func (s *Server) readyHandler(w http.ResponseWriter, _ *http.Request) {
 	if s.isReady.Load() {
 		w.WriteHeader(http.StatusOK)
 		return
 	}
+	if groups, _ := s.degradedGroups.Load().([]string); len(groups) > 0 {
+		http.Error(w, "degraded secret groups: "+strings.Join(groups, ", "), http.StatusServiceUnavailable)
+		return
+	}
 	w.WriteHeader(http.StatusServiceUnavailable)
 }
+
+// SetNextRefresh sets the time of the next scheduled refresh of secrets, or
+// the zero time when no refresh is scheduled
+func (s *Server) SetNextRefresh(next time.Time) {
+	s.nextRefresh.Store(next)
+}
+
+func (s *Server) statusHandler(w http.ResponseWriter, _ *http.Request) {
+	status := Status{
+		Healthy: s.isHealthy.Load(),
+		Ready:   s.isReady.Load(),
+	}
+	status.DegradedGroups, _ = s.degradedGroups.Load().([]string)
+	if next, _ := s.nextRefresh.Load().(time.Time); !next.IsZero() {
+		next = next.UTC()
+		status.NextRefresh = &next
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(status)
+}
+
+// NewAdminServer creates a server with the endpoints rolling back secret
+// groups with admin, listening on address, DefaultAdminAddress by default.
+// They only accept requests from the loopback interface, such as requests
+// sent with kubectl exec or kubectl port-forward, since they have no other
+// authentication.
+func NewAdminServer(address string, admin SecretGroupAdmin) (*Server, error) {
+	if address == "" {
+		address = DefaultAdminAddress
+	}
+
+	listener, err := net.Listen("tcp", address)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &Server{listener: listener, mux: http.NewServeMux()}
+	s.mux.HandleFunc("POST /admin/groups/{group}/rollback", s.adminHandler(func(r *http.Request) error {
+		generation := 1
+		if value := r.URL.Query().Get("generation"); value != "" {
+			var err error
+			if generation, err = strconv.Atoi(value); err != nil {
+				return fmt.Errorf("invalid generation %q", value)
+			}
+		}
+		return admin.Rollback(r.Context(), r.PathValue("group"), generation)
+	}))
+	s.mux.HandleFunc("POST /admin/groups/{group}/resume", s.adminHandler(func(r *http.Request) error {
+		return admin.Resume(r.PathValue("group"))
+	}))
+	s.httpServer = &http.Server{Handler: s.mux}
+
+	return s, nil
+}
+
+func (s *Server) adminHandler(handle func(r *http.Request) error) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !isLoopback(r.RemoteAddr) {
+			log.Warn(messages.CSPFK109E, r.RemoteAddr)
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		if err := handle(r); err != nil {
+			log.Error(messages.CSPFK110E, r.Method, r.URL.Path, err)
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}
+}
+
+func isLoopback(remoteAddr string) bool {
+	host, _, err := net.SplitHostPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
@@ -2,7 +2,10 @@ package server
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
 
@@ -52,3 +55,102 @@ func TestServerHealthAndReadiness(t *testing.T) {
 		return resp.StatusCode == http.StatusOK
 	}, 2*time.Second, 20*time.Millisecond)
 }
+
+func TestServerDegradedGroups(t *testing.T) {
+	server := &Server{}
+	server.SetDegradedGroups([]string{"cache", "db"})
+
+	w := httptest.NewRecorder()
+	server.readyHandler(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Contains(t, w.Body.String(), "degraded secret groups: cache, db")
+
+	server.SetDegradedGroups(nil)
+	w = httptest.NewRecorder()
+	server.readyHandler(w, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Empty(t, w.Body.String())
+}
+
+func TestServerStatus(t *testing.T) {
+	server := &Server{}
+	server.isHealthy.Store(true)
+	server.SetDegradedGroups([]string{"cache"})
+	next := time.Date(2026, 10, 19, 13, 5, 0, 0, time.UTC)
+	server.SetNextRefresh(next)
+
+	w := httptest.NewRecorder()
+	server.statusHandler(w, httptest.NewRequest(http.MethodGet, "/status", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "application/json", w.Header().Get("Content-Type"))
+	assert.JSONEq(t, `{"healthy":true,"ready":false,"degradedGroups":["cache"],"nextRefresh":"2026-10-19T13:05:00Z"}`, w.Body.String())
+
+	// Refreshes that aren't scheduled are left out
+	server.SetReady(true)
+	server.SetDegradedGroups(nil)
+	server.SetNextRefresh(time.Time{})
+	w = httptest.NewRecorder()
+	server.statusHandler(w, httptest.NewRequest(http.MethodGet, "/status", nil))
+	assert.JSONEq(t, `{"healthy":true,"ready":true}`, w.Body.String())
+}
+
+type adminSpy struct {
+	rollbacks []string
+	resumes   []string
+	err       error
+}
+
+func (a *adminSpy) Rollback(_ context.Context, group string, generation int) error {
+	a.rollbacks = append(a.rollbacks, fmt.Sprintf("%s.%d", group, generation))
+	return a.err
+}
+
+func (a *adminSpy) Resume(group string) error {
+	a.resumes = append(a.resumes, group)
+	return a.err
+}
+
+func TestServerAdmin(t *testing.T) {
+	admin := &adminSpy{}
+	server, err := NewAdminServer("127.0.0.1:0", admin)
+	require.NoError(t, err)
+	defer server.listener.Close()
+
+	request := func(method, target, remoteAddr string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, target, nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		server.mux.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := request(http.MethodPost, "/admin/groups/db/rollback", "127.0.0.1:41000")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = request(http.MethodPost, "/admin/groups/db/rollback?generation=2", "[::1]:41000")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	rec = request(http.MethodPost, "/admin/groups/db/resume", "127.0.0.1:41000")
+	assert.Equal(t, http.StatusOK, rec.Code)
+	assert.Equal(t, []string{"db.1", "db.2"}, admin.rollbacks)
+	assert.Equal(t, []string{"db"}, admin.resumes)
+
+	// Requests from other hosts are rejected
+	rec = request(http.MethodPost, "/admin/groups/db/rollback", "10.0.0.5:41000")
+	assert.Equal(t, http.StatusForbidden, rec.Code)
+	assert.Len(t, admin.rollbacks, 2)
+
+	rec = request(http.MethodGet, "/admin/groups/db/rollback", "127.0.0.1:41000")
+	assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+
+	rec = request(http.MethodPost, "/admin/groups/db/rollback?generation=last", "127.0.0.1:41000")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), `invalid generation "last"`)
+
+	// Only admin endpoints are served
+	rec = request(http.MethodGet, "/status", "127.0.0.1:41000")
+	assert.Equal(t, http.StatusNotFound, rec.Code)
+
+	admin.err = errors.New(`secret group "db" is not paused`)
+	rec = request(http.MethodPost, "/admin/groups/db/resume", "127.0.0.1:41000")
+	assert.Equal(t, http.StatusBadRequest, rec.Code)
+	assert.Contains(t, rec.Body.String(), admin.err.Error())
+}
@@ -0,0 +1,84 @@
+// Package testutils holds the fixtures shared by the tests of several
+// packages.
+package testutils
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+// PEMBundle is a leaf certificate signed by a test CA, along with the
+// private key of the leaf certificate
+type PEMBundle struct {
+	// LeafCert and ChainCert are the PEM encoded leaf and CA certificates
+	LeafCert  string
+	ChainCert string
+	// RSAKey is the PKCS #1 PEM encoded key, and PKCS8Key the PKCS #8 one
+	RSAKey   string
+	PKCS8Key string
+	// CertsDER holds the DER encoded leaf and CA certificates, in that order
+	CertsDER [][]byte
+	// PKCS8DER is the DER encoded PKCS #8 key
+	PKCS8DER []byte
+	// NotAfter is the expiry of the leaf certificate
+	NotAfter time.Time
+}
+
+// NewPEMBundle generates a PEMBundle
+func NewPEMBundle(t testing.TB) PEMBundle {
+	t.Helper()
+
+	caKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	caTemplate := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "Test CA"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(48 * time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, caTemplate, caTemplate, &caKey.PublicKey, caKey)
+	require.NoError(t, err)
+
+	leafKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	notAfter := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	leafTemplate := &x509.Certificate{
+		SerialNumber: big.NewInt(2),
+		Subject:      pkix.Name{CommonName: "app.example.com", Organization: []string{"Example"}},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leafTemplate, caTemplate, &leafKey.PublicKey, caKey)
+	require.NoError(t, err)
+
+	pkcs8DER, err := x509.MarshalPKCS8PrivateKey(leafKey)
+	require.NoError(t, err)
+
+	return PEMBundle{
+		LeafCert:  string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leafDER})),
+		ChainCert: string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: caDER})),
+		RSAKey:    string(pem.EncodeToMemory(&pem.Block{Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(leafKey)})),
+		PKCS8Key:  string(pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: pkcs8DER})),
+		CertsDER:  [][]byte{leafDER, caDER},
+		PKCS8DER:  pkcs8DER,
+		NotAfter:  notAfter,
+	}
+}
+
+// StringPtr returns a pointer to s
+func StringPtr(s string) *string {
+	return &s
+}
@@ -0,0 +1,179 @@
+package utils
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// cronField is the set of values matched by a field of a cron expression
+type cronField uint64
+
+func (f cronField) has(value int) bool {
+	return f&(1<<uint(value)) != 0
+}
+
+type cronFieldBounds struct {
+	name     string
+	min, max int
+}
+
+var cronFieldsBounds = [5]cronFieldBounds{
+	{"minute", 0, 59},
+	{"hour", 0, 23},
+	{"day of month", 1, 31},
+	{"month", 1, 12},
+	{"day of week", 0, 7},
+}
+
+var cronDescriptors = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// CronSchedule is a cron expression of five fields, the minute, hour, day of
+// month, month and day of week, such as "5 * * * *" for every hour at :05.
+// Fields are either "*", values, ranges such as "1-5", or lists of them such
+// as "0,30", with an optional step such as "*/15". The "@hourly", "@daily",
+// "@weekly", "@monthly" and "@yearly" descriptors are supported as well.
+// Cron expressions are evaluated in UTC.
+type CronSchedule struct {
+	expr       string
+	minute     cronField
+	hour       cronField
+	dayOfMonth cronField
+	month      cronField
+	dayOfWeek  cronField
+	// As in cron, days match either day field when both are restricted
+	anyDayOfMonth bool
+	anyDayOfWeek  bool
+}
+
+// ParseCronSchedule parses a cron expression
+func ParseCronSchedule(expr string) (*CronSchedule, error) {
+	spec := strings.TrimSpace(expr)
+	if descriptor, ok := cronDescriptors[spec]; ok {
+		spec = descriptor
+	}
+
+	fields := strings.Fields(spec)
+	if len(fields) != len(cronFieldsBounds) {
+		return nil, fmt.Errorf("cron expression must have %d fields, got %d", len(cronFieldsBounds), len(fields))
+	}
+
+	var values [5]cronField
+	for i, field := range fields {
+		value, err := parseCronField(field, cronFieldsBounds[i])
+		if err != nil {
+			return nil, err
+		}
+		values[i] = value
+	}
+	// Sunday is either 0 or 7
+	if values[4].has(7) {
+		values[4] |= 1
+	}
+
+	schedule := &CronSchedule{
+		expr:          expr,
+		minute:        values[0],
+		hour:          values[1],
+		dayOfMonth:    values[2],
+		month:         values[3],
+		dayOfWeek:     values[4],
+		anyDayOfMonth: strings.HasPrefix(fields[2], "*"),
+		anyDayOfWeek:  strings.HasPrefix(fields[4], "*"),
+	}
+	if schedule.Next(time.Now()).IsZero() {
+		return nil, fmt.Errorf("cron expression %q never matches", expr)
+	}
+	return schedule, nil
+}
+
+func parseCronField(field string, bounds cronFieldBounds) (cronField, error) {
+	var value cronField
+	for _, part := range strings.Split(field, ",") {
+		rangePart, stepPart, hasStep := strings.Cut(part, "/")
+		step := 1
+		if hasStep {
+			var err error
+			if step, err = strconv.Atoi(stepPart); err != nil || step < 1 {
+				return 0, fmt.Errorf("invalid step %q of %s field", stepPart, bounds.name)
+			}
+		}
+
+		first, last := bounds.min, bounds.max
+		if rangePart != "*" {
+			firstPart, lastPart, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if first, err = parseCronValue(firstPart, bounds); err != nil {
+				return 0, err
+			}
+			last = first
+			if isRange {
+				if last, err = parseCronValue(lastPart, bounds); err != nil {
+					return 0, err
+				}
+			} else if hasStep {
+				last = bounds.max
+			}
+			if first > last {
+				return 0, fmt.Errorf("invalid range %q of %s field", rangePart, bounds.name)
+			}
+		}
+
+		for v := first; v <= last; v += step {
+			value |= 1 << uint(v)
+		}
+	}
+	return value, nil
+}
+
+func parseCronValue(value string, bounds cronFieldBounds) (int, error) {
+	v, err := strconv.Atoi(value)
+	if err != nil || v < bounds.min || v > bounds.max {
+		return 0, fmt.Errorf("invalid value %q of %s field, expected %d-%d", value, bounds.name, bounds.min, bounds.max)
+	}
+	return v, nil
+}
+
+// Next returns the first time after t matching the schedule, or the zero
+// time when the schedule doesn't match within five years
+func (c *CronSchedule) Next(t time.Time) time.Time {
+	// Cron expressions have a precision of one minute
+	next := t.UTC().Truncate(time.Minute).Add(time.Minute)
+	limit := next.AddDate(5, 0, 0)
+	for next.Before(limit) {
+		switch {
+		case !c.month.has(int(next.Month())):
+			next = time.Date(next.Year(), next.Month()+1, 1, 0, 0, 0, 0, time.UTC)
+		case !c.matchesDay(next):
+			next = time.Date(next.Year(), next.Month(), next.Day()+1, 0, 0, 0, 0, time.UTC)
+		case !c.hour.has(next.Hour()):
+			next = next.Truncate(time.Hour).Add(time.Hour)
+		case !c.minute.has(next.Minute()):
+			next = next.Add(time.Minute)
+		default:
+			return next
+		}
+	}
+	return time.Time{}
+}
+
+func (c *CronSchedule) matchesDay(t time.Time) bool {
+	dayOfMonth := c.dayOfMonth.has(t.Day())
+	dayOfWeek := c.dayOfWeek.has(int(t.Weekday()))
+	if c.anyDayOfMonth || c.anyDayOfWeek {
+		return dayOfMonth && dayOfWeek
+	}
+	return dayOfMonth || dayOfWeek
+}
+
+func (c *CronSchedule) String() string {
+	return c.expr
+}
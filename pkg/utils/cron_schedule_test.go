@@ -0,0 +1,115 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCronSchedule(t *testing.T) {
+	// A Monday
+	start := time.Date(2026, 10, 19, 12, 7, 30, 0, time.UTC)
+
+	testCases := []struct {
+		description string
+		expr        string
+		expected    []time.Time
+	}{
+		{
+			description: "every hour at :05",
+			expr:        "5 * * * *",
+			expected: []time.Time{
+				time.Date(2026, 10, 19, 13, 5, 0, 0, time.UTC),
+				time.Date(2026, 10, 19, 14, 5, 0, 0, time.UTC),
+			},
+		},
+		{
+			description: "every 15 minutes",
+			expr:        "*/15 * * * *",
+			expected: []time.Time{
+				time.Date(2026, 10, 19, 12, 15, 0, 0, time.UTC),
+				time.Date(2026, 10, 19, 12, 30, 0, 0, time.UTC),
+			},
+		},
+		{
+			description: "lists and ranges",
+			expr:        "0,30 9-10 * * *",
+			expected: []time.Time{
+				time.Date(2026, 10, 20, 9, 0, 0, 0, time.UTC),
+				time.Date(2026, 10, 20, 9, 30, 0, 0, time.UTC),
+				time.Date(2026, 10, 20, 10, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			description: "weekends, with Sunday as 7",
+			expr:        "0 0 * * 6-7",
+			expected: []time.Time{
+				time.Date(2026, 10, 24, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, 10, 25, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, 10, 31, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			description: "either day when both day fields are restricted",
+			expr:        "0 0 1 * 3",
+			expected: []time.Time{
+				time.Date(2026, 10, 21, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, 10, 28, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			description: "descriptor",
+			expr:        "@monthly",
+			expected: []time.Time{
+				time.Date(2026, 11, 1, 0, 0, 0, 0, time.UTC),
+				time.Date(2026, 12, 1, 0, 0, 0, 0, time.UTC),
+				time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC),
+			},
+		},
+		{
+			description: "leap days",
+			expr:        "0 0 29 2 *",
+			expected: []time.Time{
+				time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC),
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			schedule, err := ParseCronSchedule(tc.expr)
+			require.NoError(t, err)
+			assert.Equal(t, tc.expr, schedule.String())
+
+			next := start
+			for _, expected := range tc.expected {
+				next = schedule.Next(next)
+				assert.Equal(t, expected, next)
+			}
+		})
+	}
+}
+
+func TestParseCronSchedule_Invalid(t *testing.T) {
+	testCases := []struct {
+		expr   string
+		errMsg string
+	}{
+		{"5 * * *", "cron expression must have 5 fields, got 4"},
+		{"60 * * * *", `invalid value "60" of minute field, expected 0-59`},
+		{"* 5-2 * * *", `invalid range "5-2" of hour field`},
+		{"*/0 * * * *", `invalid step "0" of minute field`},
+		{"0 0 * jan *", `invalid value "jan" of month field, expected 1-12`},
+		{"0 0 31 2 *", `cron expression "0 0 31 2 *" never matches`},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.expr, func(t *testing.T) {
+			_, err := ParseCronSchedule(tc.expr)
+			assert.EqualError(t, err, tc.errMsg)
+		})
+	}
+}
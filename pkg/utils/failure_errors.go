@@ -0,0 +1,97 @@
+package utils
+
+import (
+	"context"
+	"errors"
+)
+
+// FailureClass is the class of a failure to provide secrets, from which the
+// exit code of the Secrets Provider is derived
+type FailureClass int
+
+const (
+	// FailureUnknown is the class of failures that aren't classified
+	FailureUnknown FailureClass = iota
+	// FailureConfig is the class of invalid annotations, configuration,
+	// Conjur secret mappings and templates
+	FailureConfig
+	// FailureAuthn is the class of failures to authenticate with Conjur or
+	// the Kubernetes API
+	FailureAuthn
+	// FailureAuthz is the class of requests that the host or the service
+	// account isn't permitted to make
+	FailureAuthz
+	// FailureNotFound is the class of Conjur variables, secret values and
+	// Kubernetes Secrets that don't exist
+	FailureNotFound
+	// FailureTransient is the class of network errors, timeouts and server
+	// errors, which may not happen again
+	FailureTransient
+	// FailureWrite is the class of failures to write secret files
+	FailureWrite
+)
+
+func (c FailureClass) String() string {
+	switch c {
+	case FailureConfig:
+		return "config"
+	case FailureAuthn:
+		return "authentication"
+	case FailureAuthz:
+		return "authorization"
+	case FailureNotFound:
+		return "not-found"
+	case FailureTransient:
+		return "transient"
+	case FailureWrite:
+		return "write"
+	}
+	return "unknown"
+}
+
+// ClassifiedError is an error with the class of the failure it caused
+type ClassifiedError struct {
+	Class FailureClass
+	Err   error
+}
+
+func (e *ClassifiedError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *ClassifiedError) Unwrap() error {
+	return e.Err
+}
+
+// Classify classifies err as a failure of the class
+func Classify(class FailureClass, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &ClassifiedError{Class: class, Err: err}
+}
+
+// FailureClassOf returns the class of the first classified error that err
+// wraps. Transient errors, and exceeded deadlines, that aren't otherwise
+// classified are transient failures.
+func FailureClassOf(err error) FailureClass {
+	var classified *ClassifiedError
+	if errors.As(err, &classified) {
+		return classified.Class
+	}
+	var transient *TransientError
+	if errors.As(err, &transient) || errors.Is(err, context.DeadlineExceeded) {
+		return FailureTransient
+	}
+	return FailureUnknown
+}
+
+// IsRevokedError reports whether err is caused by secrets that don't exist,
+// or that the host or the service account isn't permitted to access
+func IsRevokedError(err error) bool {
+	switch FailureClassOf(err) {
+	case FailureNotFound, FailureAuthz:
+		return true
+	}
+	return false
+}
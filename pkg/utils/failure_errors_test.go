@@ -0,0 +1,61 @@
+package utils
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFailureClassOf(t *testing.T) {
+	authz := Permanent(Classify(FailureAuthz, errors.New("403 Forbidden")))
+	notFound := Classify(FailureNotFound, errors.New("404 Not Found"))
+
+	testCases := []struct {
+		description string
+		err         error
+		expected    FailureClass
+	}{
+		{"nil", nil, FailureUnknown},
+		{"unclassified", errors.New("failed"), FailureUnknown},
+		{"classified", authz, FailureAuthz},
+		{"wrapped classified", fmt.Errorf("retrieving: %w", notFound), FailureNotFound},
+		{"joined errors", errors.Join(errors.New("failed"), notFound), FailureNotFound},
+		{"transient", Transient(errors.New("503 Service Unavailable")), FailureTransient},
+		{"classified transient", Transient(Classify(FailureAuthn, errors.New("401"))), FailureAuthn},
+		{"deadline exceeded", fmt.Errorf("retrieving: %w", context.DeadlineExceeded), FailureTransient},
+		{"classified as cause", ClassifyAs(errors.New("CSPFK034E"), authz), FailureAuthz},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			assert.Equal(t, tc.expected, FailureClassOf(tc.err))
+		})
+	}
+	assert.True(t, IsPermanent(ClassifyAs(errors.New("CSPFK034E"), authz)))
+	assert.Equal(t, "404 Not Found", notFound.Error())
+}
+
+func TestIsRevokedError(t *testing.T) {
+	testCases := []struct {
+		description string
+		err         error
+		expected    bool
+	}{
+		{"nil", nil, false},
+		{"unclassified", errors.New("403 Forbidden"), false},
+		{"not found", Permanent(Classify(FailureNotFound, errors.New("404 Not Found"))), true},
+		{"not permitted", Classify(FailureAuthz, errors.New("403 Forbidden")), true},
+		{"wrapped not found", fmt.Errorf("CSPFK034E: %w", Classify(FailureNotFound, errors.New("404"))), true},
+		{"authentication", Transient(Classify(FailureAuthn, errors.New("401 Unauthorized"))), false},
+		{"transient", Transient(errors.New("503 Service Unavailable")), false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			assert.Equal(t, tc.expected, IsRevokedError(tc.err))
+		})
+	}
+}
@@ -1,6 +1,7 @@
 package utils
 
 import (
+	"math/rand/v2"
 	"time"
 
 	"github.com/cenkalti/backoff"
@@ -9,10 +10,16 @@ import (
 // limitedBackOff is a backoff policy that always returns the same backoff delay, for a limited number of times.
 // This is an expansion of the constant backoff policy, which is unlimited.
 // It implements the BackOff interface from backoff package.
+//
+// When MaxInterval is set, the backoff delay is exponential instead: it's
+// drawn at random, with full jitter, between zero and Interval doubled on
+// each retry, capped to MaxInterval.
 type limitedBackOff struct {
-	Interval   time.Duration
-	RetryLimit int
-	retryCount int
+	Interval    time.Duration
+	MaxInterval time.Duration
+	RetryLimit  int
+	retryCount  int
+	randomInt64 func(n int64) int64
 }
 
 func (b *limitedBackOff) RetryCount() int {
@@ -27,6 +34,17 @@ func NewLimitedBackOff(duration time.Duration, retryLimit int) *limitedBackOff {
 	}
 }
 
+// NewExponentialLimitedBackOff returns a limited backoff policy whose backoff
+// delay grows exponentially from interval up to maxInterval, with full jitter
+func NewExponentialLimitedBackOff(interval time.Duration, maxInterval time.Duration, retryLimit int) *limitedBackOff {
+	return &limitedBackOff{
+		Interval:    interval,
+		MaxInterval: max(maxInterval, interval),
+		RetryLimit:  retryLimit,
+		randomInt64: rand.Int64N,
+	}
+}
+
 func (b *limitedBackOff) Reset() {
 	b.retryCount = 0
 }
@@ -36,6 +54,16 @@ func (b *limitedBackOff) NextBackOff() time.Duration {
 		return backoff.Stop
 	}
 
+	if b.MaxInterval <= 0 || b.Interval <= 0 {
+		b.retryCount++
+		return b.Interval
+	}
+
+	ceiling := b.Interval
+	for i := 0; i < b.retryCount && ceiling < b.MaxInterval; i++ {
+		ceiling *= 2
+	}
+	ceiling = min(ceiling, b.MaxInterval)
 	b.retryCount++
-	return b.Interval
+	return time.Duration(b.randomInt64(int64(ceiling) + 1))
 }
@@ -76,3 +76,25 @@ func assertResultsEqualExpected(t *testing.T, expected time.Duration, results []
 func assertRetryCount(t *testing.T, backOff *limitedBackOff, expected int) {
 	assert.Equal(t, expected, backOff.RetryCount())
 }
+
+func TestExponentialLimitedBackOff(t *testing.T) {
+	backOff := NewExponentialLimitedBackOff(time.Second, 10*time.Second, 6)
+	var ceilings []time.Duration
+	backOff.randomInt64 = func(n int64) int64 {
+		ceilings = append(ceilings, time.Duration(n-1))
+		return n / 2
+	}
+
+	results := callMultipleNextBackOffs(backOff, 7)
+	assert.Equal(t, []time.Duration{
+		time.Second, 2 * time.Second, 4 * time.Second, 8 * time.Second, 10 * time.Second, 10 * time.Second,
+	}, ceilings)
+	assert.Equal(t, 500*time.Millisecond, results[0])
+	assert.Equal(t, 5*time.Second, results[5])
+	assert.Equal(t, backoff.Stop, results[6])
+	assertRetryCount(t, backOff, 6)
+
+	backOff.Reset()
+	backOff.NextBackOff()
+	assert.Equal(t, time.Second, ceilings[len(ceilings)-1])
+}
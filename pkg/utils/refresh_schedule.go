@@ -0,0 +1,178 @@
+package utils
+
+import (
+	"math/rand/v2"
+	"sync"
+	"time"
+)
+
+// RefreshBatchWindow is how early secrets are refreshed when they're due
+// shortly, so that secrets that are due together are refreshed at once
+const RefreshBatchWindow = time.Second
+
+type scheduledRefresh struct {
+	next     time.Time
+	interval time.Duration
+	failed   bool
+}
+
+// RefreshSchedule schedules the refreshes of named secrets, such as Push to
+// File secret groups or K8s Secrets, which have refresh intervals of their
+// own. Secrets without an interval of their own are refreshed on the default
+// cron schedule, or on the default interval. When neither is set, they
+// aren't scheduled and are refreshed whenever secrets are provided. Secrets
+// whose refresh failed stay due, so that they're retried whenever secrets
+// are provided, while they're scheduled on their interval like other secrets.
+//
+// Refreshes are delayed by a random jitter, so that secrets of many Pods
+// aren't refreshed at the same moments. Secrets refreshed together are
+// delayed by the same jitter, so that they're still refreshed together.
+type RefreshSchedule struct {
+	mutex           sync.Mutex
+	defaultInterval time.Duration
+	defaultCron     *CronSchedule
+	jitter          time.Duration
+	// jitterAt is when the last jitter was drawn, for the secrets refreshed
+	// at the same time
+	jitterAt    time.Time
+	lastJitter  time.Duration
+	randomInt64 func(n int64) int64
+	refreshes   map[string]scheduledRefresh
+}
+
+func NewRefreshSchedule() *RefreshSchedule {
+	return &RefreshSchedule{
+		randomInt64: rand.Int64N,
+		refreshes:   map[string]scheduledRefresh{},
+	}
+}
+
+// SetDefaultInterval sets the interval of the secrets without an interval of
+// their own
+func (s *RefreshSchedule) SetDefaultInterval(interval time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.defaultInterval = interval
+}
+
+// SetDefaultCron sets the cron schedule of the secrets without an interval of
+// their own, instead of the default interval
+func (s *RefreshSchedule) SetDefaultCron(cron *CronSchedule) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.defaultCron = cron
+}
+
+// SetJitter sets the maximum random delay of refreshes
+func (s *RefreshSchedule) SetJitter(jitter time.Duration) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.jitter = jitter
+}
+
+// Due reports whether the secret is refreshed at now. Secrets that weren't
+// refreshed yet, that aren't scheduled, or whose refresh failed, are due.
+func (s *RefreshSchedule) Due(name string, now time.Time) bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	refresh, ok := s.refreshes[name]
+	if !ok || refresh.failed || refresh.next.IsZero() {
+		return true
+	}
+	return !now.Add(RefreshBatchWindow).Before(refresh.next)
+}
+
+// Refreshed schedules the next refresh of a secret refreshed at now, after
+// interval, or on the default schedule when interval is zero
+func (s *RefreshSchedule) Refreshed(name string, interval time.Duration, now time.Time, failed bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.refreshes[name] = scheduledRefresh{
+		next:     s.nextRefresh(interval, now),
+		interval: interval,
+		failed:   failed,
+	}
+}
+
+// nextRefresh returns the next refresh after now of a secret with interval,
+// or the zero time when it isn't scheduled
+func (s *RefreshSchedule) nextRefresh(interval time.Duration, now time.Time) time.Time {
+	var next time.Time
+	switch {
+	case interval > 0:
+		next = now.Add(interval)
+	case s.defaultCron != nil:
+		next = s.defaultCron.Next(now)
+	case s.defaultInterval > 0:
+		next = now.Add(s.defaultInterval)
+	}
+	if next.IsZero() || s.jitter <= 0 {
+		return next
+	}
+
+	if !now.Equal(s.jitterAt) {
+		s.jitterAt = now
+		s.lastJitter = time.Duration(s.randomInt64(int64(s.jitter)))
+	}
+	return next.Add(s.lastJitter)
+}
+
+// FailedAll reschedules the secrets that were due at now, when refreshing
+// secrets failed before the due secrets were known
+func (s *RefreshSchedule) FailedAll(now time.Time) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for name, refresh := range s.refreshes {
+		if refresh.next.IsZero() || now.Add(RefreshBatchWindow).Before(refresh.next) {
+			continue
+		}
+		refresh.next = s.nextRefresh(refresh.interval, now)
+		refresh.failed = true
+		s.refreshes[name] = refresh
+	}
+}
+
+// Reset makes a secret due, such as when its configuration changed, and
+// unscheduled until it's refreshed
+func (s *RefreshSchedule) Reset(name string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	delete(s.refreshes, name)
+}
+
+// Retain forgets the secrets that aren't retained, such as secrets that were
+// removed
+func (s *RefreshSchedule) Retain(retain func(name string) bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	for name := range s.refreshes {
+		if !retain(name) {
+			delete(s.refreshes, name)
+		}
+	}
+}
+
+// Next returns the time from now until the next scheduled refresh, or false
+// when no refresh is scheduled
+func (s *RefreshSchedule) Next(now time.Time) (time.Duration, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	var next time.Time
+	for _, refresh := range s.refreshes {
+		if refresh.next.IsZero() {
+			continue
+		}
+		if next.IsZero() || refresh.next.Before(next) {
+			next = refresh.next
+		}
+	}
+	if next.IsZero() {
+		return 0, false
+	}
+	return max(next.Sub(now), 0), true
+}
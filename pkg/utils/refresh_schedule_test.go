@@ -0,0 +1,136 @@
+package utils
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRefreshSchedule(t *testing.T) {
+	start := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+	newSchedule := func() *RefreshSchedule {
+		schedule := NewRefreshSchedule()
+		schedule.SetDefaultInterval(time.Hour)
+		return schedule
+	}
+
+	t.Run("secrets are due on their own interval", func(t *testing.T) {
+		schedule := newSchedule()
+		assert.True(t, schedule.Due("db", start))
+		_, scheduled := schedule.Next(start)
+		assert.False(t, scheduled)
+
+		schedule.Refreshed("db", 30*time.Second, start, false)
+		schedule.Refreshed("api", 0, start, false)
+		next, scheduled := schedule.Next(start)
+		assert.True(t, scheduled)
+		assert.Equal(t, 30*time.Second, next)
+
+		assert.False(t, schedule.Due("db", start.Add(10*time.Second)))
+		assert.True(t, schedule.Due("db", start.Add(30*time.Second)))
+		assert.False(t, schedule.Due("api", start.Add(30*time.Second)))
+		assert.True(t, schedule.Due("api", start.Add(time.Hour)))
+	})
+
+	t.Run("secrets due shortly are batched", func(t *testing.T) {
+		schedule := newSchedule()
+		schedule.Refreshed("db", 30*time.Second, start, false)
+		assert.True(t, schedule.Due("db", start.Add(30*time.Second-RefreshBatchWindow)))
+	})
+
+	t.Run("failed secrets stay due", func(t *testing.T) {
+		schedule := newSchedule()
+		schedule.Refreshed("db", 30*time.Second, start, true)
+		assert.True(t, schedule.Due("db", start))
+		next, _ := schedule.Next(start)
+		assert.Equal(t, 30*time.Second, next)
+
+		schedule.Refreshed("db", 30*time.Second, start, false)
+		assert.False(t, schedule.Due("db", start))
+	})
+
+	t.Run("failing due secrets are rescheduled", func(t *testing.T) {
+		schedule := newSchedule()
+		schedule.Refreshed("db", 30*time.Second, start, false)
+		schedule.Refreshed("api", 0, start, false)
+
+		now := start.Add(30 * time.Second)
+		schedule.FailedAll(now)
+		next, _ := schedule.Next(now)
+		assert.Equal(t, 30*time.Second, next)
+		assert.True(t, schedule.Due("db", now))
+		schedule.Refreshed("db", 30*time.Second, now, false)
+		assert.False(t, schedule.Due("api", now))
+	})
+
+	t.Run("secrets without an interval aren't scheduled", func(t *testing.T) {
+		schedule := NewRefreshSchedule()
+		schedule.Refreshed("db", 0, start, false)
+		assert.True(t, schedule.Due("db", start))
+		_, scheduled := schedule.Next(start)
+		assert.False(t, scheduled)
+	})
+
+	t.Run("reset and removed secrets aren't scheduled", func(t *testing.T) {
+		schedule := newSchedule()
+		schedule.Refreshed("db", 30*time.Second, start, false)
+		schedule.Refreshed("api", 0, start, false)
+
+		schedule.Reset("db")
+		assert.True(t, schedule.Due("db", start))
+		next, _ := schedule.Next(start)
+		assert.Equal(t, time.Hour, next)
+
+		schedule.Retain(func(name string) bool { return name != "api" })
+		_, scheduled := schedule.Next(start)
+		assert.False(t, scheduled)
+	})
+
+	t.Run("secrets without an interval are refreshed on the default cron schedule", func(t *testing.T) {
+		cron, err := ParseCronSchedule("5 * * * *")
+		require.NoError(t, err)
+		schedule := newSchedule()
+		schedule.SetDefaultCron(cron)
+		schedule.Refreshed("db", 30*time.Second, start, false)
+		schedule.Refreshed("api", 0, start, false)
+
+		assert.False(t, schedule.Due("api", start.Add(4*time.Minute)))
+		assert.True(t, schedule.Due("api", start.Add(5*time.Minute)))
+		next, _ := schedule.Next(start)
+		assert.Equal(t, 30*time.Second, next)
+	})
+
+	t.Run("refreshes are delayed by the same jitter", func(t *testing.T) {
+		schedule := newSchedule()
+		schedule.SetJitter(time.Minute)
+		var draws []int64
+		schedule.randomInt64 = func(n int64) int64 {
+			draws = append(draws, n)
+			return int64(len(draws)) * int64(10*time.Second)
+		}
+
+		schedule.Refreshed("db", 0, start, false)
+		schedule.Refreshed("api", 0, start, false)
+		assert.Equal(t, []int64{int64(time.Minute)}, draws)
+		assert.False(t, schedule.Due("db", start.Add(time.Hour)))
+		assert.True(t, schedule.Due("db", start.Add(time.Hour+10*time.Second)))
+		assert.True(t, schedule.Due("api", start.Add(time.Hour+10*time.Second)))
+
+		// Secrets refreshed later are delayed by another jitter
+		later := start.Add(time.Minute)
+		schedule.Refreshed("db", 0, later, false)
+		next, _ := schedule.Next(later)
+		assert.Equal(t, time.Hour-time.Minute+10*time.Second, next)
+		assert.True(t, schedule.Due("db", later.Add(time.Hour+20*time.Second)))
+		assert.False(t, schedule.Due("db", later.Add(time.Hour+10*time.Second)))
+	})
+
+	t.Run("overdue refreshes are next", func(t *testing.T) {
+		schedule := newSchedule()
+		schedule.Refreshed("db", 30*time.Second, start, false)
+		next, _ := schedule.Next(start.Add(time.Minute))
+		assert.Equal(t, time.Duration(0), next)
+	})
+}
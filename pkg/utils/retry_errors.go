@@ -0,0 +1,83 @@
+package utils
+
+import "errors"
+
+// TransientError is an error that may not happen again when retrying, such
+// as a network error or an error of the Conjur server
+type TransientError struct {
+	Err error
+}
+
+func (e *TransientError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *TransientError) Unwrap() error {
+	return e.Err
+}
+
+// PermanentError is an error that happens again when retrying, such as
+// access to a secret being denied by a Conjur policy
+type PermanentError struct {
+	Err error
+}
+
+func (e *PermanentError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *PermanentError) Unwrap() error {
+	return e.Err
+}
+
+// Transient classifies err as transient
+func Transient(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &TransientError{Err: err}
+}
+
+// Permanent classifies err as permanent
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &PermanentError{Err: err}
+}
+
+// IsPermanent reports whether err is classified as permanent. Errors that
+// wrap several errors, such as joined errors, are permanent when all of the
+// errors they wrap are. Errors that aren't classified are transient.
+func IsPermanent(err error) bool {
+	switch e := err.(type) {
+	case nil:
+		return false
+	case *PermanentError:
+		return true
+	case *TransientError:
+		return false
+	case interface{ Unwrap() []error }:
+		errs := e.Unwrap()
+		for _, err := range errs {
+			if !IsPermanent(err) {
+				return false
+			}
+		}
+		return len(errs) > 0
+	}
+	return IsPermanent(errors.Unwrap(err))
+}
+
+// ClassifyAs classifies err like cause, such as when cause is replaced by an
+// error message of its own. Both whether it's permanent and the class of the
+// failure it caused are kept.
+func ClassifyAs(err error, cause error) error {
+	if class := FailureClassOf(cause); class != FailureUnknown {
+		err = Classify(class, err)
+	}
+	if IsPermanent(cause) {
+		return Permanent(err)
+	}
+	return err
+}
@@ -0,0 +1,38 @@
+package utils
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsPermanent(t *testing.T) {
+	permanent := Permanent(errors.New("403 Forbidden"))
+	transient := Transient(errors.New("503 Service Unavailable"))
+
+	testCases := []struct {
+		description string
+		err         error
+		expected    bool
+	}{
+		{"nil", nil, false},
+		{"unclassified", errors.New("failed"), false},
+		{"permanent", permanent, true},
+		{"transient", transient, false},
+		{"wrapped permanent", fmt.Errorf("retrieving: %w", permanent), true},
+		{"transient wrapping permanent", Transient(permanent), false},
+		{"all joined errors permanent", errors.Join(permanent, Permanent(errors.New("404 Not Found"))), true},
+		{"some joined errors transient", errors.Join(permanent, transient), false},
+		{"classified as permanent cause", ClassifyAs(errors.New("CSPFK034E"), permanent), true},
+		{"classified as transient cause", ClassifyAs(errors.New("CSPFK034E"), transient), false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.description, func(t *testing.T) {
+			assert.Equal(t, tc.expected, IsPermanent(tc.err))
+		})
+	}
+	assert.Equal(t, "403 Forbidden", permanent.Error())
+}